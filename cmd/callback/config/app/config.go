@@ -16,6 +16,7 @@ import (
 	"github.com/anzx/fabric-cards/pkg/middleware/logging"
 
 	"github.com/anzx/fabric-cards/pkg/integration/ctm"
+	"github.com/anzx/pkg/auditlog"
 	flag "github.com/spf13/pflag"
 )
 
@@ -30,6 +31,7 @@ type Spec struct {
 	Forgerock      *forgerock.Config      `json:"forgerock"                    yaml:"forgerock"                    mapstructure:"forgerock"`
 	Fakerock       *fakerock.Config       `json:"fakerock"                     yaml:"fakerock"                     mapstructure:"fakerock"`
 	Certificates   *certvalidator.Config  `json:"certificates"                 yaml:"certificates"                 mapstructure:"certificates"`
+	AuditLog       *auditlog.Config       `json:"auditlog,omitempty"           yaml:"auditlog,omitempty"           mapstructure:"auditlog"`
 }
 
 const (
@@ -59,7 +59,7 @@ func TestLoadAndValidateConfig(t *testing.T) {
 			})
 			os.Args = args
 		}
-		want := "spec:\n  appName: Callback\n  port: 8060\n  log:\n    level: debug\n    payloadDecider:\n      server:\n        /visa.service.enrollmentcallback.v1.enrollmentcallbackapi/disenroll: true\n        /visa.service.enrollmentcallback.v1.enrollmentcallbackapi/enroll: true\n        /visa.service.notificationcallback.v1.notificationcallbackapi/alert: true\n      client: {}\n  ctm:\n    baseURL: http://localhost:9070/ctm\n    clientIDEnvKey: apic-corp-client-id-np\n    maxRetries: 3\n  vault:\n    vaultAddress: http://localhost:9070/vault\n    authRole: gcpiamrole-fabric-encdec.common\n    localToken: \"\"\n    authPath: v1/auth/gcp-fabric\n    namespace: eaas-test\n    zone: corp\n    metadataAddress: \"\"\n    overrideServiceEmail: fabric@anz.com\n    noGoogleCredentialsClient: true\n    tokenLifetime: 5m0s\n    tokenRenewBuffer: 2m0s\n    blockForTokenTime: 0s\n    tokenErrorRetryTime: 0s\n    tokenErrorRetryMaxTime: 5m0s\n  commandCentre:\n    pubsubEmulatorHost: localhost:8185\n    env: local\n  featureToggles:\n    rpc:\n      /visa.service.enrollmentcallback.v1.enrollmentcallbackapi/disenroll: true\n      /visa.service.enrollmentcallback.v1.enrollmentcallbackapi/enroll: true\n      /visa.service.notificationcallback.v1.notificationcallbackapi/alert: true\n    features:\n      ENROLLMENT_CALLBACK_INTEGRATED: true\n      FORGEROCK_SYSTEM_LOGIN: true\n      NOTIFICATION_CALLBACK_DECLINED_EVENT: true\n  forgerock:\n    baseURL: http://localhost:9070/forgerock/\n    clientID: fabric-visa-callback\n    clientSecretKey: callback-forgerock-secret-np\n  fakerock: null\n  certificates: null\nops:\n  port: 8062\n  opentelemetry:\n    trace:\n      exporter: jaeger\n      type: \"\"\n      sampleProbability: 0\n    metrics:\n      exporter: prometheus\n      pushPeriod: 0s\n    exporters:\n      jaeger:\n        collectorEndpoint: http://localhost:14268/api/traces\n"
+		want := "spec:\n  appName: Callback\n  port: 8060\n  log:\n    level: debug\n    payloadDecider:\n      server:\n        /visa.service.enrollmentcallback.v1.enrollmentcallbackapi/disenroll: true\n        /visa.service.enrollmentcallback.v1.enrollmentcallbackapi/enroll: true\n        /visa.service.notificationcallback.v1.notificationcallbackapi/alert: true\n      client: {}\n  ctm:\n    baseURL: http://localhost:9070/ctm\n    clientIDEnvKey: apic-corp-client-id-np\n    maxRetries: 3\n  vault:\n    vaultAddress: http://localhost:9070/vault\n    authRole: gcpiamrole-fabric-encdec.common\n    localToken: \"\"\n    authPath: v1/auth/gcp-fabric\n    namespace: eaas-test\n    zone: corp\n    metadataAddress: \"\"\n    overrideServiceEmail: fabric@anz.com\n    noGoogleCredentialsClient: true\n    tokenLifetime: 5m0s\n    tokenRenewBuffer: 2m0s\n    blockForTokenTime: 0s\n    tokenErrorRetryTime: 0s\n    tokenErrorRetryMaxTime: 5m0s\n  commandCentre:\n    pubsubEmulatorHost: localhost:8185\n    env: local\n  featureToggles:\n    rpc:\n      /visa.service.enrollmentcallback.v1.enrollmentcallbackapi/disenroll: true\n      /visa.service.enrollmentcallback.v1.enrollmentcallbackapi/enroll: true\n      /visa.service.notificationcallback.v1.notificationcallbackapi/alert: true\n    features:\n      ENROLLMENT_CALLBACK_INTEGRATED: true\n      FORGEROCK_SYSTEM_LOGIN: true\n      NOTIFICATION_CALLBACK_DECLINED_EVENT: true\n  forgerock:\n    baseURL: http://localhost:9070/forgerock/\n    clientID: fabric-visa-callback\n    clientSecretKey: callback-forgerock-secret-np\n  fakerock: null\n  certificates: null\n  auditlog:\n    name: fabric-cards\n    domain: fabric.gcp.anz\n    provider: fabric\n    pubsub:\n      projectID: auditlog\n      topicID: auditlog\n      emulatorHost: localhost:8086\nops:\n  port: 8062\n  opentelemetry:\n    trace:\n      exporter: jaeger\n      type: \"\"\n      sampleProbability: 0\n    metrics:\n      exporter: prometheus\n      pushPeriod: 0s\n    exporters:\n      jaeger:\n        collectorEndpoint: http://localhost:14268/api/traces\n"
 
 		got, err := Load()
 		require.NoError(t, err)
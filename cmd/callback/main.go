@@ -23,6 +23,7 @@ import (
 	"github.com/anzx/fabric-cards/cmd/callback/config"
 	"github.com/anzx/fabric-cards/cmd/callback/startup"
 	"github.com/anzx/fabric-cards/pkg/feature"
+	"github.com/anzx/fabric-cards/pkg/feature/admin"
 
 	"github.com/anzx/fabric-cards/pkg/middleware/grpclogging"
 
@@ -64,6 +65,24 @@ func main() {
 		fatalError(ctx, err, "failed to initialise feature gates")
 	}
 
+	// Set persona rollouts for features disabled by default
+	if err = feature.RPCGate.SetRollouts(cfg.AppSpec.FeatureToggles.RPCRollouts); err != nil {
+		fatalError(ctx, err, "failed to initialise feature gates")
+	}
+
+	if err = feature.FeatureGate.SetRollouts(cfg.AppSpec.FeatureToggles.FeatureRollouts); err != nil {
+		fatalError(ctx, err, "failed to initialise feature gates")
+	}
+
+	// Set time windows for scheduled launches and maintenance
+	if err = feature.RPCGate.SetWindows(cfg.AppSpec.FeatureToggles.RPCWindows); err != nil {
+		fatalError(ctx, err, "failed to initialise feature gates")
+	}
+
+	if err = feature.FeatureGate.SetWindows(cfg.AppSpec.FeatureToggles.FeatureWindows); err != nil {
+		fatalError(ctx, err, "failed to initialise feature gates")
+	}
+
 	logf.Info(ctx, "startup: creating GSM client")
 	gsmClient, err := gsm.NewClient(ctx)
 	if err != nil {
@@ -83,6 +102,12 @@ func main() {
 		fatalError(ctx, err, "failed to start opentelemetry")
 	}
 
+	logf.Info(ctx, "startup: creating feature admin")
+	featureAdmin, err := admin.NewAdmin(ctx, cfg.OpsSpec.Admin, gsmClient, adapters.AuditLog)
+	if err != nil {
+		fatalError(ctx, err, "failed to create feature admin")
+	}
+
 	logf.Info(ctx, "startup: creating servers")
 	enrollmentCallbackService := enrollmentcallback.NewServer(adapters.CTM, adapters.Vault, adapters.Fakerock, adapters.Forgerock)
 	notificationCallbackService := notificationcallback.NewServer(adapters.CommandCentre)
@@ -103,7 +128,8 @@ func main() {
 	g, gCtx := errgroup.WithContext(ctx)
 
 	g.Go(startup.RunAPIServer(gCtx, cfg.AppSpec, serverPayloadDecider, grpcRegistrations, restRegistrations))
-	g.Go(servers.RunOperationsServer(gCtx, cfg.AppSpec.AppName, cfg.OpsSpec.Port))
+	g.Go(servers.RunOperationsServer(gCtx, cfg.AppSpec.AppName, cfg.OpsSpec.Port, featureAdmin.Register))
+	g.Go(feature.Watch(gCtx, cfg.AppSpec.FeatureToggles.Watch, gsmClient))
 	g.Go(servers.SignalListener(gCtx))
 
 	logf.Info(ctx, "Callback Service terminated with error: %v", g.Wait())
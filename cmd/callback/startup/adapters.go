@@ -9,6 +9,7 @@ import (
 	"github.com/anzx/fabric-cards/pkg/integration/fakerock"
 	"github.com/anzx/fabric-cards/pkg/integration/forgerock"
 
+	"github.com/anzx/fabric-cards/pkg/integration/auditlogger"
 	"github.com/anzx/fabric-cards/pkg/integration/commandcentre"
 	"github.com/anzx/fabric-cards/pkg/integration/vault"
 	anzerrors "github.com/anzx/pkg/errors"
@@ -24,6 +25,7 @@ type Adapters struct {
 	Vault         vault.Client
 	Forgerock     forgerock.Clienter
 	Fakerock      *fakerock.Client
+	AuditLog      *auditlogger.Client
 }
 
 func NewAdapters(ctx context.Context, config app.Spec, gsmClient *gsm.Client) (*Adapters, error) {
@@ -58,6 +60,12 @@ func NewAdapters(ctx context.Context, config app.Spec, gsmClient *gsm.Client) (*
 	}
 	adapters.Fakerock = fakerockClient
 
+	auditLogClient, err := auditlogger.NewClient(ctx, config.AuditLog)
+	if err != nil {
+		return nil, anzErr(err, fmt.Sprintf("could not configure auditlog Client with config %+v", config.AuditLog))
+	}
+	adapters.AuditLog = auditLogClient
+
 	return &adapters, nil
 }
 
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/anzx/fabric-cards/pkg/integration/commandcentre"
+	"github.com/anzx/pkg/auditlog"
 	"github.com/anzx/pkg/gsm"
 	"github.com/googleapis/gax-go/v2"
 	secretmanagerpb "google.golang.org/genproto/googleapis/cloud/secretmanager/v1"
@@ -96,6 +97,11 @@ func TestNewAdapters(t *testing.T) {
 				name:    "ForgerockClientIDEnvKey",
 				payload: "returned secret",
 			},
+		}, {
+			name: "create auditlog adapter",
+			config: app.Spec{
+				AuditLog: &auditlog.Config{},
+			},
 		},
 	}
 	for _, test := range tests {
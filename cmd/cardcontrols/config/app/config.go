@@ -13,7 +13,10 @@ import (
 
 	"github.com/anzx/fabric-cards/pkg/integration/ocv"
 
+	"github.com/anzx/fabric-cards/pkg/expiry"
 	"github.com/anzx/fabric-cards/pkg/feature"
+	"github.com/anzx/fabric-cards/pkg/idempotency"
+	"github.com/anzx/fabric-cards/pkg/presets"
 
 	"github.com/anzx/fabric-cards/pkg/middleware/logging"
 
@@ -44,6 +47,9 @@ type Spec struct {
 	OCV            *ocv.Config            `json:"ocv,omitempty"                 yaml:"ocv,omitempty"               mapstructure:"ocv"`
 	Forgerock      *forgerock.Config      `json:"forgerock"                     yaml:"forgerock"                   mapstructure:"forgerock"`
 	Fakerock       *fakerock.Config       `json:"fakerock"                      yaml:"fakerock"                    mapstructure:"fakerock"`
+	Idempotency    *idempotency.Config    `json:"idempotency,omitempty"         yaml:"idempotency,omitempty"       mapstructure:"idempotency"`
+	Expiries       *expiry.Config         `json:"expiries,omitempty"            yaml:"expiries,omitempty"          mapstructure:"expiries"`
+	Presets        []presets.Config       `json:"presets,omitempty"             yaml:"presets,omitempty"           mapstructure:"presets"`
 }
 
 const (
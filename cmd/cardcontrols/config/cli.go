@@ -9,6 +9,7 @@ import (
 	"github.com/anzx/fabric-cards/pkg/ops"
 
 	"github.com/anzx/fabric-cards/cmd/cardcontrols/config/app"
+	"github.com/anzx/fabric-cards/pkg/feature"
 	"github.com/anzx/pkg/jsontime"
 	"github.com/mitchellh/mapstructure"
 	flag "github.com/spf13/pflag"
@@ -71,7 +72,10 @@ func create(v *viper.Viper, f *flag.FlagSet) (*Config, error) {
 	// because it's likely config file not found and the default config will be used instead
 	_ = v.ReadInConfig()
 
-	err := v.Unmarshal(&config, viper.DecodeHook(jsontime.DurationMapstructureDecodeHookFunc))
+	err := v.Unmarshal(&config, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		jsontime.DurationMapstructureDecodeHookFunc,
+		feature.TimeDecodeHook(),
+	)))
 	if err != nil {
 		log.Println(fmt.Errorf("error while unmarshalling configuration file: %w", err))
 
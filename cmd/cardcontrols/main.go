@@ -14,6 +14,7 @@ import (
 	"google.golang.org/grpc"
 
 	"github.com/anzx/fabric-cards/pkg/feature"
+	"github.com/anzx/fabric-cards/pkg/feature/admin"
 
 	"github.com/anzx/fabric-cards/cmd/cardcontrols/startup"
 	"github.com/anzx/fabric-cards/pkg/middleware/grpclogging"
@@ -61,6 +62,24 @@ func main() {
 		fatalError(ctx, err, "failed to initialise feature gates")
 	}
 
+	// Set persona rollouts for features disabled by default
+	if err = feature.RPCGate.SetRollouts(cfg.AppSpec.FeatureToggles.RPCRollouts); err != nil {
+		fatalError(ctx, err, "failed to initialise feature gates")
+	}
+
+	if err = feature.FeatureGate.SetRollouts(cfg.AppSpec.FeatureToggles.FeatureRollouts); err != nil {
+		fatalError(ctx, err, "failed to initialise feature gates")
+	}
+
+	// Set time windows for scheduled launches and maintenance
+	if err = feature.RPCGate.SetWindows(cfg.AppSpec.FeatureToggles.RPCWindows); err != nil {
+		fatalError(ctx, err, "failed to initialise feature gates")
+	}
+
+	if err = feature.FeatureGate.SetWindows(cfg.AppSpec.FeatureToggles.FeatureWindows); err != nil {
+		fatalError(ctx, err, "failed to initialise feature gates")
+	}
+
 	// Create JWT Auth Authenticator
 	authenticator, err := jwtauth.AuthFromConfig(ctx, &cfg.AppSpec.Auth, jwtauth.DefaultHTTPClientFunc)
 	if err != nil {
@@ -86,6 +105,12 @@ func main() {
 		fatalError(ctx, err, "failed to create adapters")
 	}
 
+	logf.Info(ctx, "startup: creating feature admin")
+	featureAdmin, err := admin.NewAdmin(ctx, cfg.OpsSpec.Admin, gsmClient, adapters.V1beta2.AuditLog)
+	if err != nil {
+		fatalError(ctx, err, "failed to create feature admin")
+	}
+
 	logf.Info(ctx, "startup: creating servers")
 	cardControlsV1Beta1API := v1beta1.NewServer(adapters.V1beta1.Fabric, adapters.V1beta1.Internal, adapters.V1beta1.External)
 	cardControlsV1Beta2API := v1beta2.NewServer(adapters.V1beta2.Fabric, adapters.V1beta2.Internal, adapters.V1beta2.External)
@@ -105,8 +130,10 @@ func main() {
 	// Run servers and signal listener
 	g, gCtx := errgroup.WithContext(ctx)
 
-	g.Go(startup.RunAPIServer(gCtx, cfg.AppSpec, serverPayloadDecider, registrations, restRegistrations, authenticator))
-	g.Go(servers.RunOperationsServer(gCtx, cfg.AppSpec.AppName, cfg.OpsSpec.Port))
+	g.Go(startup.RunAPIServer(gCtx, cfg.AppSpec, serverPayloadDecider, registrations, restRegistrations, authenticator, adapters.Idempotency))
+	g.Go(servers.RunOperationsServer(gCtx, cfg.AppSpec.AppName, cfg.OpsSpec.Port, featureAdmin.Register))
+	g.Go(feature.Watch(gCtx, cfg.AppSpec.FeatureToggles.Watch, gsmClient))
+	g.Go(adapters.V1beta2.Expiries.Run(gCtx))
 	g.Go(servers.SignalListener(gCtx))
 
 	logf.Info(ctx, "Card Features Service terminated with error: %v", g.Wait())
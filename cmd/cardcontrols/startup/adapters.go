@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/anzx/pkg/gsm"
+	"github.com/go-redis/redis/v8"
 
 	"google.golang.org/grpc/credentials/insecure"
 
@@ -12,6 +13,10 @@ import (
 
 	"github.com/anzx/fabric-cards/pkg/integration/forgerock"
 
+	"github.com/anzx/fabric-cards/pkg/expiry"
+	"github.com/anzx/fabric-cards/pkg/idempotency"
+	"github.com/anzx/fabric-cards/pkg/presets"
+
 	"github.com/anzx/fabric-cards/pkg/util/jwtutil"
 	"google.golang.org/grpc"
 
@@ -42,8 +47,9 @@ import (
 )
 
 type Adapters struct {
-	V1beta1 Beta1
-	V1beta2 Beta2
+	V1beta1     Beta1
+	V1beta2     Beta2
+	Idempotency *idempotency.Client
 }
 
 type Beta1 struct {
@@ -91,9 +97,6 @@ func NewAdapters(ctx context.Context, config app.Spec, gsmClient *gsm.Client) (*
 		return nil, anzErr(err, fmt.Sprintf("could not configure CTM Client with config %+v", config.CTM))
 	}
 
-	adapters.V1beta1.CTM = ctmClient
-	adapters.V1beta2.CTM = ctmClient
-
 	visaClient, err := visa.ClientFromConfig(ctx, nil, config.Visa, gsmClient)
 	if err != nil {
 		return nil, anzErr(err, fmt.Sprintf("could not configure Visa Client with config %+v", config.Visa))
@@ -134,6 +137,44 @@ func NewAdapters(ctx context.Context, config app.Spec, gsmClient *gsm.Client) (*
 	adapters.V1beta1.OCV = ocvClient
 	adapters.V1beta2.OCV = ocvClient
 
+	idempotencyClient, err := idempotency.NewClient(ctx, config.Idempotency, nil, gsmClient)
+	if err != nil {
+		return nil, anzErr(err, fmt.Sprintf("could not configure Idempotency Client with config %+v", config.Idempotency))
+	}
+	adapters.Idempotency = idempotencyClient
+
+	// expiries are kept in the idempotency Redis unless they have their own, the controls each preset added are always
+	// kept there
+	var idempotencyRedis redis.Cmdable
+	var idempotencyPrefix string
+	if idempotencyClient != nil {
+		idempotencyRedis = idempotencyClient.Client
+		idempotencyPrefix = idempotencyClient.Prefix
+	}
+
+	// the CTM cache is shared with cards, so card status changes invalidate the inquiries it cached
+	if config.CTM != nil {
+		ctmCache, err := ctm.NewCache(ctx, config.CTM.Cache, gsmClient)
+		if err != nil {
+			return nil, anzErr(err, fmt.Sprintf("could not configure CTM Cache with config %+v", config.CTM.Cache))
+		}
+		ctmClient = ctm.NewCachingClient(ctx, ctmClient, ctmCache, config.CTM.Cache)
+	}
+	adapters.V1beta1.CTM = ctmClient
+	adapters.V1beta2.CTM = ctmClient
+
+	expiries, err := expiry.NewScheduler(ctx, config.Expiries, idempotencyRedis, gsmClient)
+	if err != nil {
+		return nil, anzErr(err, fmt.Sprintf("could not configure Expiry Scheduler with config %+v", config.Expiries))
+	}
+	adapters.V1beta2.Expiries = expiries
+
+	controlPresets, err := presets.New(ctx, config.Presets, idempotencyRedis, idempotencyPrefix)
+	if err != nil {
+		return nil, anzErr(err, fmt.Sprintf("could not configure Presets with config %+v", config.Presets))
+	}
+	adapters.V1beta2.Presets = controlPresets
+
 	return &adapters, nil
 }
 
@@ -14,6 +14,7 @@ import (
 
 	"github.com/anzx/fabric-cards/cmd/cardcontrols/config/app"
 	"github.com/anzx/fabric-cards/pkg/feature"
+	"github.com/anzx/fabric-cards/pkg/idempotency"
 	"github.com/anzx/fabric-cards/pkg/middleware/grpclogging"
 	"github.com/anzx/fabric-cards/pkg/middleware/requestid"
 	"github.com/anzx/fabric-cards/pkg/servers"
@@ -23,7 +24,7 @@ import (
 	"google.golang.org/grpc"
 )
 
-func RunAPIServer(ctx context.Context, cfg app.Spec, serverPayloadDecider grpclogging.ServerPayloadLoggingDecider, registrations []servers.GRPCRegistration, restRegistrations []servers.RestRegistration, auth jwtauth.Authenticator) func() error {
+func RunAPIServer(ctx context.Context, cfg app.Spec, serverPayloadDecider grpclogging.ServerPayloadLoggingDecider, registrations []servers.GRPCRegistration, restRegistrations []servers.RestRegistration, auth jwtauth.Authenticator, idempotencyClient *idempotency.Client) func() error {
 	return func() error {
 		interceptors := []grpc.UnaryServerInterceptor{
 			extractor.MonitorGRPCServerUnaryInterceptor(),
@@ -32,8 +33,9 @@ func RunAPIServer(ctx context.Context, cfg app.Spec, serverPayloadDecider grpclo
 			grpcValidator.UnaryServerInterceptor(),
 			anzerrors.UnaryServerInterceptor(),
 			errors.UnaryServerErrorLogInterceptor(),
-			feature.APIFeatureGate(),
 			jwtgrpc.UnaryServerInterceptor(auth),
+			feature.APIFeatureGate(),
+			idempotency.UnaryServerInterceptor(idempotencyClient),
 			auditlog.UnaryServerInterceptor(cfg.AuditLog, os.Getenv("POD_ID")),
 		}
 
@@ -54,7 +54,7 @@ func TestRunAPIServer(t *testing.T) {
 			},
 		}
 
-		assert.Error(t, RunAPIServer(ctx, cfg, decider, registrations, nil, nil)())
+		assert.Error(t, RunAPIServer(ctx, cfg, decider, registrations, nil, nil, nil)())
 	})
 }
 
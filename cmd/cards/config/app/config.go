@@ -19,12 +19,14 @@ import (
 	"github.com/anzx/fabric-cards/pkg/integration/ocv"
 
 	"github.com/anzx/fabric-cards/pkg/feature"
+	"github.com/anzx/fabric-cards/pkg/idempotency"
 
 	"github.com/anzx/fabric-cards/pkg/middleware/logging"
 
 	"github.com/anzx/fabric-cards/pkg/integration/selfservice"
 
 	"github.com/anzx/fabric-cards/pkg/ratelimit"
+	"github.com/anzx/fabric-cards/pkg/saga"
 
 	"github.com/anzx/fabric-cards/pkg/integration/echidna"
 
@@ -46,6 +48,7 @@ type Spec struct {
 	Auth           jwtauth.Config         `json:"auth"                         yaml:"auth"                         mapstructure:"auth"              validate:"required_without=Insecure"` //nolint:lll
 	CTM            *ctm.Config            `json:"ctm,omitempty"                yaml:"ctm,omitempty"                mapstructure:"ctm"`
 	CommandCentre  *commandcentre.Config  `json:"commandCentre,omitempty"      yaml:"commandCentre,omitempty"      mapstructure:"commandCentre"`
+	DailyLimits    *ctm.LimitsConfig      `json:"dailyLimits,omitempty"        yaml:"dailyLimits,omitempty"        mapstructure:"dailyLimits"`
 	Echidna        *echidna.Config        `json:"echidna,omitempty"            yaml:"echidna,omitempty"            mapstructure:"echidna"`
 	RateLimit      *ratelimit.Config      `json:"rateLimit,omitempty"          yaml:"rateLimit,omitempty"          mapstructure:"rateLimit"`
 	SelfService    *selfservice.Config    `json:"selfService,omitempty"        yaml:"selfService,omitempty"        mapstructure:"selfService"`
@@ -58,6 +61,8 @@ type Spec struct {
 	APCAM          *apcam.Config          `json:"apcam,omitempty"              yaml:"apcam,omitempty"              mapstructure:"apcam"`
 	Forgerock      *forgerock.Config      `json:"forgerock,omitempty"          yaml:"forgerock,omitempty"          mapstructure:"forgerock"`
 	GPay           *gpay.Config           `json:"gpay,omitempty"               yaml:"gpay,omitempty"               mapstructure:"gpay"`
+	Sagas          *saga.Config           `json:"sagas,omitempty"              yaml:"sagas,omitempty"              mapstructure:"sagas"`
+	Idempotency    *idempotency.Config    `json:"idempotency,omitempty"        yaml:"idempotency,omitempty"        mapstructure:"idempotency"`
 }
 
 const (
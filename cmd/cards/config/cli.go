@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	"github.com/anzx/fabric-cards/cmd/cards/config/app"
+	"github.com/anzx/fabric-cards/pkg/feature"
 	"github.com/anzx/fabric-cards/pkg/ops"
 	"github.com/anzx/pkg/jsontime"
 	"github.com/anzx/pkg/validator"
@@ -68,7 +69,10 @@ func create(v *viper.Viper, f *flag.FlagSet) (*Config, error) {
 	// because it's likely config file not found and the default config will be used instead
 	_ = v.ReadInConfig()
 
-	err := v.Unmarshal(&config, viper.DecodeHook(jsontime.DurationMapstructureDecodeHookFunc))
+	err := v.Unmarshal(&config, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		jsontime.DurationMapstructureDecodeHookFunc,
+		feature.TimeDecodeHook(),
+	)))
 	if err != nil {
 		log.Println(fmt.Errorf("error while unmarshalling configuration file: %w", err))
 
@@ -15,7 +15,9 @@ import (
 	"github.com/anzx/fabric-cards/internal/service/cards"
 	"github.com/anzx/fabric-cards/internal/service/eligibility"
 	"github.com/anzx/fabric-cards/pkg/feature"
+	"github.com/anzx/fabric-cards/pkg/feature/admin"
 	"github.com/anzx/fabric-cards/pkg/middleware/grpclogging"
+	"github.com/anzx/fabric-cards/pkg/saga"
 	"github.com/anzx/fabric-cards/pkg/servers"
 	"github.com/anzx/pkg/gsm"
 	"github.com/anzx/pkg/jwtauth"
@@ -55,6 +57,24 @@ func main() {
 		fatalError(ctx, err, "Failed to initialise feature gates")
 	}
 
+	// Set persona rollouts for features disabled by default
+	if err = feature.RPCGate.SetRollouts(cfg.AppSpec.FeatureToggles.RPCRollouts); err != nil {
+		fatalError(ctx, err, "Failed to initialise feature gates")
+	}
+
+	if err = feature.FeatureGate.SetRollouts(cfg.AppSpec.FeatureToggles.FeatureRollouts); err != nil {
+		fatalError(ctx, err, "Failed to initialise feature gates")
+	}
+
+	// Set time windows for scheduled launches and maintenance
+	if err = feature.RPCGate.SetWindows(cfg.AppSpec.FeatureToggles.RPCWindows); err != nil {
+		fatalError(ctx, err, "Failed to initialise feature gates")
+	}
+
+	if err = feature.FeatureGate.SetWindows(cfg.AppSpec.FeatureToggles.FeatureWindows); err != nil {
+		fatalError(ctx, err, "Failed to initialise feature gates")
+	}
+
 	// Create JWT Auth Authenticator
 	authenticator, err := jwtauth.AuthFromConfig(ctx, &cfg.AppSpec.Auth, jwtauth.DefaultHTTPClientFunc)
 	if err != nil {
@@ -80,6 +100,12 @@ func main() {
 		fatalError(ctx, err, "failed to create adapters")
 	}
 
+	logf.Info(ctx, "startup: creating feature admin")
+	featureAdmin, err := admin.NewAdmin(ctx, cfg.OpsSpec.Admin, gsmClient, adapters.AuditLog)
+	if err != nil {
+		fatalError(ctx, err, "failed to create feature admin")
+	}
+
 	logf.Info(ctx, "startup: creating servers")
 	cardControlsAPI := cards.NewServer(adapters.Fabric, adapters.Internal, adapters.External)
 	eligibilityAPI := eligibility.NewServer(adapters.Entitlements, adapters.CTM, adapters.Vault)
@@ -89,9 +115,16 @@ func main() {
 	// Run servers and signal listener
 	g, gCtx := errgroup.WithContext(ctx)
 
-	g.Go(startup.RunAPIServer(gCtx, cfg.AppSpec, serverPayloadDecider, authenticator,
+	g.Go(startup.RunAPIServer(gCtx, cfg.AppSpec, serverPayloadDecider, authenticator, adapters.RateLimit, adapters.Idempotency,
 		cardControlsAPI, eligibilityAPI, walletAPI))
-	g.Go(servers.RunOperationsServer(gCtx, cfg.AppSpec.AppName, cfg.OpsSpec.Port))
+	// sagas are served with the feature admin token, they can't be listed or re-driven unless ops.admin is configured
+	if featureAdmin == nil && adapters.Sagas != nil {
+		logf.Info(ctx, "startup: feature admin not configured, %s is not served", saga.AdminPath)
+	}
+	featureAdmin.Handle(saga.AdminPath, saga.Admin(adapters.Sagas))
+	g.Go(servers.RunOperationsServer(gCtx, cfg.AppSpec.AppName, cfg.OpsSpec.Port, featureAdmin.Register))
+	g.Go(feature.Watch(gCtx, cfg.AppSpec.FeatureToggles.Watch, gsmClient))
+	g.Go(adapters.Sagas.Resume(gCtx))
 	g.Go(servers.SignalListener(gCtx))
 
 	logf.Info(ctx, "Cards Service terminated with error: %v", g.Wait())
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/anzx/fabric-cards/pkg/idempotency"
 	"github.com/anzx/fabric-cards/pkg/integration/forgerock"
 	"github.com/anzx/fabric-cards/pkg/integration/gpay"
 	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/anzx/fabric-cards/pkg/sanitize"
 
+	"github.com/go-redis/redis/v8"
 	"google.golang.org/grpc/credentials/insecure"
 
 	"github.com/anzx/fabric-cards/pkg/integration/cardcontrols"
@@ -39,14 +41,16 @@ import (
 	"github.com/anzx/fabric-cards/pkg/integration/vault"
 	"github.com/anzx/fabric-cards/pkg/middleware/grpclogging"
 	"github.com/anzx/fabric-cards/pkg/ratelimit"
+	"github.com/anzx/fabric-cards/pkg/saga"
 )
 
 type Adapters struct {
 	cards.Fabric
 	cards.Internal
 	cards.External
-	APCAM apcam.Client
-	GPay  gpay.Client
+	APCAM       apcam.Client
+	GPay        gpay.Client
+	Idempotency *idempotency.Client
 }
 
 func NewAdapters(ctx context.Context, config app.Spec, gsmClient *gsm.Client) (*Adapters, error) {
@@ -100,14 +104,37 @@ func NewAdapters(ctx context.Context, config app.Spec, gsmClient *gsm.Client) (*
 		return nil, anzErr(err, "could not configure Rate Limit client")
 	}
 	adapters.RateLimit = rateLimitClient
+	adapters.DailyLimits = config.DailyLimits
 
 	// External Adapters
 	ctmClient, err := ctm.ClientFromConfig(ctx, nil, config.CTM, gsmClient)
 	if err != nil {
 		return nil, anzErr(err, fmt.Sprintf("could not configure CTM Client with config %+v", config.CTM))
 	}
+
+	// the CTM cache is shared with cardcontrols, so card changes in either service invalidate it
+	if config.CTM != nil {
+		ctmCache, err := ctm.NewCache(ctx, config.CTM.Cache, gsmClient)
+		if err != nil {
+			return nil, anzErr(err, fmt.Sprintf("could not configure CTM Cache with config %+v", config.CTM.Cache))
+		}
+		ctmClient = ctm.NewCachingClient(ctx, ctmClient, ctmCache, config.CTM.Cache)
+	}
 	adapters.CTM = ctmClient
 
+	// sagas and idempotency keys are kept in the rate limit Redis unless they have their own
+	var rateLimitRedis redis.Cmdable
+	if redisRateLimit, ok := rateLimitClient.(*ratelimit.RedisRateLimit); ok {
+		rateLimitRedis = redisRateLimit.Client
+	}
+	adapters.Sagas = saga.NewRunner(ctx, config.Sagas, rateLimitRedis)
+
+	idempotencyClient, err := idempotency.NewClient(ctx, config.Idempotency, rateLimitRedis, gsmClient)
+	if err != nil {
+		return nil, anzErr(err, fmt.Sprintf("could not configure Idempotency Client with config %+v", config.Idempotency))
+	}
+	adapters.Idempotency = idempotencyClient
+
 	echidnaClient, err := echidna.ClientFromConfig(ctx, nil, config.Echidna, gsmClient)
 	if err != nil {
 		return nil, anzErr(err, fmt.Sprintf("could not configure Echidna Client with config %+v", config.Echidna))
@@ -10,8 +10,10 @@ import (
 
 	"github.com/anzx/fabric-cards/cmd/cards/config/app"
 	"github.com/anzx/fabric-cards/pkg/feature"
+	"github.com/anzx/fabric-cards/pkg/idempotency"
 	"github.com/anzx/fabric-cards/pkg/middleware/grpclogging"
 	"github.com/anzx/fabric-cards/pkg/middleware/requestid"
+	"github.com/anzx/fabric-cards/pkg/ratelimit"
 	"github.com/anzx/fabric-cards/pkg/servers"
 	cpb "github.com/anzx/fabricapis/pkg/fabric/service/card/v1beta1"
 	epb "github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1"
@@ -25,7 +27,8 @@ import (
 )
 
 func RunAPIServer(ctx context.Context, cfg app.Spec,
-	serverPayloadDecider grpclogging.ServerPayloadLoggingDecider, authenticator jwtauth.Authenticator,
+	serverPayloadDecider grpclogging.ServerPayloadLoggingDecider, authenticator jwtauth.Authenticator, rateLimit ratelimit.RateLimit,
+	idempotencyClient *idempotency.Client,
 	cardsAPI cpb.CardAPIServer, eligibilityAPI epb.CardEligibilityAPIServer, walletAPI cpb.WalletAPIServer,
 ) func() error {
 	return func() error {
@@ -36,8 +39,10 @@ func RunAPIServer(ctx context.Context, cfg app.Spec,
 			grpcValidator.UnaryServerInterceptor(),
 			anzerrors.UnaryServerInterceptor(),
 			errors.UnaryServerErrorLogInterceptor(),
-			feature.APIFeatureGate(),
 			jwtgrpc.UnaryServerInterceptor(authenticator),
+			feature.APIFeatureGate(),
+			ratelimit.UnaryServerInterceptor(rateLimit),
+			idempotency.UnaryServerInterceptor(idempotencyClient),
 			auditlog.UnaryServerInterceptor(cfg.AuditLog, os.Getenv("POD_ID")),
 		}
 
@@ -53,7 +53,7 @@ func TestRunAPIServer(t *testing.T) {
 		eligibilityServer := eligibility.NewServer(nil, nil, nil)
 		walletServer := wallet.NewServer(nil, nil, nil, nil, nil, nil, nil, nil)
 
-		assert.Error(t, RunAPIServer(ctx, cfg, decider, &jwtauth.InsecureAuthenticator{}, cardServer, eligibilityServer, walletServer)())
+		assert.Error(t, RunAPIServer(ctx, cfg, decider, &jwtauth.InsecureAuthenticator{}, nil, nil, cardServer, eligibilityServer, walletServer)())
 	})
 }
 
@@ -13,23 +13,23 @@ require (
 	github.com/anzx/fabric-pnv v0.7.0
 	github.com/anzx/fabric-visa-gateway v1.2.3
 	github.com/anzx/fabricapis/pkg/fabric/service/accounts/v1alpha6 v0.7.4
-	github.com/anzx/fabricapis/pkg/fabric/service/card/v1beta1 v0.7.4
+	github.com/anzx/fabricapis/pkg/fabric/service/card/v1beta1 v0.11.0
 	github.com/anzx/fabricapis/pkg/fabric/service/cardcontrols/v1beta1 v0.4.3
-	github.com/anzx/fabricapis/pkg/fabric/service/cardcontrols/v1beta2 v0.0.7
+	github.com/anzx/fabricapis/pkg/fabric/service/cardcontrols/v1beta2 v0.4.0
 	github.com/anzx/fabricapis/pkg/fabric/service/commandcentre/v1beta1 v1.2.3
-	github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1 v0.2.4
+	github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1 v0.6.0
 	github.com/anzx/fabricapis/pkg/fabric/service/entitlements/v1beta1 v0.0.26
 	github.com/anzx/fabricapis/pkg/fabric/service/fakerock/v1alpha1 v0.1.11
 	github.com/anzx/fabricapis/pkg/fabric/service/selfservice/v1beta2 v0.3.0
 	github.com/anzx/fabricapis/pkg/fabric/type v0.9.0
-	github.com/anzx/fabricapis/pkg/fabric/type/audit v0.11.0
+	github.com/anzx/fabricapis/pkg/fabric/type/audit v0.14.0
 	github.com/anzx/fabricapis/pkg/gateway/visa/service/cardonfile v0.0.3
-	github.com/anzx/fabricapis/pkg/gateway/visa/service/customerrules v0.0.13
+	github.com/anzx/fabricapis/pkg/gateway/visa/service/customerrules v0.1.0
 	github.com/anzx/fabricapis/pkg/gateway/visa/service/dcvv2 v0.0.1
 	github.com/anzx/fabricapis/pkg/visa/service/enrollmentcallback v0.0.7
-	github.com/anzx/fabricapis/pkg/visa/service/notificationcallback v0.0.8
+	github.com/anzx/fabricapis/pkg/visa/service/notificationcallback v0.1.0
 	github.com/anzx/pkg/accountformatter v1.0.0
-	github.com/anzx/pkg/auditlog v0.8.0
+	github.com/anzx/pkg/auditlog v0.10.0
 	github.com/anzx/pkg/errors v0.8.0
 	github.com/anzx/pkg/gsm v0.2.0
 	github.com/anzx/pkg/jsontime v0.4.0
@@ -59,6 +59,8 @@ require (
 	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.12.0
 	github.com/stretchr/testify v1.8.0
+	go.opentelemetry.io/otel v1.7.0
+	go.opentelemetry.io/otel/metric v0.27.0
 	go.opentelemetry.io/otel/trace v1.7.0
 	golang.org/x/net v0.0.0-20220624214902-1bab6f366d9e
 	golang.org/x/oauth2 v0.0.0-20220822191816-0ebed06d0094
@@ -156,7 +158,6 @@ require (
 	github.com/zeromq/gomq/zmtp v0.0.0-20201031135124-cef4e507bb8e // indirect
 	go.opencensus.io v0.23.0 // indirect
 	go.opentelemetry.io/contrib/propagators/b3 v1.4.0 // indirect
-	go.opentelemetry.io/otel v1.7.0 // indirect
 	go.opentelemetry.io/otel/bridge/opencensus v0.27.0 // indirect
 	go.opentelemetry.io/otel/exporters/jaeger v1.4.1 // indirect
 	go.opentelemetry.io/otel/exporters/otlp/internal/retry v1.4.0 // indirect
@@ -168,7 +169,6 @@ require (
 	go.opentelemetry.io/otel/exporters/stdout/stdoutmetric v0.27.0 // indirect
 	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.4.0 // indirect
 	go.opentelemetry.io/otel/internal/metric v0.27.0 // indirect
-	go.opentelemetry.io/otel/metric v0.27.0 // indirect
 	go.opentelemetry.io/otel/sdk v1.4.1 // indirect
 	go.opentelemetry.io/otel/sdk/metric v0.27.0 // indirect
 	go.opentelemetry.io/proto/otlp v0.12.0 // indirect
@@ -76,7 +76,7 @@ func (s server) Activate(ctx context.Context, req *cpb.ActivateRequest) (retResp
 
 	isActivated := false
 	// If we are not eligible for activation, we can interpret that to mean our card is already activated
-	if err := s.Eligibility.Can(ctx, epb.Eligibility_ELIGIBILITY_CARD_ACTIVATION, req.TokenizedCardNumber); err != nil {
+	if err := s.Eligibility.CanCard(ctx, epb.Eligibility_ELIGIBILITY_CARD_ACTIVATION, card); err != nil {
 		isActivated = true
 	}
 
@@ -155,6 +155,8 @@ func TestActivation(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_BLOCK,
 					epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 					epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+					epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 			},
 		},
@@ -178,6 +180,8 @@ func TestActivation(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_BLOCK,
 					epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 					epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+					epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 			},
 		},
@@ -201,6 +205,8 @@ func TestActivation(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_BLOCK,
 					epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 					epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+					epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 			},
 		},
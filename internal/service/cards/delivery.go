@@ -0,0 +1,80 @@
+package cards
+
+import (
+	"context"
+	"regexp"
+
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+	"google.golang.org/grpc/codes"
+
+	"github.com/anzx/fabric-cards/pkg/integration/ctm"
+	"github.com/anzx/fabric-cards/pkg/integration/selfservice"
+
+	cpb "github.com/anzx/fabricapis/pkg/fabric/service/card/v1beta1"
+	"github.com/anzx/fabricapis/pkg/fabric/type/audit/servicedata"
+)
+
+var branchNumber = regexp.MustCompile(`^\d{4}$`)
+
+// delivery is how a replacement card reaches the customer, by mail to the address on their profile unless the request
+// chose a branch to collect it from or a one-off mailing address
+type delivery struct {
+	method  ctm.DispatchedMethod
+	branch  string
+	address *ctm.MailingAddress
+}
+
+// requestedDelivery validates the delivery chosen on a replace request
+func requestedDelivery(ctx context.Context, req *cpb.ReplaceRequest) (delivery, error) {
+	branch, address := req.GetCollectionBranch(), req.GetMailingAddress()
+
+	switch {
+	case branch != "" && address != nil:
+		return delivery{}, anzerrors.New(codes.InvalidArgument, replacementFailed,
+			anzerrors.NewErrorInfo(ctx, anzcodes.ValidationFailure, "collection branch and mailing address both requested"))
+	case branch != "":
+		if !branchNumber.MatchString(branch) {
+			return delivery{}, anzerrors.New(codes.InvalidArgument, replacementFailed,
+				anzerrors.NewErrorInfo(ctx, anzcodes.ValidationFailure, "invalid collection branch"))
+		}
+		return delivery{method: ctm.DispatchedMethodBranch, branch: branch}, nil
+	case address != nil:
+		mailingAddress, err := ctm.GetRequestedAddress(ctx, address)
+		if err != nil {
+			return delivery{}, serviceErr(err, replacementFailed)
+		}
+		return delivery{method: ctm.DispatchedMethodMail, address: &mailingAddress}, nil
+	default:
+		return delivery{method: ctm.DispatchedMethodMail}, nil
+	}
+}
+
+// mailingAddress returns the address the card is posted to. Cards collected from a branch are not posted, so the
+// profile address is only sent to CTM when the customer has a usable one.
+func (d delivery) mailingAddress(ctx context.Context, party *selfservice.Party) (ctm.MailingAddress, error) {
+	if d.address != nil {
+		return *d.address, nil
+	}
+
+	address, err := party.GetAddress(ctx)
+	if err != nil {
+		if d.method == ctm.DispatchedMethodBranch {
+			return ctm.MailingAddress{}, nil
+		}
+		return ctm.MailingAddress{}, serviceErr(err, replacementFailed)
+	}
+
+	mailingAddress, err := ctm.GetAddress(ctx, address)
+	if err != nil && d.method == ctm.DispatchedMethodBranch {
+		return ctm.MailingAddress{}, nil
+	}
+
+	return mailingAddress, err
+}
+
+func (d delivery) populateServiceData(serviceData *servicedata.ReplaceCard) {
+	serviceData.DispatchMethod = string(d.method)
+	serviceData.CollectionBranch = d.branch
+	serviceData.AlternateMailingAddress = d.address != nil
+}
@@ -33,7 +33,7 @@ func (s server) GetDetails(ctx context.Context, req *cpb.GetDetailsRequest) (*cp
 		return nil, err
 	}
 
-	if err := s.Eligibility.Can(ctx, epb.Eligibility_ELIGIBILITY_GET_DETAILS, req.TokenizedCardNumber); err != nil {
+	if err := s.Eligibility.CanCard(ctx, epb.Eligibility_ELIGIBILITY_GET_DETAILS, card); err != nil {
 		return nil, anzerrors.Wrap(err, codes.PermissionDenied, getDetailsFailed, anzerrors.GetErrorInfo(err))
 	}
 
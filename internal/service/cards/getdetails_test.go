@@ -99,6 +99,8 @@ func TestGetCardDetails(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_CARD_CONTROLS,
 					epb.Eligibility_ELIGIBILITY_BLOCK,
 					epb.Eligibility_ELIGIBILITY_GET_DETAILS,
+					epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 			},
 		},
@@ -131,6 +133,8 @@ func TestGetCardDetails(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_CARD_CONTROLS,
 					epb.Eligibility_ELIGIBILITY_BLOCK,
 					epb.Eligibility_ELIGIBILITY_GET_DETAILS,
+					epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 			},
 			enableDCVV2: true,
@@ -157,6 +161,8 @@ func TestGetCardDetails(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_CARD_CONTROLS,
 					epb.Eligibility_ELIGIBILITY_BLOCK,
 					epb.Eligibility_ELIGIBILITY_GET_DETAILS,
+					epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 			},
 			enableDCVV2: true,
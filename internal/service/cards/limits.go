@@ -0,0 +1,167 @@
+package cards
+
+import (
+	"context"
+
+	"github.com/anzx/fabric-commandcentre-sdk/pkg/sdk/event"
+	"github.com/anzx/pkg/auditlog"
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+	"google.golang.org/grpc/codes"
+
+	"github.com/anzx/fabric-cards/pkg/integration/ctm"
+	"github.com/anzx/fabric-cards/pkg/integration/entitlements"
+	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
+	"github.com/anzx/fabric-cards/pkg/ratelimit"
+
+	cpb "github.com/anzx/fabricapis/pkg/fabric/service/card/v1beta1"
+	epb "github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1"
+	"github.com/anzx/fabricapis/pkg/fabric/type/audit/servicedata"
+)
+
+const updateLimitsFailed = "update limits failed"
+
+// GetLimits returns the daily limits of a card along with the bounds a customer may change them within.
+func (s server) GetLimits(ctx context.Context, req *cpb.GetLimitsRequest) (*cpb.GetLimitsResponse, error) {
+	card, err := s.getCard(ctx, req.TokenizedCardNumber, "get limits")
+	if err != nil {
+		return nil, err
+	}
+
+	return &cpb.GetLimitsResponse{
+		Limits: getLimits(ctx, card.Limits),
+		Bounds: s.getLimitBounds(card.Limits),
+	}, nil
+}
+
+// UpdateLimits changes the daily limits of a card, each limit must be within the bounds configured for its type.
+func (s server) UpdateLimits(ctx context.Context, req *cpb.UpdateLimitsRequest) (retResponse *cpb.UpdateLimitsResponse, retError error) {
+	serviceData := &servicedata.UpdateCardLimits{
+		TokenizedCardNumber: req.GetTokenizedCardNumber(),
+	}
+
+	defer func() {
+		if err := serviceData.Validate(); err != nil {
+			logf.Error(ctx, err, "invalid service data payload")
+		}
+		s.AuditLog.Publish(ctx, auditlog.EventUpdateCardLimits, retResponse, retError, serviceData)
+	}()
+
+	update, err := s.limitsUpdate(ctx, req.GetLimits())
+	if err != nil {
+		return nil, err
+	}
+	serviceData.NewLimits = limitsAuditData(update.Limits)
+
+	if err := s.RateLimit.Allow(ctx, ratelimit.DailyLimits); err != nil {
+		return nil, serviceErr(err, updateLimitsFailed)
+	}
+
+	entitledCard, err := s.Entitlements.GetEntitledCard(ctx, req.TokenizedCardNumber, entitlements.OPERATION_MANAGE_CARD)
+	if err != nil {
+		return nil, serviceErr(err, updateLimitsFailed)
+	}
+	serviceData.AccountNumbers = entitledCard.GetAccountNumbers()
+
+	if err := s.Eligibility.Can(ctx, epb.Eligibility_ELIGIBILITY_DAILY_LIMITS, req.TokenizedCardNumber); err != nil {
+		return nil, anzerrors.Wrap(err, codes.PermissionDenied, updateLimitsFailed, anzerrors.GetErrorInfo(err))
+	}
+
+	card, err := s.CTM.DebitCardInquiry(ctx, req.TokenizedCardNumber)
+	if err != nil {
+		return nil, serviceErr(err, updateLimitsFailed)
+	}
+	serviceData.OldLimits = currentLimitsAuditData(card.Limits)
+
+	// a repeated request is treated as a success so it can be retried safely
+	if !limitsChanged(card, update) {
+		return &cpb.UpdateLimitsResponse{Limits: getLimits(ctx, card.Limits)}, nil
+	}
+
+	if ok, err := s.CTM.UpdateLimits(ctx, update, req.TokenizedCardNumber); !ok {
+		return nil, serviceErr(err, updateLimitsFailed)
+	}
+
+	s.CommandCentre.PublishEventAsync(ctx, event.CardControlsChange)
+
+	card, err = s.CTM.DebitCardInquiry(ctx, req.TokenizedCardNumber)
+	if err != nil {
+		return &cpb.UpdateLimitsResponse{}, nil
+	}
+
+	return &cpb.UpdateLimitsResponse{Limits: getLimits(ctx, card.Limits)}, nil
+}
+
+// limitsUpdate validates the requested limits against the configured bounds and returns the CTM request for them
+func (s server) limitsUpdate(ctx context.Context, limits []*cpb.LimitUpdate) (*ctm.UpdateLimitsRequest, error) {
+	if len(limits) == 0 {
+		return nil, anzerrors.New(codes.InvalidArgument, updateLimitsFailed,
+			anzerrors.NewErrorInfo(ctx, anzcodes.ValidationFailure, "no limits requested"))
+	}
+
+	update := &ctm.UpdateLimitsRequest{}
+	seen := map[ctm.LimitType]bool{}
+	for _, limit := range limits {
+		limitType := ctm.LimitType(limit.GetType())
+		bounds, ok := s.DailyLimits.Bounds(limitType)
+		if !ok {
+			return nil, anzerrors.New(codes.InvalidArgument, updateLimitsFailed,
+				anzerrors.NewErrorInfo(ctx, anzcodes.ValidationFailure, "limit type not supported"))
+		}
+		if seen[limitType] {
+			return nil, anzerrors.New(codes.InvalidArgument, updateLimitsFailed,
+				anzerrors.NewErrorInfo(ctx, anzcodes.ValidationFailure, "limit type requested more than once"))
+		}
+		if !bounds.Allows(limit.GetDailyLimit()) {
+			return nil, anzerrors.New(codes.InvalidArgument, updateLimitsFailed,
+				anzerrors.NewErrorInfo(ctx, anzcodes.ValidationFailure, "daily limit out of bounds"))
+		}
+		seen[limitType] = true
+		update.Limits = append(update.Limits, ctm.LimitUpdate{Type: limitType, DailyLimit: limit.GetDailyLimit()})
+	}
+
+	return update, nil
+}
+
+// getLimitBounds returns the configured bounds of every limit set on the card
+func (s server) getLimitBounds(limits []ctm.NewLimits) []*cpb.LimitBounds {
+	var result []*cpb.LimitBounds
+	for _, limit := range limits {
+		bounds, ok := s.DailyLimits.Bounds(limit.Type)
+		if !ok {
+			continue
+		}
+		result = append(result, &cpb.LimitBounds{
+			Type:    limit.Type.String(),
+			Minimum: bounds.Min,
+			Maximum: bounds.Max,
+		})
+	}
+	return result
+}
+
+func limitsChanged(card *ctm.DebitCardResponse, update *ctm.UpdateLimitsRequest) bool {
+	for _, limit := range update.Limits {
+		current, ok := card.Limit(limit.Type)
+		if !ok || current.DailyLimit != limit.DailyLimit {
+			return true
+		}
+	}
+	return false
+}
+
+func limitsAuditData(limits []ctm.LimitUpdate) map[string]int64 {
+	out := make(map[string]int64, len(limits))
+	for _, limit := range limits {
+		out[limit.Type.String()] = limit.DailyLimit
+	}
+	return out
+}
+
+func currentLimitsAuditData(limits []ctm.NewLimits) map[string]int64 {
+	out := make(map[string]int64, len(limits))
+	for _, limit := range limits {
+		out[limit.Type.String()] = limit.DailyLimit
+	}
+	return out
+}
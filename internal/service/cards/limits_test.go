@@ -0,0 +1,273 @@
+package cards
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/anzx/fabric-cards/pkg/date"
+	"github.com/anzx/fabric-cards/pkg/integration/ctm"
+	"github.com/anzx/fabric-cards/test/data"
+	"github.com/anzx/fabric-cards/test/fixtures"
+	"github.com/anzx/fabric-cards/test/util"
+
+	cpb "github.com/anzx/fabricapis/pkg/fabric/service/card/v1beta1"
+	"github.com/anzx/fabricapis/pkg/fabric/type/audit"
+	"github.com/anzx/fabricapis/pkg/fabric/type/audit/servicedata"
+)
+
+func TestGetLimits(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		builder *fixtures.ServerBuilder
+		want    *cpb.GetLimitsResponse
+		wantErr error
+	}{
+		{
+			name:    "limits and bounds returned",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()),
+			want: &cpb.GetLimitsResponse{
+				Limits: []*cpb.Limit{
+					{
+						DailyLimit:          "1000",
+						DailyLimitAvailable: "1000",
+						Type:                "APO",
+					},
+					{
+						DailyLimit:          "2500",
+						DailyLimitAvailable: "2347",
+						LastTransaction:     date.NewDate(2015, 8, 5).ToProto(),
+						Type:                "ATMEFTPOS",
+					},
+				},
+				Bounds: []*cpb.LimitBounds{
+					{Type: "APO", Minimum: 0, Maximum: 5000},
+					{Type: "ATMEFTPOS", Minimum: 100, Maximum: 5000},
+				},
+			},
+		},
+		{
+			name: "limit types without bounds are not changeable",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()).WithDailyLimits(&ctm.LimitsConfig{
+				Bounds: []ctm.LimitBounds{{Type: ctm.LimitTypeATMEFTPOS, Min: 100, Max: 3000}},
+			}),
+			want: &cpb.GetLimitsResponse{
+				Limits: []*cpb.Limit{
+					{
+						DailyLimit:          "1000",
+						DailyLimitAvailable: "1000",
+						Type:                "APO",
+					},
+					{
+						DailyLimit:          "2500",
+						DailyLimitAvailable: "2347",
+						LastTransaction:     date.NewDate(2015, 8, 5).ToProto(),
+						Type:                "ATMEFTPOS",
+					},
+				},
+				Bounds: []*cpb.LimitBounds{
+					{Type: "ATMEFTPOS", Minimum: 100, Maximum: 3000},
+				},
+			},
+		},
+		{
+			name:    "CTM fails inquiry",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()).WithCtmInquiryError(errors.New("oh no")),
+			wantErr: errors.New("message=get limits failed"),
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			ctx, b := fixtures.GetTestContextWithLogger(nil)
+			s := buildCardServer(test.builder)
+
+			got, err := s.GetLimits(ctx, &cpb.GetLimitsRequest{TokenizedCardNumber: data.AUserWithACard().Token()})
+			util.CheckTestAndAuditLogs(t, got, test.want, test.wantErr, err, b)
+		})
+	}
+}
+
+func TestUpdateLimits(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		builder   *fixtures.ServerBuilder
+		limits    []*cpb.LimitUpdate
+		want      *cpb.UpdateLimitsResponse
+		wantErr   error
+		wantLimit int64
+	}{
+		{
+			name:    "lower the ATM/EFTPOS limit",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()),
+			limits:  []*cpb.LimitUpdate{{Type: "ATMEFTPOS", DailyLimit: 1500}},
+			want: &cpb.UpdateLimitsResponse{
+				Limits: []*cpb.Limit{
+					{
+						DailyLimit:          "1000",
+						DailyLimitAvailable: "1000",
+						Type:                "APO",
+					},
+					{
+						DailyLimit:          "1500",
+						DailyLimitAvailable: "1347",
+						LastTransaction:     date.NewDate(2015, 8, 5).ToProto(),
+						Type:                "ATMEFTPOS",
+					},
+				},
+			},
+			wantLimit: 1500,
+		},
+		{
+			name:    "unchanged limit is not sent to CTM",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()).WithCtmUpdateLimitsError(errors.New("not called")),
+			limits:  []*cpb.LimitUpdate{{Type: "ATMEFTPOS", DailyLimit: 2500}},
+			want: &cpb.UpdateLimitsResponse{
+				Limits: []*cpb.Limit{
+					{
+						DailyLimit:          "1000",
+						DailyLimitAvailable: "1000",
+						Type:                "APO",
+					},
+					{
+						DailyLimit:          "2500",
+						DailyLimitAvailable: "2347",
+						LastTransaction:     date.NewDate(2015, 8, 5).ToProto(),
+						Type:                "ATMEFTPOS",
+					},
+				},
+			},
+			wantLimit: 2500,
+		},
+		{
+			name:      "above the maximum",
+			builder:   fixtures.AServer().WithData(data.AUserWithACard()),
+			limits:    []*cpb.LimitUpdate{{Type: "ATMEFTPOS", DailyLimit: 5001}},
+			wantErr:   errors.New("message=update limits failed, reason=daily limit out of bounds"),
+			wantLimit: 2500,
+		},
+		{
+			name:      "below the minimum",
+			builder:   fixtures.AServer().WithData(data.AUserWithACard()),
+			limits:    []*cpb.LimitUpdate{{Type: "ATMEFTPOS", DailyLimit: 99}},
+			wantErr:   errors.New("message=update limits failed, reason=daily limit out of bounds"),
+			wantLimit: 2500,
+		},
+		{
+			name: "limit type not configured",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()).WithDailyLimits(&ctm.LimitsConfig{
+				Bounds: []ctm.LimitBounds{{Type: ctm.LimitTypeAPO, Min: 0, Max: 5000}},
+			}),
+			limits:    []*cpb.LimitUpdate{{Type: "ATMEFTPOS", DailyLimit: 1500}},
+			wantErr:   errors.New("message=update limits failed, reason=limit type not supported"),
+			wantLimit: 2500,
+		},
+		{
+			name:    "limit type requested twice",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()),
+			limits: []*cpb.LimitUpdate{
+				{Type: "ATMEFTPOS", DailyLimit: 1500},
+				{Type: "ATMEFTPOS", DailyLimit: 2000},
+			},
+			wantErr:   errors.New("message=update limits failed, reason=limit type requested more than once"),
+			wantLimit: 2500,
+		},
+		{
+			name:    "no limits",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()),
+			wantErr: errors.New("message=update limits failed, reason=no limits requested"),
+		},
+		{
+			name:      "card not eligible",
+			builder:   fixtures.AServer().WithData(data.AUserWithACard(data.WithStatus(ctm.StatusTemporaryBlock))),
+			limits:    []*cpb.LimitUpdate{{Type: "ATMEFTPOS", DailyLimit: 1500}},
+			wantErr:   errors.New("status_code=PermissionDenied"),
+			wantLimit: 2500,
+		},
+		{
+			name:      "inactive card not eligible",
+			builder:   fixtures.AServer().WithData(data.AUserWithACard(data.Inactive)),
+			limits:    []*cpb.LimitUpdate{{Type: "ATMEFTPOS", DailyLimit: 1500}},
+			wantErr:   errors.New("message=update limits failed, reason=card not eligible"),
+			wantLimit: 2500,
+		},
+		{
+			name:      "rate limited",
+			builder:   fixtures.AServer().WithData(data.AUserWithACard()).WithRateLimitError(errors.New("over rate limit")),
+			limits:    []*cpb.LimitUpdate{{Type: "ATMEFTPOS", DailyLimit: 1500}},
+			wantErr:   errors.New("status_code=ResourceExhausted"),
+			wantLimit: 2500,
+		},
+		{
+			name: "not entitled",
+			builder: fixtures.AServer().WithData(
+				data.AUser(
+					data.WithAPersonaID("personaID"),
+					data.WithACard())),
+			limits:  []*cpb.LimitUpdate{{Type: "ATMEFTPOS", DailyLimit: 1500}},
+			wantErr: errors.New("message=update limits failed, reason=user not entitled"),
+		},
+		{
+			name: "CTM fails to update limits",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()).WithCtmUpdateLimitsError(
+				anzerrors.New(codes.Unavailable, "failed request",
+					anzerrors.NewErrorInfo(context.Background(), anzcodes.DownstreamFailure, "service unavailable"))),
+			limits:    []*cpb.LimitUpdate{{Type: "ATMEFTPOS", DailyLimit: 1500}},
+			wantErr:   errors.New("status_code=Unavailable, error_code=2, message=update limits failed, reason=service unavailable"),
+			wantLimit: 2500,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			ctx, b := fixtures.GetTestContextWithLogger(nil)
+			s := buildCardServer(test.builder)
+
+			got, err := s.UpdateLimits(ctx, &cpb.UpdateLimitsRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				Limits:              test.limits,
+			})
+			util.CheckTestAndAuditLogs(t, got, test.want, test.wantErr, err, b)
+
+			if test.wantLimit != 0 {
+				card, err := test.builder.CTMClient.DebitCardInquiry(ctx, data.AUserWithACard().Token())
+				require.NoError(t, err)
+				limit, ok := card.Limit(ctm.LimitTypeATMEFTPOS)
+				require.True(t, ok)
+				assert.Equal(t, test.wantLimit, limit.DailyLimit)
+			}
+		})
+	}
+}
+
+func TestUpdateLimitsAuditLog(t *testing.T) {
+	sd := servicedata.UpdateCardLimits{}
+	hook := func(buf []byte) {
+		p := &audit.AuditLog{}
+		_ = protojson.Unmarshal(buf, p)
+		_ = p.GetServiceData()[0].UnmarshalTo(&sd)
+	}
+
+	builder := fixtures.AServer().WithData(data.AUserWithACard()).WithAuditLogHook(hook)
+	ctx, _ := fixtures.GetTestContextWithLogger(nil)
+	s := buildCardServer(builder)
+
+	_, err := s.UpdateLimits(ctx, &cpb.UpdateLimitsRequest{
+		TokenizedCardNumber: data.AUserWithACard().Token(),
+		Limits:              []*cpb.LimitUpdate{{Type: "ATMEFTPOS", DailyLimit: 1500}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, sd.Validate())
+	assert.Equal(t, data.AUserWithACard().Token(), sd.GetTokenizedCardNumber())
+	assert.Equal(t, map[string]int64{"ATMEFTPOS": 1500}, sd.GetNewLimits())
+	assert.Equal(t, map[string]int64{"APO": 1000, "ATMEFTPOS": 2500}, sd.GetOldLimits())
+}
@@ -2,6 +2,9 @@ package cards
 
 import (
 	"context"
+	"sync"
+
+	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
 
 	"github.com/anzx/fabric-cards/pkg/date"
 
@@ -10,40 +13,55 @@ import (
 	"github.com/anzx/fabric-cards/pkg/integration/ctm"
 
 	cpb "github.com/anzx/fabricapis/pkg/fabric/service/card/v1beta1"
+	epb "github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1"
 )
 
-const listCardsFailed = "list cards failed"
+const (
+	listCardsFailed = "list cards failed"
+	// listConcurrency bounds the CTM inquiries made concurrently for one List request
+	listConcurrency = 4
+)
 
 type cardDetail struct {
 	entitledCard      *entpb.EntitledCard
 	debitCardResponse *ctm.DebitCardResponse
+	err               error
 }
 
-func (s server) List(ctx context.Context, _ *cpb.ListRequest) (*cpb.ListResponse, error) {
+func (s server) List(ctx context.Context, req *cpb.ListRequest) (*cpb.ListResponse, error) {
 	entitledCards, err := s.Entitlements.ListEntitledCards(ctx)
 	if err != nil {
 		return nil, serviceErr(err, listCardsFailed)
 	}
 
-	cardDetails := make([]*cardDetail, 0, len(entitledCards))
-	allCards := make(map[string]*ctm.DebitCardResponse, len(entitledCards))
-	for _, card := range entitledCards {
-		c := card
-		tokenizedCardNumber := c.TokenizedCardNumber
-		response, err := s.CTM.DebitCardInquiry(ctx, tokenizedCardNumber)
-		if err != nil {
-			return nil, serviceErr(err, listCardsFailed)
+	cardDetails := s.inquireAll(ctx, entitledCards)
+
+	allCards := make(map[string]*ctm.DebitCardResponse, len(cardDetails))
+	for _, card := range cardDetails {
+		if card.err == nil {
+			allCards[card.entitledCard.GetTokenizedCardNumber()] = card.debitCardResponse
 		}
-		cardDetails = append(cardDetails, &cardDetail{
-			entitledCard:      c,
-			debitCardResponse: response,
-		})
-		allCards[tokenizedCardNumber] = response
+	}
+
+	// the response only fails if no card could be inquired, otherwise failed cards are degraded
+	if len(allCards) == 0 && len(cardDetails) > 0 {
+		return nil, serviceErr(cardDetails[0].err, listCardsFailed)
 	}
 
 	var cards []*cpb.Card
 	for _, card := range cardDetails {
-		if !card.debitCardResponse.Visible() && newCardIsActive(card.debitCardResponse, allCards) {
+		if hidden(card, allCards) {
+			continue
+		}
+
+		// only the entitlements data is returned for a card whose inquiry failed
+		if card.err != nil {
+			logf.Error(ctx, card.err, "list cards: returning degraded card")
+			cards = append(cards, &cpb.Card{
+				TokenizedCardNumber: card.entitledCard.GetTokenizedCardNumber(),
+				AccountNumbers:      card.entitledCard.GetAccountNumbers(),
+				Status:              ctm.StatusUnavailable.String(),
+			})
 			continue
 		}
 
@@ -64,6 +82,10 @@ func (s server) List(ctx context.Context, _ *cpb.ListRequest) (*cpb.ListResponse
 			c.NewTokenizedCardNumber = d.NewCardNumber.Token
 		}
 
+		if req.GetIncludeIneligibilities() {
+			c.Ineligibilities = getIneligibilities(d.Ineligibilities())
+		}
+
 		cards = append(cards, c)
 	}
 
@@ -72,6 +94,45 @@ func (s server) List(ctx context.Context, _ *cpb.ListRequest) (*cpb.ListResponse
 	}, nil
 }
 
+// inquireAll inquires every entitled card with at most listConcurrency inquiries in flight, the details are
+// returned in the order of entitledCards
+func (s server) inquireAll(ctx context.Context, entitledCards []*entpb.EntitledCard) []*cardDetail {
+	cardDetails := make([]*cardDetail, len(entitledCards))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < listConcurrency && w < len(entitledCards); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				response, err := s.CTM.DebitCardInquiry(ctx, entitledCards[i].GetTokenizedCardNumber())
+				cardDetails[i] = &cardDetail{
+					entitledCard:      entitledCards[i],
+					debitCardResponse: response,
+					err:               err,
+				}
+			}
+		}()
+	}
+
+	for i := range entitledCards {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return cardDetails
+}
+
+func getIneligibilities(in []ctm.Ineligibility) []*epb.Ineligibility {
+	out := make([]*epb.Ineligibility, 0, len(in))
+	for _, ineligibility := range in {
+		out = append(out, ineligibility.ToProto())
+	}
+	return out
+}
+
 func getWallet(in ctm.Wallet) *cpb.Wallets {
 	return &cpb.Wallets{
 		Other:      in.Other,
@@ -83,6 +144,24 @@ func getWallet(in ctm.Wallet) *cpb.Wallets {
 	}
 }
 
+// hidden returns true for a replaced card that is not visible once its new card is active. A card whose inquiry failed
+// is hidden if an active card replaced it.
+func hidden(card *cardDetail, allCards map[string]*ctm.DebitCardResponse) bool {
+	if card.err != nil {
+		return replacedByActiveCard(card.entitledCard.GetTokenizedCardNumber(), allCards)
+	}
+	return !card.debitCardResponse.Visible() && newCardIsActive(card.debitCardResponse, allCards)
+}
+
+func replacedByActiveCard(tokenizedCardNumber string, allCards map[string]*ctm.DebitCardResponse) bool {
+	for _, c := range allCards {
+		if c.OldCardNumber != nil && c.OldCardNumber.Token == tokenizedCardNumber && c.ActivationStatus {
+			return true
+		}
+	}
+	return false
+}
+
 func newCardIsActive(card *ctm.DebitCardResponse, allCards map[string]*ctm.DebitCardResponse) bool {
 	// if new card is issued
 	if card.NewCardNumber != nil {
@@ -34,6 +34,7 @@ import (
 	cpb "github.com/anzx/fabricapis/pkg/fabric/service/card/v1beta1"
 	epb "github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestList(t *testing.T) {
@@ -70,6 +71,109 @@ func TestList(t *testing.T) {
 					anzerrors.NewErrorInfo(context.Background(), anzcodes.DownstreamFailure, "service unavailable"))),
 			wantErr: errors.New("fabric error: status_code=Unavailable, error_code=2, message=list cards failed, reason=service unavailable"),
 		},
+		{
+			name: "CTM fails for one card, card is degraded and others are returned",
+			builder: fixtures.AServer().WithData(
+				data.AUser(
+					data.WithACard(
+						data.WithAToken("failedToken"),
+						data.WithACardNumber("failedCardNumber")),
+					data.WithACard(
+						data.WithAToken("token"),
+						data.WithACardNumber("1234567890123456")),
+				),
+			).WithCtmInquiryErrorFor("failedToken", anzerrors.New(codes.Unavailable, "failed request",
+				anzerrors.NewErrorInfo(context.Background(), anzcodes.DownstreamFailure, "service unavailable"))),
+			want: &cpb.ListResponse{
+				Cards: []*cpb.Card{
+					{
+						TokenizedCardNumber: "failedToken",
+						AccountNumbers:      []string{"1234567890"},
+						Status:              ctm.StatusUnavailable.String(),
+					},
+					{
+						Name:                "MR NATHAN FUKUSHIMA",
+						TokenizedCardNumber: "token",
+						Last_4Digits:        "3456",
+						Status:              "Issued",
+						ExpiryDate: &pbtype.Date{
+							Year:  &pbtype.OptionalInt32{Value: 2017},
+							Month: &pbtype.OptionalInt32{Value: 5},
+						},
+						AccountNumbers: []string{"1234567890"},
+						Eligibilities: []epb.Eligibility{
+							epb.Eligibility_ELIGIBILITY_APPLE_PAY,
+							epb.Eligibility_ELIGIBILITY_GOOGLE_PAY,
+							epb.Eligibility_ELIGIBILITY_SAMSUNG_PAY,
+							epb.Eligibility_ELIGIBILITY_CHANGE_PIN,
+							epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_LOST,
+							epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_STOLEN,
+							epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_DAMAGED,
+							epb.Eligibility_ELIGIBILITY_CARD_CONTROLS,
+							epb.Eligibility_ELIGIBILITY_BLOCK,
+							epb.Eligibility_ELIGIBILITY_GET_DETAILS,
+							epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+							epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+							epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
+						},
+						Wallets: &cpb.Wallets{
+							ApplePay:  2,
+							GooglePay: 1,
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "CTM fails for a card replaced by an active card, card is hidden",
+			builder: fixtures.AServer().WithData(
+				data.AUser(
+					data.WithACard(
+						data.WithAToken("failedToken"),
+						data.WithACardNumber("failedCardNumber")),
+					data.WithACard(
+						data.WithAToken("token"),
+						data.WithACardNumber("1234567890123456"),
+						data.WithOldCard("failedToken"),
+						data.Active),
+				),
+			).WithCtmInquiryErrorFor("failedToken", anzerrors.New(codes.Unavailable, "failed request",
+				anzerrors.NewErrorInfo(context.Background(), anzcodes.DownstreamFailure, "service unavailable"))),
+			want: &cpb.ListResponse{
+				Cards: []*cpb.Card{
+					{
+						Name:                "MR NATHAN FUKUSHIMA",
+						TokenizedCardNumber: "token",
+						Last_4Digits:        "3456",
+						Status:              "Issued",
+						ExpiryDate: &pbtype.Date{
+							Year:  &pbtype.OptionalInt32{Value: 2017},
+							Month: &pbtype.OptionalInt32{Value: 5},
+						},
+						AccountNumbers: []string{"1234567890"},
+						Eligibilities: []epb.Eligibility{
+							epb.Eligibility_ELIGIBILITY_APPLE_PAY,
+							epb.Eligibility_ELIGIBILITY_GOOGLE_PAY,
+							epb.Eligibility_ELIGIBILITY_SAMSUNG_PAY,
+							epb.Eligibility_ELIGIBILITY_CHANGE_PIN,
+							epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_LOST,
+							epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_STOLEN,
+							epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_DAMAGED,
+							epb.Eligibility_ELIGIBILITY_CARD_CONTROLS,
+							epb.Eligibility_ELIGIBILITY_BLOCK,
+							epb.Eligibility_ELIGIBILITY_GET_DETAILS,
+							epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+							epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+							epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
+						},
+						Wallets: &cpb.Wallets{
+							ApplePay:  2,
+							GooglePay: 1,
+						},
+					},
+				},
+			},
+		},
 		{
 			name:    "CTM succeeds get cards return true",
 			builder: fixtures.AServer().WithData(data.AUserWithACard()),
@@ -97,6 +201,8 @@ func TestList(t *testing.T) {
 							epb.Eligibility_ELIGIBILITY_BLOCK,
 							epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 							epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+							epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+							epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 						},
 						Wallets: &cpb.Wallets{
 							Other:      0,
@@ -151,6 +257,8 @@ func TestList(t *testing.T) {
 							epb.Eligibility_ELIGIBILITY_BLOCK,
 							epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 							epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+							epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+							epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 						},
 						Wallets: &cpb.Wallets{
 							Other:      0,
@@ -258,6 +366,7 @@ func TestList(t *testing.T) {
 							epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_DAMAGED,
 							epb.Eligibility_ELIGIBILITY_CARD_CONTROLS,
 							epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+							epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 						},
 						Wallets: &cpb.Wallets{
 							Other:      0,
@@ -289,6 +398,37 @@ func TestList(t *testing.T) {
 	}
 }
 
+func TestListIneligibilities(t *testing.T) {
+	t.Parallel()
+	builder := fixtures.AServer().WithData(data.AUserWithACard(data.Inactive))
+	s := buildCardServer(builder)
+
+	t.Run("not returned unless requested", func(t *testing.T) {
+		got, err := s.List(fixtures.GetTestContext(), &cpb.ListRequest{})
+		require.NoError(t, err)
+		require.Len(t, got.GetCards(), 1)
+		assert.Empty(t, got.GetCards()[0].GetIneligibilities())
+	})
+
+	t.Run("every denied eligibility is explained", func(t *testing.T) {
+		got, err := s.List(fixtures.GetTestContext(), &cpb.ListRequest{IncludeIneligibilities: true})
+		require.NoError(t, err)
+		require.Len(t, got.GetCards(), 1)
+
+		card := got.GetCards()[0]
+		reasons := map[epb.Eligibility]string{}
+		for _, ineligibility := range card.GetIneligibilities() {
+			reasons[ineligibility.GetEligibility()] = ineligibility.GetReason()
+		}
+		for _, eligibility := range card.GetEligibilities() {
+			assert.NotContains(t, reasons, eligibility)
+		}
+		assert.Equal(t, "CARD_NOT_ACTIVATED", reasons[epb.Eligibility_ELIGIBILITY_GET_DETAILS])
+		assert.Equal(t, "PIN_ALREADY_SET", reasons[epb.Eligibility_ELIGIBILITY_SET_PIN])
+		assert.Equal(t, "NOT_APPLICABLE", reasons[epb.Eligibility_ELIGIBILITY_UNBLOCK])
+	})
+}
+
 func buildCardServer(c *fixtures.ServerBuilder) cpb.CardAPIServer {
 	fabric := Fabric{
 		CommandCentre: &commandcentre.Client{
@@ -296,6 +436,7 @@ func buildCardServer(c *fixtures.ServerBuilder) cpb.CardAPIServer {
 		},
 		Eligibility: &eligibility.Client{
 			CardEligibilityAPIClient: c.CardEligibilityAPIClient,
+			Evaluator:                c.CardEligibilityAPIClient,
 		},
 		Entitlements: &entitlements.Client{
 			CardEntitlementsAPIClient:    c.CardEntitlementsAPIClient,
@@ -313,7 +454,9 @@ func buildCardServer(c *fixtures.ServerBuilder) cpb.CardAPIServer {
 		},
 	}
 	internal := Internal{
-		RateLimit: c.RateLimit,
+		RateLimit:   c.RateLimit,
+		DailyLimits: c.DailyLimits,
+		Sagas:       c.Sagas,
 	}
 	external := External{
 		CTM:     c.CTMClient,
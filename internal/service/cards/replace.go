@@ -70,12 +70,18 @@ func (s server) Replace(ctx context.Context, req *cpb.ReplaceRequest) (retRespon
 		s.AuditLog.Publish(ctx, auditlog.EventReplaceCard, retResponse, retError, serviceData)
 	}()
 
-	if !feature.FeatureGate.Enabled(feature.Feature(req.Reason.String())) {
+	if !feature.FeatureGate.EnabledFor(ctx, feature.Feature(req.Reason.String())) {
 		return nil, anzerrors.New(codes.Unavailable, "reason not allowed",
 			anzerrors.NewErrorInfo(ctx, anzcodes.FeatureDisabled, "reason is disabled"),
 			anzerrors.WithCause(fmt.Errorf("%s is behind feature toggle", req.Reason.String())))
 	}
 
+	delivery, err := requestedDelivery(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	delivery.populateServiceData(serviceData)
+
 	entitledCard, err := s.Entitlements.GetEntitledCard(ctx, req.TokenizedCardNumber, entitlements.OPERATION_MANAGE_CARD)
 	if err != nil {
 		return nil, serviceErr(err, replacementFailed)
@@ -87,10 +93,6 @@ func (s server) Replace(ctx context.Context, req *cpb.ReplaceRequest) (retRespon
 
 	serviceData.AccountNumbers = entitledCard.GetAccountNumbers()
 
-	if err := s.Eligibility.Can(ctx, getEligibility(req.Reason), req.TokenizedCardNumber); err != nil {
-		return nil, serviceErr(err, replacementFailed)
-	}
-
 	id, err := identity.Get(ctx)
 	if err != nil {
 		return nil, serviceErr(err, replacementFailed)
@@ -101,8 +103,23 @@ func (s server) Replace(ctx context.Context, req *cpb.ReplaceRequest) (retRespon
 		return nil, serviceErr(err, replacementFailed)
 	}
 
-	newCard, oldCard, err := s.requestReplacementCard(ctx, oldCard, req.Reason, party)
-	if err != nil {
+	data := &replacement{
+		PersonaID: id.PersonaID,
+		OcvID:     id.OcvID,
+		Reason:    req.Reason,
+		OldCard:   oldCard,
+		Accounts:  accounts,
+		inquired:  true,
+	}
+	// if a card is already replaced, skip the part for getting a new card and just try to link the card to customer/account
+	if oldCard.NewCardNumber == nil {
+		if data.Request, err = replaceCardRequest(ctx, oldCard, req.Reason, party, delivery); err != nil {
+			return nil, err
+		}
+	}
+
+	run, err := s.Sagas.Start(ctx, s.replacementSaga(), data)
+	if data.NewCard == nil {
 		return nil, err
 	}
 
@@ -110,59 +127,22 @@ func (s server) Replace(ctx context.Context, req *cpb.ReplaceRequest) (retRespon
 		s.CommandCentre.PublishEventAsync(ctx, event.CardStatusChange)
 		if id.HasDifferentSubject {
 			// This request was likely made by a staff member or coach on customer's behalf, so we should notify customer
-			go s.publishNotification(xcontext.Detach(ctx), id.PersonaID)
+			go s.publishNotification(xcontext.Detach(ctx), id.PersonaID, delivery)
 		}
-		populateServiceData(ctx, serviceData, oldCard, newCard)
+		populateServiceData(ctx, serviceData, data.OldCard, data.NewCard)
 	}()
 
-	if plasticType(req.Reason) == ctm.NewNumber {
-		if err = s.processNewCard(ctx, id.OcvID, accounts, oldCard, newCard); err != nil {
-			return nil, err
-		}
+	if err != nil {
+		logf.Error(ctx, err, "new card has been created but replacement %s is parked at %s", run.ID, run.StepName)
+		return nil, err
 	}
 
 	return &cpb.ReplaceResponse{
-		NewTokenizedCardNumber: newCard.CardNumber.Token,
-		Eligibilities:          newCard.Eligibility(),
+		NewTokenizedCardNumber: data.NewCard.CardNumber.Token,
+		Eligibilities:          data.NewCard.Eligibility(),
 	}, nil
 }
 
-func (s server) processNewCard(ctx context.Context, ocvID string, accounts []*ocv.RetrievePartyRsAccount, oldCard, newCard *ctm.DebitCardResponse) error {
-	g, gctx := errgroup.WithContext(ctx)
-	for _, account := range accounts {
-		a := account
-		g.Go(func() error {
-			if ok, err := s.OCV.AccountMaintenance(gctx, ocvID, oldCard, newCard, a); !ok {
-				return serviceErr(err, replacementFailed)
-			}
-			return nil
-		})
-	}
-	g.Go(func() error {
-		if err := s.Entitlements.Register(gctx, newCard.CardNumber.Token); err != nil {
-			return serviceErr(err, replacementFailed)
-		}
-		return nil
-	})
-
-	if err := g.Wait(); err != nil {
-		return err
-	}
-
-	if err := s.Entitlements.Latest(ctx); err != nil {
-		return serviceErr(err, replacementFailed)
-	}
-
-	if oldCard.CardControlPreference {
-		if err := s.CardControls.TransferControls(ctx, oldCard.CardNumber.Token, newCard.CardNumber.Token); err != nil {
-			logf.Error(ctx, err, "new card has been created but failed to transfer controls")
-			return serviceErr(err, replacementFailed)
-		}
-	}
-
-	return nil
-}
-
 func (s server) preamble(ctx context.Context, entitledCard *entpb.EntitledCard, reason cpb.ReplaceRequest_Reason, ocvID string) (*ctm.DebitCardResponse, []*ocv.RetrievePartyRsAccount, *selfservice.Party, error) {
 	var (
 		parties []*ocv.RetrievePartyRs
@@ -177,6 +157,10 @@ func (s server) preamble(ctx context.Context, entitledCard *entpb.EntitledCard,
 		if err != nil {
 			return serviceErr(err, replacementFailed)
 		}
+		// the card has just been inquired so eligibility is evaluated on it rather than inquiring it again
+		if err = s.Eligibility.CanCard(gctx, getEligibility(reason), oldCard); err != nil {
+			return serviceErr(err, replacementFailed)
+		}
 		return nil
 	})
 
@@ -218,83 +202,28 @@ func (s server) preamble(ctx context.Context, entitledCard *entpb.EntitledCard,
 	return oldCard, accounts, party, nil
 }
 
-func (s server) requestReplacementCard(ctx context.Context, currentCard *ctm.DebitCardResponse, reason cpb.ReplaceRequest_Reason, party *selfservice.Party) (*ctm.DebitCardResponse, *ctm.DebitCardResponse, error) {
-	// if a card is already replaced, skip the part for getting a new card and just try to link the card to customer/account
-	var newTokenizedCardNumber string
-	switch {
-	case currentCard.NewCardNumber != nil:
-		newTokenizedCardNumber = currentCard.NewCardNumber.Token
-	default:
-		var err error
-		newTokenizedCardNumber, err = s.replaceCard(ctx, currentCard, reason, party)
-		if err != nil {
-			return nil, nil, err
-		}
-	}
-
-	var oldCard, newCard *ctm.DebitCardResponse
-
-	g, gctx := errgroup.WithContext(ctx)
-
-	g.Go(func() (err error) {
-		newCard, err = s.CTM.DebitCardInquiry(gctx, newTokenizedCardNumber)
-		if err != nil {
-			return serviceErr(err, replacementFailed)
-		}
-		return nil
-	})
-
-	g.Go(func() (err error) {
-		oldCard, err = s.CTM.DebitCardInquiry(gctx, currentCard.CardNumber.Token)
-		if err != nil {
-			return serviceErr(err, replacementFailed)
-		}
-		return nil
-	})
-
-	if err := g.Wait(); err != nil {
-		return nil, nil, err
-	}
-
-	return newCard, oldCard, nil
-}
-
-func (s server) replaceCard(ctx context.Context, currentCard *ctm.DebitCardResponse, reason cpb.ReplaceRequest_Reason, party *selfservice.Party) (string, error) {
-	address, err := party.GetAddress(ctx)
+// replaceCardRequest builds the request for a new card from the customer's party and requested delivery
+func replaceCardRequest(ctx context.Context, currentCard *ctm.DebitCardResponse, reason cpb.ReplaceRequest_Reason, party *selfservice.Party, delivery delivery) (*ctm.ReplaceCardRequest, error) {
+	mailingAddress, err := delivery.mailingAddress(ctx, party)
 	if err != nil {
-		return "", serviceErr(err, replacementFailed)
-	}
-
-	mailingAddress, err := ctm.GetAddress(ctx, address)
-	if err != nil {
-		return "", err
-	}
-
-	if err = s.updateStatus(ctx, currentCard, reason); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	firstName, lastName := toCTMName(party.LegalName.FirstName, party.LegalName.LastName)
 
 	embossedName := toEmbossedName(firstName, lastName)
 
-	request := &ctm.ReplaceCardRequest{
+	return &ctm.ReplaceCardRequest{
 		PlasticType:              plasticType(reason),
 		FirstName:                firstName,
 		LastName:                 lastName,
 		EmbossingLine1:           embossedName,
-		DispatchedMethod:         ctm.DispatchedMethodMail,
+		DispatchedMethod:         delivery.method,
+		CollectionBranch:         delivery.branch,
 		DesignCode:               currentCard.DesignCode,
 		MerchantUpdatePreference: currentCard.MerchantUpdatePreference,
 		MailingAddress:           mailingAddress,
-	}
-
-	newTokenizedCardNumber, err := s.CTM.ReplaceCard(ctx, request, currentCard.CardNumber.Token)
-	if err != nil {
-		return "", serviceErr(err, replacementFailed)
-	}
-
-	return newTokenizedCardNumber, nil
+	}, nil
 }
 
 func (s server) updateStatus(ctx context.Context, currentCard *ctm.DebitCardResponse, reason cpb.ReplaceRequest_Reason) error {
@@ -459,7 +388,12 @@ func toFirstInitial(str string) string {
 	return ""
 }
 
-func (s server) publishNotification(ctx context.Context, personaID string) {
+func (s server) publishNotification(ctx context.Context, personaID string, delivery delivery) {
+	body := "We've cancelled your current card. Your new one should arrive in 5 to 10 days."
+	if delivery.method == ctm.DispatchedMethodBranch {
+		body = fmt.Sprintf("We've cancelled your current card. Your new one will be sent to branch %s for you to collect.", delivery.branch)
+	}
+
 	notify := &sdk.NotificationForPersona{
 		PersonaID: personaID,
 		Notification: notification.Simple{
@@ -467,7 +401,7 @@ func (s server) publishNotification(ctx context.Context, personaID string) {
 		},
 		Preview: notification.Preview{
 			Title: "Card Ordered",
-			Body:  "We've cancelled your current card. Your new one should arrive in 5 to 10 days.",
+			Body:  body,
 		},
 		IdempotencyKey: uuid.NewString(),
 	}
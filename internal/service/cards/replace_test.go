@@ -124,6 +124,8 @@ func TestReplaceCard(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_BLOCK,
 					epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 					epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+					epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 				NewTokenizedCardNumber: data.AUserWithACard().Token(),
 			},
@@ -165,6 +167,8 @@ func TestReplaceCard(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_BLOCK,
 					epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 					epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+					epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 				NewTokenizedCardNumber: data.AUserWithACard().Token(),
 			},
@@ -189,6 +193,8 @@ func TestReplaceCard(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_BLOCK,
 					epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 					epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+					epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 				NewTokenizedCardNumber: data.AUserWithACard().Token(),
 			},
@@ -243,6 +249,8 @@ func TestReplaceCard(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_BLOCK,
 					epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 					epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+					epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 			},
 		},
@@ -281,6 +289,8 @@ func TestReplaceCard(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_BLOCK,
 					epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 					epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+					epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 			},
 		},
@@ -363,6 +373,8 @@ func TestReplaceCard(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_BLOCK,
 					epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 					epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+					epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 				NewTokenizedCardNumber: data.AUserWithACard().Token(),
 			},
@@ -410,6 +422,126 @@ func TestReplaceCard(t *testing.T) {
 	}
 }
 
+func TestReplaceCardDelivery(t *testing.T) {
+	noAddress := &sspb.GetPartyResponse{
+		LegalName: &sspb.Name{FirstName: "Oprah", LastName: "Winfrey"},
+	}
+	tests := []struct {
+		name             string
+		party            *sspb.GetPartyResponse
+		collectionBranch string
+		mailingAddress   *sspb.Address
+		want             *ctm.ReplaceCardRequest
+		wantErr          error
+	}{
+		{
+			name: "mailed to the address on the customer profile",
+			want: &ctm.ReplaceCardRequest{
+				DispatchedMethod: ctm.DispatchedMethodMail,
+				MailingAddress: ctm.MailingAddress{
+					AddressLine1: "Mailroom",
+					AddressLine2: "833 Collins Street",
+					AddressLine3: "Docklands VIC 3008",
+					PostCode:     "3008",
+				},
+			},
+		},
+		{
+			name:             "collected from a branch",
+			collectionBranch: "3008",
+			want: &ctm.ReplaceCardRequest{
+				DispatchedMethod: ctm.DispatchedMethodBranch,
+				CollectionBranch: "3008",
+				MailingAddress: ctm.MailingAddress{
+					AddressLine1: "Mailroom",
+					AddressLine2: "833 Collins Street",
+					AddressLine3: "Docklands VIC 3008",
+					PostCode:     "3008",
+				},
+			},
+		},
+		{
+			name:             "collected from a branch without an address on the customer profile",
+			party:            noAddress,
+			collectionBranch: "3008",
+			want: &ctm.ReplaceCardRequest{
+				DispatchedMethod: ctm.DispatchedMethodBranch,
+				CollectionBranch: "3008",
+			},
+		},
+		{
+			name:           "mailed to a one-off address",
+			party:          noAddress,
+			mailingAddress: &sspb.Address{LineOne: "15 station street", City: "Reservoir", State: "AU-VI", PostalCode: "3073", Country: "AUS"},
+			want: &ctm.ReplaceCardRequest{
+				DispatchedMethod: ctm.DispatchedMethodMail,
+				MailingAddress: ctm.MailingAddress{
+					AddressLine1: "15 station street",
+					AddressLine2: "Reservoir VIC 3073",
+					PostCode:     "3073",
+				},
+			},
+		},
+		{
+			name:             "invalid collection branch",
+			collectionBranch: "30A8",
+			wantErr:          errors.New("message=replacement failed, reason=invalid collection branch"),
+		},
+		{
+			name:           "international one-off address",
+			mailingAddress: &sspb.Address{LineOne: "213 Derrick Street", City: "Boston", PostalCode: "02130", Country: "USA"},
+			wantErr:        errors.New("status_code=InvalidArgument, error_code=20003, message=replacement failed, reason=requested address is international"),
+		},
+		{
+			name:           "incomplete one-off address",
+			mailingAddress: &sspb.Address{LineOne: "15 station street", Country: "AUS"},
+			wantErr:        errors.New("message=replacement failed, reason=requested address is incomplete"),
+		},
+		{
+			name:             "branch and one-off address",
+			collectionBranch: "3008",
+			mailingAddress:   &sspb.Address{LineOne: "15 station street", City: "Reservoir", State: "AU-VI", PostalCode: "3073", Country: "AUS"},
+			wantErr:          errors.New("message=replacement failed, reason=collection branch and mailing address both requested"),
+		},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			require.NoError(t, feature.FeatureGate.Set(map[feature.Feature]bool{
+				feature.REASON_DAMAGED: true,
+			}))
+			user := data.AUserWithACard()
+			builder := fixtures.AServer().WithData(user)
+			if test.party != nil {
+				builder = builder.WithSelfServiceResponse(test.party)
+			}
+			ctx, _ := fixtures.GetTestContextWithLogger(nil)
+			s := buildCardServer(builder)
+
+			_, err := s.Replace(ctx, &cpb.ReplaceRequest{
+				TokenizedCardNumber: user.Token(),
+				Reason:              cpb.ReplaceRequest_REASON_DAMAGED,
+				CollectionBranch:    test.collectionBranch,
+				MailingAddress:      test.mailingAddress,
+			})
+
+			got := user.GetCard(user.Token()).Replacement
+			if test.wantErr != nil {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.wantErr.Error())
+				assert.Nil(t, got)
+				assert.Equal(t, ctm.StatusIssued, user.GetCard(user.Token()).Status)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, got)
+			assert.Equal(t, test.want.DispatchedMethod, got.DispatchedMethod)
+			assert.Equal(t, test.want.CollectionBranch, got.CollectionBranch)
+			assert.Equal(t, test.want.MailingAddress, got.MailingAddress)
+		})
+	}
+}
+
 func TestServer_ReplaceFeatureToggle(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -444,6 +576,8 @@ func TestServer_ReplaceFeatureToggle(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_BLOCK,
 					epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 					epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+					epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 			},
 		},
@@ -504,6 +638,38 @@ func TestReplaceCardAuditLog(t *testing.T) {
 		assert.Equal(t, "MR NATHAN FUKUSHIMA MR NATHAN FUKUSHIMA", sd.GetOldNameOnInstrument())
 		assert.Equal(t, "MR NATHAN FUKUSHIMA MR NATHAN FUKUSHIMA", sd.GetNewNameOnInstrument())
 		assert.Equal(t, data.AUserWithACard().CardNumber()[12:], sd.GetLast_4Digits())
+		assert.Equal(t, "Mail", sd.GetDispatchMethod())
+		assert.Empty(t, sd.GetCollectionBranch())
+		assert.False(t, sd.GetAlternateMailingAddress())
+	})
+
+	t.Run("audit log records the chosen delivery", func(t *testing.T) {
+		require.NoError(t, feature.FeatureGate.Set(map[feature.Feature]bool{
+			feature.REASON_DAMAGED: true,
+		}))
+
+		sd := servicedata.ReplaceCard{}
+
+		hook := func(buf []byte) {
+			p := &audit.AuditLog{}
+			_ = protojson.Unmarshal(buf, p)
+			_ = p.GetServiceData()[0].UnmarshalTo(&sd)
+		}
+		builder := fixtures.AServer().WithData(data.AUserWithACard()).WithAuditLogHook(hook)
+		request := &cpb.ReplaceRequest{
+			TokenizedCardNumber: data.AUserWithACard().Token(),
+			Reason:              cpb.ReplaceRequest_REASON_DAMAGED,
+			CollectionBranch:    "3008",
+		}
+		ctx, _ := fixtures.GetTestContextWithLogger(nil)
+		s := buildCardServer(builder)
+
+		_, err := s.Replace(ctx, request)
+		require.NoError(t, err)
+
+		assert.Equal(t, "Sent to Branch", sd.GetDispatchMethod())
+		assert.Equal(t, "3008", sd.GetCollectionBranch())
+		assert.False(t, sd.GetAlternateMailingAddress())
 	})
 }
 
@@ -735,10 +901,20 @@ func Test_server_publishNotification(t *testing.T) {
 	tests := []struct {
 		name      string
 		personaID string
+		delivery  delivery
+		body      string
 	}{
 		{
 			name:      "Happy flow",
 			personaID: "1234",
+			delivery:  delivery{method: ctm.DispatchedMethodMail},
+			body:      "We've cancelled your current card. Your new one should arrive in 5 to 10 days.",
+		},
+		{
+			name:      "Collected from a branch",
+			personaID: "1234",
+			delivery:  delivery{method: ctm.DispatchedMethodBranch, branch: "3008"},
+			body:      "We've cancelled your current card. Your new one will be sent to branch 3008 for you to collect.",
 		},
 	}
 	for _, tt := range tests {
@@ -753,7 +929,7 @@ func Test_server_publishNotification(t *testing.T) {
 				},
 				Preview: notification.Preview{
 					Title: "Card Ordered",
-					Body:  "We've cancelled your current card. Your new one should arrive in 5 to 10 days.",
+					Body:  tt.body,
 				},
 			}
 			cc.EXPECT().Publish(gomock.Any(), &matchers.NotificationMatcher{Notification: notificationToMatch}).Times(1).Return(&sdk.PublishResponse{
@@ -762,7 +938,7 @@ func Test_server_publishNotification(t *testing.T) {
 			s := &server{
 				Fabric: Fabric{CommandCentre: &commandcentre.Client{Publisher: cc}},
 			}
-			s.publishNotification(context.Background(), tt.personaID)
+			s.publishNotification(context.Background(), tt.personaID, tt.delivery)
 		})
 	}
 }
@@ -0,0 +1,243 @@
+package cards
+
+import (
+	"context"
+	"fmt"
+
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+	"github.com/anzx/pkg/jwtauth"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/anzx/fabric-cards/pkg/feature"
+	"github.com/anzx/fabric-cards/pkg/integration/ctm"
+	"github.com/anzx/fabric-cards/pkg/integration/ocv"
+	"github.com/anzx/fabric-cards/pkg/saga"
+	cpb "github.com/anzx/fabricapis/pkg/fabric/service/card/v1beta1"
+)
+
+const replacementSaga = "replace-card"
+
+// replacement is the state of a card replacement saga. It is persisted after every step so a replacement that failed
+// after CTM issued the new card can be resumed by any instance.
+type replacement struct {
+	PersonaID string                    `json:"personaId"`
+	OcvID     string                    `json:"ocvId"`
+	Reason    cpb.ReplaceRequest_Reason `json:"reason"`
+	OldCard   *ctm.DebitCardResponse    `json:"oldCard"`
+	NewCard   *ctm.DebitCardResponse    `json:"newCard,omitempty"`
+	// Request is sent to CTM for a new card, nil if the card had already been replaced
+	Request  *ctm.ReplaceCardRequest       `json:"request,omitempty"`
+	Accounts []*ocv.RetrievePartyRsAccount `json:"accounts"`
+
+	// inquired is set while OldCard is the inquiry made by the request. It is not persisted so a resumed replacement
+	// inquires the card again before asking CTM for a new one.
+	inquired bool
+}
+
+// replacementSaga replaces a card in CTM, then links the new card to the customer's accounts and entitlements and
+// transfers its controls. Only the claim on the card can be undone, a card reported lost or stolen stays blocked even
+// if a new card could not be issued. Once CTM has issued the new card the remaining steps are retried until they
+// succeed. The mailing address and names are not persisted once the new card is issued.
+func (s server) replacementSaga() saga.Definition {
+	return saga.Definition{
+		Kind: replacementSaga,
+		Steps: []saga.Step{
+			{Name: "claim", Do: s.claimCard, Compensate: s.unclaimCard},
+			{Name: "status", Do: s.blockOldCard},
+			{Name: "replace", Do: s.issueNewCard, Pivot: true},
+			{Name: "inquire", Do: s.inquireCards},
+			{Name: "link", Do: s.linkNewCard},
+			{Name: "latest", Do: s.latestEntitlements},
+			{Name: "controls", Do: s.transferControls},
+			{Name: "unclaim", Do: s.unclaimCard},
+		},
+		NewData: func() interface{} {
+			return &replacement{}
+		},
+		Context: s.replacementContext,
+		Redact:  redactReplacement,
+	}
+}
+
+// claimCard stops a second replacement of the card starting while this one is running
+func (s server) claimCard(ctx context.Context, run *saga.Saga, data interface{}) error {
+	r := data.(*replacement)
+	ok, err := s.Sagas.Claim(ctx, claimKey(r), run)
+	if err != nil {
+		return anzerrors.Wrap(err, codes.Unavailable, replacementFailed,
+			anzerrors.NewErrorInfo(ctx, anzcodes.DownstreamFailure, "unable to claim card"))
+	}
+	if !ok {
+		return anzerrors.New(codes.FailedPrecondition, replacementFailed,
+			anzerrors.NewErrorInfo(ctx, anzcodes.ValidationFailure, "card replacement already in progress"))
+	}
+	return nil
+}
+
+func (s server) unclaimCard(ctx context.Context, run *saga.Saga, data interface{}) error {
+	if err := s.Sagas.Unclaim(ctx, claimKey(data.(*replacement)), run); err != nil {
+		return anzerrors.Wrap(err, codes.Unavailable, replacementFailed,
+			anzerrors.NewErrorInfo(ctx, anzcodes.DownstreamFailure, "unable to release card"))
+	}
+	return nil
+}
+
+func (s server) blockOldCard(ctx context.Context, _ *saga.Saga, data interface{}) error {
+	r := data.(*replacement)
+	if r.Request == nil {
+		return nil
+	}
+	return s.updateStatus(ctx, r.OldCard, r.Reason)
+}
+
+// issueNewCard asks CTM for the new card. The card is inquired again unless this is the first attempt of the request
+// so a card CTM replaced before a failure or restart is not replaced twice.
+func (s server) issueNewCard(ctx context.Context, _ *saga.Saga, data interface{}) error {
+	r := data.(*replacement)
+	if !r.inquired {
+		card, err := s.CTM.DebitCardInquiry(ctx, r.OldCard.CardNumber.Token)
+		if err != nil {
+			return serviceErr(err, replacementFailed)
+		}
+		r.OldCard = card
+	}
+	r.inquired = false
+
+	if r.OldCard.NewCardNumber != nil || r.Request == nil {
+		return nil
+	}
+
+	newTokenizedCardNumber, err := s.CTM.ReplaceCard(ctx, r.Request, r.OldCard.CardNumber.Token)
+	if err != nil {
+		return serviceErr(err, replacementFailed)
+	}
+	r.OldCard.NewCardNumber = &ctm.CardNumber{Token: newTokenizedCardNumber}
+	return nil
+}
+
+func (s server) inquireCards(ctx context.Context, _ *saga.Saga, data interface{}) error {
+	r := data.(*replacement)
+	if r.OldCard.NewCardNumber == nil {
+		return anzerrors.New(codes.Internal, replacementFailed,
+			anzerrors.NewErrorInfo(ctx, anzcodes.DownstreamFailure, "replaced card has no new card number"))
+	}
+
+	var oldCard, newCard *ctm.DebitCardResponse
+
+	g, gctx := errgroup.WithContext(ctx)
+
+	g.Go(func() (err error) {
+		newCard, err = s.CTM.DebitCardInquiry(gctx, r.OldCard.NewCardNumber.Token)
+		if err != nil {
+			return serviceErr(err, replacementFailed)
+		}
+		return nil
+	})
+
+	g.Go(func() (err error) {
+		oldCard, err = s.CTM.DebitCardInquiry(gctx, r.OldCard.CardNumber.Token)
+		if err != nil {
+			return serviceErr(err, replacementFailed)
+		}
+		return nil
+	})
+
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	r.OldCard, r.NewCard = oldCard, newCard
+	return nil
+}
+
+// linkNewCard adds a new card number to the customer's accounts and entitlements
+func (s server) linkNewCard(ctx context.Context, _ *saga.Saga, data interface{}) error {
+	r := data.(*replacement)
+	if plasticType(r.Reason) != ctm.NewNumber {
+		return nil
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	for _, account := range r.Accounts {
+		a := account
+		g.Go(func() error {
+			if ok, err := s.OCV.AccountMaintenance(gctx, r.OcvID, r.OldCard, r.NewCard, a); !ok {
+				return serviceErr(err, replacementFailed)
+			}
+			return nil
+		})
+	}
+	g.Go(func() error {
+		if err := s.Entitlements.Register(gctx, r.NewCard.CardNumber.Token); err != nil {
+			return serviceErr(err, replacementFailed)
+		}
+		return nil
+	})
+
+	return g.Wait()
+}
+
+func (s server) latestEntitlements(ctx context.Context, _ *saga.Saga, data interface{}) error {
+	if plasticType(data.(*replacement).Reason) != ctm.NewNumber {
+		return nil
+	}
+	if err := s.Entitlements.Latest(ctx); err != nil {
+		return serviceErr(err, replacementFailed)
+	}
+	return nil
+}
+
+func (s server) transferControls(ctx context.Context, _ *saga.Saga, data interface{}) error {
+	r := data.(*replacement)
+	if plasticType(r.Reason) != ctm.NewNumber || !r.OldCard.CardControlPreference {
+		return nil
+	}
+	if err := s.CardControls.TransferControls(ctx, r.OldCard.CardNumber.Token, r.NewCard.CardNumber.Token); err != nil {
+		return serviceErr(err, replacementFailed)
+	}
+	return nil
+}
+
+// replacementContext acts as the customer a replacement is resumed for, with a system JWT for downstream calls
+func (s server) replacementContext(ctx context.Context, data interface{}) (context.Context, error) {
+	r := data.(*replacement)
+
+	if feature.FeatureGate.Enabled(feature.FORGEROCK_SYSTEM_LOGIN) {
+		systemCtx, err := s.Forgerock.SystemJWT(ctx, s.Sagas.Scopes()...)
+		if err != nil {
+			return ctx, err
+		}
+		ctx = systemCtx
+	}
+
+	return jwtauth.AddClaimsToContext(ctx, jwtauth.NewClaims(jwtauth.BaseClaims{
+		Claims:  jwt.Claims{Subject: r.PersonaID},
+		Persona: &jwtauth.Persona{PersonaID: r.PersonaID},
+		OCVID:   r.OcvID,
+	})), nil
+}
+
+// redactReplacement drops the mailing address and names CTM needed to issue the new card, no later step uses them
+func redactReplacement(data interface{}) interface{} {
+	r := *data.(*replacement)
+	r.Request = nil
+	r.OldCard, r.NewCard = withoutCardholder(r.OldCard), withoutCardholder(r.NewCard)
+	return &r
+}
+
+func withoutCardholder(card *ctm.DebitCardResponse) *ctm.DebitCardResponse {
+	if card == nil {
+		return nil
+	}
+	c := *card
+	c.Title, c.FirstName, c.LastName = "", "", ""
+	c.EmbossingLine1, c.EmbossingLine2 = "", ""
+	return &c
+}
+
+func claimKey(r *replacement) string {
+	return fmt.Sprintf("replace:%s", r.OldCard.CardNumber.Token)
+}
@@ -0,0 +1,83 @@
+package cards
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+
+	"github.com/anzx/fabric-cards/pkg/feature"
+	"github.com/anzx/fabric-cards/pkg/saga"
+	"github.com/anzx/fabric-cards/test/data"
+	"github.com/anzx/fabric-cards/test/fixtures"
+	cpb "github.com/anzx/fabricapis/pkg/fabric/service/card/v1beta1"
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+)
+
+func TestReplaceCardSaga(t *testing.T) {
+	require.NoError(t, feature.FeatureGate.Set(map[feature.Feature]bool{
+		feature.REASON_LOST: true,
+	}))
+
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	defer s.Close()
+
+	runner := saga.NewRunner(context.Background(), &saga.Config{
+		Prefix:   "test:",
+		Attempts: 1,
+		Backoff:  time.Millisecond,
+	}, redis.NewClient(&redis.Options{Addr: s.Addr()}))
+
+	user := data.AUserWithACard(data.WithControls(data.CardControlsPresetGlobalControls))
+	req := &cpb.ReplaceRequest{
+		TokenizedCardNumber: user.Token(),
+		Reason:              cpb.ReplaceRequest_REASON_LOST,
+	}
+
+	ctx, _ := fixtures.GetTestContextWithLogger(nil)
+	failing := buildCardServer(fixtures.AServer().WithData(user).WithSagas(runner).
+		WithCardControlsTransferControlsError(anzerrors.New(codes.Unavailable, "failed request",
+			anzerrors.NewErrorInfo(context.Background(), anzcodes.DownstreamFailure, "service unavailable"))))
+
+	_, err = failing.Replace(ctx, req)
+	require.Error(t, err)
+
+	active, err := runner.Active(context.Background())
+	require.NoError(t, err)
+	require.Len(t, active, 1, "a replacement that failed after the new card was issued is kept")
+	parked := active[0]
+	assert.Equal(t, replacementSaga, parked.Kind)
+	assert.Equal(t, saga.StatusRunning, parked.Status)
+	assert.Equal(t, "controls", parked.StepName)
+	assert.True(t, parked.Stuck)
+
+	// the mailing address and names are only kept until the new card is issued
+	var persisted replacement
+	require.NoError(t, json.Unmarshal(parked.Data, &persisted))
+	assert.Nil(t, persisted.Request)
+	assert.Empty(t, persisted.OldCard.FirstName)
+	assert.Empty(t, persisted.OldCard.EmbossingLine1)
+	assert.Empty(t, persisted.NewCard.LastName)
+	assert.NotEmpty(t, persisted.NewCard.CardNumber.Token)
+
+	// another instance, where card controls have recovered, re-drives the replacement
+	buildCardServer(fixtures.AServer().WithData(user).WithSagas(runner))
+
+	redriven, err := runner.Redrive(context.Background(), parked.ID)
+	require.NoError(t, err)
+	assert.Equal(t, saga.StatusCompleted, redriven.Status)
+	assert.False(t, redriven.Stuck)
+
+	active, err = runner.Active(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, active)
+	assert.False(t, s.Exists("test:saga:claim:replace:"+user.Token()), "the card is released once replaced")
+}
@@ -137,6 +137,8 @@ func TestServer_ResetPIN(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_BLOCK,
 					epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 					epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+					epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 			},
 		},
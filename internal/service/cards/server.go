@@ -14,6 +14,7 @@ import (
 	"github.com/anzx/fabric-cards/pkg/integration/vault"
 	"github.com/anzx/fabric-cards/pkg/integration/visagateway/dcvv2"
 	"github.com/anzx/fabric-cards/pkg/ratelimit"
+	"github.com/anzx/fabric-cards/pkg/saga"
 
 	cpb "github.com/anzx/fabricapis/pkg/fabric/service/card/v1beta1"
 )
@@ -36,6 +37,10 @@ type Fabric struct {
 
 type Internal struct {
 	RateLimit ratelimit.RateLimit
+	// DailyLimits bounds the daily limits a customer may set, limits can't be changed if not set
+	DailyLimits *ctm.LimitsConfig
+	// Sagas persists replacements so they can be resumed, replacements are not persisted if nil
+	Sagas *saga.Runner
 }
 
 type External struct {
@@ -49,5 +54,7 @@ type External struct {
 
 // NewServer constructs a new CustomerRulesAPI from configured clients
 func NewServer(fabric Fabric, internal Internal, external External) cpb.CardAPIServer {
-	return &server{Fabric: fabric, Internal: internal, External: external}
+	srv := &server{Fabric: fabric, Internal: internal, External: external}
+	internal.Sagas.Register(srv.replacementSaga())
+	return srv
 }
@@ -154,6 +154,8 @@ func TestServer_SetPIN(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_BLOCK,
 					epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 					epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+					epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 			},
 		},
@@ -0,0 +1,95 @@
+package cards
+
+import (
+	"context"
+
+	"github.com/anzx/fabric-commandcentre-sdk/pkg/sdk/event"
+	"github.com/anzx/pkg/auditlog"
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+	"google.golang.org/grpc/codes"
+
+	"github.com/anzx/fabric-cards/pkg/integration/ctm"
+	"github.com/anzx/fabric-cards/pkg/integration/entitlements"
+	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
+
+	cpb "github.com/anzx/fabricapis/pkg/fabric/service/card/v1beta1"
+	epb "github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1"
+	"github.com/anzx/fabricapis/pkg/fabric/type/audit/servicedata"
+)
+
+const updateStatusFailed = "update status failed"
+
+// UpdateStatus reports a card lost or stolen without ordering a replacement, or closes it. The card must have
+// ELIGIBILITY_UPDATE_STATUS and the change must be allowed from its current status by ctm.Status.ValidNextState.
+func (s server) UpdateStatus(ctx context.Context, req *cpb.UpdateStatusRequest) (retResponse *cpb.UpdateStatusResponse, retError error) {
+	next := requestedStatus(req.GetStatus())
+	serviceData := &servicedata.UpdateCardStatus{
+		TokenizedCardNumber: req.GetTokenizedCardNumber(),
+		NewStatus:           next.String(),
+	}
+
+	defer func() {
+		if err := serviceData.Validate(); err != nil {
+			logf.Error(ctx, err, "invalid service data payload")
+		}
+		s.AuditLog.Publish(ctx, auditlog.EventUpdateCardStatus, retResponse, retError, serviceData)
+	}()
+
+	if next == "" {
+		return nil, anzerrors.New(codes.InvalidArgument, updateStatusFailed,
+			anzerrors.NewErrorInfo(ctx, anzcodes.ValidationFailure, "status not supported"))
+	}
+
+	entitledCard, err := s.Entitlements.GetEntitledCard(ctx, req.TokenizedCardNumber, entitlements.OPERATION_MANAGE_CARD)
+	if err != nil {
+		return nil, serviceErr(err, updateStatusFailed)
+	}
+	serviceData.AccountNumbers = entitledCard.GetAccountNumbers()
+
+	card, err := s.CTM.DebitCardInquiry(ctx, req.TokenizedCardNumber)
+	if err != nil {
+		return nil, serviceErr(err, updateStatusFailed)
+	}
+	serviceData.OldStatus = card.Status.String()
+
+	// a repeated request is treated as a success so it can be retried safely
+	if card.Status == next {
+		return &cpb.UpdateStatusResponse{Eligibilities: card.Eligibility()}, nil
+	}
+
+	if err := s.Eligibility.CanCard(ctx, epb.Eligibility_ELIGIBILITY_UPDATE_STATUS, card); err != nil {
+		return nil, anzerrors.Wrap(err, codes.PermissionDenied, updateStatusFailed, anzerrors.GetErrorInfo(err))
+	}
+
+	if !card.CanUpdateStatus(next) {
+		return nil, anzerrors.New(codes.PermissionDenied, updateStatusFailed,
+			anzerrors.NewErrorInfo(ctx, anzcodes.CardStatusNotAvailable, "card ineligible for status change"))
+	}
+
+	if ok, err := s.CTM.UpdateStatus(ctx, req.TokenizedCardNumber, next); !ok {
+		return nil, serviceErr(err, updateStatusFailed)
+	}
+
+	s.CommandCentre.PublishEventAsync(ctx, event.CardStatusChange)
+
+	card, err = s.CTM.DebitCardInquiry(ctx, req.TokenizedCardNumber)
+	if err != nil {
+		return &cpb.UpdateStatusResponse{}, nil
+	}
+
+	return &cpb.UpdateStatusResponse{Eligibilities: card.Eligibility()}, nil
+}
+
+func requestedStatus(in cpb.UpdateStatusRequest_Status) ctm.Status {
+	switch in {
+	case cpb.UpdateStatusRequest_STATUS_LOST:
+		return ctm.StatusLost
+	case cpb.UpdateStatusRequest_STATUS_STOLEN:
+		return ctm.StatusStolen
+	case cpb.UpdateStatusRequest_STATUS_CLOSED:
+		return ctm.StatusClosed
+	default:
+		return ""
+	}
+}
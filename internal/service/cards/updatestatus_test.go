@@ -0,0 +1,184 @@
+package cards
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/anzx/fabric-cards/pkg/integration/ctm"
+	"github.com/anzx/fabric-cards/test/data"
+	"github.com/anzx/fabric-cards/test/fixtures"
+	"github.com/anzx/fabric-cards/test/util"
+
+	cpb "github.com/anzx/fabricapis/pkg/fabric/service/card/v1beta1"
+	epb "github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1"
+	"github.com/anzx/fabricapis/pkg/fabric/type/audit"
+	"github.com/anzx/fabricapis/pkg/fabric/type/audit/servicedata"
+)
+
+func TestUpdateStatus(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		builder    *fixtures.ServerBuilder
+		request    *cpb.UpdateStatusRequest
+		want       *cpb.UpdateStatusResponse
+		wantErr    error
+		wantStatus ctm.Status
+	}{
+		{
+			name:    "report an issued card lost",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()),
+			request: &cpb.UpdateStatusRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				Status:              cpb.UpdateStatusRequest_STATUS_LOST,
+			},
+			want: &cpb.UpdateStatusResponse{
+				Eligibilities: []epb.Eligibility{epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_LOST},
+			},
+			wantStatus: ctm.StatusLost,
+		},
+		{
+			name:    "report a temporarily blocked card stolen",
+			builder: fixtures.AServer().WithData(data.AUserWithACard(data.WithStatus(ctm.StatusTemporaryBlock))),
+			request: &cpb.UpdateStatusRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				Status:              cpb.UpdateStatusRequest_STATUS_STOLEN,
+			},
+			want: &cpb.UpdateStatusResponse{
+				Eligibilities: []epb.Eligibility{epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_STOLEN},
+			},
+			wantStatus: ctm.StatusStolen,
+		},
+		{
+			name:    "close a card",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()),
+			request: &cpb.UpdateStatusRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				Status:              cpb.UpdateStatusRequest_STATUS_CLOSED,
+			},
+			want:       &cpb.UpdateStatusResponse{},
+			wantStatus: ctm.StatusClosed,
+		},
+		{
+			name:    "card already lost",
+			builder: fixtures.AServer().WithData(data.AUserWithACard(data.WithStatus(ctm.StatusLost))).WithCtmUpdateError(errors.New("not called")),
+			request: &cpb.UpdateStatusRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				Status:              cpb.UpdateStatusRequest_STATUS_LOST,
+			},
+			want: &cpb.UpdateStatusResponse{
+				Eligibilities: []epb.Eligibility{epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_LOST},
+			},
+			wantStatus: ctm.StatusLost,
+		},
+		{
+			name:    "stolen card is not eligible",
+			builder: fixtures.AServer().WithData(data.AUserWithACard(data.WithStatus(ctm.StatusStolen))),
+			request: &cpb.UpdateStatusRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				Status:              cpb.UpdateStatusRequest_STATUS_CLOSED,
+			},
+			wantErr:    errors.New("status_code=PermissionDenied, error_code=20002, message=update status failed, reason=card not eligible"),
+			wantStatus: ctm.StatusStolen,
+		},
+		{
+			name:    "card suspected of fraud",
+			builder: fixtures.AServer().WithData(data.AUserWithACard(data.WithStatus(ctm.StatusBlockAtm))),
+			request: &cpb.UpdateStatusRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				Status:              cpb.UpdateStatusRequest_STATUS_LOST,
+			},
+			wantErr:    errors.New("status_code=PermissionDenied"),
+			wantStatus: ctm.StatusBlockAtm,
+		},
+		{
+			name:    "status not supported",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()),
+			request: &cpb.UpdateStatusRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+			},
+			wantErr:    errors.New("message=update status failed, reason=status not supported"),
+			wantStatus: ctm.StatusIssued,
+		},
+		{
+			name: "not entitled",
+			builder: fixtures.AServer().WithData(
+				data.AUser(
+					data.WithAPersonaID("personaID"),
+					data.WithACard(data.WithAToken("token")))),
+			request: &cpb.UpdateStatusRequest{
+				TokenizedCardNumber: "token",
+				Status:              cpb.UpdateStatusRequest_STATUS_LOST,
+			},
+			wantErr: errors.New("fabric error: status_code=PermissionDenied, error_code=2, message=update status failed, reason=user not entitled"),
+		},
+		{
+			name: "CTM fails to update status",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()).WithCtmUpdateError(
+				anzerrors.New(codes.Unavailable, "failed request",
+					anzerrors.NewErrorInfo(context.Background(), anzcodes.DownstreamFailure, "service unavailable"))),
+			request: &cpb.UpdateStatusRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				Status:              cpb.UpdateStatusRequest_STATUS_LOST,
+			},
+			wantErr:    errors.New("fabric error: status_code=Unavailable, error_code=2, message=update status failed, reason=service unavailable"),
+			wantStatus: ctm.StatusIssued,
+		},
+		{
+			name:    "CTM fails inquiry",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()).WithCtmInquiryError(errors.New("oh no")),
+			request: &cpb.UpdateStatusRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				Status:              cpb.UpdateStatusRequest_STATUS_LOST,
+			},
+			wantErr: errors.New("message=update status failed"),
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			ctx, b := fixtures.GetTestContextWithLogger(nil)
+			s := buildCardServer(test.builder)
+
+			got, err := s.UpdateStatus(ctx, test.request)
+			util.CheckTestAndAuditLogs(t, got, test.want, test.wantErr, err, b)
+
+			if test.wantStatus != "" {
+				card, err := test.builder.CTMClient.DebitCardInquiry(ctx, test.request.TokenizedCardNumber)
+				require.NoError(t, err)
+				assert.Equal(t, test.wantStatus, card.Status)
+			}
+		})
+	}
+}
+
+func TestUpdateStatusAuditLog(t *testing.T) {
+	sd := servicedata.UpdateCardStatus{}
+	hook := func(buf []byte) {
+		p := &audit.AuditLog{}
+		_ = protojson.Unmarshal(buf, p)
+		_ = p.GetServiceData()[0].UnmarshalTo(&sd)
+	}
+
+	builder := fixtures.AServer().WithData(data.AUserWithACard()).WithAuditLogHook(hook)
+	ctx, _ := fixtures.GetTestContextWithLogger(nil)
+	s := buildCardServer(builder)
+
+	_, err := s.UpdateStatus(ctx, &cpb.UpdateStatusRequest{
+		TokenizedCardNumber: data.AUserWithACard().Token(),
+		Status:              cpb.UpdateStatusRequest_STATUS_CLOSED,
+	})
+	require.NoError(t, err)
+	require.NoError(t, sd.Validate())
+	assert.Equal(t, data.AUserWithACard().Token(), sd.GetTokenizedCardNumber())
+	assert.Equal(t, ctm.StatusIssued.String(), sd.GetOldStatus())
+	assert.Equal(t, ctm.StatusClosed.String(), sd.GetNewStatus())
+}
@@ -2,22 +2,49 @@ package cards
 
 import (
 	"context"
+	"time"
 
+	"github.com/anzx/fabric-cards/pkg/date"
+	"github.com/anzx/fabric-cards/pkg/integration/ctm"
 	"github.com/anzx/fabric-cards/pkg/integration/echidna"
 	"github.com/anzx/fabric-cards/pkg/integration/entitlements"
+	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
+	"github.com/anzx/fabric-cards/pkg/ratelimit"
 	"github.com/anzx/fabric-commandcentre-sdk/pkg/sdk/event"
 	cpb "github.com/anzx/fabricapis/pkg/fabric/service/card/v1beta1"
 	epb "github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1"
+	"github.com/anzx/pkg/auditlog"
+	anzerrors "github.com/anzx/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
-const verifyPINFailed = "verify PIN failed"
+const (
+	verifyPINFailed = "verify PIN failed"
+
+	// ctmTimeZone is the time zone of the dates recorded by CTM
+	ctmTimeZone = "Australia/Melbourne"
+
+	// EventPINLockout is published when consecutive incorrect PINs lock out PIN verification of a card
+	EventPINLockout auditlog.Event = "PINVerificationLockout"
+)
 
 func (s server) VerifyPIN(ctx context.Context, req *cpb.VerifyPINRequest) (*cpb.VerifyPINResponse, error) {
 	if _, err := s.Entitlements.GetEntitledCard(ctx, req.TokenizedCardNumber, entitlements.OPERATION_MANAGE_CARD); err != nil {
 		return nil, serviceErr(err, verifyPINFailed)
 	}
 
-	if err := s.Eligibility.Can(ctx, epb.Eligibility_ELIGIBILITY_CHANGE_PIN, req.TokenizedCardNumber); err != nil {
+	// the PIN failures counted by CTM must be current
+	card, err := s.CTM.DebitCardInquiry(ctm.Uncached(ctx), req.TokenizedCardNumber)
+	if err != nil {
+		return nil, serviceErr(err, verifyPINFailed)
+	}
+
+	if err := s.Eligibility.CanCard(ctx, epb.Eligibility_ELIGIBILITY_CHANGE_PIN, card); err != nil {
+		return nil, anzerrors.Wrap(err, codes.PermissionDenied, verifyPINFailed, anzerrors.GetErrorInfo(err))
+	}
+
+	if err := s.RateLimit.CheckLockout(ctx, req.TokenizedCardNumber, ctmPINFailures(ctx, card)); err != nil {
 		return nil, serviceErr(err, verifyPINFailed)
 	}
 
@@ -32,10 +59,63 @@ func (s server) VerifyPIN(ctx context.Context, req *cpb.VerifyPINRequest) (*cpb.
 	}
 
 	if err := s.Echidna.VerifyPIN(ctx, request); err != nil {
+		// only an incorrect PIN counts towards a lockout, not an invalid request or a downstream failure
+		if echidna.IsIncorrectPIN(err) {
+			s.recordPINFailure(ctx, req.TokenizedCardNumber)
+		}
 		return nil, serviceErr(err, verifyPINFailed)
 	}
 
+	if err := s.RateLimit.ResetLockout(ctx, req.TokenizedCardNumber); err != nil {
+		logf.Error(ctx, err, "unable to reset PIN lockout")
+	}
+
 	s.CommandCentre.PublishEventAsync(ctx, event.CardStatusChange)
 
 	return &cpb.VerifyPINResponse{}, nil
 }
+
+// ctmPINFailures returns the PIN failures recorded by CTM. CTM only records the date of the last failure, which is
+// taken as the end of that day so a lockout is never shorter than the window after the actual failure. A reset on
+// that day ignores them as they may have occurred before it.
+func ctmPINFailures(ctx context.Context, card *ctm.DebitCardResponse) ratelimit.Failures {
+	if card.PinFailedCount == 0 || card.LastPinFailed == "" {
+		return ratelimit.Failures{}
+	}
+
+	loc, err := time.LoadLocation(ctmTimeZone)
+	if err != nil {
+		logf.Error(ctx, err, "unable to load CTM time zone, using UTC")
+		loc = time.UTC
+	}
+
+	day, err := time.ParseInLocation(string(date.YYYYMMDD), card.LastPinFailed, loc)
+	if err != nil {
+		logf.Error(ctx, err, "unable to parse last PIN failed date %v", card.LastPinFailed)
+		return ratelimit.Failures{}
+	}
+
+	return ratelimit.Failures{Count: card.PinFailedCount, Last: day.AddDate(0, 0, 1), Since: day}
+}
+
+// recordPINFailure counts an incorrect PIN and audits the lockout it starts
+func (s server) recordPINFailure(ctx context.Context, tokenizedCardNumber string) {
+	state, err := s.RateLimit.RecordFailure(ctx, tokenizedCardNumber)
+	if err != nil || !state.Started() {
+		return
+	}
+
+	logf.Info(ctx, "PIN verification locked out for %s after %d failures", state.LockedFor, state.Failures)
+
+	data, err := structpb.NewStruct(map[string]interface{}{
+		"tokenizedCardNumber": tokenizedCardNumber,
+		"failures":            state.Failures,
+		"lockedFor":           state.LockedFor.String(),
+	})
+	if err != nil {
+		logf.Error(ctx, err, "unable to build PIN lockout audit data")
+		return
+	}
+
+	s.AuditLog.Publish(ctx, EventPINLockout, data, nil, data)
+}
@@ -3,6 +3,7 @@ package cards
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/anzx/fabric-cards/test/data"
 	anzerrors "github.com/anzx/pkg/errors"
@@ -10,11 +11,16 @@ import (
 	"google.golang.org/grpc/codes"
 
 	"github.com/anzx/fabric-cards/pkg/integration/ctm"
+	"github.com/anzx/fabric-cards/pkg/ratelimit"
 
 	"github.com/anzx/fabric-cards/test/fixtures"
 	cpb "github.com/anzx/fabricapis/pkg/fabric/service/card/v1beta1"
+	"github.com/anzx/fabricapis/pkg/fabric/type/audit"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 const (
@@ -125,6 +131,16 @@ func TestServer_VerifyPIN(t *testing.T) {
 			},
 			wantErr: errors.New("fabric error: status_code=Unavailable, error_code=2, message=verify PIN failed, reason=Operation failed due to service error"),
 		},
+		{
+			name:      "PIN verification locked out",
+			personaID: data.AUserWithACard().PersonaID,
+			builder:   fixtures.AServer().WithData(data.AUserWithACard()).WithLockoutError(time.Minute),
+			req: &cpb.VerifyPINRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				EncryptedPinBlock:   encryptedVerifyPINBlock,
+			},
+			wantErr: errors.New("message=verify PIN failed, reason=locked out after repeated failures"),
+		},
 		{
 			name:      "EchidnaClient Call succeeds return true",
 			personaID: data.AUserWithACard().PersonaID,
@@ -151,3 +167,81 @@ func TestServer_VerifyPIN(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyPINLockout(t *testing.T) {
+	t.Run("CTM PIN failures are reconciled", func(t *testing.T) {
+		var known ratelimit.Failures
+		builder := fixtures.AServer().WithData(data.AUserWithACard(data.WithPINFailures(3, "2022-10-01")))
+		builder.RateLimit.Failures = &known
+		s := buildCardServer(builder)
+
+		_, err := s.VerifyPIN(fixtures.GetTestContextWithJWT(data.AUserWithACard().PersonaID), &cpb.VerifyPINRequest{
+			TokenizedCardNumber: data.AUserWithACard().Token(),
+			EncryptedPinBlock:   encryptedVerifyPINBlock,
+		})
+		require.NoError(t, err)
+		loc, err := time.LoadLocation("Australia/Melbourne")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), known.Count)
+		assert.True(t, time.Date(2022, 10, 2, 0, 0, 0, 0, loc).Equal(known.Last), "a failure on a date counts until the end of that day")
+		assert.True(t, time.Date(2022, 10, 1, 0, 0, 0, 0, loc).Equal(known.Since), "and may have occurred from the start of it")
+	})
+
+	t.Run("audit log published when incorrect PIN starts a lockout", func(t *testing.T) {
+		sd := structpb.Struct{}
+		hook := func(buf []byte) {
+			p := &audit.AuditLog{}
+			_ = protojson.Unmarshal(buf, p)
+			_ = p.GetServiceData()[0].UnmarshalTo(&sd)
+		}
+		builder := fixtures.AServer().WithData(data.AUserWithACard()).WithEchidnaErrorCode(55).
+			WithLockoutState(ratelimit.LockoutState{Failures: 3, LockedFor: time.Minute}).WithAuditLogHook(hook)
+		s := buildCardServer(builder)
+
+		_, err := s.VerifyPIN(fixtures.GetTestContextWithJWT(data.AUserWithACard().PersonaID), &cpb.VerifyPINRequest{
+			TokenizedCardNumber: data.AUserWithACard().Token(),
+			EncryptedPinBlock:   encryptedVerifyPINBlock,
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Incorrect PIN")
+		assert.Equal(t, data.AUserWithACard().Token(), sd.GetFields()["tokenizedCardNumber"].GetStringValue())
+		assert.Equal(t, float64(3), sd.GetFields()["failures"].GetNumberValue())
+		assert.Equal(t, "1m0s", sd.GetFields()["lockedFor"].GetStringValue())
+	})
+
+	t.Run("no audit log when the request is invalid", func(t *testing.T) {
+		var events []string
+		hook := func(buf []byte) {
+			events = append(events, string(buf))
+		}
+		builder := fixtures.AServer().WithData(data.AUserWithACard()).
+			WithLockoutState(ratelimit.LockoutState{Failures: 3, LockedFor: time.Minute}).WithAuditLogHook(hook)
+		builder.EchidnaClient.Err = anzerrors.New(codes.InvalidArgument, "echidna failed",
+			anzerrors.NewErrorInfo(context.Background(), anzcodes.ValidationFailure, "mandatory request fields not supplied"))
+		s := buildCardServer(builder)
+
+		_, err := s.VerifyPIN(fixtures.GetTestContextWithJWT(data.AUserWithACard().PersonaID), &cpb.VerifyPINRequest{
+			TokenizedCardNumber: data.AUserWithACard().Token(),
+			EncryptedPinBlock:   encryptedVerifyPINBlock,
+		})
+		require.Error(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("no audit log when echidna is unavailable", func(t *testing.T) {
+		var events []string
+		hook := func(buf []byte) {
+			events = append(events, string(buf))
+		}
+		builder := fixtures.AServer().WithData(data.AUserWithACard()).WithEchidnaErrorCode(1012).
+			WithLockoutState(ratelimit.LockoutState{Failures: 3, LockedFor: time.Minute}).WithAuditLogHook(hook)
+		s := buildCardServer(builder)
+
+		_, err := s.VerifyPIN(fixtures.GetTestContextWithJWT(data.AUserWithACard().PersonaID), &cpb.VerifyPINRequest{
+			TokenizedCardNumber: data.AUserWithACard().Token(),
+			EncryptedPinBlock:   encryptedVerifyPINBlock,
+		})
+		require.Error(t, err)
+		assert.Empty(t, events)
+	})
+}
@@ -48,6 +48,7 @@ func TestBlock(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_STOLEN,
 					epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_DAMAGED,
 					epb.Eligibility_ELIGIBILITY_UNBLOCK,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 			},
 		},
@@ -65,6 +66,7 @@ func TestBlock(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_STOLEN,
 					epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_DAMAGED,
 					epb.Eligibility_ELIGIBILITY_UNBLOCK,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 			},
 		},
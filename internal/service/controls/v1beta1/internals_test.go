@@ -17,6 +17,7 @@ func buildCardControlsServer(c *fixtures.ServerBuilder) ccpb.CardControlsAPIServ
 		},
 		Eligibility: &eligibility.Client{
 			CardEligibilityAPIClient: c.CardEligibilityAPIClient,
+			Evaluator:                c.CardEligibilityAPIClient,
 		},
 		Entitlements: entitlements.Client{
 			CardEntitlementsAPIClient: c.CardEntitlementsAPIClient,
@@ -0,0 +1,218 @@
+package v1beta2
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/anzx/pkg/auditlog"
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+	"github.com/anzx/pkg/xcontext"
+	"google.golang.org/grpc/codes"
+
+	"github.com/anzx/fabric-cards/pkg/feature"
+	"github.com/anzx/fabric-cards/pkg/integration/entitlements"
+	"github.com/anzx/fabric-cards/pkg/integration/visagateway/customerrules"
+	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
+	"github.com/anzx/fabric-cards/pkg/presets"
+	ccpb "github.com/anzx/fabricapis/pkg/fabric/service/cardcontrols/v1beta2"
+	entpb "github.com/anzx/fabricapis/pkg/fabric/service/entitlements/v1beta1"
+	crpb "github.com/anzx/fabricapis/pkg/gateway/visa/service/customerrules"
+)
+
+const applyPresetFailed = "apply preset failed"
+
+// ApplyPreset sets or removes every control of a preset. If Visa fails part way the controls the preset sets are put
+// back as they were, so a preset is either applied in full or not at all. Removing a preset only removes the controls
+// it added, controls the customer had set before it was applied stay on the card. A preset can't be applied over a
+// control the customer set differently.
+func (s server) ApplyPreset(ctx context.Context, req *ccpb.ApplyPresetRequest) (*ccpb.CardControlResponse, error) {
+	preset, ok := s.Presets.Get(req.GetPresetId())
+	if !ok {
+		return nil, anzerrors.New(codes.NotFound, applyPresetFailed,
+			anzerrors.NewErrorInfo(ctx, anzcodes.ValidationFailure, "unknown preset"))
+	}
+
+	entitledCard, err := s.Entitlements.GetEntitledCard(ctx, req.GetTokenizedCardNumber(), entitlements.OPERATION_CARDCONTROLS)
+	if err != nil {
+		return nil, serviceErr(err, applyPresetFailed)
+	}
+
+	var visaCtx context.Context
+	if feature.FeatureGate.Enabled(feature.FORGEROCK_SYSTEM_LOGIN) {
+		visaCtx, err = s.Forgerock.SystemJWT(ctx, visaGatewayRead, visaGatewayUpdate, visaGatewayCreate, visaGatewayDelete)
+		if err != nil {
+			return nil, serviceErr(err, applyPresetFailed)
+		}
+	} else {
+		visaCtx = ctx
+	}
+
+	cardNumber, snapshot, err := s.getControlDocument(ctx, visaCtx, req.GetTokenizedCardNumber())
+	if err != nil {
+		return nil, serviceErr(err, applyPresetFailed)
+	}
+
+	var response *ccpb.CardControlResponse
+	switch req.GetAction() {
+	case ccpb.ApplyPresetRequest_ACTION_APPLY:
+		response, err = s.applyPreset(ctx, visaCtx, req.GetTokenizedCardNumber(), *cardNumber, entitledCard, snapshot, preset)
+	case ccpb.ApplyPresetRequest_ACTION_REMOVE:
+		response, err = s.removePreset(ctx, visaCtx, req.GetTokenizedCardNumber(), *cardNumber, entitledCard, snapshot, preset)
+	default:
+		return nil, anzerrors.New(codes.InvalidArgument, applyPresetFailed,
+			anzerrors.NewErrorInfo(ctx, anzcodes.ValidationFailure, "action is required"))
+	}
+
+	if err != nil {
+		if rollbackErr := s.restoreControls(xcontext.Detach(ctx), visaCtx, req.GetTokenizedCardNumber(), snapshot, preset); rollbackErr != nil {
+			logf.Error(ctx, rollbackErr, "unable to roll back preset %s, the card may have part of it", preset.ID)
+		}
+		return nil, serviceErr(err, applyPresetFailed)
+	}
+
+	s.addPresets(response)
+	return response, nil
+}
+
+// applyPreset sets the controls of a preset and records those the card did not have yet
+func (s server) applyPreset(ctx, visaCtx context.Context, tokenizedCardNumber, cardNumber string, entitledCard *entpb.EntitledCard,
+	snapshot *crpb.Resource, preset *presets.Preset) (response *ccpb.CardControlResponse, err error) {
+	req := &ccpb.SetControlsRequest{
+		TokenizedCardNumber: tokenizedCardNumber,
+		CardControls:        preset.ControlRequests(),
+	}
+	serviceData := initSetVisaControlServiceData(req)
+	serviceData.AccountNumbers = entitledCard.GetAccountNumbers()
+	serviceData.Last_4Digits = cardNumber[12:]
+	defer func() {
+		s.AuditLog.Publish(ctx, auditlog.EventSetVisaControl, response, err, serviceData)
+	}()
+
+	controls := getCardControlResponse(snapshot, tokenizedCardNumber).GetCardControls()
+	if conflicting := preset.Conflicting(controls); len(conflicting) > 0 {
+		// the preset would replace them and removing it would not put them back
+		return nil, anzerrors.New(codes.FailedPrecondition, applyPresetFailed,
+			anzerrors.NewErrorInfo(ctx, anzcodes.CardControlAlreadyExists,
+				fmt.Sprintf("%s already set differently to the preset", strings.Join(getRemoveControlTypes(conflicting), ", "))))
+	}
+	added := addedControlTypes(controls, preset)
+
+	response, err = s.setControls(ctx, visaCtx, req, cardNumber, snapshot)
+	if anzerrors.GetStatusCode(err) == codes.AlreadyExists {
+		// the preset is already on
+		response, err = getCardControlResponse(snapshot, tokenizedCardNumber), nil
+		s.addExpiries(ctx, response)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Presets.Record(ctx, tokenizedCardNumber, preset, added); err != nil {
+		logf.Error(ctx, err, "unable to record the controls preset %s added", preset.ID)
+	}
+	return response, nil
+}
+
+// removePreset removes the controls a preset added. If it was not recorded which they are, the controls on the card as
+// the preset sets them are removed.
+func (s server) removePreset(ctx, visaCtx context.Context, tokenizedCardNumber, cardNumber string, entitledCard *entpb.EntitledCard,
+	snapshot *crpb.Resource, preset *presets.Preset) (response *ccpb.CardControlResponse, err error) {
+	controlTypes, recorded, addedErr := s.Presets.Added(ctx, tokenizedCardNumber, preset)
+	if addedErr != nil {
+		logf.Error(ctx, addedErr, "unable to read the controls preset %s added", preset.ID)
+	}
+	if !recorded {
+		controlTypes = preset.Matching(getCardControlResponse(snapshot, tokenizedCardNumber).GetCardControls())
+	}
+
+	req := &ccpb.RemoveControlsRequest{
+		TokenizedCardNumber: tokenizedCardNumber,
+		ControlTypes:        controlTypes,
+	}
+	serviceData := initRemoveVisaControlServiceData(req)
+	serviceData.AccountNumbers = entitledCard.GetAccountNumbers()
+	serviceData.Last_4Digits = cardNumber[12:]
+	defer func() {
+		s.AuditLog.Publish(ctx, auditlog.EventRemoveVisaControl, response, err, serviceData)
+	}()
+
+	if len(controlTypes) == 0 {
+		response = getCardControlResponse(snapshot, tokenizedCardNumber)
+		s.addExpiries(ctx, response)
+	} else if response, err = s.removeControls(ctx, visaCtx, req, snapshot); err != nil {
+		return nil, err
+	}
+
+	if err := s.Presets.Forget(ctx, tokenizedCardNumber, preset); err != nil {
+		logf.Error(ctx, err, "unable to forget the controls preset %s added", preset.ID)
+	}
+	return response, nil
+}
+
+// addedControlTypes returns the control types of a preset a card does not have yet
+func addedControlTypes(controls []*ccpb.CardControl, preset *presets.Preset) []ccpb.ControlType {
+	on := make(map[ccpb.ControlType]bool, len(controls))
+	for _, control := range controls {
+		on[control.GetControlType()] = true
+	}
+	var out []ccpb.ControlType
+	for _, controlType := range preset.ControlTypes() {
+		if !on[controlType] {
+			out = append(out, controlType)
+		}
+	}
+	return out
+}
+
+// restoreControls puts the controls a preset sets back as they were in snapshot. Nothing is sent to Visa if they were
+// not changed.
+func (s server) restoreControls(ctx, visaCtx context.Context, tokenizedCardNumber string, snapshot *crpb.Resource, preset *presets.Preset) error {
+	_, current, err := s.getControlDocument(ctx, visaCtx, tokenizedCardNumber)
+	if err != nil {
+		return err
+	}
+
+	controlTypes := preset.ControlTypes()
+	if _, ok := getGamblingControlFromDocument(snapshot.GetMerchantControls()); ok {
+		// a gambling block the customer had is only lifted after its impulse delay, it is left as it is
+		controlTypes = removeControlType(controlTypes, ccpb.ControlType_MCT_GAMBLING)
+	}
+
+	before, _ := customerrules.GetDeleteRequest(snapshot, controlTypes)
+	after, changed := customerrules.GetDeleteRequest(current, controlTypes)
+	if sameControls(before, after) {
+		return nil
+	}
+
+	logf.Info(ctx, "rolling back preset %s", preset.ID)
+	if changed {
+		if _, err := s.Visa.Delete(visaCtx, current.GetDocumentId(), after); err != nil {
+			return err
+		}
+	}
+	if before != nil {
+		if _, err := s.Visa.Create(visaCtx, current.GetDocumentId(), before); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameControls compares the controls of a preset on a card, nil if the card has none of them
+func sameControls(before, after *crpb.ControlRequest) bool {
+	if before == nil {
+		before = &crpb.ControlRequest{}
+	}
+	return !existingControlsChanged(before, &crpb.Resource{
+		GlobalControls:      after.GetGlobalControls(),
+		MerchantControls:    after.GetMerchantControls(),
+		TransactionControls: after.GetTransactionControls(),
+	})
+}
+
+// addPresets adds the presets that are on to a response
+func (s server) addPresets(response *ccpb.CardControlResponse) {
+	response.Presets = s.Presets.Active(response.GetCardControls())
+}
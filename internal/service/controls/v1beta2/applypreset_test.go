@@ -0,0 +1,183 @@
+package v1beta2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+
+	pkgutil "github.com/anzx/fabric-cards/pkg/integration/util"
+	"github.com/anzx/fabric-cards/pkg/presets"
+	"github.com/anzx/fabric-cards/test/data"
+	"github.com/anzx/fabric-cards/test/fixtures"
+	ccpb "github.com/anzx/fabricapis/pkg/fabric/service/cardcontrols/v1beta2"
+	crpb "github.com/anzx/fabricapis/pkg/gateway/visa/service/customerrules"
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+)
+
+// buildPresetServer builds a server with a lockdown preset that blocks online and overseas transactions
+func buildPresetServer(t *testing.T, c *fixtures.ServerBuilder) *server {
+	m, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(m.Close)
+
+	p, err := presets.New(context.Background(), []presets.Config{{
+		ID:          "lockdown",
+		DisplayName: "Lockdown mode",
+		Controls: []presets.ControlConfig{
+			{ControlType: ccpb.ControlType_TCT_E_COMMERCE.String()},
+			{ControlType: ccpb.ControlType_TCT_CROSS_BORDER.String()},
+		},
+	}}, redis.NewClient(&redis.Options{Addr: m.Addr()}), "st:")
+	require.NoError(t, err)
+
+	s := buildCardControlsServer(c).(*server)
+	s.Presets = p
+	return s
+}
+
+func TestApplyPreset(t *testing.T) {
+	lockdown := []*ccpb.ControlPreset{{Id: "lockdown", DisplayName: "Lockdown mode"}}
+	tests := []struct {
+		name         string
+		builder      *fixtures.ServerBuilder
+		presetID     string
+		action       ccpb.ApplyPresetRequest_Action
+		wantControls int
+		wantPresets  []*ccpb.ControlPreset
+		wantCode     codes.Code
+	}{
+		{
+			name:         "apply",
+			builder:      fixtures.AServer().WithData(data.AUserWithACard()),
+			presetID:     "lockdown",
+			action:       ccpb.ApplyPresetRequest_ACTION_APPLY,
+			wantControls: 2,
+			wantPresets:  lockdown,
+		},
+		{
+			name: "apply when already on",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()).
+				WithVisaGatewayControls(ccpb.ControlType_TCT_E_COMMERCE, ccpb.ControlType_TCT_CROSS_BORDER),
+			presetID:     "lockdown",
+			action:       ccpb.ApplyPresetRequest_ACTION_APPLY,
+			wantControls: 2,
+			wantPresets:  lockdown,
+		},
+		{
+			name: "remove",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()).
+				WithVisaGatewayControls(ccpb.ControlType_TCT_E_COMMERCE, ccpb.ControlType_TCT_CROSS_BORDER, ccpb.ControlType_GCT_GLOBAL),
+			presetID:     "lockdown",
+			action:       ccpb.ApplyPresetRequest_ACTION_REMOVE,
+			wantControls: 1,
+		},
+		{
+			name: "apply over a control set differently",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()).
+				WithVisaGatewayResource(&crpb.Resource{
+					DocumentId: documentID,
+					TransactionControls: []*crpb.TransactionControl{{
+						ControlType:      ccpb.ControlType_TCT_E_COMMERCE.String(),
+						IsControlEnabled: true,
+						ShouldDeclineAll: pkgutil.ToBoolPtr(false),
+						SpendLimit:       &crpb.SpendLimit{Type: "LMT_DAY", DeclineThreshold: 100},
+					}},
+				}),
+			presetID: "lockdown",
+			action:   ccpb.ApplyPresetRequest_ACTION_APPLY,
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:     "unknown preset",
+			builder:  fixtures.AServer().WithData(data.AUserWithACard()),
+			presetID: "travel",
+			action:   ccpb.ApplyPresetRequest_ACTION_APPLY,
+			wantCode: codes.NotFound,
+		},
+		{
+			name:     "no action",
+			builder:  fixtures.AServer().WithData(data.AUserWithACard()),
+			presetID: "lockdown",
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "visa fails",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()).
+				WithVisaGatewayCreateError(anzerrors.New(codes.Unavailable, "failed request",
+					anzerrors.NewErrorInfo(context.Background(), anzcodes.DownstreamFailure, "service unavailable"))),
+			presetID: "lockdown",
+			action:   ccpb.ApplyPresetRequest_ACTION_APPLY,
+			wantCode: codes.Unavailable,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := buildPresetServer(t, test.builder)
+			got, err := s.ApplyPreset(fixtures.GetTestContext(), &ccpb.ApplyPresetRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				PresetId:            test.presetID,
+				Action:              test.action,
+			})
+			if test.wantCode != codes.OK {
+				require.Error(t, err)
+				assert.Equal(t, test.wantCode, anzerrors.GetStatusCode(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, got.GetCardControls(), test.wantControls)
+			require.Len(t, got.GetPresets(), len(test.wantPresets))
+			for i := range test.wantPresets {
+				assert.Equal(t, test.wantPresets[i].GetId(), got.GetPresets()[i].GetId())
+				assert.Equal(t, test.wantPresets[i].GetDisplayName(), got.GetPresets()[i].GetDisplayName())
+			}
+		})
+	}
+}
+
+func TestApplyPreset_QueryControls(t *testing.T) {
+	s := buildPresetServer(t, fixtures.AServer().WithData(data.AUserWithACard()))
+	query := func() *ccpb.CardControlResponse {
+		got, err := s.QueryControls(fixtures.GetTestContext(), &ccpb.QueryControlsRequest{
+			TokenizedCardNumber: data.AUserWithACard().Token(),
+		})
+		require.NoError(t, err)
+		return got
+	}
+	assert.Empty(t, query().GetPresets())
+
+	_, err := s.ApplyPreset(fixtures.GetTestContext(), &ccpb.ApplyPresetRequest{
+		TokenizedCardNumber: data.AUserWithACard().Token(),
+		PresetId:            "lockdown",
+		Action:              ccpb.ApplyPresetRequest_ACTION_APPLY,
+	})
+	require.NoError(t, err)
+	presets := query().GetPresets()
+	require.Len(t, presets, 1)
+	assert.Equal(t, "lockdown", presets[0].GetId())
+}
+
+func TestApplyPreset_RemoveKeepsCustomerControls(t *testing.T) {
+	s := buildPresetServer(t, fixtures.AServer().WithData(data.AUserWithACard()).
+		WithVisaGatewayControls(ccpb.ControlType_TCT_E_COMMERCE))
+	apply := func(action ccpb.ApplyPresetRequest_Action) *ccpb.CardControlResponse {
+		got, err := s.ApplyPreset(fixtures.GetTestContext(), &ccpb.ApplyPresetRequest{
+			TokenizedCardNumber: data.AUserWithACard().Token(),
+			PresetId:            "lockdown",
+			Action:              action,
+		})
+		require.NoError(t, err)
+		return got
+	}
+
+	assert.Len(t, apply(ccpb.ApplyPresetRequest_ACTION_APPLY).GetCardControls(), 2)
+
+	got := apply(ccpb.ApplyPresetRequest_ACTION_REMOVE)
+	require.Len(t, got.GetCardControls(), 1, "the control the customer set before the preset stays")
+	assert.Equal(t, ccpb.ControlType_TCT_E_COMMERCE, got.GetCardControls()[0].GetControlType())
+}
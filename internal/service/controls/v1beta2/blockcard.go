@@ -30,7 +30,7 @@ func (s server) BlockCard(ctx context.Context, req *ccpb.BlockCardRequest) (retR
 	}
 
 	if hasTempBlock(req.GetAction(), card.Status) {
-		if err := s.Eligibility.Can(ctx, actionEligibility(req.Action), req.GetTokenizedCardNumber()); err != nil {
+		if err := s.Eligibility.CanCard(ctx, actionEligibility(req.Action), card); err != nil {
 			return nil, serviceErr(err, failMessage)
 		}
 
@@ -94,6 +94,8 @@ func TestBlockCard(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_BLOCK,
 					epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 					epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+					epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 			},
 		},
@@ -117,6 +119,8 @@ func TestBlockCard(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_BLOCK,
 					epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 					epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+					epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 			},
 		},
@@ -140,6 +144,8 @@ func TestBlockCard(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_BLOCK,
 					epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 					epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+					epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 			},
 		},
@@ -164,6 +170,8 @@ func TestBlockCard(t *testing.T) {
 					epb.Eligibility_ELIGIBILITY_BLOCK,
 					epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 					epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+					epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+					epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 				},
 			},
 		},
@@ -248,6 +256,7 @@ func buildCardControlsServer(c *fixtures.ServerBuilder) ccpb.CardControlsAPIServ
 		},
 		Eligibility: &eligibility.Client{
 			CardEligibilityAPIClient: c.CardEligibilityAPIClient,
+			Evaluator:                c.CardEligibilityAPIClient,
 		},
 		Entitlements: entitlements.Client{
 			CardEntitlementsAPIClient: c.CardEntitlementsAPIClient,
@@ -0,0 +1,214 @@
+package v1beta2
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+	"github.com/anzx/pkg/jwtauth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/anzx/fabric-cards/pkg/expiry"
+	"github.com/anzx/fabric-cards/pkg/feature"
+	"github.com/anzx/fabric-cards/pkg/identity"
+	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
+	ccpb "github.com/anzx/fabricapis/pkg/fabric/service/cardcontrols/v1beta2"
+)
+
+const invalidExpiry = "invalid expiry"
+
+// controlExpiries validates the expiry of every control in a request. It returns the expiries to schedule and the
+// control types set without one, whose previous expiry is cancelled so they stay until they are removed.
+func (s server) controlExpiries(ctx context.Context, req *ccpb.SetControlsRequest, id *identity.Identity) ([]*expiry.Expiry, []string, error) {
+	byType := make(map[ccpb.ControlType]*timestamppb.Timestamp, len(req.GetCardControls()))
+	var order []ccpb.ControlType
+	for _, control := range req.GetCardControls() {
+		previous, seen := byType[control.GetControlType()]
+		if !seen {
+			order = append(order, control.GetControlType())
+		} else if !sameExpireTime(previous, control.GetExpireTime()) {
+			return nil, nil, expiryErr(ctx, "%s controls must expire at the same time", control.GetControlType())
+		}
+		byType[control.GetControlType()] = control.GetExpireTime()
+	}
+
+	var (
+		expiries  []*expiry.Expiry
+		permanent []string
+	)
+	now := time.Now()
+	for _, controlType := range order {
+		expireTime := byType[controlType]
+		if expireTime == nil {
+			permanent = append(permanent, controlType.String())
+			continue
+		}
+
+		if controlType == ccpb.ControlType_MCT_GAMBLING {
+			// the gambling block is removed after an impulse delay, it can't be lifted at a set time
+			return nil, nil, expiryErr(ctx, "%s can't expire", controlType)
+		}
+		if err := expireTime.CheckValid(); err != nil {
+			return nil, nil, expiryErr(ctx, "expire time of %s is not a valid time", controlType)
+		}
+		at := expireTime.AsTime()
+		if !at.After(now) {
+			return nil, nil, expiryErr(ctx, "expire time of %s must be in the future", controlType)
+		}
+		if s.Expiries == nil {
+			return nil, nil, anzerrors.New(codes.Unavailable, setControlFailed,
+				anzerrors.NewErrorInfo(ctx, anzcodes.FeatureDisabled, "controls can't be set to expire"))
+		}
+		if at.Sub(now) > s.Expiries.MaxDuration() {
+			return nil, nil, expiryErr(ctx, "%s can't expire more than %v from now", controlType, s.Expiries.MaxDuration())
+		}
+
+		expiries = append(expiries, &expiry.Expiry{
+			TokenizedCardNumber: req.GetTokenizedCardNumber(),
+			ControlType:         controlType.String(),
+			PersonaID:           id.PersonaID,
+			OcvID:               id.OcvID,
+			ExpireTime:          at,
+		})
+	}
+	return expiries, permanent, nil
+}
+
+// scheduleExpiries persists the expiries of the controls that were set and forgets those of controls set without one
+func (s server) scheduleExpiries(ctx context.Context, tokenizedCardNumber string, expiries []*expiry.Expiry, permanent []string) error {
+	for _, e := range expiries {
+		if err := s.Expiries.Schedule(ctx, e); err != nil {
+			logf.Error(ctx, err, "unable to schedule expiry of %s", e.ControlType)
+			return expiryUnavailable(ctx, err)
+		}
+	}
+	if err := s.Expiries.Cancel(ctx, tokenizedCardNumber, permanent...); err != nil {
+		logf.Error(ctx, err, "unable to cancel expiries of %v", permanent)
+		return expiryUnavailable(ctx, err)
+	}
+	return nil
+}
+
+// expiring returns true if any of the control types is set to expire
+func (s server) expiring(ctx context.Context, tokenizedCardNumber string, controlTypes []string) bool {
+	expiries, err := s.Expiries.Get(ctx, tokenizedCardNumber)
+	if err != nil {
+		logf.Error(ctx, err, "unable to read expiries")
+		return false
+	}
+	for _, controlType := range controlTypes {
+		if _, ok := expiries[controlType]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// addExpiries sets the expire time of the controls in a response, controls are returned without one if the expiries
+// can't be read
+func (s server) addExpiries(ctx context.Context, response *ccpb.CardControlResponse) {
+	if s.Expiries == nil || len(response.GetCardControls()) == 0 {
+		return
+	}
+	expiries, err := s.Expiries.Get(ctx, response.GetTokenizedCardNumber())
+	if err != nil {
+		logf.Error(ctx, err, "unable to read expiries")
+		return
+	}
+	for _, control := range response.GetCardControls() {
+		if e, ok := expiries[control.GetControlType().String()]; ok {
+			control.ExpireTime = timestamppb.New(e.ExpireTime)
+		}
+	}
+}
+
+// transferExpiries moves the expiries of a replaced card to its replacement, the lock on the replaced card is not
+// transferred
+func (s server) transferExpiries(ctx context.Context, current, replacement string) {
+	if s.Expiries == nil {
+		return
+	}
+	expiries, err := s.Expiries.Get(ctx, current)
+	if err != nil {
+		logf.Error(ctx, err, "unable to read expiries of replaced card")
+		return
+	}
+
+	controlTypes := make([]string, 0, len(expiries))
+	for controlType, e := range expiries {
+		controlTypes = append(controlTypes, controlType)
+		if controlType == ccpb.ControlType_GCT_GLOBAL.String() {
+			continue
+		}
+		e.TokenizedCardNumber = replacement
+		e.Attempts = 0
+		if err := s.Expiries.Schedule(ctx, e); err != nil {
+			logf.Error(ctx, err, "unable to transfer expiry of %s", controlType)
+		}
+	}
+	if err := s.Expiries.Cancel(ctx, current, controlTypes...); err != nil {
+		logf.Error(ctx, err, "unable to cancel expiries of replaced card")
+	}
+}
+
+// expireControl removes a control once it expired and lets the customer know
+func (s server) expireControl(ctx context.Context, e *expiry.Expiry) error {
+	ctx, err := s.expiryContext(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	controlType := ccpb.ControlType(ccpb.ControlType_value[e.ControlType])
+	if _, err := s.RemoveControls(ctx, &ccpb.RemoveControlsRequest{
+		TokenizedCardNumber: e.TokenizedCardNumber,
+		ControlTypes:        []ccpb.ControlType{controlType},
+	}); err != nil {
+		if anzerrors.GetStatusCode(err) == codes.NotFound || anzerrors.GetStatusCode(err) == codes.PermissionDenied {
+			// the card was closed or the customer no longer has access to it, there is nothing to remove
+			logf.Info(ctx, "expired %s not removed: %v", e.ControlType, err)
+			return nil
+		}
+		return err
+	}
+
+	s.sendNotifications(ctx, []ccpb.ControlType{controlType}, e.PersonaID, false)
+	return nil
+}
+
+// expiryContext acts as the customer a control expired for, with a system JWT for downstream calls
+func (s server) expiryContext(ctx context.Context, e *expiry.Expiry) (context.Context, error) {
+	if feature.FeatureGate.Enabled(feature.FORGEROCK_SYSTEM_LOGIN) {
+		systemCtx, err := s.Forgerock.SystemJWT(ctx, s.Expiries.Scopes()...)
+		if err != nil {
+			return ctx, err
+		}
+		ctx = systemCtx
+	}
+
+	return jwtauth.AddClaimsToContext(ctx, jwtauth.NewClaims(jwtauth.BaseClaims{
+		Claims:  jwt.Claims{Subject: e.PersonaID},
+		Persona: &jwtauth.Persona{PersonaID: e.PersonaID},
+		OCVID:   e.OcvID,
+	})), nil
+}
+
+func sameExpireTime(a, b *timestamppb.Timestamp) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.AsTime().Equal(b.AsTime())
+}
+
+func expiryErr(ctx context.Context, format string, args ...interface{}) error {
+	return anzerrors.New(codes.InvalidArgument, invalidExpiry,
+		anzerrors.NewErrorInfo(ctx, anzcodes.ValidationFailure, fmt.Sprintf(format, args...)))
+}
+
+func expiryUnavailable(ctx context.Context, err error) error {
+	return anzerrors.Wrap(err, codes.Unavailable, setControlFailed,
+		anzerrors.NewErrorInfo(ctx, anzcodes.DownstreamFailure, "unable to schedule expiry"))
+}
@@ -0,0 +1,186 @@
+package v1beta2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/anzx/fabric-commandcentre-sdk/pkg/sdk"
+	"github.com/anzx/fabric-commandcentre-sdk/pkg/sdk/notification"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/anzx/fabric-cards/pkg/expiry"
+	"github.com/anzx/fabric-cards/pkg/integration/commandcentre"
+	matchers "github.com/anzx/fabric-cards/pkg/integration/commandcentre/matchers"
+	mock "github.com/anzx/fabric-cards/pkg/integration/commandcentre/mocks"
+	"github.com/anzx/fabric-cards/test/data"
+	"github.com/anzx/fabric-cards/test/fixtures"
+	ccpb "github.com/anzx/fabricapis/pkg/fabric/service/cardcontrols/v1beta2"
+	anzerrors "github.com/anzx/pkg/errors"
+)
+
+// buildExpiringServer builds a server whose controls can be set to expire
+func buildExpiringServer(t *testing.T, c *fixtures.ServerBuilder) *server {
+	m, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(m.Close)
+
+	scheduler, err := expiry.NewScheduler(context.Background(), &expiry.Config{MaxDuration: 48 * time.Hour},
+		redis.NewClient(&redis.Options{Addr: m.Addr()}), nil)
+	require.NoError(t, err)
+
+	s := buildCardControlsServer(c).(*server)
+	s.Expiries = scheduler
+	scheduler.Handle(s.expireControl)
+	return s
+}
+
+func TestSetControls_Expiry(t *testing.T) {
+	expireTime := timestamppb.New(time.Now().Add(24 * time.Hour).Truncate(time.Second))
+	tests := []struct {
+		name     string
+		expiring bool
+		controls []*ccpb.ControlRequest
+		wantCode codes.Code
+		wantErr  string
+	}{
+		{
+			name:     "card locked for a day",
+			expiring: true,
+			controls: []*ccpb.ControlRequest{{ControlType: ccpb.ControlType_GCT_GLOBAL, ExpireTime: expireTime}},
+		},
+		{
+			name:     "expire time in the past",
+			expiring: true,
+			controls: []*ccpb.ControlRequest{{ControlType: ccpb.ControlType_GCT_GLOBAL, ExpireTime: timestamppb.New(time.Now().Add(-time.Minute))}},
+			wantCode: codes.InvalidArgument,
+			wantErr:  "expire time of GCT_GLOBAL must be in the future",
+		},
+		{
+			name:     "expire time too far in the future",
+			expiring: true,
+			controls: []*ccpb.ControlRequest{{ControlType: ccpb.ControlType_GCT_GLOBAL, ExpireTime: timestamppb.New(time.Now().Add(72 * time.Hour))}},
+			wantCode: codes.InvalidArgument,
+			wantErr:  "GCT_GLOBAL can't expire more than",
+		},
+		{
+			name:     "gambling block",
+			expiring: true,
+			controls: []*ccpb.ControlRequest{{ControlType: ccpb.ControlType_MCT_GAMBLING, ExpireTime: expireTime}},
+			wantCode: codes.InvalidArgument,
+			wantErr:  "MCT_GAMBLING can't expire",
+		},
+		{
+			name:     "controls of the same type expire at different times",
+			expiring: true,
+			controls: []*ccpb.ControlRequest{
+				{ControlType: ccpb.ControlType_TCT_E_COMMERCE, Schedule: aSchedule(9, 12), ExpireTime: expireTime},
+				{ControlType: ccpb.ControlType_TCT_E_COMMERCE, Schedule: aSchedule(13, 17)},
+			},
+			wantCode: codes.InvalidArgument,
+			wantErr:  "TCT_E_COMMERCE controls must expire at the same time",
+		},
+		{
+			name:     "expiries not configured",
+			controls: []*ccpb.ControlRequest{{ControlType: ccpb.ControlType_GCT_GLOBAL, ExpireTime: expireTime}},
+			wantCode: codes.Unavailable,
+			wantErr:  "controls can't be set to expire",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			builder := fixtures.AServer().WithData(data.AUserWithACard())
+			var s *server
+			if test.expiring {
+				s = buildExpiringServer(t, builder)
+			} else {
+				s = buildCardControlsServer(builder).(*server)
+			}
+
+			got, err := s.SetControls(fixtures.GetTestContext(), &ccpb.SetControlsRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				CardControls:        test.controls,
+			})
+			if test.wantErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.wantCode, anzerrors.GetStatusCode(err))
+				assert.Contains(t, err.Error(), test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, got.GetCardControls(), 1)
+			assert.True(t, expireTime.AsTime().Equal(got.GetCardControls()[0].GetExpireTime().AsTime()))
+
+			expiries, err := s.Expiries.Get(context.Background(), data.AUserWithACard().Token())
+			require.NoError(t, err)
+			require.Contains(t, expiries, "GCT_GLOBAL")
+			assert.Equal(t, data.AUserWithACard().PersonaID, expiries["GCT_GLOBAL"].PersonaID)
+		})
+	}
+}
+
+func TestSetControls_ExtendExpiry(t *testing.T) {
+	builder := fixtures.AServer().WithData(data.AUserWithACard()).WithVisaGatewayControls(controlType)
+	s := buildExpiringServer(t, builder)
+	token := data.AUserWithACard().Token()
+	set := func(expireTime *timestamppb.Timestamp) *ccpb.SetControlsRequest {
+		return &ccpb.SetControlsRequest{
+			TokenizedCardNumber: token,
+			CardControls:        []*ccpb.ControlRequest{{ControlType: controlType, ExpireTime: expireTime}},
+		}
+	}
+
+	_, err := s.SetControls(fixtures.GetTestContext(), set(timestamppb.New(time.Now().Add(time.Hour))))
+	require.NoError(t, err, "the control is set to expire")
+	expiries, err := s.Expiries.Get(context.Background(), token)
+	require.NoError(t, err)
+	assert.Contains(t, expiries, controlType.String())
+
+	_, err = s.SetControls(fixtures.GetTestContext(), set(nil))
+	require.NoError(t, err, "the control no longer expires")
+	expiries, err = s.Expiries.Get(context.Background(), token)
+	require.NoError(t, err)
+	assert.Empty(t, expiries)
+
+	_, err = s.SetControls(fixtures.GetTestContext(), set(nil))
+	require.Error(t, err)
+	assert.Equal(t, codes.AlreadyExists, anzerrors.GetStatusCode(err))
+}
+
+func TestExpireControl(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cc := mock.NewMockPublisher(ctrl)
+	cc.EXPECT().Publish(gomock.Any(), gomock.AssignableToTypeOf(&sdk.EventForPersona{})).AnyTimes().
+		Return(&sdk.PublishResponse{}, nil)
+	cc.EXPECT().Publish(gomock.Any(), &matchers.NotificationMatcher{Notification: &sdk.NotificationForPersona{
+		PersonaID:    data.AUserWithACard().PersonaID,
+		Notification: notification.Simple{ActionURL: "https://plus.anz/cards"},
+		Preview:      getGlobalPreview(false),
+	}}).Times(1).Return(&sdk.PublishResponse{}, nil)
+
+	builder := fixtures.AServer().WithData(data.AUserWithACard()).WithVisaGatewayControls(ccpb.ControlType_GCT_GLOBAL)
+	s := buildExpiringServer(t, builder)
+	s.CommandCentre = &commandcentre.Client{Publisher: cc}
+	s.Expiries.Handle(s.expireControl)
+
+	e := &expiry.Expiry{
+		TokenizedCardNumber: data.AUserWithACard().Token(),
+		ControlType:         ccpb.ControlType_GCT_GLOBAL.String(),
+		PersonaID:           data.AUserWithACard().PersonaID,
+		ExpireTime:          time.Now().Add(-time.Minute),
+	}
+	require.NoError(t, s.Expiries.Schedule(context.Background(), e))
+
+	s.Expiries.ExpireDue(context.Background())
+
+	expiries, err := s.Expiries.Get(context.Background(), data.AUserWithACard().Token())
+	require.NoError(t, err)
+	assert.Empty(t, expiries, "the expiry is forgotten once the control is removed")
+}
@@ -110,6 +110,8 @@ func (s server) asyncGetDoc(ctx context.Context, visaCtx context.Context, tokeni
 	}
 
 	control := getCardControlResponse(visaResponse, tokenizedCardNumber)
+	s.addExpiries(ctx, control)
+	s.addPresets(control)
 	out = append(out, control)
 
 	ch <- out
@@ -43,7 +43,10 @@ func (s server) QueryControls(ctx context.Context, req *ccpb.QueryControlsReques
 		return nil, serviceErr(err, "query failed")
 	}
 
-	return getCardControlResponse(controlDocument, req.TokenizedCardNumber), nil
+	response := getCardControlResponse(controlDocument, req.TokenizedCardNumber)
+	s.addExpiries(ctx, response)
+	s.addPresets(response)
+	return response, nil
 }
 
 func (s server) getControlDocument(ctx context.Context, visaCtx context.Context, tokenizedCardNumber string) (*string, *crpb.Resource, error) {
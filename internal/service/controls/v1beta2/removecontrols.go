@@ -61,6 +61,13 @@ func (s server) RemoveControls(ctx context.Context, req *ccpb.RemoveControlsRequ
 
 	serviceData.Last_4Digits = (*cardNumber)[12:]
 
+	return s.removeControls(ctx, visaCtx, req, existingControlDocument)
+}
+
+// removeControls removes the controls in req from a card whose control document was fetched already
+func (s server) removeControls(ctx, visaCtx context.Context, req *ccpb.RemoveControlsRequest,
+	existingControlDocument *crpb.Resource) (*ccpb.CardControlResponse, error) {
+	var err error
 	if !customerrules.Enrolled(existingControlDocument) {
 		logf.Info(ctx, "Card Not Enrolled")
 		return &ccpb.CardControlResponse{}, nil
@@ -79,6 +86,7 @@ func (s server) RemoveControls(ctx context.Context, req *ccpb.RemoveControlsRequ
 
 	deleteRequest, ok := customerrules.GetDeleteRequest(existingControlDocument, req.GetControlTypes())
 	if !ok {
+		s.cancelExpiries(ctx, req)
 		return getCardControlResponse(existingControlDocument, req.GetTokenizedCardNumber()), nil
 	}
 
@@ -87,6 +95,8 @@ func (s server) RemoveControls(ctx context.Context, req *ccpb.RemoveControlsRequ
 		return nil, serviceErr(err, "remove failed")
 	}
 
+	s.cancelExpiries(ctx, req)
+
 	s.CommandCentre.PublishEventAsync(ctx, event.CardControlsChange)
 
 	id, err := identity.Get(ctx)
@@ -98,6 +108,13 @@ func (s server) RemoveControls(ctx context.Context, req *ccpb.RemoveControlsRequ
 	return getCardControlResponse(resource, req.GetTokenizedCardNumber()), nil
 }
 
+// cancelExpiries forgets when removed controls were set to expire, a control set again later stays until it is removed
+func (s server) cancelExpiries(ctx context.Context, req *ccpb.RemoveControlsRequest) {
+	if err := s.Expiries.Cancel(ctx, req.GetTokenizedCardNumber(), getRemoveControlTypes(req.GetControlTypes())...); err != nil {
+		logf.Error(ctx, err, "unable to cancel expiries")
+	}
+}
+
 func removeControlType(in []ccpb.ControlType, removeType ccpb.ControlType) []ccpb.ControlType {
 	for i, control := range in {
 		if control == removeType {
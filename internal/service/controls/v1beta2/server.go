@@ -1,6 +1,7 @@
 package v1beta2
 
 import (
+	"github.com/anzx/fabric-cards/pkg/expiry"
 	"github.com/anzx/fabric-cards/pkg/integration/auditlogger"
 	"github.com/anzx/fabric-cards/pkg/integration/commandcentre"
 	"github.com/anzx/fabric-cards/pkg/integration/ctm"
@@ -10,6 +11,7 @@ import (
 	"github.com/anzx/fabric-cards/pkg/integration/ocv"
 	"github.com/anzx/fabric-cards/pkg/integration/vault"
 	"github.com/anzx/fabric-cards/pkg/integration/visagateway/customerrules"
+	"github.com/anzx/fabric-cards/pkg/presets"
 	ccpb "github.com/anzx/fabricapis/pkg/fabric/service/cardcontrols/v1beta2"
 )
 
@@ -28,7 +30,10 @@ type Fabric struct {
 	Visa          *customerrules.Client
 }
 
-type Internal struct{}
+type Internal struct {
+	Expiries *expiry.Scheduler
+	Presets  *presets.Presets
+}
 
 type server struct {
 	Fabric
@@ -39,5 +44,7 @@ type server struct {
 
 // NewServer constructs a new CustomerRulesAPI from configured clients
 func NewServer(fabric Fabric, internal Internal, external External) ccpb.CardControlsAPIServer {
-	return &server{Fabric: fabric, Internal: internal, External: external}
+	srv := &server{Fabric: fabric, Internal: internal, External: external}
+	internal.Expiries.Handle(srv.expireControl)
+	return srv
 }
@@ -17,6 +17,7 @@ import (
 	anzerrors "github.com/anzx/pkg/errors"
 	anzcodes "github.com/anzx/pkg/errors/errcodes"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/anzx/fabric-cards/pkg/identity"
 
@@ -63,9 +64,16 @@ func (s server) SetControls(ctx context.Context, req *ccpb.SetControlsRequest) (
 
 	serviceData.Last_4Digits = (*cardNumber)[12:]
 
+	return s.setControls(ctx, visaCtx, req, *cardNumber, existingControlDocument)
+}
+
+// setControls sets the controls in req on a card whose control document was fetched already
+func (s server) setControls(ctx, visaCtx context.Context, req *ccpb.SetControlsRequest, cardNumber string,
+	existingControlDocument *crpb.Resource) (*ccpb.CardControlResponse, error) {
+	var err error
 	documentID := existingControlDocument.GetDocumentId()
 	if !customerrules.Enrolled(existingControlDocument) {
-		documentID, err = s.Visa.Registration(visaCtx, *cardNumber)
+		documentID, err = s.Visa.Registration(visaCtx, cardNumber)
 		if err != nil {
 			logf.Error(ctx, err, "unable to enrol card")
 			return nil, serviceErr(err, setControlFailed)
@@ -82,13 +90,27 @@ func (s server) SetControls(ctx context.Context, req *ccpb.SetControlsRequest) (
 		return nil, serviceErr(err, setControlFailed)
 	}
 
+	expiries, permanent, err := s.controlExpiries(ctx, req, id)
+	if err != nil {
+		return nil, serviceErr(err, setControlFailed)
+	}
+
 	request := customerrules.ControlRequest(controls...)
 	if !existingControlsChanged(request, existingControlDocument) {
-		return nil, anzerrors.New(
-			codes.AlreadyExists,
-			setControlFailed,
-			anzerrors.NewErrorInfo(ctx, anzcodes.CardControlAlreadyExists, "control already exists"),
-		)
+		// setting the controls a card already has only changes when they expire
+		if len(expiries) == 0 && !s.expiring(ctx, req.TokenizedCardNumber, permanent) {
+			return nil, anzerrors.New(
+				codes.AlreadyExists,
+				setControlFailed,
+				anzerrors.NewErrorInfo(ctx, anzcodes.CardControlAlreadyExists, "control already exists"),
+			)
+		}
+		if err := s.scheduleExpiries(ctx, req.TokenizedCardNumber, expiries, permanent); err != nil {
+			return nil, err
+		}
+		response := getCardControlResponse(existingControlDocument, req.TokenizedCardNumber)
+		s.addExpiries(ctx, response)
+		return response, nil
 	}
 
 	// send new controls to customerRulesAPI
@@ -97,8 +119,24 @@ func (s server) SetControls(ctx context.Context, req *ccpb.SetControlsRequest) (
 		return nil, serviceErr(err, setControlFailed)
 	}
 
+	// controls of the requested types with other schedules are deleted once the new ones are set, so a failed request
+	// never leaves a control type unset and can be retried
+	if replaced := customerrules.ReplacedControls(request, existingControlDocument); replaced != nil {
+		if _, err := s.Visa.Delete(visaCtx, documentID, replaced); err != nil {
+			logf.Error(ctx, err, "unable to delete replaced controls")
+			return nil, serviceErr(err, setControlFailed)
+		}
+		documentResponse = customerrules.WithoutControls(documentResponse, replaced)
+	}
+
 	s.CommandCentre.PublishEventAsync(ctx, event.CardControlsChange)
 
+	// the controls are set before they are scheduled to expire so a failed request never removes controls set earlier,
+	// the request can be retried to schedule them
+	if err := s.scheduleExpiries(ctx, req.TokenizedCardNumber, expiries, permanent); err != nil {
+		return nil, err
+	}
+
 	if id.HasDifferentSubject {
 		// This request was likely made by a staff member or coach on customer's behalf, so we should notify customer
 		controlTypes := make([]ccpb.ControlType, len(req.GetCardControls()))
@@ -108,13 +146,15 @@ func (s server) SetControls(ctx context.Context, req *ccpb.SetControlsRequest) (
 		go s.sendNotifications(xcontext.Detach(ctx), controlTypes, id.PersonaID, true)
 	}
 
-	return getCardControlResponse(documentResponse, req.TokenizedCardNumber), nil
+	response := getCardControlResponse(documentResponse, req.TokenizedCardNumber)
+	s.addExpiries(ctx, response)
+	return response, nil
 }
 
 func existingControlsChanged(request *crpb.ControlRequest, existingControls *crpb.Resource) bool {
 	globalControlsAreTheSame := reflect.DeepEqual(existingControls.GlobalControls, request.GlobalControls)
-	merchantControlsAreTheSame := reflect.DeepEqual(existingControls.MerchantControls, request.MerchantControls)
-	transactionControlsAreTheSame := reflect.DeepEqual(existingControls.TransactionControls, request.TransactionControls)
+	merchantControlsAreTheSame := sameMerchantControls(existingControls.MerchantControls, request.MerchantControls)
+	transactionControlsAreTheSame := sameTransactionControls(existingControls.TransactionControls, request.TransactionControls)
 
 	if globalControlsAreTheSame && merchantControlsAreTheSame && transactionControlsAreTheSame {
 		return false // Control Document has not been modified
@@ -123,6 +163,42 @@ func existingControlsChanged(request *crpb.ControlRequest, existingControls *crp
 	return true // Control Document has been modified
 }
 
+// sameTransactionControls compares spend limits by amount and period only, Visa adds the current period spend
+func sameTransactionControls(existing, requested []*crpb.TransactionControl) bool {
+	if len(existing) != len(requested) {
+		return false
+	}
+	for i := range existing {
+		if !customerrules.SameSpendLimit(existing[i].GetSpendLimit(), requested[i].GetSpendLimit()) {
+			return false
+		}
+		a, b := proto.Clone(existing[i]).(*crpb.TransactionControl), proto.Clone(requested[i]).(*crpb.TransactionControl)
+		a.SpendLimit, b.SpendLimit = nil, nil
+		if !proto.Equal(a, b) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameMerchantControls compares spend limits by amount and period only, Visa adds the current period spend
+func sameMerchantControls(existing, requested []*crpb.MerchantControl) bool {
+	if len(existing) != len(requested) {
+		return false
+	}
+	for i := range existing {
+		if !customerrules.SameSpendLimit(existing[i].GetSpendLimit(), requested[i].GetSpendLimit()) {
+			return false
+		}
+		a, b := proto.Clone(existing[i]).(*crpb.MerchantControl), proto.Clone(requested[i]).(*crpb.MerchantControl)
+		a.SpendLimit, b.SpendLimit = nil, nil
+		if !proto.Equal(a, b) {
+			return false
+		}
+	}
+	return true
+}
+
 func getSetControlTypes(controlRequests []*ccpb.ControlRequest) []string {
 	var controls []string
 	for _, cardControl := range controlRequests {
@@ -7,17 +7,22 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/type/money"
+	"google.golang.org/genproto/googleapis/type/timeofday"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/protobuf/encoding/protojson"
 
 	"github.com/anzx/fabric-cards/pkg/feature"
 	"github.com/anzx/fabric-cards/pkg/integration/ctm"
+	pkgutil "github.com/anzx/fabric-cards/pkg/integration/util"
+	"github.com/anzx/fabric-cards/pkg/integration/visagateway/customerrules"
 	"github.com/anzx/fabric-cards/test/data"
 	"github.com/anzx/fabric-cards/test/fixtures"
 	"github.com/anzx/fabric-cards/test/util"
 	ccpb "github.com/anzx/fabricapis/pkg/fabric/service/cardcontrols/v1beta2"
 	"github.com/anzx/fabricapis/pkg/fabric/type/audit"
 	"github.com/anzx/fabricapis/pkg/fabric/type/audit/servicedata"
+	crpb "github.com/anzx/fabricapis/pkg/gateway/visa/service/customerrules"
 	anzerrors "github.com/anzx/pkg/errors"
 	anzcodes "github.com/anzx/pkg/errors/errcodes"
 	"github.com/pkg/errors"
@@ -91,6 +96,196 @@ func TestSetControls(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:    "successful set request with a spend limit",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()),
+			req: &ccpb.SetControlsRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				CardControls: []*ccpb.ControlRequest{
+					{
+						ControlType: ccpb.ControlType_MCT_GAMBLING,
+						SpendLimit:  aSpendLimit(100, 50),
+					},
+				},
+			},
+			want: &ccpb.CardControlResponse{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				CardControls: []*ccpb.CardControl{
+					{
+						ControlType: ccpb.ControlType_MCT_GAMBLING,
+						SpendLimit:  aSpendLimit(100, 50),
+					},
+				},
+			},
+		},
+		{
+			name:    "spend limit replaces a control of the same type",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()).WithVisaGatewayControls(controlType),
+			req: &ccpb.SetControlsRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				CardControls: []*ccpb.ControlRequest{
+					{
+						ControlType: controlType,
+						SpendLimit:  aSpendLimit(20, 0),
+					},
+				},
+			},
+			want: &ccpb.CardControlResponse{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				CardControls: []*ccpb.CardControl{
+					{
+						ControlType: controlType,
+						SpendLimit:  aSpendLimit(20, 0),
+					},
+				},
+			},
+		},
+		{
+			name:    "successful set request with schedules",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()),
+			req: &ccpb.SetControlsRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				CardControls: []*ccpb.ControlRequest{
+					{
+						ControlType: controlType,
+						Schedule:    aSchedule(23, 6),
+					},
+					{
+						ControlType: controlType,
+						Schedule:    aSchedule(9, 12),
+					},
+				},
+			},
+			want: &ccpb.CardControlResponse{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				CardControls: []*ccpb.CardControl{
+					{
+						ControlType: controlType,
+						Schedules:   []*ccpb.ControlSchedule{aSchedule(23, 6), aSchedule(9, 12)},
+					},
+				},
+			},
+		},
+		{
+			name:    "unable to set overlapping schedules",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()),
+			req: &ccpb.SetControlsRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				CardControls: []*ccpb.ControlRequest{
+					{
+						ControlType: controlType,
+						Schedule:    aSchedule(23, 6),
+					},
+					{
+						ControlType: controlType,
+						Schedule:    aSchedule(5, 7),
+					},
+				},
+			},
+			wantErr: errors.New("TCT_CONTACTLESS schedules overlap"),
+		},
+		{
+			name: "schedule and spend limit replace a scheduled control of the same type",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()).
+				WithVisaGatewayResource(aScheduledControlDocument(&crpb.TimeRange{TimeZoneId: customerrules.DefaultTimeZone, StartTime: "23:00", EndTime: "06:00"})),
+			req: &ccpb.SetControlsRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				CardControls: []*ccpb.ControlRequest{
+					{
+						ControlType: controlType,
+						Schedule:    aSchedule(22, 7),
+						SpendLimit:  aSpendLimit(50, 0),
+					},
+				},
+			},
+			want: &ccpb.CardControlResponse{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				CardControls: []*ccpb.CardControl{
+					{
+						ControlType: controlType,
+						Schedules:   []*ccpb.ControlSchedule{aSchedule(22, 7)},
+						SpendLimit:  aSpendLimit(50, 0),
+					},
+				},
+			},
+		},
+		{
+			name: "schedule replaces an always active control of the same type",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()).
+				WithVisaGatewayResource(aScheduledControlDocument(nil)),
+			req: &ccpb.SetControlsRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				CardControls: []*ccpb.ControlRequest{
+					{
+						ControlType: controlType,
+						Schedule:    aSchedule(9, 17),
+					},
+				},
+			},
+			want: &ccpb.CardControlResponse{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				CardControls: []*ccpb.CardControl{
+					{
+						ControlType: controlType,
+						Schedules:   []*ccpb.ControlSchedule{aSchedule(9, 17)},
+					},
+				},
+			},
+		},
+		{
+			name: "unable to delete the replaced control",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()).
+				WithVisaGatewayResource(aScheduledControlDocument(nil)).
+				WithVisaGatewayDeleteError(anzerrors.New(codes.Unavailable, "failed request", anzerrors.NewErrorInfo(context.Background(), anzcodes.DownstreamFailure, "service unavailable"))),
+			req: &ccpb.SetControlsRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				CardControls: []*ccpb.ControlRequest{
+					{
+						ControlType: controlType,
+						Schedule:    aSchedule(9, 17),
+					},
+				},
+			},
+			wantErr: errors.New("fabric error: status_code=Unavailable, error_code=2, message=set control failed, reason=invalid response from visa gateway"),
+		},
+		{
+			name:    "successful set request for an alert-only control",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()),
+			req: &ccpb.SetControlsRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				CardControls: []*ccpb.ControlRequest{
+					{
+						ControlType:    ccpb.ControlType_MCT_GAMBLING,
+						AlertOnly:      true,
+						AlertThreshold: &money.Money{CurrencyCode: "AUD", Units: 50},
+					},
+				},
+			},
+			want: &ccpb.CardControlResponse{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				CardControls: []*ccpb.CardControl{
+					{
+						ControlType:    ccpb.ControlType_MCT_GAMBLING,
+						AlertOnly:      true,
+						AlertThreshold: &money.Money{CurrencyCode: "AUD", Units: 50},
+					},
+				},
+			},
+		},
+		{
+			name:    "unable to set an invalid spend limit",
+			builder: fixtures.AServer().WithData(data.AUserWithACard()),
+			req: &ccpb.SetControlsRequest{
+				TokenizedCardNumber: data.AUserWithACard().Token(),
+				CardControls: []*ccpb.ControlRequest{
+					{
+						ControlType: controlType,
+						SpendLimit:  aSpendLimit(0, 0),
+					},
+				},
+			},
+			wantErr: errors.New("spend limit amount must be greater than zero"),
+		},
 		{
 			name:    "audit log failure does not affect response",
 			builder: fixtures.AServer().WithData(data.AUserWithACard()).WithAuditLogError(anzerrors.New(codes.Unavailable, "failed request", anzerrors.NewErrorInfo(context.Background(), anzcodes.DownstreamFailure, "service unavailable"))),
@@ -300,3 +495,33 @@ func TestSetControls(t *testing.T) {
 		})
 	}
 }
+
+func aSpendLimit(units int64, cents int32) *ccpb.SpendLimit {
+	return &ccpb.SpendLimit{
+		Amount: &money.Money{CurrencyCode: "AUD", Units: units, Nanos: cents * 1e7},
+		Period: ccpb.SpendLimit_PERIOD_DAILY,
+	}
+}
+
+func aSchedule(start, end int32) *ccpb.ControlSchedule {
+	return &ccpb.ControlSchedule{
+		TimeZone:  customerrules.DefaultTimeZone,
+		StartTime: &timeofday.TimeOfDay{Hours: start},
+		EndTime:   &timeofday.TimeOfDay{Hours: end},
+	}
+}
+
+// aScheduledControlDocument returns a control document with a contactless control restricted to the time range
+func aScheduledControlDocument(timeRange *crpb.TimeRange) *crpb.Resource {
+	return &crpb.Resource{
+		DocumentId: documentID,
+		TransactionControls: []*crpb.TransactionControl{
+			{
+				ControlType:      controlType.String(),
+				IsControlEnabled: true,
+				ShouldDeclineAll: pkgutil.ToBoolPtr(true),
+				TimeRange:        timeRange,
+			},
+		},
+	}
+}
@@ -63,6 +63,8 @@ func (s server) TransferControls(ctx context.Context, req *ccpb.TransferControls
 
 	s.CommandCentre.PublishEventAsync(ctx, event.CardControlsChange)
 
+	s.transferExpiries(ctx, req.CurrentTokenizedCardNumber, req.NewTokenizedCardNumber)
+
 	// Remove global control as part of the transferControls
 	detachCtx := xcontext.Detach(visaCtx)
 	go func() {
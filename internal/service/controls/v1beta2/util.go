@@ -29,34 +29,51 @@ func getCardControlResponse(controlDocument *crpb.Resource, tokenizedCardNumber
 
 	for _, transactionControl := range controlDocument.TransactionControls {
 		controlType := ccpb.ControlType(ccpb.ControlType_value[transactionControl.ControlType])
-		if _, hasControl := controlSet[controlType]; hasControl {
+		if !transactionControl.GetIsControlEnabled() {
 			continue
 		}
-		if transactionControl.GetIsControlEnabled() {
-			controlSet[controlType] = &ccpb.CardControl{
-				ControlType: controlType,
-			}
+		if control, hasControl := controlSet[controlType]; hasControl {
+			// a control type is set more than once when each is restricted to a different schedule
+			addSchedule(control, transactionControl.GetTimeRange())
+			continue
 		}
+		controlSet[controlType] = &ccpb.CardControl{
+			ControlType:    controlType,
+			SpendLimit:     customerrules.GetSpendLimit(transactionControl.GetSpendLimit()),
+			AlertOnly:      customerrules.AlertOnly(transactionControl.ShouldDeclineAll, transactionControl.GetSpendLimit()),
+			AlertThreshold: customerrules.GetAlertThreshold(transactionControl.AlertThreshold),
+		}
+		addSchedule(controlSet[controlType], transactionControl.GetTimeRange())
 	}
 
 	for _, merchantControl := range controlDocument.MerchantControls {
 		controlType := ccpb.ControlType(ccpb.ControlType_value[merchantControl.ControlType])
-		if _, hasControl := controlSet[controlType]; hasControl {
+		if !merchantControl.GetIsControlEnabled() {
+			continue
+		}
+		if control, hasControl := controlSet[controlType]; hasControl {
+			// a control type is set more than once when each is restricted to a different schedule
+			addSchedule(control, merchantControl.GetTimeRange())
 			continue
 		}
-		if merchantControl.GetIsControlEnabled() {
-			if controlType == ccpb.ControlType_MCT_GAMBLING {
-				controlSet[controlType] = &ccpb.CardControl{
-					ControlType:        controlType,
-					ImpulseDelayStart:  customerrules.GetImpulseDelayStartTimestamp(merchantControl),
-					ImpulseDelayPeriod: customerrules.GetImpulseDelayPeriodProto(merchantControl),
-				}
-			} else {
-				controlSet[controlType] = &ccpb.CardControl{
-					ControlType: controlType,
-				}
+		if controlType == ccpb.ControlType_MCT_GAMBLING {
+			controlSet[controlType] = &ccpb.CardControl{
+				ControlType:        controlType,
+				ImpulseDelayStart:  customerrules.GetImpulseDelayStartTimestamp(merchantControl),
+				ImpulseDelayPeriod: customerrules.GetImpulseDelayPeriodProto(merchantControl),
+				SpendLimit:         customerrules.GetSpendLimit(merchantControl.GetSpendLimit()),
+				AlertOnly:          customerrules.AlertOnly(merchantControl.ShouldDeclineAll, merchantControl.GetSpendLimit()),
+				AlertThreshold:     customerrules.GetAlertThreshold(merchantControl.AlertThreshold),
+			}
+		} else {
+			controlSet[controlType] = &ccpb.CardControl{
+				ControlType:    controlType,
+				SpendLimit:     customerrules.GetSpendLimit(merchantControl.GetSpendLimit()),
+				AlertOnly:      customerrules.AlertOnly(merchantControl.ShouldDeclineAll, merchantControl.GetSpendLimit()),
+				AlertThreshold: customerrules.GetAlertThreshold(merchantControl.AlertThreshold),
 			}
 		}
+		addSchedule(controlSet[controlType], merchantControl.GetTimeRange())
 	}
 
 	var out []*ccpb.CardControl
@@ -70,6 +87,13 @@ func getCardControlResponse(controlDocument *crpb.Resource, tokenizedCardNumber
 	}
 }
 
+// addSchedule adds the schedule of a Visa control to the control returned to the customer
+func addSchedule(control *ccpb.CardControl, timeRange *crpb.TimeRange) {
+	if schedule := customerrules.GetSchedule(timeRange); schedule != nil {
+		control.Schedules = append(control.Schedules, schedule)
+	}
+}
+
 func serviceErr(err error, msg string) error {
 	return anzerrors.Wrap(err, anzerrors.GetStatusCode(err), msg, anzerrors.GetErrorInfo(err))
 }
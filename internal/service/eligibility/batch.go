@@ -0,0 +1,139 @@
+package eligibility
+
+import (
+	"context"
+	"sync"
+
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+	"google.golang.org/grpc/codes"
+
+	"github.com/anzx/fabric-cards/pkg/integration/ctm"
+
+	epb "github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1"
+)
+
+const (
+	batchCanFailed = "batch eligibility failed"
+	// maxBatchCards bounds the CTM inquiries a single request can make
+	maxBatchCards = 20
+	// batchConcurrency is how many cards are inquired from CTM at once
+	batchConcurrency = 5
+)
+
+// BatchCan evaluates many eligibilities for many cards. Entitlements are listed once and every card is inquired once,
+// however many eligibilities are requested for it.
+func (s server) BatchCan(ctx context.Context, req *epb.BatchCanRequest) (*epb.BatchCanResponse, error) {
+	tokenizedCardNumbers := unique(req.GetTokenizedCardNumbers())
+	if err := validateBatch(ctx, tokenizedCardNumbers, req.GetEligibilities()); err != nil {
+		return nil, err
+	}
+
+	entitledCards, err := s.entitlements.ListEntitledCards(ctx)
+	if err != nil {
+		return nil, anzerrors.Wrap(err, anzerrors.GetStatusCode(err), batchCanFailed, anzerrors.GetErrorInfo(err))
+	}
+
+	entitled := make(map[string]bool, len(entitledCards))
+	for _, card := range entitledCards {
+		entitled[card.GetTokenizedCardNumber()] = true
+	}
+	for _, tokenizedCardNumber := range tokenizedCardNumbers {
+		if !entitled[tokenizedCardNumber] {
+			return nil, anzerrors.New(codes.PermissionDenied, batchCanFailed,
+				anzerrors.NewErrorInfo(ctx, anzcodes.CardNotFound, "card not entitled"))
+		}
+	}
+
+	cards, err := s.inquireAll(ctx, tokenizedCardNumbers)
+	if err != nil {
+		return nil, anzerrors.Wrap(err, anzerrors.GetStatusCode(err), batchCanFailed, anzerrors.GetErrorInfo(err))
+	}
+
+	response := &epb.BatchCanResponse{
+		Cards: make([]*epb.CardEligibilities, 0, len(cards)),
+	}
+	for i, card := range cards {
+		response.Cards = append(response.Cards, evaluateCard(tokenizedCardNumbers[i], card, req.GetEligibilities()))
+	}
+	return response, nil
+}
+
+func validateBatch(ctx context.Context, tokenizedCardNumbers []string, eligibilities []epb.Eligibility) error {
+	var reason string
+	switch {
+	case len(tokenizedCardNumbers) == 0:
+		reason = "tokenized card numbers required"
+	case len(tokenizedCardNumbers) > maxBatchCards:
+		reason = "too many cards requested"
+	case len(eligibilities) == 0:
+		reason = "eligibilities required"
+	default:
+		for _, eligibility := range eligibilities {
+			if eligibility == epb.Eligibility_ELIGIBILITY_INVALID_UNSPECIFIED {
+				reason = "invalid eligibility"
+			}
+		}
+	}
+	if reason == "" {
+		return nil
+	}
+	return anzerrors.New(codes.InvalidArgument, batchCanFailed, anzerrors.NewErrorInfo(ctx, anzcodes.ValidationFailure, reason))
+}
+
+// inquireAll inquires every card from CTM in the order requested, the first failure fails the batch
+func (s server) inquireAll(ctx context.Context, tokenizedCardNumbers []string) ([]*ctm.DebitCardResponse, error) {
+	cards := make([]*ctm.DebitCardResponse, len(tokenizedCardNumbers))
+	errs := make([]error, len(tokenizedCardNumbers))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < batchConcurrency && w < len(tokenizedCardNumbers); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				cards[i], errs[i] = s.ctm.DebitCardInquiry(ctx, tokenizedCardNumbers[i])
+			}
+		}()
+	}
+
+	for i := range tokenizedCardNumbers {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cards, nil
+}
+
+func evaluateCard(tokenizedCardNumber string, card *ctm.DebitCardResponse, eligibilities []epb.Eligibility) *epb.CardEligibilities {
+	out := &epb.CardEligibilities{
+		TokenizedCardNumber: tokenizedCardNumber,
+	}
+	for _, eligibility := range eligibilities {
+		if ineligibility, ineligible := card.Ineligibility(eligibility); ineligible {
+			out.Ineligibilities = append(out.Ineligibilities, ineligibility.ToProto())
+			continue
+		}
+		out.Eligibilities = append(out.Eligibilities, eligibility)
+	}
+	return out
+}
+
+func unique(in []string) []string {
+	seen := make(map[string]bool, len(in))
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
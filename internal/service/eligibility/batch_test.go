@@ -0,0 +1,147 @@
+package eligibility
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/anzx/fabric-cards/pkg/integration/ctm"
+	"github.com/anzx/fabric-cards/test/data"
+	"github.com/anzx/fabric-cards/test/fixtures"
+
+	epb "github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBatchCan(t *testing.T) {
+	const stolenToken = "stolenToken"
+	user := data.AUser(
+		data.WithACard(),
+		data.WithACard(
+			data.WithAToken(stolenToken),
+			data.WithACardNumber("4622390512341001"),
+			data.WithStatus(ctm.StatusStolen)))
+
+	tests := []struct {
+		name    string
+		builder *fixtures.ServerBuilder
+		req     *epb.BatchCanRequest
+		want    *epb.BatchCanResponse
+		wantErr string
+	}{
+		{
+			name:    "evaluates every eligibility for every card",
+			builder: fixtures.AServer().WithData(user),
+			req: &epb.BatchCanRequest{
+				TokenizedCardNumbers: []string{user.Token(), stolenToken, user.Token()},
+				Eligibilities:        []epb.Eligibility{epb.Eligibility_ELIGIBILITY_APPLE_PAY, epb.Eligibility_ELIGIBILITY_GET_DETAILS},
+			},
+			want: &epb.BatchCanResponse{
+				Cards: []*epb.CardEligibilities{
+					{
+						TokenizedCardNumber: user.Token(),
+						Eligibilities:       []epb.Eligibility{epb.Eligibility_ELIGIBILITY_APPLE_PAY, epb.Eligibility_ELIGIBILITY_GET_DETAILS},
+					},
+					{
+						TokenizedCardNumber: stolenToken,
+						Ineligibilities: []*epb.Ineligibility{
+							{
+								Eligibility: epb.Eligibility_ELIGIBILITY_APPLE_PAY,
+								Reason:      "CARD_STOLEN",
+								Facts:       map[string]string{"status": "Stolen"},
+							},
+							{
+								Eligibility: epb.Eligibility_ELIGIBILITY_GET_DETAILS,
+								Reason:      "CARD_STOLEN",
+								Facts:       map[string]string{"status": "Stolen"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:    "no cards requested",
+			builder: fixtures.AServer().WithData(user),
+			req: &epb.BatchCanRequest{
+				Eligibilities: []epb.Eligibility{epb.Eligibility_ELIGIBILITY_APPLE_PAY},
+			},
+			wantErr: "message=batch eligibility failed, reason=tokenized card numbers required",
+		},
+		{
+			name:    "no eligibilities requested",
+			builder: fixtures.AServer().WithData(user),
+			req: &epb.BatchCanRequest{
+				TokenizedCardNumbers: []string{user.Token()},
+			},
+			wantErr: "message=batch eligibility failed, reason=eligibilities required",
+		},
+		{
+			name:    "unspecified eligibility",
+			builder: fixtures.AServer().WithData(user),
+			req: &epb.BatchCanRequest{
+				TokenizedCardNumbers: []string{user.Token()},
+				Eligibilities:        []epb.Eligibility{epb.Eligibility_ELIGIBILITY_INVALID_UNSPECIFIED},
+			},
+			wantErr: "message=batch eligibility failed, reason=invalid eligibility",
+		},
+		{
+			name:    "card not entitled",
+			builder: fixtures.AServer().WithData(user),
+			req: &epb.BatchCanRequest{
+				TokenizedCardNumbers: []string{user.Token(), "someoneElsesCard"},
+				Eligibilities:        []epb.Eligibility{epb.Eligibility_ELIGIBILITY_APPLE_PAY},
+			},
+			wantErr: "message=batch eligibility failed, reason=card not entitled",
+		},
+		{
+			name:    "entitlements fail",
+			builder: fixtures.AServer().WithData(user).WithEntListError(errors.New("oh no")),
+			req: &epb.BatchCanRequest{
+				TokenizedCardNumbers: []string{user.Token()},
+				Eligibilities:        []epb.Eligibility{epb.Eligibility_ELIGIBILITY_APPLE_PAY},
+			},
+			wantErr: "message=batch eligibility failed",
+		},
+		{
+			name:    "ctm fails for one card",
+			builder: fixtures.AServer().WithData(user).WithCtmInquiryErrorFor(stolenToken, errors.New("oh no")),
+			req: &epb.BatchCanRequest{
+				TokenizedCardNumbers: []string{user.Token(), stolenToken},
+				Eligibilities:        []epb.Eligibility{epb.Eligibility_ELIGIBILITY_APPLE_PAY},
+			},
+			wantErr: "message=batch eligibility failed",
+		},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			s := BuildEligibilityServer(test.builder)
+			got, err := s.BatchCan(fixtures.GetTestContext(), test.req)
+			if test.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, proto.Equal(test.want, got), "got %v", got)
+		})
+	}
+}
+
+func TestBatchCanTooManyCards(t *testing.T) {
+	tokenizedCardNumbers := make([]string, 0, maxBatchCards+1)
+	for i := 0; i <= maxBatchCards; i++ {
+		tokenizedCardNumbers = append(tokenizedCardNumbers, string(rune('a'+i)))
+	}
+
+	s := BuildEligibilityServer(fixtures.AServer().WithData(data.AUserWithACard()))
+	_, err := s.BatchCan(fixtures.GetTestContext(), &epb.BatchCanRequest{
+		TokenizedCardNumbers: tokenizedCardNumbers,
+		Eligibilities:        []epb.Eligibility{epb.Eligibility_ELIGIBILITY_APPLE_PAY},
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reason=too many cards requested")
+}
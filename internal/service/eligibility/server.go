@@ -3,12 +3,10 @@ package eligibility
 import (
 	"context"
 
-	anzerrors "github.com/anzx/pkg/errors"
-	anzcodes "github.com/anzx/pkg/errors/errcodes"
-	"google.golang.org/grpc/codes"
-
 	"github.com/anzx/fabric-cards/pkg/integration/vault"
 
+	evaluator "github.com/anzx/fabric-cards/pkg/integration/eligibility"
+
 	"github.com/anzx/fabric-cards/pkg/integration/entitlements"
 
 	"github.com/anzx/fabric-cards/pkg/integration/ctm"
@@ -43,8 +41,9 @@ func (s server) Can(ctx context.Context, req *epb.CanRequest) (*epb.CanResponse,
 		return nil, err
 	}
 
-	if eligible := cardDetails.HasEligibility(req.Eligibility); !eligible {
-		return nil, anzerrors.New(codes.InvalidArgument, "eligibility failed", anzerrors.NewErrorInfo(ctx, anzcodes.CardIneligible, "card not eligible"))
+	// the reason the card is not eligible is returned as an error detail so clients can explain it to the customer
+	if err := evaluator.Evaluate(ctx, req.Eligibility, cardDetails); err != nil {
+		return nil, err
 	}
 	return &epb.CanResponse{}, nil
 }
@@ -14,6 +14,9 @@ import (
 
 	epb "github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestCan(t *testing.T) {
@@ -87,6 +90,57 @@ func TestCan(t *testing.T) {
 	}
 }
 
+func TestCanIneligibilityDetails(t *testing.T) {
+	tests := []struct {
+		name        string
+		card        *data.User
+		eligibility epb.Eligibility
+		want        *epb.Ineligibility
+	}{
+		{
+			name:        "closed card",
+			card:        data.AUserWithACard(data.WithStatus(ctm.StatusClosed)),
+			eligibility: epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_LOST,
+			want: &epb.Ineligibility{
+				Eligibility: epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_LOST,
+				Reason:      "CARD_CLOSED",
+				Facts:       map[string]string{"status": "Closed"},
+			},
+		},
+		{
+			name:        "inactive card",
+			card:        data.AUserWithACard(data.Inactive),
+			eligibility: epb.Eligibility_ELIGIBILITY_GET_DETAILS,
+			want: &epb.Ineligibility{
+				Eligibility: epb.Eligibility_ELIGIBILITY_GET_DETAILS,
+				Reason:      "CARD_NOT_ACTIVATED",
+				Facts:       map[string]string{"status": "Issued", "activationStatus": "false"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			s := BuildEligibilityServer(fixtures.AServer().WithData(test.card))
+			_, err := s.Can(fixtures.GetTestContext(), &epb.CanRequest{
+				TokenizedCardNumber: test.card.Token(),
+				Eligibility:         test.eligibility,
+			})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "reason=card not eligible")
+
+			var got *epb.Ineligibility
+			for _, detail := range status.Convert(err).Details() {
+				if ineligibility, ok := detail.(*epb.Ineligibility); ok {
+					got = ineligibility
+				}
+			}
+			require.NotNil(t, got)
+			assert.True(t, proto.Equal(test.want, got), "got %v", got)
+		})
+	}
+}
+
 func BuildEligibilityServer(c *fixtures.ServerBuilder) epb.CardEligibilityAPIServer {
 	return NewServer(
 		entitlements.Client{
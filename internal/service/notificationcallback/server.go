@@ -36,10 +36,15 @@ func NewServer(cmdcntr sdk.Publisher) ncpb.NotificationCallbackAPIServer {
 }
 
 func (s server) Alert(ctx context.Context, request *ncpb.Request) (*ncpb.Response, error) {
-	if !feature.FeatureGate.Enabled(feature.NotificationCallbackDeclinedEvent) {
+	declined := request.GetTransactionOutcome().GetTransactionApproved() == transactionDeclined
+	if declined && !feature.FeatureGate.Enabled(feature.NotificationCallbackDeclinedEvent) {
 		logf.Info(ctx, "notification callback declined events are disabled by feature flag")
 		return &ncpb.Response{}, nil
 	}
+	if !declined && !feature.FeatureGate.Enabled(feature.NotificationCallbackSpendAlertEvent) {
+		logf.Info(ctx, "notification callback: spend alert events are disabled by feature flag, nothing to do")
+		return &ncpb.Response{}, nil
+	}
 
 	details := request.GetTransactionDetails()
 	personaId := details.GetUserIdentifier()
@@ -47,9 +52,9 @@ func (s server) Alert(ctx context.Context, request *ncpb.Request) (*ncpb.Respons
 		return nil, errors.New("no user identifier present visa notification callback request")
 	}
 
-	// Early out if the transaction was not declined
-	if request.GetTransactionOutcome().GetTransactionApproved() != transactionDeclined {
-		logf.Info(ctx, "notification callback: transaction was approved, nothing to do")
+	// Approved transactions are only notified when an alert-only control or alert threshold triggered
+	if !declined && len(request.GetTransactionOutcome().GetAlertDetails()) == 0 {
+		logf.Info(ctx, "notification callback: transaction was approved without an alert, nothing to do")
 		return &ncpb.Response{}, nil
 	}
 
@@ -70,9 +75,14 @@ func (s server) Alert(ctx context.Context, request *ncpb.Request) (*ncpb.Respons
 
 	moneyValue := details.GetCardholderBillAmount()
 
-	ccreq := transactionDeclinedNotification(ctx, personaId, currencyCodeName, moneyValue, maskedCardNumber, merchantName)
+	var ccreq *sdk.NotificationForPersona
+	if declined {
+		ccreq = transactionDeclinedNotification(ctx, personaId, currencyCodeName, moneyValue, maskedCardNumber, merchantName)
+	} else {
+		ccreq = spendAlertNotification(ctx, personaId, currencyCodeName, moneyValue, maskedCardNumber, merchantName)
+	}
 
-	log.Info(ctx, "Publishing controls declined notification", log.Str("personaID", personaId), log.Str("title", ccreq.Preview.Title), log.Str("body", ccreq.Preview.Body))
+	log.Info(ctx, "Publishing controls notification", log.Str("personaID", personaId), log.Str("title", ccreq.Preview.Title), log.Str("body", ccreq.Preview.Body))
 
 	resp, err := s.CommandCentre.Publish(ctx, ccreq)
 	if err != nil {
@@ -80,7 +90,10 @@ func (s server) Alert(ctx context.Context, request *ncpb.Request) (*ncpb.Respons
 	}
 
 	if resp.Status != sdk.PublishResponsePublished {
-		return nil, errors.New("failed to publish controls declined alert")
+		if declined {
+			return nil, errors.New("failed to publish controls declined alert")
+		}
+		return nil, errors.New("failed to publish controls spend alert")
 	}
 
 	log.Info(ctx, "Notification sent", log.Str("personaID", personaId))
@@ -131,3 +144,28 @@ func transactionDeclinedNotification(ctx context.Context, persona string, curren
 		IdempotencyKey: uuid.NewString(),
 	}
 }
+
+func spendAlertNotification(ctx context.Context, persona string, currency string, value float32, maskedCardNumber string, merchantName string) *sdk.NotificationForPersona {
+	valueString := fmt.Sprintf("%0.2f", value)
+	last4digits := maskedCardNumber[len(maskedCardNumber)-4:]
+	var body string
+	if merchantName == "" {
+		body = fmt.Sprintf("A transaction of %s%s was approved on your card ending in %s. You asked to be alerted about these transactions", currency, valueString, last4digits)
+	} else {
+		body = fmt.Sprintf("A transaction of %s%s (%s) was approved on your card ending in %s. You asked to be alerted about these transactions", currency, valueString, merchantName, last4digits)
+	}
+
+	log.Debug(ctx, "Notification Composed", log.Str("currency", currency), log.Str("valueString", valueString), log.Str("merchantName", merchantName), log.Str("last4digits", last4digits))
+
+	return &sdk.NotificationForPersona{
+		PersonaID: persona,
+		Notification: notification.Simple{
+			ActionURL: "https://plus.anz/cards",
+		},
+		Preview: notification.Preview{
+			Title: "Spend Alert",
+			Body:  body,
+		},
+		IdempotencyKey: uuid.NewString(),
+	}
+}
@@ -61,6 +61,7 @@ func TestServer_Alert(t *testing.T) {
 		expectedError      string
 		expectedPubSubSend int
 		want               string
+		spendAlerts        bool
 	}{
 		{
 			name:    "Skip invalid request",
@@ -246,6 +247,58 @@ func TestServer_Alert(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "spend alert for approved transaction",
+			request: &ncpb.Request{
+				TransactionDetails: &ncpb.TransactionDetails{
+					UserIdentifier:           aPersonaID,
+					BillerCurrencyCode:       "036",
+					RequestReceivedTimeStamp: "12345",
+					PrimaryAccountNumber:     "1234123412341234",
+					CardholderBillAmount:     42.5,
+					MerchantInfo: &ncpb.MerchantInfo{
+						Name:                 "Lime",
+						CountryCode:          "AUD",
+						MerchantCategoryCode: "1234",
+						CurrencyCode:         "AUD",
+					},
+				},
+				TransactionOutcome: &ncpb.TransactionOutcome{
+					DecisionId:                "123",
+					NotificationId:            "abc123",
+					TransactionApproved:       "APPROVED",
+					DecisionResponseTimeStamp: "1234",
+					AlertDetails: []*ncpb.AlertDetails{
+						{
+							TriggeringAppId: gofakeit.UUID(),
+							RuleCategory:    "PCT_MERCHANT",
+							RuleType:        cardcontrols.ControlType_MCT_GAMBLING.String(),
+						},
+					},
+				},
+			},
+			spendAlerts:        true,
+			want:               "A transaction of $42.50 (Lime) was approved on your card ending in 1234. You asked to be alerted about these transactions",
+			expectedPubSubSend: 1,
+		},
+		{
+			name: "nothing done when approved transaction triggered no alert",
+			request: &ncpb.Request{
+				TransactionDetails: &ncpb.TransactionDetails{
+					UserIdentifier:           aPersonaID,
+					BillerCurrencyCode:       "036",
+					RequestReceivedTimeStamp: "12345",
+					PrimaryAccountNumber:     "1234123412341234",
+				},
+				TransactionOutcome: &ncpb.TransactionOutcome{
+					DecisionId:                "123",
+					NotificationId:            "abc123",
+					TransactionApproved:       "APPROVED",
+					DecisionResponseTimeStamp: "1234",
+				},
+			},
+			spendAlerts: true,
+		},
 		{
 			name: "rejects transaction with no payment token",
 			request: &ncpb.Request{
@@ -285,7 +338,8 @@ func TestServer_Alert(t *testing.T) {
 			}
 
 			require.NoError(t, feature.FeatureGate.Set(map[feature.Feature]bool{
-				feature.NotificationCallbackDeclinedEvent: true,
+				feature.NotificationCallbackDeclinedEvent:   true,
+				feature.NotificationCallbackSpendAlertEvent: test.spendAlerts,
 			}))
 
 			cc := cc.NewFakePublisher()
@@ -350,3 +404,9 @@ func TestTransactionDeclinedNotification(t *testing.T) {
 		})
 	}
 }
+
+func TestSpendAlertNotification(t *testing.T) {
+	res := spendAlertNotification(context.Background(), "1233", "AUD", 12.34, "************1234", "")
+	require.Equal(t, "Spend Alert", res.Preview.Title)
+	require.Equal(t, "A transaction of AUD12.34 was approved on your card ending in 1234. You asked to be alerted about these transactions", res.Preview.Body)
+}
@@ -32,6 +32,7 @@ func TestNewService(t *testing.T) {
 	c := fixtures.AServer().WithData(data.AUserWithACard())
 	eligibility := &eligibility.Client{
 		CardEligibilityAPIClient: c.CardEligibilityAPIClient,
+		Evaluator:                c.CardEligibilityAPIClient,
 	}
 	entitlements := &entitlements.Client{
 		CardEntitlementsAPIClient: c.CardEntitlementsAPIClient,
@@ -50,6 +51,7 @@ func TestNewService(t *testing.T) {
 func buildCardServer(c *fixtures.ServerBuilder) cpb.WalletAPIServer {
 	eligibility := &eligibility.Client{
 		CardEligibilityAPIClient: c.CardEligibilityAPIClient,
+		Evaluator:                c.CardEligibilityAPIClient,
 	}
 	entitlements := &entitlements.Client{
 		CardEntitlementsAPIClient: c.CardEntitlementsAPIClient,
@@ -0,0 +1,287 @@
+// Package expiry removes card controls once they expire. Expiries are persisted in Redis so they survive restarts, and
+// every scheduler leases an expiry before removing it so running several schedulers removes each control once.
+package expiry
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/anzx/pkg/gsm"
+
+	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
+	"github.com/anzx/fabric-cards/pkg/ratelimit"
+)
+
+type Config struct {
+	// Redis the expiries are stored in, the Redis of the idempotency keys is used if not set
+	Redis *ratelimit.RedisConfig `json:"redis,omitempty" yaml:"redis,omitempty" mapstructure:"redis"`
+	// Prefix to be added to every key, can be empty
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty" mapstructure:"prefix"`
+	// Interval is how often expired controls are looked for, defaults to 30s
+	Interval time.Duration `json:"interval,omitempty" yaml:"interval,omitempty" mapstructure:"interval"`
+	// Lease is how long a scheduler owns an expiry it is removing before another may take it over, defaults to 2m
+	Lease time.Duration `json:"lease,omitempty" yaml:"lease,omitempty" mapstructure:"lease"`
+	// Backoff is the delay before a failed removal is retried, it doubles with every attempt. Defaults to 1m
+	Backoff time.Duration `json:"backoff,omitempty" yaml:"backoff,omitempty" mapstructure:"backoff"`
+	// Attempts is how many times a removal may fail before every further failure is reported as stuck, the removal
+	// is still retried at the longest backoff. Defaults to 10
+	Attempts int `json:"attempts,omitempty" yaml:"attempts,omitempty" mapstructure:"attempts" validate:"gte=0"`
+	// MaxDuration is how far in the future a control may expire, defaults to 90 days
+	MaxDuration time.Duration `json:"maxDuration,omitempty" yaml:"maxDuration,omitempty" mapstructure:"maxDuration"`
+	// Scopes are requested for the system JWT expired controls are removed with
+	Scopes []string `json:"scopes,omitempty" yaml:"scopes,omitempty" mapstructure:"scopes"`
+}
+
+const (
+	defaultInterval    = 30 * time.Second
+	defaultLease       = 2 * time.Minute
+	defaultBackoff     = time.Minute
+	defaultAttempts    = 10
+	defaultMaxDuration = 90 * 24 * time.Hour
+	batchSize          = 100
+)
+
+func (c Config) withDefaults() Config {
+	if c.Interval <= 0 {
+		c.Interval = defaultInterval
+	}
+	if c.Lease <= 0 {
+		c.Lease = defaultLease
+	}
+	if c.Backoff <= 0 {
+		c.Backoff = defaultBackoff
+	}
+	if c.Attempts <= 0 {
+		c.Attempts = defaultAttempts
+	}
+	if c.MaxDuration <= 0 {
+		c.MaxDuration = defaultMaxDuration
+	}
+	return c
+}
+
+// Expiry of a control on a card, the persona is the customer the control is removed for
+type Expiry struct {
+	TokenizedCardNumber string    `json:"tokenizedCardNumber"`
+	ControlType         string    `json:"controlType"`
+	PersonaID           string    `json:"personaId"`
+	OcvID               string    `json:"ocvId,omitempty"`
+	ExpireTime          time.Time `json:"expireTime"`
+	// Attempts counts the failed removals
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// Handler removes an expired control, it must succeed if the control was already removed
+type Handler func(ctx context.Context, expiry *Expiry) error
+
+// Scheduler persists expiries and removes expired controls with its Handler
+type Scheduler struct {
+	config Config
+	store  *store
+	// owner identifies the instance in the leases it holds
+	owner   string
+	now     func() time.Time
+	handler Handler
+}
+
+// NewScheduler returns nil if no config is provided. The Redis in config is used if set, otherwise client, which is
+// usually the idempotency Redis. Controls can't be set to expire if there is no Redis.
+func NewScheduler(ctx context.Context, config *Config, client redis.Cmdable, gsmClient *gsm.Client) (*Scheduler, error) {
+	if config == nil {
+		logf.Debug(ctx, "expiry config not provided %v", config)
+		return nil, nil
+	}
+
+	if config.Redis != nil {
+		if err := config.Redis.GetSecrets(ctx, gsmClient); err != nil {
+			logf.Error(ctx, err, "expiry: failed to get redis secret")
+			return nil, errors.Wrap(err, "unable to access secret")
+		}
+		redisClient, err := ratelimit.NewRedisClient(ctx, *config.Redis)
+		if err != nil {
+			// the client reconnects, expiries can't be set or removed until Redis is available
+			logf.Error(ctx, err, "expiry: redis unavailable")
+		}
+		client = redisClient
+	}
+
+	if client == nil {
+		logf.Info(ctx, "expiry: redis not configured, controls can't be set to expire")
+		return nil, nil
+	}
+
+	c := config.withDefaults()
+	return &Scheduler{
+		config: c,
+		store:  &store{client: client, prefix: c.Prefix},
+		owner:  uuid.New().String(),
+		now:    time.Now,
+	}, nil
+}
+
+// Handle sets the handler expired controls are removed with
+func (s *Scheduler) Handle(handler Handler) {
+	if s == nil {
+		return
+	}
+	s.handler = handler
+}
+
+// Scopes returns the scopes configured for the system JWT expired controls are removed with
+func (s *Scheduler) Scopes() []string {
+	if s == nil {
+		return nil
+	}
+	return s.config.Scopes
+}
+
+// MaxDuration returns how far in the future a control may expire
+func (s *Scheduler) MaxDuration() time.Duration {
+	if s == nil {
+		return 0
+	}
+	return s.config.MaxDuration
+}
+
+// ErrUnavailable is returned when an expiry is scheduled without a scheduler
+var ErrUnavailable = errors.New("expiries are not configured")
+
+// Schedule persists an expiry, it replaces the expiry of the same control on the card
+func (s *Scheduler) Schedule(ctx context.Context, expiry *Expiry) error {
+	if s == nil {
+		return ErrUnavailable
+	}
+	return s.store.save(ctx, expiry, expiry.ExpireTime)
+}
+
+// Cancel forgets the expiries of controls on a card
+func (s *Scheduler) Cancel(ctx context.Context, tokenizedCardNumber string, controlTypes ...string) error {
+	if s == nil || len(controlTypes) == 0 {
+		return nil
+	}
+	return s.store.delete(ctx, tokenizedCardNumber, controlTypes...)
+}
+
+// Get returns the expiries of the controls on a card by control type
+func (s *Scheduler) Get(ctx context.Context, tokenizedCardNumber string) (map[string]*Expiry, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return s.store.card(ctx, tokenizedCardNumber)
+}
+
+// Run returns a function suitable for an errgroup which removes expired controls until ctx is done
+func (s *Scheduler) Run(ctx context.Context) func() error {
+	return func() error {
+		if s == nil {
+			return nil
+		}
+
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+
+		logf.Info(ctx, "expiry: removing expired controls every %v", s.config.Interval)
+
+		for {
+			s.ExpireDue(ctx)
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// ExpireDue removes every control that has expired, expiries leased by another scheduler are skipped
+func (s *Scheduler) ExpireDue(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	if s.handler == nil {
+		logf.Error(ctx, errors.New("no handler"), "expiry: unable to remove expired controls")
+		return
+	}
+
+	members, err := s.store.due(ctx, s.now(), batchSize)
+	if err != nil {
+		logf.Error(ctx, err, "expiry: unable to list expired controls")
+		return
+	}
+
+	for _, member := range members {
+		if ctx.Err() != nil {
+			return
+		}
+		s.expire(ctx, member)
+	}
+}
+
+func (s *Scheduler) expire(ctx context.Context, member string) {
+	leased, err := s.store.lease(ctx, member, s.owner, s.config.Lease)
+	if err != nil {
+		logf.Error(ctx, err, "expiry: unable to lease %s", member)
+		return
+	}
+	if !leased {
+		return
+	}
+	defer func() {
+		if err := s.store.release(ctx, member, s.owner); err != nil {
+			logf.Error(ctx, err, "expiry: unable to release lease of %s", member)
+		}
+	}()
+
+	expiry, payload, err := s.store.get(ctx, member)
+	if err == ErrNotFound {
+		_ = s.store.unschedule(ctx, member)
+		return
+	}
+	if err != nil {
+		logf.Error(ctx, err, "expiry: unable to read %s", member)
+		return
+	}
+	if expiry.ExpireTime.After(s.now()) {
+		// extended since it was listed
+		return
+	}
+
+	if err := s.handler(ctx, expiry); err != nil {
+		s.retry(ctx, expiry, payload, err)
+		return
+	}
+
+	logf.Info(ctx, "expiry: removed %s from card %s", expiry.ControlType, expiry.TokenizedCardNumber)
+	if err := s.store.complete(ctx, expiry, payload); err != nil {
+		logf.Error(ctx, err, "expiry: unable to complete %s", member)
+	}
+}
+
+// retry schedules a failed removal after a backoff. A removal is never given up on, the expiry stays stored so it is
+// still shown on the card, and once it ran out of attempts every failure is reported as stuck for an operator.
+func (s *Scheduler) retry(ctx context.Context, expiry *Expiry, payload string, cause error) {
+	expiry.Attempts++
+	if expiry.Attempts >= s.config.Attempts {
+		logf.Error(ctx, cause, "expiry: removal of %s on card %s stuck after %d attempts, it must be looked at by an operator",
+			expiry.ControlType, expiry.TokenizedCardNumber, expiry.Attempts)
+	} else {
+		logf.Error(ctx, cause, "expiry: removal of %s on card %s failed, attempt %d", expiry.ControlType, expiry.TokenizedCardNumber, expiry.Attempts)
+	}
+	if err := s.store.replace(ctx, expiry, payload, s.now().Add(backoff(s.config.Backoff, expiry.Attempts))); err != nil {
+		logf.Error(ctx, err, "expiry: unable to retry %s on card %s", expiry.ControlType, expiry.TokenizedCardNumber)
+	}
+}
+
+// backoff doubles base for every previous try
+func backoff(base time.Duration, tries int) time.Duration {
+	delay := base
+	for i := 1; i < tries && delay < time.Hour; i++ {
+		delay *= 2
+	}
+	return delay
+}
@@ -0,0 +1,206 @@
+package expiry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const card = "6688390000000001"
+
+var start = time.Date(2022, 1, 10, 9, 0, 0, 0, time.UTC)
+
+func newTestScheduler(t *testing.T, client redis.Cmdable) *Scheduler {
+	s, err := NewScheduler(context.Background(), &Config{
+		Prefix:   "test:",
+		Backoff:  time.Minute,
+		Attempts: 2,
+	}, client, nil)
+	require.NoError(t, err)
+	s.now = func() time.Time { return start }
+	return s
+}
+
+func newTestRedis(t *testing.T) (redis.Cmdable, *miniredis.Miniredis) {
+	m, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(m.Close)
+	return redis.NewClient(&redis.Options{Addr: m.Addr()}), m
+}
+
+func anExpiry(controlType string, at time.Time) *Expiry {
+	return &Expiry{TokenizedCardNumber: card, ControlType: controlType, PersonaID: "persona", ExpireTime: at}
+}
+
+// recorder counts removals, errs are returned on successive calls
+type recorder struct {
+	removed []string
+	errs    []error
+}
+
+func (r *recorder) handle(_ context.Context, expiry *Expiry) error {
+	if len(r.errs) > 0 {
+		err := r.errs[0]
+		r.errs = r.errs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	r.removed = append(r.removed, expiry.ControlType)
+	return nil
+}
+
+func TestScheduler_ExpireDue(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestRedis(t)
+	s := newTestScheduler(t, client)
+	r := &recorder{}
+	s.Handle(r.handle)
+
+	require.NoError(t, s.Schedule(ctx, anExpiry("GCT_GLOBAL", start.Add(-time.Minute))))
+	require.NoError(t, s.Schedule(ctx, anExpiry("TCT_E_COMMERCE", start.Add(time.Hour))))
+
+	s.ExpireDue(ctx)
+	assert.Equal(t, []string{"GCT_GLOBAL"}, r.removed)
+
+	expiries, err := s.Get(ctx, card)
+	require.NoError(t, err)
+	assert.Len(t, expiries, 1)
+	assert.Contains(t, expiries, "TCT_E_COMMERCE")
+
+	s.now = func() time.Time { return start.Add(2 * time.Hour) }
+	s.ExpireDue(ctx)
+	assert.Equal(t, []string{"GCT_GLOBAL", "TCT_E_COMMERCE"}, r.removed)
+
+	expiries, err = s.Get(ctx, card)
+	require.NoError(t, err)
+	assert.Empty(t, expiries)
+}
+
+func TestScheduler_Cancel(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestRedis(t)
+	s := newTestScheduler(t, client)
+	r := &recorder{}
+	s.Handle(r.handle)
+
+	require.NoError(t, s.Schedule(ctx, anExpiry("GCT_GLOBAL", start.Add(-time.Minute))))
+	require.NoError(t, s.Cancel(ctx, card, "GCT_GLOBAL"))
+
+	s.ExpireDue(ctx)
+	assert.Empty(t, r.removed)
+}
+
+func TestScheduler_Extended(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestRedis(t)
+	s := newTestScheduler(t, client)
+	r := &recorder{}
+	s.Handle(r.handle)
+
+	require.NoError(t, s.Schedule(ctx, anExpiry("GCT_GLOBAL", start.Add(-time.Minute))))
+	require.NoError(t, s.Schedule(ctx, anExpiry("GCT_GLOBAL", start.Add(time.Hour))))
+
+	s.ExpireDue(ctx)
+	assert.Empty(t, r.removed, "the expiry set last applies")
+}
+
+func TestScheduler_Retry(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestRedis(t)
+	s := newTestScheduler(t, client)
+	r := &recorder{errs: []error{errors.New("unavailable")}}
+	s.Handle(r.handle)
+
+	require.NoError(t, s.Schedule(ctx, anExpiry("GCT_GLOBAL", start.Add(-time.Minute))))
+
+	s.ExpireDue(ctx)
+	assert.Empty(t, r.removed)
+	expiries, err := s.Get(ctx, card)
+	require.NoError(t, err)
+	require.Contains(t, expiries, "GCT_GLOBAL")
+	assert.Equal(t, 1, expiries["GCT_GLOBAL"].Attempts)
+
+	s.ExpireDue(ctx)
+	assert.Empty(t, r.removed, "retried after the backoff")
+
+	s.now = func() time.Time { return start.Add(time.Minute) }
+	s.ExpireDue(ctx)
+	assert.Equal(t, []string{"GCT_GLOBAL"}, r.removed)
+}
+
+func TestScheduler_Stuck(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestRedis(t)
+	s := newTestScheduler(t, client)
+	r := &recorder{errs: []error{errors.New("unavailable"), errors.New("unavailable"), errors.New("unavailable")}}
+	s.Handle(r.handle)
+
+	require.NoError(t, s.Schedule(ctx, anExpiry("GCT_GLOBAL", start.Add(-time.Minute))))
+
+	s.ExpireDue(ctx)
+	s.now = func() time.Time { return start.Add(time.Hour) }
+	s.ExpireDue(ctx)
+	s.now = func() time.Time { return start.Add(2 * time.Hour) }
+	s.ExpireDue(ctx)
+
+	expiries, err := s.Get(ctx, card)
+	require.NoError(t, err)
+	require.Contains(t, expiries, "GCT_GLOBAL", "the expiry is kept once it ran out of attempts")
+	assert.Equal(t, 3, expiries["GCT_GLOBAL"].Attempts)
+	assert.Empty(t, r.removed)
+
+	s.now = func() time.Time { return start.Add(3 * time.Hour) }
+	s.ExpireDue(ctx)
+	assert.Equal(t, []string{"GCT_GLOBAL"}, r.removed, "still retried once it ran out of attempts")
+}
+
+func TestScheduler_Leased(t *testing.T) {
+	ctx := context.Background()
+	client, m := newTestRedis(t)
+	first := newTestScheduler(t, client)
+	second := newTestScheduler(t, client)
+	r := &recorder{}
+	first.Handle(r.handle)
+	second.Handle(r.handle)
+
+	require.NoError(t, first.Schedule(ctx, anExpiry("GCT_GLOBAL", start.Add(-time.Minute))))
+
+	// the first scheduler stopped while it held the lease
+	ok, err := first.store.lease(ctx, member(card, "GCT_GLOBAL"), first.owner, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	second.ExpireDue(ctx)
+	assert.Empty(t, r.removed, "the control is removed by one scheduler")
+
+	m.FastForward(time.Minute)
+	second.ExpireDue(ctx)
+	assert.Equal(t, []string{"GCT_GLOBAL"}, r.removed, "the lease of a stopped scheduler lapses")
+}
+
+func TestNewScheduler(t *testing.T) {
+	s, err := NewScheduler(context.Background(), nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+
+	s, err = NewScheduler(context.Background(), &Config{}, nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, s, "controls can't be set to expire without redis")
+
+	assert.ErrorIs(t, s.Schedule(context.Background(), anExpiry("GCT_GLOBAL", start)), ErrUnavailable)
+	assert.NoError(t, s.Cancel(context.Background(), card, "GCT_GLOBAL"))
+	assert.NoError(t, s.Run(context.Background())())
+}
+
+func Test_backoff(t *testing.T) {
+	assert.Equal(t, time.Minute, backoff(time.Minute, 1))
+	assert.Equal(t, 4*time.Minute, backoff(time.Minute, 3))
+	assert.Equal(t, 64*time.Minute, backoff(time.Minute, 20), "backoff stops doubling past an hour")
+}
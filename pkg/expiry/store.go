@@ -0,0 +1,183 @@
+package expiry
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+const (
+	cardGroup  = "expiry:card:"
+	dueKey     = "expiry:due"
+	leaseGroup = "expiry:lease:"
+)
+
+// ErrNotFound is returned for an expiry that does not exist or was cancelled
+var ErrNotFound = errors.New("expiry not found")
+
+// unlock deletes a key only while it holds the expected value
+var unlock = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`)
+
+// complete forgets an expiry only while it is unchanged, an expiry set again while its control was being removed is kept
+var complete = redis.NewScript(`
+if redis.call("hget", KEYS[1], ARGV[1]) == ARGV[2] then
+	redis.call("hdel", KEYS[1], ARGV[1])
+	redis.call("zrem", KEYS[2], ARGV[3])
+	return 1
+end
+return 0`)
+
+// replace updates an expiry and when it is due only while it is unchanged
+var replace = redis.NewScript(`
+if redis.call("hget", KEYS[1], ARGV[1]) == ARGV[2] then
+	redis.call("hset", KEYS[1], ARGV[1], ARGV[3])
+	redis.call("zadd", KEYS[2], ARGV[4], ARGV[5])
+	return 1
+end
+return 0`)
+
+// store persists expiries in Redis. The expiries of a card are kept in a hash by control type so a card is read at
+// once, and every expiry in a sorted set scored by when it is due.
+type store struct {
+	client redis.Cmdable
+	prefix string
+}
+
+func (s *store) cardKey(tokenizedCardNumber string) string {
+	return s.prefix + cardGroup + tokenizedCardNumber
+}
+
+// member identifies the expiry of a control on a card in the sorted set
+func member(tokenizedCardNumber, controlType string) string {
+	return tokenizedCardNumber + ":" + controlType
+}
+
+// parseMember splits a member on its last separator, control types don't contain one
+func parseMember(m string) (string, string, bool) {
+	i := strings.LastIndex(m, ":")
+	if i < 0 {
+		return "", "", false
+	}
+	return m[:i], m[i+1:], true
+}
+
+func (s *store) save(ctx context.Context, expiry *Expiry, at time.Time) error {
+	payload, err := json.Marshal(expiry)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal expiry")
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, s.cardKey(expiry.TokenizedCardNumber), expiry.ControlType, payload)
+		pipe.ZAdd(ctx, s.prefix+dueKey, &redis.Z{
+			Score:  float64(at.Unix()),
+			Member: member(expiry.TokenizedCardNumber, expiry.ControlType),
+		})
+		return nil
+	})
+	return errors.Wrap(err, "unable to save expiry")
+}
+
+func (s *store) delete(ctx context.Context, tokenizedCardNumber string, controlTypes ...string) error {
+	members := make([]interface{}, 0, len(controlTypes))
+	for _, controlType := range controlTypes {
+		members = append(members, member(tokenizedCardNumber, controlType))
+	}
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, s.cardKey(tokenizedCardNumber), controlTypes...)
+		pipe.ZRem(ctx, s.prefix+dueKey, members...)
+		return nil
+	})
+	return errors.Wrap(err, "unable to cancel expiry")
+}
+
+// get returns an expiry and its payload, the payload is compared when the expiry is completed or replaced
+func (s *store) get(ctx context.Context, m string) (*Expiry, string, error) {
+	tokenizedCardNumber, controlType, ok := parseMember(m)
+	if !ok {
+		return nil, "", ErrNotFound
+	}
+
+	payload, err := s.client.HGet(ctx, s.cardKey(tokenizedCardNumber), controlType).Result()
+	if err == redis.Nil {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", errors.Wrap(err, "unable to read expiry")
+	}
+
+	var expiry Expiry
+	if err := json.Unmarshal([]byte(payload), &expiry); err != nil {
+		return nil, "", errors.Wrap(err, "unable to unmarshal expiry")
+	}
+	return &expiry, payload, nil
+}
+
+func (s *store) card(ctx context.Context, tokenizedCardNumber string) (map[string]*Expiry, error) {
+	payloads, err := s.client.HGetAll(ctx, s.cardKey(tokenizedCardNumber)).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read expiries")
+	}
+
+	expiries := make(map[string]*Expiry, len(payloads))
+	for controlType, payload := range payloads {
+		var expiry Expiry
+		if err := json.Unmarshal([]byte(payload), &expiry); err != nil {
+			return nil, errors.Wrap(err, "unable to unmarshal expiry")
+		}
+		expiries[controlType] = &expiry
+	}
+	return expiries, nil
+}
+
+func (s *store) complete(ctx context.Context, expiry *Expiry, payload string) error {
+	err := complete.Run(ctx, s.client, []string{s.cardKey(expiry.TokenizedCardNumber), s.prefix + dueKey},
+		expiry.ControlType, payload, member(expiry.TokenizedCardNumber, expiry.ControlType)).Err()
+	return errors.Wrap(err, "unable to complete expiry")
+}
+
+func (s *store) replace(ctx context.Context, expiry *Expiry, payload string, at time.Time) error {
+	updated, err := json.Marshal(expiry)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal expiry")
+	}
+
+	err = replace.Run(ctx, s.client, []string{s.cardKey(expiry.TokenizedCardNumber), s.prefix + dueKey},
+		expiry.ControlType, payload, string(updated), at.Unix(), member(expiry.TokenizedCardNumber, expiry.ControlType)).Err()
+	return errors.Wrap(err, "unable to reschedule expiry")
+}
+
+func (s *store) unschedule(ctx context.Context, m string) error {
+	return errors.Wrap(s.client.ZRem(ctx, s.prefix+dueKey, m).Err(), "unable to unschedule expiry")
+}
+
+// due returns up to count expiries scheduled at or before now
+func (s *store) due(ctx context.Context, now time.Time, count int64) ([]string, error) {
+	members, err := s.client.ZRangeByScore(ctx, s.prefix+dueKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.Unix(), 10),
+		Count: count,
+	}).Result()
+	return members, errors.Wrap(err, "unable to list due expiries")
+}
+
+// lease makes owner the only scheduler removing an expiry until ttl has passed
+func (s *store) lease(ctx context.Context, m, owner string, ttl time.Duration) (bool, error) {
+	ok, err := s.client.SetNX(ctx, s.prefix+leaseGroup+m, owner, ttl).Result()
+	return ok, errors.Wrap(err, "unable to lease expiry")
+}
+
+func (s *store) release(ctx context.Context, m, owner string) error {
+	err := unlock.Run(ctx, s.client, []string{s.prefix + leaseGroup + m}, owner).Err()
+	return errors.Wrap(err, "unable to release expiry lease")
+}
@@ -0,0 +1,347 @@
+// Package admin provides ops port endpoints to inspect feature gates and temporarily override features
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anzx/fabric-cards/pkg/feature"
+	"github.com/anzx/fabric-cards/pkg/integration/auditlogger"
+	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
+	"github.com/anzx/fabric-cards/pkg/rest"
+	"github.com/anzx/pkg/auditlog"
+	"github.com/anzx/pkg/gsm"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	FeaturesPath  = "/admin/features"
+	OverridesPath = "/admin/features/overrides"
+
+	// GateRPC and GateFeature name the gates an override can target
+	GateRPC     = "rpc"
+	GateFeature = "feature"
+
+	operatorHeader = "X-Operator"
+	defaultMaxTTL  = 24 * time.Hour
+)
+
+const (
+	EventFeatureOverride        auditlog.Event = "FeatureOverride"
+	EventFeatureOverrideRemoved auditlog.Event = "FeatureOverrideRemoved"
+	EventFeatureOverrideExpired auditlog.Event = "FeatureOverrideExpired"
+)
+
+// Config enables the feature admin endpoints on the ops port
+type Config struct {
+	// TokenSecretID is the GSM secret holding the bearer token required by every admin request
+	TokenSecretID string `json:"tokenSecretId" yaml:"tokenSecretId" mapstructure:"tokenSecretId" validate:"required"`
+	// MaxTTL caps how long an override can last, defaults to 24h
+	MaxTTL time.Duration `json:"maxTTL,omitempty" yaml:"maxTTL,omitempty" mapstructure:"maxTTL"`
+}
+
+// Admin serves the feature admin endpoints
+type Admin struct {
+	ctx      context.Context
+	token    string
+	maxTTL   time.Duration
+	auditLog *auditlogger.Client
+	gates    map[string]gate
+
+	lock   sync.Mutex
+	timers map[string]*time.Timer
+
+	// handlers are other admin endpoints served with the same token
+	handlers map[string]http.Handler
+}
+
+type gate struct {
+	feature.Gate
+	registered map[feature.Feature]bool
+}
+
+// State describes the live state of a registered feature
+type State struct {
+	Name       feature.Feature   `json:"name"`
+	Enabled    bool              `json:"enabled"`
+	Configured bool              `json:"configured"`
+	Override   *feature.Override `json:"override,omitempty"`
+}
+
+// StatesResponse lists the state of every registered rpc and feature
+type StatesResponse struct {
+	RPCs     []State `json:"rpcs"`
+	Features []State `json:"features"`
+}
+
+// OverrideRequest temporarily enables or disables a feature
+type OverrideRequest struct {
+	Gate    string          `json:"gate"`
+	Name    feature.Feature `json:"name"`
+	Enabled bool            `json:"enabled"`
+	TTL     string          `json:"ttl"`
+	Reason  string          `json:"reason"`
+}
+
+// NewAdmin returns an Admin for the configured token, or nil if no config is provided
+func NewAdmin(ctx context.Context, config *Config, gsmClient *gsm.Client, auditLog *auditlogger.Client) (*Admin, error) {
+	if config == nil {
+		logf.Debug(ctx, "feature admin config not provided %v", config)
+		return nil, nil
+	}
+
+	token, err := gsmClient.AccessSecret(ctx, config.TokenSecretID)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to access feature admin token")
+	}
+	if token == "" {
+		return nil, errors.New("feature admin token is empty")
+	}
+
+	maxTTL := config.MaxTTL
+	if maxTTL <= 0 {
+		maxTTL = defaultMaxTTL
+	}
+
+	return &Admin{
+		ctx:      ctx,
+		token:    token,
+		maxTTL:   maxTTL,
+		auditLog: auditLog,
+		gates: map[string]gate{
+			GateRPC:     {Gate: feature.RPCGate, registered: feature.RegisteredRPCs},
+			GateFeature: {Gate: feature.FeatureGate, registered: feature.RegisteredFeatures},
+		},
+		timers: map[string]*time.Timer{},
+	}, nil
+}
+
+// Register adds the admin endpoints to mux, it does nothing if admin is not configured
+func (a *Admin) Register(mux *http.ServeMux) {
+	if a == nil {
+		return
+	}
+	mux.Handle(FeaturesPath, a.authenticate(http.HandlerFunc(a.serveStates)))
+	mux.Handle(OverridesPath, a.authenticate(http.HandlerFunc(a.serveOverrides)))
+	for pattern, handler := range a.handlers {
+		mux.Handle(pattern, a.authenticate(handler))
+	}
+}
+
+// Handle serves another admin endpoint with the admin token, it must be called before Register. The endpoint is not
+// served if admin is not configured.
+func (a *Admin) Handle(pattern string, handler http.Handler) {
+	if a == nil {
+		return
+	}
+	if a.handlers == nil {
+		a.handlers = map[string]http.Handler{}
+	}
+	a.handlers[pattern] = handler
+}
+
+func (a *Admin) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			rest.WriteError(w, http.StatusUnauthorized, "invalid admin token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *Admin) serveStates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		rest.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	rest.WriteJSON(w, http.StatusOK, a.States())
+}
+
+func (a *Admin) serveOverrides(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var req OverrideRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			rest.WriteError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		state, err := a.Override(r.Context(), req, r.Header.Get(operatorHeader))
+		if err != nil {
+			rest.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		rest.WriteJSON(w, http.StatusOK, state)
+	case http.MethodDelete:
+		query := r.URL.Query()
+		state, err := a.RemoveOverride(r.Context(), query.Get("gate"), feature.Feature(query.Get("name")), r.Header.Get(operatorHeader))
+		if err != nil {
+			rest.WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		rest.WriteJSON(w, http.StatusOK, state)
+	default:
+		rest.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// States returns the state of every registered rpc and feature sorted by name
+func (a *Admin) States() StatesResponse {
+	return StatesResponse{
+		RPCs:     a.gates[GateRPC].states(),
+		Features: a.gates[GateFeature].states(),
+	}
+}
+
+// Override validates and applies req, and schedules its expiry
+func (a *Admin) Override(ctx context.Context, req OverrideRequest, operator string) (*State, error) {
+	g, ok := a.gates[req.Gate]
+	if !ok {
+		return nil, fmt.Errorf("unknown gate %q, must be %s or %s", req.Gate, GateRPC, GateFeature)
+	}
+	if req.Gate == GateRPC {
+		req.Name = feature.Feature(strings.ToLower(string(req.Name)))
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		return nil, errors.New("reason is required")
+	}
+	ttl, err := time.ParseDuration(req.TTL)
+	if err != nil || ttl <= 0 {
+		return nil, fmt.Errorf("invalid ttl %q", req.TTL)
+	}
+	if ttl > a.maxTTL {
+		return nil, fmt.Errorf("ttl must not exceed %v", a.maxTTL)
+	}
+
+	override := feature.Override{
+		Enabled:   req.Enabled,
+		ExpiresAt: g.Now().Add(ttl),
+		Reason:    req.Reason,
+		Operator:  operator,
+	}
+	if err := g.Override(req.Name, override); err != nil {
+		return nil, err
+	}
+
+	logf.Info(ctx, "feature: %s %s overridden to %t for %v by %q: %s", req.Gate, req.Name, req.Enabled, ttl, operator, req.Reason)
+	a.publish(ctx, EventFeatureOverride, req.Gate, req.Name, override)
+	a.schedule(req.Gate, req.Name, ttl)
+
+	state := g.state(req.Name)
+	return &state, nil
+}
+
+// RemoveOverride reverts an override before it expires
+func (a *Admin) RemoveOverride(ctx context.Context, gateName string, name feature.Feature, operator string) (*State, error) {
+	g, ok := a.gates[gateName]
+	if !ok {
+		return nil, fmt.Errorf("unknown gate %q, must be %s or %s", gateName, GateRPC, GateFeature)
+	}
+	if gateName == GateRPC {
+		name = feature.Feature(strings.ToLower(string(name)))
+	}
+
+	override, ok := g.RemoveOverride(name)
+	if !ok {
+		return nil, fmt.Errorf("no override for %s", name)
+	}
+	a.stop(gateName, name)
+
+	logf.Info(ctx, "feature: %s %s override removed by %q", gateName, name, operator)
+	override.Operator = operator
+	a.publish(ctx, EventFeatureOverrideRemoved, gateName, name, override)
+
+	state := g.state(name)
+	return &state, nil
+}
+
+// schedule reverts the override of name once ttl has passed
+func (a *Admin) schedule(gateName string, name feature.Feature, ttl time.Duration) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	key := fmt.Sprintf("%s/%s", gateName, name)
+	if timer, ok := a.timers[key]; ok {
+		timer.Stop()
+	}
+	a.timers[key] = time.AfterFunc(ttl, func() {
+		a.expire(gateName)
+	})
+}
+
+func (a *Admin) stop(gateName string, name feature.Feature) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	key := fmt.Sprintf("%s/%s", gateName, name)
+	if timer, ok := a.timers[key]; ok {
+		timer.Stop()
+		delete(a.timers, key)
+	}
+}
+
+// expire removes every expired override of the gate and records the revert
+func (a *Admin) expire(gateName string) {
+	g := a.gates[gateName]
+	for name, override := range g.ExpireOverrides(g.Now()) {
+		a.lock.Lock()
+		delete(a.timers, fmt.Sprintf("%s/%s", gateName, name))
+		a.lock.Unlock()
+
+		logf.Info(a.ctx, "feature: %s %s override expired", gateName, name)
+		a.publish(a.ctx, EventFeatureOverrideExpired, gateName, name, override)
+	}
+}
+
+func (a *Admin) publish(ctx context.Context, event auditlog.Event, gateName string, name feature.Feature, override feature.Override) {
+	if a.auditLog == nil {
+		return
+	}
+
+	data, err := structpb.NewStruct(map[string]interface{}{
+		"gate":      gateName,
+		"name":      string(name),
+		"enabled":   override.Enabled,
+		"expiresAt": override.ExpiresAt.Format(time.RFC3339),
+		"reason":    override.Reason,
+		"operator":  override.Operator,
+	})
+	if err != nil {
+		logf.Error(ctx, err, "feature: unable to build override audit data")
+		return
+	}
+
+	a.auditLog.Publish(ctx, event, data, nil, data)
+}
+
+func (g gate) states() []State {
+	states := make([]State, 0, len(g.registered))
+	for name := range g.registered {
+		states = append(states, g.state(name))
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Name < states[j].Name
+	})
+	return states
+}
+
+func (g gate) state(name feature.Feature) State {
+	state := State{
+		Name:       name,
+		Enabled:    g.Enabled(name),
+		Configured: g.Features()[name],
+	}
+	if override, ok := g.Overrides()[name]; ok {
+		state.Override = &override
+	}
+	return state
+}
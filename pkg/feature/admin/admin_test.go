@@ -0,0 +1,215 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anzx/fabric-cards/pkg/feature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "admin-token"
+
+func newTestAdmin() (*Admin, *http.ServeMux) {
+	a := &Admin{
+		ctx:    context.Background(),
+		token:  testToken,
+		maxTTL: time.Hour,
+		gates: map[string]gate{
+			GateRPC:     {Gate: feature.RPCGate, registered: feature.RegisteredRPCs},
+			GateFeature: {Gate: feature.FeatureGate, registered: feature.RegisteredFeatures},
+		},
+		timers: map[string]*time.Timer{},
+	}
+	mux := http.NewServeMux()
+	a.Register(mux)
+	return a, mux
+}
+
+func do(mux *http.ServeMux, method string, target string, body string, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(operatorHeader, "oncall")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNewAdmin_NilConfig(t *testing.T) {
+	a, err := NewAdmin(context.Background(), nil, nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, a)
+	assert.NotPanics(t, func() {
+		a.Register(http.NewServeMux())
+	})
+}
+
+func TestAdmin_Unauthorized(t *testing.T) {
+	_, mux := newTestAdmin()
+
+	rec := do(mux, http.MethodGet, FeaturesPath, "", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = do(mux, http.MethodPost, OverridesPath, `{}`, "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAdmin_Handle(t *testing.T) {
+	a, _ := newTestAdmin()
+	a.Handle("/admin/other", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	mux := http.NewServeMux()
+	a.Register(mux)
+
+	assert.Equal(t, http.StatusUnauthorized, do(mux, http.MethodGet, "/admin/other", "", "wrong").Code)
+	assert.Equal(t, http.StatusTeapot, do(mux, http.MethodGet, "/admin/other", "", testToken).Code)
+
+	var disabled *Admin
+	assert.NotPanics(t, func() {
+		disabled.Handle("/admin/other", http.NotFoundHandler())
+	})
+}
+
+func TestAdmin_States(t *testing.T) {
+	_, mux := newTestAdmin()
+
+	rec := do(mux, http.MethodGet, FeaturesPath, "", testToken)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got StatesResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Len(t, got.RPCs, len(feature.RegisteredRPCs))
+	assert.Len(t, got.Features, len(feature.RegisteredFeatures))
+	for _, state := range got.RPCs {
+		if state.Name == feature.HealthAlive {
+			assert.True(t, state.Enabled)
+		}
+	}
+
+	rec = do(mux, http.MethodPost, FeaturesPath, "", testToken)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestAdmin_Override(t *testing.T) {
+	_, mux := newTestAdmin()
+	defer feature.FeatureGate.RemoveOverride(feature.MCT_ALCOHOL)
+
+	require.False(t, feature.FeatureGate.Enabled(feature.MCT_ALCOHOL))
+
+	rec := do(mux, http.MethodPost, OverridesPath,
+		`{"gate":"feature","name":"MCT_ALCOHOL","enabled":true,"ttl":"30m","reason":"incident 123"}`, testToken)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var got State
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.True(t, got.Enabled)
+	assert.False(t, got.Configured)
+	require.NotNil(t, got.Override)
+	assert.Equal(t, "incident 123", got.Override.Reason)
+	assert.Equal(t, "oncall", got.Override.Operator)
+	assert.True(t, feature.FeatureGate.Enabled(feature.MCT_ALCOHOL))
+
+	rec = do(mux, http.MethodDelete, OverridesPath+"?gate=feature&name=MCT_ALCOHOL", "", testToken)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.False(t, feature.FeatureGate.Enabled(feature.MCT_ALCOHOL))
+
+	rec = do(mux, http.MethodDelete, OverridesPath+"?gate=feature&name=MCT_ALCOHOL", "", testToken)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdmin_OverrideExpires(t *testing.T) {
+	a, _ := newTestAdmin()
+	defer feature.RPCGate.RemoveOverride(feature.CardAuditTrail)
+
+	_, err := a.Override(context.Background(), OverrideRequest{
+		Gate:    GateRPC,
+		Name:    "/fabric.service.card.v1beta1.cardapi/AuditTrail",
+		Enabled: true,
+		TTL:     "50ms",
+		Reason:  "testing",
+	}, "oncall")
+	require.NoError(t, err)
+	assert.True(t, feature.RPCGate.Enabled(feature.CardAuditTrail))
+
+	assert.Eventually(t, func() bool {
+		_, ok := feature.RPCGate.Overrides()[feature.CardAuditTrail]
+		return !ok && !feature.RPCGate.Enabled(feature.CardAuditTrail)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestAdmin_OverrideClock(t *testing.T) {
+	a, _ := newTestAdmin()
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	feature.RPCGate.SetClock(func() time.Time { return now })
+	defer feature.RPCGate.SetClock(time.Now)
+	defer feature.RPCGate.RemoveOverride(feature.CardAuditTrail)
+
+	state, err := a.Override(context.Background(), OverrideRequest{
+		Gate:    GateRPC,
+		Name:    "/fabric.service.card.v1beta1.cardapi/AuditTrail",
+		Enabled: true,
+		TTL:     "30m",
+		Reason:  "testing",
+	}, "oncall")
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(30*time.Minute), state.Override.ExpiresAt)
+
+	// overrides expire by the gate's clock
+	a.expire(GateRPC)
+	assert.True(t, feature.RPCGate.Enabled(feature.CardAuditTrail))
+
+	now = now.Add(30 * time.Minute)
+	a.expire(GateRPC)
+	assert.False(t, feature.RPCGate.Enabled(feature.CardAuditTrail))
+}
+
+func TestAdmin_OverrideValidation(t *testing.T) {
+	a, _ := newTestAdmin()
+
+	tests := []struct {
+		name    string
+		req     OverrideRequest
+		wantErr string
+	}{
+		{
+			name:    "unknown gate",
+			req:     OverrideRequest{Gate: "other", Name: feature.DCVV2, TTL: "1m", Reason: "r"},
+			wantErr: `unknown gate "other", must be rpc or feature`,
+		},
+		{
+			name:    "missing reason",
+			req:     OverrideRequest{Gate: GateFeature, Name: feature.DCVV2, TTL: "1m"},
+			wantErr: "reason is required",
+		},
+		{
+			name:    "invalid ttl",
+			req:     OverrideRequest{Gate: GateFeature, Name: feature.DCVV2, TTL: "soon", Reason: "r"},
+			wantErr: `invalid ttl "soon"`,
+		},
+		{
+			name:    "ttl too long",
+			req:     OverrideRequest{Gate: GateFeature, Name: feature.DCVV2, TTL: "2h", Reason: "r"},
+			wantErr: "ttl must not exceed 1h0m0s",
+		},
+		{
+			name:    "unregistered feature",
+			req:     OverrideRequest{Gate: GateFeature, Name: "NOT_A_FEATURE", TTL: "1m", Reason: "r"},
+			wantErr: "feature is not registered in feature gate: NOT_A_FEATURE",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			_, err := a.Override(context.Background(), test.req, "oncall")
+			assert.EqualError(t, err, test.wantErr)
+		})
+	}
+}
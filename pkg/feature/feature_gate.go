@@ -7,6 +7,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	anzcodes "github.com/anzx/pkg/errors/errcodes"
 	"github.com/pkg/errors"
@@ -19,6 +20,14 @@ import (
 type Config struct {
 	RPCs     map[Feature]bool `json:"rpc,omitempty"       yaml:"rpc,omitempty"      mapstructure:"rpc"`
 	Features map[Feature]bool `json:"features,omitempty"  yaml:"features,omitempty" mapstructure:"features"`
+	// RPCRollouts and FeatureRollouts enable a feature for a subset of personas while it is globally disabled
+	RPCRollouts     map[Feature]Rollout `json:"rpcRollouts,omitempty"     yaml:"rpcRollouts,omitempty"     mapstructure:"rpcRollouts"`
+	FeatureRollouts map[Feature]Rollout `json:"featureRollouts,omitempty" yaml:"featureRollouts,omitempty" mapstructure:"featureRollouts"`
+	// RPCWindows and FeatureWindows set the state of a feature for a period of time, e.g. a maintenance window
+	RPCWindows     map[Feature]Window `json:"rpcWindows,omitempty"     yaml:"rpcWindows,omitempty"     mapstructure:"rpcWindows"`
+	FeatureWindows map[Feature]Window `json:"featureWindows,omitempty" yaml:"featureWindows,omitempty" mapstructure:"featureWindows"`
+	// Watch configures where the rpc and features maps are reloaded from at runtime, can be nil
+	Watch *WatchConfig `json:"watch,omitempty"     yaml:"watch,omitempty"    mapstructure:"watch"`
 }
 
 // Feature is a string representing the name of the feature to be used for feature gating
@@ -39,6 +48,9 @@ const (
 	CardSetPin                     Feature = "/fabric.service.card.v1beta1.cardapi/setpin"
 	CardVerifyPin                  Feature = "/fabric.service.card.v1beta1.cardapi/verifypin"
 	CardResetPin                   Feature = "/fabric.service.card.v1beta1.cardapi/resetpin"
+	CardUpdateStatus               Feature = "/fabric.service.card.v1beta1.cardapi/updatestatus"
+	CardGetLimits                  Feature = "/fabric.service.card.v1beta1.cardapi/getlimits"
+	CardUpdateLimits               Feature = "/fabric.service.card.v1beta1.cardapi/updatelimits"
 	WalletCreateApplePaymentToken  Feature = "/fabric.service.card.v1beta1.walletapi/createapplepaymenttoken"
 	WalletCreateGooglePaymentToken Feature = "/fabric.service.card.v1beta1.walletapi/creategooglepaymenttoken"
 	EligibilityCan                 Feature = "/fabric.service.eligibility.v1beta1.cardeligibilityapi/can"
@@ -53,6 +65,7 @@ const (
 	ControlV1beta2Remove           Feature = "/fabric.service.cardcontrols.v1beta2.cardcontrolsapi/removecontrols"
 	ControlV1beta2Set              Feature = "/fabric.service.cardcontrols.v1beta2.cardcontrolsapi/setcontrols"
 	ControlV1beta2Transfer         Feature = "/fabric.service.cardcontrols.v1beta2.cardcontrolsapi/transfercontrols"
+	ControlV1beta2ApplyPreset      Feature = "/fabric.service.cardcontrols.v1beta2.cardcontrolsapi/applypreset"
 	CallbackEnroll                 Feature = "/visa.service.enrollmentcallback.v1.enrollmentcallbackapi/enroll"
 	CallbackDisenroll              Feature = "/visa.service.enrollmentcallback.v1.enrollmentcallbackapi/disenroll"
 	CallbackAlert                  Feature = "/visa.service.notificationcallback.v1.notificationcallbackapi/alert"
@@ -73,6 +86,9 @@ var RegisteredRPCs = map[Feature]bool{
 	CardSetPin:                     false,
 	CardVerifyPin:                  false,
 	CardResetPin:                   false,
+	CardUpdateStatus:               false,
+	CardGetLimits:                  false,
+	CardUpdateLimits:               false,
 	WalletCreateApplePaymentToken:  false,
 	WalletCreateGooglePaymentToken: false,
 	EligibilityCan:                 false,
@@ -87,6 +103,7 @@ var RegisteredRPCs = map[Feature]bool{
 	ControlV1beta2Remove:           false,
 	ControlV1beta2Set:              false,
 	ControlV1beta2Transfer:         false,
+	ControlV1beta2ApplyPreset:      false,
 	CallbackEnroll:                 false,
 	CallbackDisenroll:              false,
 	CallbackAlert:                  false,
@@ -94,74 +111,76 @@ var RegisteredRPCs = map[Feature]bool{
 
 const (
 	// Features
-	UNKNOWN_UNSPECIFIED               Feature = "UNKNOWN_UNSPECIFIED"
-	TCT_ATM_WITHDRAW                  Feature = "TCT_ATM_WITHDRAW"
-	TCT_AUTO_PAY                      Feature = "TCT_AUTO_PAY"
-	TCT_BRICK_AND_MORTAR              Feature = "TCT_BRICK_AND_MORTAR"
-	TCT_CROSS_BORDER                  Feature = "TCT_CROSS_BORDER"
-	TCT_E_COMMERCE                    Feature = "TCT_E_COMMERCE"
-	TCT_CONTACTLESS                   Feature = "TCT_CONTACTLESS"
-	MCT_ADULT_ENTERTAINMENT           Feature = "MCT_ADULT_ENTERTAINMENT"
-	MCT_AIRFARE                       Feature = "MCT_AIRFARE"
-	MCT_ALCOHOL                       Feature = "MCT_ALCOHOL"
-	MCT_APPAREL_AND_ACCESSORIES       Feature = "MCT_APPAREL_AND_ACCESSORIES"
-	MCT_AUTOMOTIVE                    Feature = "MCT_AUTOMOTIVE"
-	MCT_CAR_RENTAL                    Feature = "MCT_CAR_RENTAL"
-	MCT_ELECTRONICS                   Feature = "MCT_ELECTRONICS"
-	MCT_SPORT_AND_RECREATION          Feature = "MCT_SPORT_AND_RECREATION"
-	MCT_GAMBLING                      Feature = "MCT_GAMBLING"
-	MCT_GAS_AND_PETROLEUM             Feature = "MCT_GAS_AND_PETROLEUM"
-	MCT_GROCERY                       Feature = "MCT_GROCERY"
-	MCT_HOTEL_AND_LODGING             Feature = "MCT_HOTEL_AND_LODGING"
-	MCT_HOUSEHOLD                     Feature = "MCT_HOUSEHOLD"
-	MCT_PERSONAL_CARE                 Feature = "MCT_PERSONAL_CARE"
-	MCT_SMOKE_AND_TOBACCO             Feature = "MCT_SMOKE_AND_TOBACCO"
-	GCT_GLOBAL                        Feature = "GCT_GLOBAL"
-	REASON_UNKNOWN_UNSPECIFIED        Feature = "REASON_UNKNOWN_UNSPECIFIED"
-	REASON_LOST                       Feature = "REASON_LOST"
-	REASON_STOLEN                     Feature = "REASON_STOLEN"
-	REASON_DAMAGED                    Feature = "REASON_DAMAGED"
-	DCVV2                             Feature = "DCVV2"
-	FORGEROCK_SYSTEM_LOGIN            Feature = "FORGEROCK_SYSTEM_LOGIN"
-	ENROLLMENT_CALLBACK_INTEGRATED    Feature = "ENROLLMENT_CALLBACK_INTEGRATED"
-	PIN_CHANGE_COUNT                  Feature = "PIN_CHANGE_COUNT"
-	NotificationCallbackDeclinedEvent Feature = "NOTIFICATION_CALLBACK_DECLINED_EVENT"
+	UNKNOWN_UNSPECIFIED                 Feature = "UNKNOWN_UNSPECIFIED"
+	TCT_ATM_WITHDRAW                    Feature = "TCT_ATM_WITHDRAW"
+	TCT_AUTO_PAY                        Feature = "TCT_AUTO_PAY"
+	TCT_BRICK_AND_MORTAR                Feature = "TCT_BRICK_AND_MORTAR"
+	TCT_CROSS_BORDER                    Feature = "TCT_CROSS_BORDER"
+	TCT_E_COMMERCE                      Feature = "TCT_E_COMMERCE"
+	TCT_CONTACTLESS                     Feature = "TCT_CONTACTLESS"
+	MCT_ADULT_ENTERTAINMENT             Feature = "MCT_ADULT_ENTERTAINMENT"
+	MCT_AIRFARE                         Feature = "MCT_AIRFARE"
+	MCT_ALCOHOL                         Feature = "MCT_ALCOHOL"
+	MCT_APPAREL_AND_ACCESSORIES         Feature = "MCT_APPAREL_AND_ACCESSORIES"
+	MCT_AUTOMOTIVE                      Feature = "MCT_AUTOMOTIVE"
+	MCT_CAR_RENTAL                      Feature = "MCT_CAR_RENTAL"
+	MCT_ELECTRONICS                     Feature = "MCT_ELECTRONICS"
+	MCT_SPORT_AND_RECREATION            Feature = "MCT_SPORT_AND_RECREATION"
+	MCT_GAMBLING                        Feature = "MCT_GAMBLING"
+	MCT_GAS_AND_PETROLEUM               Feature = "MCT_GAS_AND_PETROLEUM"
+	MCT_GROCERY                         Feature = "MCT_GROCERY"
+	MCT_HOTEL_AND_LODGING               Feature = "MCT_HOTEL_AND_LODGING"
+	MCT_HOUSEHOLD                       Feature = "MCT_HOUSEHOLD"
+	MCT_PERSONAL_CARE                   Feature = "MCT_PERSONAL_CARE"
+	MCT_SMOKE_AND_TOBACCO               Feature = "MCT_SMOKE_AND_TOBACCO"
+	GCT_GLOBAL                          Feature = "GCT_GLOBAL"
+	REASON_UNKNOWN_UNSPECIFIED          Feature = "REASON_UNKNOWN_UNSPECIFIED"
+	REASON_LOST                         Feature = "REASON_LOST"
+	REASON_STOLEN                       Feature = "REASON_STOLEN"
+	REASON_DAMAGED                      Feature = "REASON_DAMAGED"
+	DCVV2                               Feature = "DCVV2"
+	FORGEROCK_SYSTEM_LOGIN              Feature = "FORGEROCK_SYSTEM_LOGIN"
+	ENROLLMENT_CALLBACK_INTEGRATED      Feature = "ENROLLMENT_CALLBACK_INTEGRATED"
+	PIN_CHANGE_COUNT                    Feature = "PIN_CHANGE_COUNT"
+	NotificationCallbackDeclinedEvent   Feature = "NOTIFICATION_CALLBACK_DECLINED_EVENT"
+	NotificationCallbackSpendAlertEvent Feature = "NOTIFICATION_CALLBACK_SPEND_ALERT_EVENT"
 )
 
 // RegisteredFeatures defines a list of registered Card Features and only these features which state can be changed
 var RegisteredFeatures = map[Feature]bool{
-	UNKNOWN_UNSPECIFIED:               false,
-	TCT_ATM_WITHDRAW:                  false,
-	TCT_AUTO_PAY:                      false,
-	TCT_BRICK_AND_MORTAR:              false,
-	TCT_CROSS_BORDER:                  false,
-	TCT_E_COMMERCE:                    false,
-	TCT_CONTACTLESS:                   false,
-	MCT_ADULT_ENTERTAINMENT:           false,
-	MCT_AIRFARE:                       false,
-	MCT_ALCOHOL:                       false,
-	MCT_APPAREL_AND_ACCESSORIES:       false,
-	MCT_AUTOMOTIVE:                    false,
-	MCT_CAR_RENTAL:                    false,
-	MCT_ELECTRONICS:                   false,
-	MCT_SPORT_AND_RECREATION:          false,
-	MCT_GAMBLING:                      false,
-	MCT_GAS_AND_PETROLEUM:             false,
-	MCT_GROCERY:                       false,
-	MCT_HOTEL_AND_LODGING:             false,
-	MCT_HOUSEHOLD:                     false,
-	MCT_PERSONAL_CARE:                 false,
-	MCT_SMOKE_AND_TOBACCO:             false,
-	GCT_GLOBAL:                        false,
-	REASON_UNKNOWN_UNSPECIFIED:        false,
-	REASON_LOST:                       false,
-	REASON_STOLEN:                     false,
-	REASON_DAMAGED:                    false,
-	DCVV2:                             false,
-	FORGEROCK_SYSTEM_LOGIN:            false,
-	ENROLLMENT_CALLBACK_INTEGRATED:    false,
-	PIN_CHANGE_COUNT:                  false,
-	NotificationCallbackDeclinedEvent: false,
+	UNKNOWN_UNSPECIFIED:                 false,
+	TCT_ATM_WITHDRAW:                    false,
+	TCT_AUTO_PAY:                        false,
+	TCT_BRICK_AND_MORTAR:                false,
+	TCT_CROSS_BORDER:                    false,
+	TCT_E_COMMERCE:                      false,
+	TCT_CONTACTLESS:                     false,
+	MCT_ADULT_ENTERTAINMENT:             false,
+	MCT_AIRFARE:                         false,
+	MCT_ALCOHOL:                         false,
+	MCT_APPAREL_AND_ACCESSORIES:         false,
+	MCT_AUTOMOTIVE:                      false,
+	MCT_CAR_RENTAL:                      false,
+	MCT_ELECTRONICS:                     false,
+	MCT_SPORT_AND_RECREATION:            false,
+	MCT_GAMBLING:                        false,
+	MCT_GAS_AND_PETROLEUM:               false,
+	MCT_GROCERY:                         false,
+	MCT_HOTEL_AND_LODGING:               false,
+	MCT_HOUSEHOLD:                       false,
+	MCT_PERSONAL_CARE:                   false,
+	MCT_SMOKE_AND_TOBACCO:               false,
+	GCT_GLOBAL:                          false,
+	REASON_UNKNOWN_UNSPECIFIED:          false,
+	REASON_LOST:                         false,
+	REASON_STOLEN:                       false,
+	REASON_DAMAGED:                      false,
+	DCVV2:                               false,
+	FORGEROCK_SYSTEM_LOGIN:              false,
+	ENROLLMENT_CALLBACK_INTEGRATED:      false,
+	PIN_CHANGE_COUNT:                    false,
+	NotificationCallbackDeclinedEvent:   false,
+	NotificationCallbackSpendAlertEvent: false,
 }
 
 var (
@@ -176,8 +195,44 @@ type Gate interface {
 	// Enabled returns true if the key is enabled
 	Enabled(key Feature) bool
 
+	// EnabledFor returns true if the key is enabled globally or for the persona in ctx
+	EnabledFor(ctx context.Context, key Feature) bool
+
 	// Add features to the feature map
 	Set(features map[Feature]bool) error
+
+	// Replace resets the feature map to its registered defaults and applies features on top
+	Replace(features map[Feature]bool) error
+
+	// SetRollouts replaces all persona rollouts
+	SetRollouts(rollouts map[Feature]Rollout) error
+
+	// Override temporarily sets the state of a feature until the override expires
+	Override(key Feature, override Override) error
+
+	// RemoveOverride removes the override of a feature before it expires
+	RemoveOverride(key Feature) (Override, bool)
+
+	// ExpireOverrides removes all overrides expired at now and returns them
+	ExpireOverrides(now time.Time) map[Feature]Override
+
+	// Overrides returns all active overrides
+	Overrides() map[Feature]Override
+
+	// Features returns the feature map without overrides or rollouts applied
+	Features() map[Feature]bool
+
+	// SetWindows replaces all time windows
+	SetWindows(windows map[Feature]Window) error
+
+	// RetryAfter returns how long until a disabled feature is enabled by a window
+	RetryAfter(key Feature) (time.Duration, bool)
+
+	// SetClock replaces the clock used to evaluate windows and overrides
+	SetClock(now func() time.Time)
+
+	// Now returns the time of the clock used to evaluate windows and overrides
+	Now() time.Time
 }
 
 // featureGate implements Gate.
@@ -185,14 +240,36 @@ type featureGate struct {
 	// featureMap holds a map[RPCs]bool.
 	featureMap *atomic.Value
 
+	// defaults holds the registered state the feature map is reset to on Replace
+	defaults map[Feature]bool
+
+	// rollouts holds a map[Feature]Rollout.
+	rollouts *atomic.Value
+
+	// overrides holds a map[Feature]Override.
+	overrides *atomic.Value
+
+	// windows holds a map[Feature]Window.
+	windows *atomic.Value
+
+	// clock holds a func() time.Time used to evaluate windows and overrides.
+	clock *atomic.Value
+
 	// lock guards writes to known, enabled, and reads/writes of closed
 	// currently realtime change to the featuremap is not available however this is needed for parallel testing
 	lock sync.Mutex
 }
 
 // Enabled returns true if the key is enabled.
+// An active override takes precedence over a window, which takes precedence over the feature map.
 // If the key is not known, this will return false
 func (fg *featureGate) Enabled(key Feature) bool {
+	if o, ok := fg.active(key); ok {
+		return o.Enabled
+	}
+	if w, ok := fg.window(key, fg.Now()); ok {
+		return w.Enabled
+	}
 	if v, ok := fg.featureMap.Load().(map[Feature]bool)[key]; ok {
 		return v
 	}
@@ -201,15 +278,31 @@ func (fg *featureGate) Enabled(key Feature) bool {
 
 // newFeatureGate initialises Gate with default feature map and returns its instance
 func newFeatureGate(features map[Feature]bool) Gate {
-	fm := map[Feature]bool{}
-	for k, v := range features {
-		fm[k] = v
-	}
+	fm := copyFeatures(features)
 
 	mapValue := &atomic.Value{}
 	mapValue.Store(fm)
 
-	fg := &featureGate{featureMap: mapValue}
+	rolloutValue := &atomic.Value{}
+	rolloutValue.Store(map[Feature]Rollout{})
+
+	overrideValue := &atomic.Value{}
+	overrideValue.Store(map[Feature]Override{})
+
+	windowValue := &atomic.Value{}
+	windowValue.Store(map[Feature]Window{})
+
+	clockValue := &atomic.Value{}
+	clockValue.Store(time.Now)
+
+	fg := &featureGate{
+		featureMap: mapValue,
+		defaults:   copyFeatures(features),
+		rollouts:   rolloutValue,
+		overrides:  overrideValue,
+		windows:    windowValue,
+		clock:      clockValue,
+	}
 	return fg
 }
 
@@ -218,12 +311,23 @@ func (fg *featureGate) Set(features map[Feature]bool) error {
 	fg.lock.Lock()
 	defer fg.lock.Unlock()
 
-	fm := map[Feature]bool{}
 	// Copy state
-	for k, v := range fg.featureMap.Load().(map[Feature]bool) {
-		fm[k] = v
-	}
+	fm := copyFeatures(fg.featureMap.Load().(map[Feature]bool))
+
+	return fg.apply(fm, features)
+}
 
+// Replace resets features to their registered defaults before applying the input, so features
+// removed from the input revert to their default state. Nothing is changed if any feature is unknown.
+func (fg *featureGate) Replace(features map[Feature]bool) error {
+	fg.lock.Lock()
+	defer fg.lock.Unlock()
+
+	return fg.apply(copyFeatures(fg.defaults), features)
+}
+
+// apply sets features from input on fm and persists it, it must be called while holding lock
+func (fg *featureGate) apply(fm map[Feature]bool, features map[Feature]bool) error {
 	// Set feature from input
 	for k, v := range features {
 		if _, ok := fm[k]; !ok {
@@ -238,12 +342,28 @@ func (fg *featureGate) Set(features map[Feature]bool) error {
 	return nil
 }
 
+func copyFeatures(features map[Feature]bool) map[Feature]bool {
+	fm := make(map[Feature]bool, len(features))
+	for k, v := range features {
+		fm[k] = v
+	}
+	return fm
+}
+
 // APIFeatureGate to check whether feature has been enabled via the feature map from the config file.
-// It returns codes.Unavailable if the feature is not defined or enabled.
+// It returns codes.Unavailable if the feature is not defined or enabled for the calling persona.
+// It should be chained after authentication so persona rollouts can be evaluated.
 func APIFeatureGate() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		feature := Feature(strings.ToLower(info.FullMethod))
-		if !RPCGate.Enabled(feature) {
+		if !RPCGate.EnabledFor(ctx, feature) {
+			// Let the caller know when to try again if the method is disabled by a window
+			if retryAfter, ok := RPCGate.RetryAfter(feature); ok {
+				return nil, anzerrors.New(codes.Unavailable, "method is unavailable",
+					anzerrors.NewErrorInfo(ctx, anzcodes.FeatureDisabled, "feature disabled"),
+					anzerrors.WithCause(errors.New("method is behind feature window")),
+					anzerrors.WithRetryDelay(retryAfter))
+			}
 			return nil, anzerrors.New(codes.Unavailable, "method is unavailable",
 				anzerrors.NewErrorInfo(ctx, anzcodes.FeatureDisabled, "feature disabled"),
 				anzerrors.WithCause(errors.New("method is behind feature toggle")))
@@ -0,0 +1,99 @@
+package feature
+
+import (
+	"fmt"
+	"time"
+)
+
+// Override is a temporary state for a feature which takes precedence over the feature map and rollouts until it expires
+type Override struct {
+	Enabled   bool      `json:"enabled"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Reason    string    `json:"reason"`
+	Operator  string    `json:"operator,omitempty"`
+}
+
+// active returns the override for key if one exists and has not expired
+func (fg *featureGate) active(key Feature) (Override, bool) {
+	o, ok := fg.overrides.Load().(map[Feature]Override)[key]
+	if !ok || !fg.Now().Before(o.ExpiresAt) {
+		return Override{}, false
+	}
+	return o, true
+}
+
+// Override sets a temporary state for key, replacing any existing override
+func (fg *featureGate) Override(key Feature, override Override) error {
+	fg.lock.Lock()
+	defer fg.lock.Unlock()
+
+	if _, ok := fg.defaults[key]; !ok {
+		return fmt.Errorf("feature is not registered in feature gate: %s", key)
+	}
+
+	om := copyOverrides(fg.overrides.Load().(map[Feature]Override))
+	om[key] = override
+	fg.overrides.Store(om)
+
+	return nil
+}
+
+// RemoveOverride removes the override for key and returns it, if one existed
+func (fg *featureGate) RemoveOverride(key Feature) (Override, bool) {
+	fg.lock.Lock()
+	defer fg.lock.Unlock()
+
+	om := copyOverrides(fg.overrides.Load().(map[Feature]Override))
+	o, ok := om[key]
+	if ok {
+		delete(om, key)
+		fg.overrides.Store(om)
+	}
+
+	return o, ok
+}
+
+// ExpireOverrides removes and returns all overrides which expired at or before now
+func (fg *featureGate) ExpireOverrides(now time.Time) map[Feature]Override {
+	fg.lock.Lock()
+	defer fg.lock.Unlock()
+
+	expired := map[Feature]Override{}
+	om := copyOverrides(fg.overrides.Load().(map[Feature]Override))
+	for k, o := range om {
+		if !now.Before(o.ExpiresAt) {
+			expired[k] = o
+			delete(om, k)
+		}
+	}
+
+	if len(expired) > 0 {
+		fg.overrides.Store(om)
+	}
+
+	return expired
+}
+
+// Overrides returns a copy of all overrides which have not expired
+func (fg *featureGate) Overrides() map[Feature]Override {
+	out := map[Feature]Override{}
+	for k := range fg.overrides.Load().(map[Feature]Override) {
+		if o, ok := fg.active(k); ok {
+			out[k] = o
+		}
+	}
+	return out
+}
+
+// Features returns a copy of the feature map without overrides or rollouts applied
+func (fg *featureGate) Features() map[Feature]bool {
+	return copyFeatures(fg.featureMap.Load().(map[Feature]bool))
+}
+
+func copyOverrides(overrides map[Feature]Override) map[Feature]Override {
+	om := make(map[Feature]Override, len(overrides))
+	for k, v := range overrides {
+		om[k] = v
+	}
+	return om
+}
@@ -0,0 +1,38 @@
+package feature
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverride(t *testing.T) {
+	fg := newFeatureGate(RegisteredFeatures)
+	require.NoError(t, fg.SetRollouts(map[Feature]Rollout{DCVV2: {Percentage: 100}}))
+
+	require.NoError(t, fg.Override(DCVV2, Override{Enabled: false, ExpiresAt: time.Now().Add(time.Hour), Reason: "incident"}))
+	require.NoError(t, fg.Override(MCT_GAMBLING, Override{Enabled: true, ExpiresAt: time.Now().Add(-time.Second)}))
+
+	// an active override takes precedence over rollouts
+	assert.False(t, fg.EnabledFor(personaContext("persona", "fakerock"), DCVV2))
+	// an expired override is ignored
+	assert.False(t, fg.Enabled(MCT_GAMBLING))
+	assert.Len(t, fg.Overrides(), 1)
+
+	expired := fg.ExpireOverrides(time.Now())
+	assert.Contains(t, expired, MCT_GAMBLING)
+	assert.NotContains(t, expired, DCVV2)
+
+	o, ok := fg.RemoveOverride(DCVV2)
+	assert.True(t, ok)
+	assert.Equal(t, "incident", o.Reason)
+	assert.True(t, fg.EnabledFor(personaContext("persona", "fakerock"), DCVV2))
+
+	_, ok = fg.RemoveOverride(DCVV2)
+	assert.False(t, ok)
+
+	err := fg.Override("UnregisteredFeature", Override{ExpiresAt: time.Now().Add(time.Hour)})
+	assert.EqualError(t, err, "feature is not registered in feature gate: UnregisteredFeature")
+}
@@ -0,0 +1,91 @@
+package feature
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/anzx/fabric-cards/pkg/identity"
+)
+
+// Rollout enables a globally disabled feature for a subset of personas.
+// A persona is enabled if it matches any of the rules.
+type Rollout struct {
+	// Personas is an allow-list of persona IDs
+	Personas []string `json:"personas,omitempty"   yaml:"personas,omitempty"   mapstructure:"personas"`
+	// Percentage of personas, between 0 and 100, enabled by a deterministic hash of the feature and persona ID
+	Percentage int `json:"percentage,omitempty" yaml:"percentage,omitempty" mapstructure:"percentage"`
+	// Issuers enables every persona authenticated by a matching issuer, e.g. fakerock or forgerock.
+	// An issuer matches if it contains the configured value, ignoring case.
+	Issuers []string `json:"issuers,omitempty"    yaml:"issuers,omitempty"    mapstructure:"issuers"`
+}
+
+// EnabledFor returns true if the key is enabled globally or the rollout of the key matches the persona in ctx.
+// If the persona cannot be identified only the global state is used.
+func (fg *featureGate) EnabledFor(ctx context.Context, key Feature) bool {
+	if o, ok := fg.active(key); ok {
+		return o.Enabled
+	}
+
+	if fg.Enabled(key) {
+		return true
+	}
+
+	rollout, ok := fg.rollouts.Load().(map[Feature]Rollout)[key]
+	if !ok {
+		return false
+	}
+
+	id, err := identity.Get(ctx)
+	if err != nil {
+		return false
+	}
+
+	return rollout.matches(key, id)
+}
+
+// SetRollouts replaces all rollouts, nothing is changed if any feature is unknown or a percentage is out of range
+func (fg *featureGate) SetRollouts(rollouts map[Feature]Rollout) error {
+	fg.lock.Lock()
+	defer fg.lock.Unlock()
+
+	rm := make(map[Feature]Rollout, len(rollouts))
+	for k, v := range rollouts {
+		if _, ok := fg.defaults[k]; !ok {
+			return fmt.Errorf("feature is not registered in feature gate: %s", k)
+		}
+		if v.Percentage < 0 || v.Percentage > 100 {
+			return fmt.Errorf("rollout percentage must be between 0 and 100: %s", k)
+		}
+		rm[k] = v
+	}
+
+	fg.rollouts.Store(rm)
+
+	return nil
+}
+
+func (r Rollout) matches(key Feature, id *identity.Identity) bool {
+	for _, persona := range r.Personas {
+		if persona == id.PersonaID {
+			return true
+		}
+	}
+
+	for _, issuer := range r.Issuers {
+		if issuer != "" && strings.Contains(strings.ToLower(id.Issuer), strings.ToLower(issuer)) {
+			return true
+		}
+	}
+
+	return r.Percentage > 0 && bucket(key, id.PersonaID) < r.Percentage
+}
+
+// bucket deterministically places a persona in one of 100 buckets, salted with the feature
+// so each feature rolls out to a different cohort
+func bucket(key Feature, personaID string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(fmt.Sprintf("%s:%s", key, personaID)))
+	return int(h.Sum32() % 100)
+}
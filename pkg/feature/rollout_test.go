@@ -0,0 +1,123 @@
+package feature
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/anzx/pkg/jwtauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func personaContext(personaID string, issuer string) context.Context {
+	return jwtauth.AddClaimsToContext(context.Background(), jwtauth.NewClaims(jwtauth.BaseClaims{
+		Claims: jwt.Claims{
+			Issuer:  issuer,
+			Subject: "Subject",
+		},
+		Persona: &jwtauth.Persona{PersonaID: personaID},
+	}))
+}
+
+func TestEnabledFor(t *testing.T) {
+	tests := []struct {
+		description string
+		features    map[Feature]bool
+		rollouts    map[Feature]Rollout
+		ctx         context.Context
+		want        bool
+	}{
+		{
+			description: "globally enabled",
+			features:    map[Feature]bool{MCT_GAMBLING: true},
+			ctx:         context.Background(),
+			want:        true,
+		},
+		{
+			description: "globally disabled without rollout",
+			ctx:         personaContext("persona", "fakerock.sit.fabric.gcpnp.anz"),
+			want:        false,
+		},
+		{
+			description: "allow-listed persona",
+			rollouts:    map[Feature]Rollout{MCT_GAMBLING: {Personas: []string{"other", "persona"}}},
+			ctx:         personaContext("persona", "fakerock.sit.fabric.gcpnp.anz"),
+			want:        true,
+		},
+		{
+			description: "persona not allow-listed",
+			rollouts:    map[Feature]Rollout{MCT_GAMBLING: {Personas: []string{"other"}}},
+			ctx:         personaContext("persona", "fakerock.sit.fabric.gcpnp.anz"),
+			want:        false,
+		},
+		{
+			description: "matching issuer",
+			rollouts:    map[Feature]Rollout{MCT_GAMBLING: {Issuers: []string{"FakeRock"}}},
+			ctx:         personaContext("persona", "fakerock.sit.fabric.gcpnp.anz"),
+			want:        true,
+		},
+		{
+			description: "other issuer",
+			rollouts:    map[Feature]Rollout{MCT_GAMBLING: {Issuers: []string{"fakerock"}}},
+			ctx:         personaContext("persona", "https://forgerock.anz.com"),
+			want:        false,
+		},
+		{
+			description: "full percentage",
+			rollouts:    map[Feature]Rollout{MCT_GAMBLING: {Percentage: 100}},
+			ctx:         personaContext("persona", "fakerock.sit.fabric.gcpnp.anz"),
+			want:        true,
+		},
+		{
+			description: "rollout without identity",
+			rollouts:    map[Feature]Rollout{MCT_GAMBLING: {Percentage: 100}},
+			ctx:         context.Background(),
+			want:        false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.description, func(t *testing.T) {
+			fg := newFeatureGate(RegisteredFeatures)
+			require.NoError(t, fg.Set(test.features))
+			require.NoError(t, fg.SetRollouts(test.rollouts))
+
+			assert.Equal(t, test.want, fg.EnabledFor(test.ctx, MCT_GAMBLING))
+		})
+	}
+}
+
+func TestEnabledFor_Percentage(t *testing.T) {
+	fg := newFeatureGate(RegisteredFeatures)
+	require.NoError(t, fg.SetRollouts(map[Feature]Rollout{DCVV2: {Percentage: 30}}))
+
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		ctx := personaContext(fmt.Sprintf("persona-%d", i), "fakerock.sit.fabric.gcpnp.anz")
+		got := fg.EnabledFor(ctx, DCVV2)
+		// the same persona always lands in the same bucket
+		assert.Equal(t, got, fg.EnabledFor(ctx, DCVV2))
+		if got {
+			enabled++
+		}
+	}
+
+	assert.InDelta(t, 300, enabled, 60)
+}
+
+func TestSetRollouts(t *testing.T) {
+	fg := newFeatureGate(RegisteredFeatures)
+	require.NoError(t, fg.SetRollouts(map[Feature]Rollout{DCVV2: {Percentage: 100}}))
+
+	err := fg.SetRollouts(map[Feature]Rollout{"UnregisteredFeature": {Percentage: 10}})
+	assert.EqualError(t, err, "feature is not registered in feature gate: UnregisteredFeature")
+
+	err = fg.SetRollouts(map[Feature]Rollout{DCVV2: {Percentage: 101}})
+	assert.EqualError(t, err, "rollout percentage must be between 0 and 100: DCVV2")
+
+	// rejected rollouts leave the previous rollouts in place
+	assert.True(t, fg.EnabledFor(personaContext("persona", "fakerock"), DCVV2))
+}
@@ -0,0 +1,233 @@
+package feature
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
+	"github.com/anzx/pkg/gsm"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+const defaultWatchInterval = 30 * time.Second
+
+// WatchConfig defines the source feature toggles are reloaded from while the service is running.
+// Exactly one of File or SecretID should be set.
+type WatchConfig struct {
+	// File is the path to a mounted config file, e.g. /app/config/config.yaml
+	File string `json:"file,omitempty"     yaml:"file,omitempty"     mapstructure:"file"`
+	// SecretID is the GSM secret holding the feature toggles yaml
+	SecretID string `json:"secretId,omitempty" yaml:"secretId,omitempty" mapstructure:"secretId"`
+	// Key is the dot separated path to the feature toggles within the document, e.g. spec.featureToggles.
+	// Leave empty when the document only holds the rpc and features maps.
+	Key string `json:"key,omitempty"      yaml:"key,omitempty"      mapstructure:"key"`
+	// Interval between reads of the source, defaults to 30s
+	Interval time.Duration `json:"interval,omitempty" yaml:"interval,omitempty" mapstructure:"interval"`
+}
+
+// Watcher reloads RPCGate and FeatureGate whenever the watched source changes
+type Watcher struct {
+	config   WatchConfig
+	read     func(ctx context.Context) ([]byte, error)
+	rpcs     Gate
+	features Gate
+	last     []byte
+}
+
+// NewWatcher returns a Watcher for the configured source, or nil if no config is provided
+func NewWatcher(ctx context.Context, config *WatchConfig, gsmClient *gsm.Client) (*Watcher, error) {
+	if config == nil {
+		logf.Debug(ctx, "feature watch config not provided %v", config)
+		return nil, nil
+	}
+
+	w := &Watcher{
+		config:   *config,
+		rpcs:     RPCGate,
+		features: FeatureGate,
+	}
+
+	if w.config.Interval <= 0 {
+		w.config.Interval = defaultWatchInterval
+	}
+
+	switch {
+	case config.File != "":
+		w.read = func(_ context.Context) ([]byte, error) {
+			return os.ReadFile(config.File)
+		}
+	case config.SecretID != "":
+		if gsmClient == nil {
+			return nil, errors.New("feature watch requires a gsm client to read secrets")
+		}
+		w.read = func(ctx context.Context) ([]byte, error) {
+			payload, err := gsmClient.AccessSecret(ctx, config.SecretID)
+			return []byte(payload), err
+		}
+	default:
+		return nil, errors.New("feature watch requires either a file or secretId")
+	}
+
+	return w, nil
+}
+
+// Watch returns a function suitable for an errgroup which reloads feature toggles until ctx is done.
+// It is a no-op if config is nil.
+func Watch(ctx context.Context, config *WatchConfig, gsmClient *gsm.Client) func() error {
+	return func() error {
+		w, err := NewWatcher(ctx, config, gsmClient)
+		if err != nil || w == nil {
+			return err
+		}
+		return w.Run(ctx)
+	}
+}
+
+// Run polls the source on every interval and applies changes until ctx is done
+func (w *Watcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	logf.Info(ctx, "feature: watching feature toggles every %v", w.config.Interval)
+
+	for {
+		// A failed reload keeps the current state, the next tick will try again
+		if err := w.Reload(ctx); err != nil {
+			logf.Error(ctx, err, "feature: failed to reload feature toggles")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reload reads the source and, if it changed since the last read, replaces the state of both gates.
+// Unknown keys reject the whole reload and leave both gates untouched.
+func (w *Watcher) Reload(ctx context.Context) error {
+	payload, err := w.read(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to read feature toggles")
+	}
+
+	if w.last != nil && bytes.Equal(payload, w.last) {
+		return nil
+	}
+
+	config, err := w.parse(payload)
+	if err != nil {
+		return err
+	}
+
+	if err := validate(RegisteredRPCs, config.RPCs, config.RPCRollouts, config.RPCWindows); err != nil {
+		return err
+	}
+	if err := validate(RegisteredFeatures, config.Features, config.FeatureRollouts, config.FeatureWindows); err != nil {
+		return err
+	}
+
+	changes := append(w.replace(ctx, w.rpcs, RegisteredRPCs, config.RPCs), w.replace(ctx, w.features, RegisteredFeatures, config.Features)...)
+	for _, change := range changes {
+		logf.Info(ctx, "feature: %s", change)
+	}
+
+	if err := w.rpcs.SetRollouts(config.RPCRollouts); err != nil {
+		logf.Error(ctx, err, "feature: failed to replace rpc rollouts")
+	}
+	if err := w.features.SetRollouts(config.FeatureRollouts); err != nil {
+		logf.Error(ctx, err, "feature: failed to replace feature rollouts")
+	}
+	logf.Info(ctx, "feature: rollouts set for %d rpcs and %d features", len(config.RPCRollouts), len(config.FeatureRollouts))
+
+	if err := w.rpcs.SetWindows(config.RPCWindows); err != nil {
+		logf.Error(ctx, err, "feature: failed to replace rpc windows")
+	}
+	if err := w.features.SetWindows(config.FeatureWindows); err != nil {
+		logf.Error(ctx, err, "feature: failed to replace feature windows")
+	}
+	logf.Info(ctx, "feature: windows set for %d rpcs and %d features", len(config.RPCWindows), len(config.FeatureWindows))
+
+	w.last = payload
+
+	return nil
+}
+
+func (w *Watcher) parse(payload []byte) (Config, error) {
+	var config Config
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewReader(payload)); err != nil {
+		return config, errors.Wrap(err, "unable to read feature toggles")
+	}
+
+	hook := viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		TimeDecodeHook(),
+	))
+
+	var err error
+	if w.config.Key == "" {
+		err = v.Unmarshal(&config, hook)
+	} else {
+		err = v.UnmarshalKey(w.config.Key, &config, hook)
+	}
+	if err != nil {
+		return config, errors.Wrap(err, "unable to unmarshal feature toggles")
+	}
+
+	return config, nil
+}
+
+// replace swaps the state of gate and returns a description of every feature whose state changed
+func (w *Watcher) replace(ctx context.Context, gate Gate, registered map[Feature]bool, features map[Feature]bool) []string {
+	before := make(map[Feature]bool, len(registered))
+	for k := range registered {
+		before[k] = gate.Enabled(k)
+	}
+
+	if err := gate.Replace(features); err != nil {
+		// Keys are validated before replace is called so this should not happen
+		logf.Error(ctx, err, "feature: failed to replace feature toggles")
+		return nil
+	}
+
+	var changes []string
+	for k, was := range before {
+		if now := gate.Enabled(k); now != was {
+			changes = append(changes, fmt.Sprintf("%s changed from %t to %t", k, was, now))
+		}
+	}
+
+	return changes
+}
+
+// validate returns an error for the first feature, rollout or window that would be rejected by the gate
+func validate(registered map[Feature]bool, features map[Feature]bool, rollouts map[Feature]Rollout, windows map[Feature]Window) error {
+	for k := range features {
+		if _, ok := registered[k]; !ok {
+			return fmt.Errorf("feature is not registered in feature gate: %s", k)
+		}
+	}
+	for k, v := range rollouts {
+		if _, ok := registered[k]; !ok {
+			return fmt.Errorf("feature is not registered in feature gate: %s", k)
+		}
+		if v.Percentage < 0 || v.Percentage > 100 {
+			return fmt.Errorf("rollout percentage must be between 0 and 100: %s", k)
+		}
+	}
+	for k, v := range windows {
+		if err := validateWindow(registered, k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
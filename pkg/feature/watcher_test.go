@@ -0,0 +1,143 @@
+package feature
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const watchedConfig = `
+spec:
+  featureToggles:
+    features:
+      - REASON_LOST: true
+      - MCT_GAMBLING: true
+    rpc:
+      - /fabric.service.card.v1beta1.cardapi/activate: true
+      - /fabric.service.card.v1beta1.cardapi/replace: true
+`
+
+func newTestWatcher(t *testing.T, payload string) (*Watcher, string) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(payload), 0o600))
+
+	w, err := NewWatcher(context.Background(), &WatchConfig{File: file, Key: "spec.featureToggles"}, nil)
+	require.NoError(t, err)
+
+	w.rpcs = newFeatureGate(RegisteredRPCs)
+	w.features = newFeatureGate(RegisteredFeatures)
+
+	return w, file
+}
+
+func TestNewWatcher(t *testing.T) {
+	t.Run("no config", func(t *testing.T) {
+		w, err := NewWatcher(context.Background(), nil, nil)
+		assert.NoError(t, err)
+		assert.Nil(t, w)
+	})
+	t.Run("no source", func(t *testing.T) {
+		_, err := NewWatcher(context.Background(), &WatchConfig{}, nil)
+		assert.EqualError(t, err, "feature watch requires either a file or secretId")
+	})
+	t.Run("secret without gsm client", func(t *testing.T) {
+		_, err := NewWatcher(context.Background(), &WatchConfig{SecretID: "feature-toggles"}, nil)
+		assert.EqualError(t, err, "feature watch requires a gsm client to read secrets")
+	})
+	t.Run("default interval", func(t *testing.T) {
+		w, err := NewWatcher(context.Background(), &WatchConfig{File: "config.yaml"}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, defaultWatchInterval, w.config.Interval)
+	})
+}
+
+func TestWatcher_Reload(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("applies rpc and features", func(t *testing.T) {
+		w, _ := newTestWatcher(t, watchedConfig)
+
+		require.NoError(t, w.Reload(ctx))
+
+		assert.True(t, w.rpcs.Enabled(CardActivate))
+		assert.True(t, w.rpcs.Enabled(CardReplace))
+		assert.False(t, w.rpcs.Enabled(CardList))
+		assert.True(t, w.features.Enabled(REASON_LOST))
+		assert.True(t, w.features.Enabled(MCT_GAMBLING))
+		assert.False(t, w.features.Enabled(REASON_STOLEN))
+	})
+	t.Run("removed toggles revert to default", func(t *testing.T) {
+		w, file := newTestWatcher(t, watchedConfig)
+		require.NoError(t, w.Reload(ctx))
+
+		require.NoError(t, os.WriteFile(file, []byte(`
+spec:
+  featureToggles:
+    features:
+      - REASON_LOST: true
+    rpc:
+      - /fabric.service.card.v1beta1.cardapi/activate: true
+`), 0o600))
+		require.NoError(t, w.Reload(ctx))
+
+		assert.True(t, w.rpcs.Enabled(CardActivate))
+		assert.False(t, w.rpcs.Enabled(CardReplace))
+		assert.True(t, w.features.Enabled(REASON_LOST))
+		assert.False(t, w.features.Enabled(MCT_GAMBLING))
+		assert.True(t, w.rpcs.Enabled(HealthAlive))
+	})
+	t.Run("unknown key rejects the whole reload", func(t *testing.T) {
+		w, file := newTestWatcher(t, watchedConfig)
+		require.NoError(t, w.Reload(ctx))
+
+		require.NoError(t, os.WriteFile(file, []byte(`
+spec:
+  featureToggles:
+    features:
+      - NOT_A_FEATURE: true
+    rpc:
+      - /fabric.service.card.v1beta1.cardapi/activate: false
+`), 0o600))
+		err := w.Reload(ctx)
+
+		assert.EqualError(t, err, "feature is not registered in feature gate: NOT_A_FEATURE")
+		assert.True(t, w.rpcs.Enabled(CardActivate))
+		assert.True(t, w.features.Enabled(MCT_GAMBLING))
+	})
+	t.Run("missing file keeps current state", func(t *testing.T) {
+		w, file := newTestWatcher(t, watchedConfig)
+		require.NoError(t, w.Reload(ctx))
+		require.NoError(t, os.Remove(file))
+
+		assert.Error(t, w.Reload(ctx))
+		assert.True(t, w.rpcs.Enabled(CardActivate))
+	})
+	t.Run("whole document when no key", func(t *testing.T) {
+		w, _ := newTestWatcher(t, `
+rpc:
+  - /fabric.service.card.v1beta1.cardapi/list: true
+`)
+		w.config.Key = ""
+
+		require.NoError(t, w.Reload(ctx))
+		assert.True(t, w.rpcs.Enabled(CardList))
+	})
+}
+
+func TestFeatureGate_Replace(t *testing.T) {
+	fg := newFeatureGate(RegisteredRPCs)
+	require.NoError(t, fg.Set(map[Feature]bool{CardActivate: true, CardList: true}))
+
+	require.NoError(t, fg.Replace(map[Feature]bool{CardList: true}))
+	assert.False(t, fg.Enabled(CardActivate))
+	assert.True(t, fg.Enabled(CardList))
+	assert.True(t, fg.Enabled(HealthAlive))
+
+	err := fg.Replace(map[Feature]bool{CardActivate: true, "UnregisteredFeature": true})
+	assert.EqualError(t, err, "feature is not registered in feature gate: UnregisteredFeature")
+	assert.False(t, fg.Enabled(CardActivate))
+}
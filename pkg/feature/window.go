@@ -0,0 +1,126 @@
+package feature
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// Window sets the state of a feature between EnableFrom and EnableUntil, outside the window the feature map applies.
+// Either bound can be left empty for an open ended window, e.g. a launch only sets EnableFrom.
+type Window struct {
+	// Enabled is the state of the feature within the window
+	Enabled bool `json:"enabled"               yaml:"enabled"               mapstructure:"enabled"`
+	// EnableFrom is the RFC3339 time the window starts, inclusive
+	EnableFrom time.Time `json:"enableFrom,omitempty"  yaml:"enableFrom,omitempty"  mapstructure:"enableFrom"`
+	// EnableUntil is the RFC3339 time the window ends, exclusive
+	EnableUntil time.Time `json:"enableUntil,omitempty" yaml:"enableUntil,omitempty" mapstructure:"enableUntil"`
+}
+
+// TimeDecodeHook decodes RFC3339 strings into time.Time, it should be composed with any other decode hooks
+// used to unmarshal Config
+func TimeDecodeHook() mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if t != reflect.TypeOf(time.Time{}) {
+			return data, nil
+		}
+		switch v := data.(type) {
+		case string:
+			if v == "" {
+				return time.Time{}, nil
+			}
+			return time.Parse(time.RFC3339, v)
+		case time.Time:
+			return v, nil
+		}
+		return data, nil
+	}
+}
+
+// contains returns true if now is within the window
+func (w Window) contains(now time.Time) bool {
+	if !w.EnableFrom.IsZero() && now.Before(w.EnableFrom) {
+		return false
+	}
+	if !w.EnableUntil.IsZero() && !now.Before(w.EnableUntil) {
+		return false
+	}
+	return true
+}
+
+// window returns the window for key if now is within it
+func (fg *featureGate) window(key Feature, now time.Time) (Window, bool) {
+	w, ok := fg.windows.Load().(map[Feature]Window)[key]
+	if !ok || !w.contains(now) {
+		return Window{}, false
+	}
+	return w, true
+}
+
+// SetWindows replaces all windows, nothing is changed if any feature is unknown or a window ends before it starts
+func (fg *featureGate) SetWindows(windows map[Feature]Window) error {
+	fg.lock.Lock()
+	defer fg.lock.Unlock()
+
+	wm := make(map[Feature]Window, len(windows))
+	for k, v := range windows {
+		if err := validateWindow(fg.defaults, k, v); err != nil {
+			return err
+		}
+		wm[k] = v
+	}
+
+	fg.windows.Store(wm)
+
+	return nil
+}
+
+// RetryAfter returns how long until a disabled feature is expected to be enabled by a window.
+// It returns false if the feature is enabled or no window will enable it.
+func (fg *featureGate) RetryAfter(key Feature) (time.Duration, bool) {
+	now := fg.Now()
+	if fg.Enabled(key) {
+		return 0, false
+	}
+
+	w, ok := fg.windows.Load().(map[Feature]Window)[key]
+	if !ok {
+		return 0, false
+	}
+
+	switch {
+	case w.contains(now) && !w.Enabled && !w.EnableUntil.IsZero() && fg.featureMap.Load().(map[Feature]bool)[key]:
+		// disabled for a maintenance window and enabled again once it ends
+		return w.EnableUntil.Sub(now), true
+	case w.Enabled && !w.EnableFrom.IsZero() && now.Before(w.EnableFrom):
+		// scheduled to launch
+		return w.EnableFrom.Sub(now), true
+	}
+
+	return 0, false
+}
+
+// SetClock replaces the clock used to evaluate windows and overrides
+func (fg *featureGate) SetClock(now func() time.Time) {
+	fg.lock.Lock()
+	defer fg.lock.Unlock()
+
+	fg.clock.Store(now)
+}
+
+// Now returns the time of the clock used to evaluate windows and overrides
+func (fg *featureGate) Now() time.Time {
+	return fg.clock.Load().(func() time.Time)()
+}
+
+func validateWindow(registered map[Feature]bool, key Feature, w Window) error {
+	if _, ok := registered[key]; !ok {
+		return fmt.Errorf("feature is not registered in feature gate: %s", key)
+	}
+	if !w.EnableFrom.IsZero() && !w.EnableUntil.IsZero() && !w.EnableFrom.Before(w.EnableUntil) {
+		return fmt.Errorf("window must start before it ends: %s", key)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package feature
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+var (
+	windowStart = time.Date(2022, 10, 1, 13, 0, 0, 0, time.UTC)
+	windowEnd   = time.Date(2022, 10, 1, 15, 0, 0, 0, time.UTC)
+)
+
+func clockAt(at time.Time) func() time.Time {
+	return func() time.Time {
+		return at
+	}
+}
+
+func TestWindow(t *testing.T) {
+	tests := []struct {
+		description    string
+		configured     bool
+		window         Window
+		now            time.Time
+		wantEnabled    bool
+		wantRetryAfter time.Duration
+	}{
+		{
+			description: "before maintenance window",
+			configured:  true,
+			window:      Window{Enabled: false, EnableFrom: windowStart, EnableUntil: windowEnd},
+			now:         windowStart.Add(-time.Minute),
+			wantEnabled: true,
+		},
+		{
+			description:    "inside maintenance window",
+			configured:     true,
+			window:         Window{Enabled: false, EnableFrom: windowStart, EnableUntil: windowEnd},
+			now:            windowStart.Add(30 * time.Minute),
+			wantEnabled:    false,
+			wantRetryAfter: 90 * time.Minute,
+		},
+		{
+			description: "end of maintenance window is exclusive",
+			configured:  true,
+			window:      Window{Enabled: false, EnableFrom: windowStart, EnableUntil: windowEnd},
+			now:         windowEnd,
+			wantEnabled: true,
+		},
+		{
+			description:    "before launch",
+			window:         Window{Enabled: true, EnableFrom: windowStart},
+			now:            windowStart.Add(-time.Hour),
+			wantEnabled:    false,
+			wantRetryAfter: time.Hour,
+		},
+		{
+			description: "start of launch is inclusive",
+			window:      Window{Enabled: true, EnableFrom: windowStart},
+			now:         windowStart,
+			wantEnabled: true,
+		},
+		{
+			description: "inside maintenance window of a disabled feature",
+			window:      Window{Enabled: false, EnableFrom: windowStart, EnableUntil: windowEnd},
+			now:         windowStart,
+			wantEnabled: false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.description, func(t *testing.T) {
+			fg := newFeatureGate(RegisteredRPCs)
+			fg.SetClock(clockAt(test.now))
+			require.NoError(t, fg.Set(map[Feature]bool{CardReplace: test.configured}))
+			require.NoError(t, fg.SetWindows(map[Feature]Window{CardReplace: test.window}))
+
+			assert.Equal(t, test.wantEnabled, fg.Enabled(CardReplace))
+			retryAfter, ok := fg.RetryAfter(CardReplace)
+			assert.Equal(t, test.wantRetryAfter != 0, ok)
+			assert.Equal(t, test.wantRetryAfter, retryAfter)
+		})
+	}
+}
+
+func TestSetWindows(t *testing.T) {
+	fg := newFeatureGate(RegisteredFeatures)
+
+	err := fg.SetWindows(map[Feature]Window{"UnregisteredFeature": {Enabled: true}})
+	assert.EqualError(t, err, "feature is not registered in feature gate: UnregisteredFeature")
+
+	err = fg.SetWindows(map[Feature]Window{MCT_GAMBLING: {Enabled: true, EnableFrom: windowEnd, EnableUntil: windowStart}})
+	assert.EqualError(t, err, "window must start before it ends: MCT_GAMBLING")
+}
+
+func TestWatcher_ReloadWindows(t *testing.T) {
+	w, _ := newTestWatcher(t, `
+spec:
+  featureToggles:
+    rpc:
+      - /fabric.service.card.v1beta1.cardapi/replace: true
+    rpcWindows:
+      - /fabric.service.card.v1beta1.cardapi/replace:
+          enabled: false
+          enableFrom: "2022-10-01T13:00:00Z"
+          enableUntil: "2022-10-01T15:00:00Z"
+`)
+	w.rpcs.SetClock(clockAt(windowStart.Add(time.Minute)))
+
+	require.NoError(t, w.Reload(context.Background()))
+
+	assert.False(t, w.rpcs.Enabled(CardReplace))
+	retryAfter, ok := w.rpcs.RetryAfter(CardReplace)
+	assert.True(t, ok)
+	assert.Equal(t, 119*time.Minute, retryAfter)
+}
+
+func TestAPIFeatureGate_Window(t *testing.T) {
+	require.NoError(t, RPCGate.Set(map[Feature]bool{CardReplace: true}))
+	require.NoError(t, RPCGate.SetWindows(map[Feature]Window{
+		CardReplace: {Enabled: false, EnableFrom: windowStart, EnableUntil: windowEnd},
+	}))
+	RPCGate.SetClock(clockAt(windowStart))
+	defer func() {
+		RPCGate.SetClock(time.Now)
+		require.NoError(t, RPCGate.SetWindows(nil))
+		require.NoError(t, RPCGate.Set(RegisteredRPCs))
+	}()
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	_, err := APIFeatureGate()(context.Background(), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/fabric.service.card.v1beta1.CardAPI/Replace"}, handler)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "method is unavailable")
+	assert.Contains(t, err.Error(), "feature disabled")
+}
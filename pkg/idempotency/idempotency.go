@@ -0,0 +1,93 @@
+// Package idempotency replays the outcome of a mutating RPC to clients that retry it with the same idempotency key.
+package idempotency
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+
+	"github.com/anzx/pkg/gsm"
+
+	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
+	"github.com/anzx/fabric-cards/pkg/ratelimit"
+)
+
+const (
+	defaultWindow = 24 * time.Hour
+	defaultLock   = time.Minute
+)
+
+type Config struct {
+	// Redis the outcomes are stored in, the rate limit Redis is used if not set
+	Redis *ratelimit.RedisConfig `json:"redis,omitempty" yaml:"redis,omitempty" mapstructure:"redis"`
+	// Prefix to be added to every cache key, can be empty
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty" mapstructure:"prefix"`
+	// Methods are the full gRPC method names that honour an idempotency key, e.g. /fabric.service.card.v1beta1.CardAPI/Replace
+	Methods []string `json:"methods" yaml:"methods" mapstructure:"methods" validate:"required"`
+	// Window is how long an outcome is replayed for, defaults to 24h
+	Window time.Duration `json:"window,omitempty" yaml:"window,omitempty" mapstructure:"window"`
+	// Lock is how long a call is considered in progress if it never completes, e.g. the pod running it stops. It is
+	// extended while the call runs so calls can take longer. Defaults to 1m
+	Lock time.Duration `json:"lock,omitempty" yaml:"lock,omitempty" mapstructure:"lock"`
+}
+
+// Client stores the outcome of each call made with an idempotency key
+type Client struct {
+	Prefix  string
+	Methods map[string]bool
+	Window  time.Duration
+	Lock    time.Duration
+	Client  redis.Cmdable
+}
+
+// NewClient returns nil if no config is provided. The Redis in config is used if set, otherwise client, which is
+// usually the rate limit Redis. Calls are not made idempotent if there is no Redis.
+func NewClient(ctx context.Context, config *Config, client redis.Cmdable, gsmClient *gsm.Client) (*Client, error) {
+	if config == nil {
+		logf.Debug(ctx, "idempotency config not provided %v", config)
+		return nil, nil
+	}
+
+	if config.Redis != nil {
+		if err := config.Redis.GetSecrets(ctx, gsmClient); err != nil {
+			logf.Error(ctx, err, "idempotency: failed to get redis secret")
+			return nil, errors.Wrap(err, "unable to access secret")
+		}
+		redisClient, err := ratelimit.NewRedisClient(ctx, *config.Redis)
+		if err != nil {
+			// the client reconnects, calls are not made idempotent until Redis is available
+			logf.Error(ctx, err, "idempotency: redis unavailable")
+		}
+		client = redisClient
+	}
+
+	if client == nil {
+		logf.Info(ctx, "idempotency: redis not configured, idempotency keys are ignored")
+		return nil, nil
+	}
+
+	methods := make(map[string]bool, len(config.Methods))
+	for _, m := range config.Methods {
+		methods[strings.ToLower(m)] = true
+	}
+
+	window := config.Window
+	if window <= 0 {
+		window = defaultWindow
+	}
+	lock := config.Lock
+	if lock <= 0 {
+		lock = defaultLock
+	}
+
+	return &Client{
+		Prefix:  config.Prefix,
+		Methods: methods,
+		Window:  window,
+		Lock:    lock,
+		Client:  client,
+	}, nil
+}
@@ -0,0 +1,269 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/anzx/fabric-cards/pkg/identity"
+	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+)
+
+const (
+	// KeyHeader is the metadata header carrying the idempotency key chosen by the client
+	KeyHeader = "idempotency-key"
+	// ReplayedHeader is set on the response header of a replayed call
+	ReplayedHeader = "idempotency-replayed"
+
+	maxKeyLength = 128
+	failed       = "idempotency check failed"
+)
+
+type state string
+
+const (
+	inProgress state = "in_progress"
+	completed  state = "completed"
+)
+
+// outcome is stored under the idempotency key, Hash identifies the request so a key can't be reused for another one
+type outcome struct {
+	State    state  `json:"state"`
+	Hash     string `json:"hash"`
+	Response []byte `json:"response,omitempty"`
+	Status   []byte `json:"status,omitempty"`
+}
+
+// UnaryServerInterceptor replays the outcome of the first call made to a configured method with an idempotency key to
+// later calls by the same persona with the same key. It must be chained after authentication.
+func UnaryServerInterceptor(client *Client) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if client == nil || !client.Methods[strings.ToLower(info.FullMethod)] {
+			return handler(ctx, req)
+		}
+
+		key := idempotencyKey(ctx)
+		if key == "" {
+			return handler(ctx, req)
+		}
+
+		return client.call(ctx, info.FullMethod, key, req, handler)
+	}
+}
+
+func (c *Client) call(ctx context.Context, method, key string, req interface{}, handler grpc.UnaryHandler) (interface{}, error) {
+	if len(key) > maxKeyLength {
+		return nil, anzerrors.New(codes.InvalidArgument, failed,
+			anzerrors.NewErrorInfo(ctx, anzcodes.ValidationFailure, fmt.Sprintf("idempotency key longer than %d characters", maxKeyLength)))
+	}
+
+	id, err := identity.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return handler(ctx, req)
+	}
+	hash, err := requestHash(msg)
+	if err != nil {
+		logf.Error(ctx, err, "idempotency: unable to hash request to %s", method)
+		return handler(ctx, req)
+	}
+
+	cacheKey := fmt.Sprintf("%sidempotency:%s:%s:%s", c.Prefix, strings.ToLower(method), id.PersonaID, key)
+
+	claimed, err := c.claim(ctx, cacheKey, hash)
+	if err != nil {
+		// calls are not made idempotent while Redis is unavailable rather than failing them
+		logf.Error(ctx, err, "idempotency: unable to claim key for %s", method)
+		return handler(ctx, req)
+	}
+	if !claimed {
+		return c.replay(ctx, cacheKey, hash)
+	}
+
+	release := c.hold(ctx, cacheKey)
+	resp, handlerErr := handler(ctx, req)
+	release()
+	c.store(ctx, cacheKey, hash, resp, handlerErr)
+	return resp, handlerErr
+}
+
+// claim records the call as in progress, false is returned if the key was already used
+func (c *Client) claim(ctx context.Context, cacheKey, hash string) (bool, error) {
+	payload, err := json.Marshal(outcome{State: inProgress, Hash: hash})
+	if err != nil {
+		return false, err
+	}
+	return c.Client.SetNX(ctx, cacheKey, payload, c.Lock).Result()
+}
+
+// hold extends the lock on a claimed key every half Lock until the returned func is called, so a call that runs for
+// longer than Lock, e.g. a Replace saga, is still in progress to retries
+func (c *Client) hold(ctx context.Context, cacheKey string) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(c.Lock / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := c.Client.Expire(ctx, cacheKey, c.Lock).Err(); err != nil && ctx.Err() == nil {
+					logf.Error(ctx, err, "idempotency: unable to extend lock")
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (c *Client) replay(ctx context.Context, cacheKey, hash string) (interface{}, error) {
+	payload, err := c.Client.Get(ctx, cacheKey).Bytes()
+	if err == redis.Nil {
+		return nil, anzerrors.New(codes.Aborted, failed,
+			anzerrors.NewErrorInfo(ctx, anzcodes.ValidationFailure, "request with this idempotency key has just completed, retry"))
+	}
+	if err != nil {
+		return nil, anzerrors.Wrap(err, codes.Unavailable, failed,
+			anzerrors.NewErrorInfo(ctx, anzcodes.DownstreamFailure, "unable to read idempotency key"))
+	}
+
+	var previous outcome
+	if err := json.Unmarshal(payload, &previous); err != nil {
+		return nil, anzerrors.Wrap(err, codes.Internal, failed,
+			anzerrors.NewErrorInfo(ctx, anzcodes.Unknown, "unable to read idempotency key"))
+	}
+
+	if previous.Hash != hash {
+		return nil, anzerrors.New(codes.InvalidArgument, failed,
+			anzerrors.NewErrorInfo(ctx, anzcodes.ValidationFailure, "idempotency key was used for a different request"))
+	}
+
+	if previous.State == inProgress {
+		return nil, anzerrors.New(codes.Aborted, failed,
+			anzerrors.NewErrorInfo(ctx, anzcodes.ValidationFailure, "request with this idempotency key is in progress"))
+	}
+
+	if err := grpc.SetHeader(ctx, metadata.Pairs(ReplayedHeader, "true")); err != nil {
+		logf.Debug(ctx, "idempotency: unable to set replayed header: %v", err)
+	}
+
+	if len(previous.Status) > 0 {
+		st := &spb.Status{}
+		if err := proto.Unmarshal(previous.Status, st); err != nil {
+			return nil, anzerrors.Wrap(err, codes.Internal, failed,
+				anzerrors.NewErrorInfo(ctx, anzcodes.Unknown, "unable to replay error"))
+		}
+		statusErr := status.ErrorProto(st)
+		if ferr, ok := anzerrors.FromStatusError(statusErr); ok {
+			return nil, ferr
+		}
+		return nil, statusErr
+	}
+
+	resp := &anypb.Any{}
+	if err := proto.Unmarshal(previous.Response, resp); err != nil {
+		return nil, anzerrors.Wrap(err, codes.Internal, failed,
+			anzerrors.NewErrorInfo(ctx, anzcodes.Unknown, "unable to replay response"))
+	}
+	msg, err := resp.UnmarshalNew()
+	if err != nil {
+		return nil, anzerrors.Wrap(err, codes.Internal, failed,
+			anzerrors.NewErrorInfo(ctx, anzcodes.Unknown, "unable to replay response"))
+	}
+	return msg, nil
+}
+
+// store keeps the outcome for the window. Errors the client is expected to retry are forgotten so the retry is made.
+func (c *Client) store(ctx context.Context, cacheKey, hash string, resp interface{}, handlerErr error) {
+	if handlerErr != nil && retryable(handlerErr) {
+		if err := c.Client.Del(ctx, cacheKey).Err(); err != nil {
+			logf.Error(ctx, err, "idempotency: unable to release key")
+		}
+		return
+	}
+
+	result := outcome{State: completed, Hash: hash}
+	var err error
+	if handlerErr != nil {
+		result.Status, err = proto.Marshal(status.Convert(handlerErr).Proto())
+	} else if msg, ok := resp.(proto.Message); ok {
+		var packed *anypb.Any
+		if packed, err = anypb.New(msg); err == nil {
+			result.Response, err = proto.Marshal(packed)
+		}
+	}
+	if err != nil {
+		logf.Error(ctx, err, "idempotency: unable to marshal outcome")
+		_ = c.Client.Del(ctx, cacheKey).Err()
+		return
+	}
+
+	payload, err := json.Marshal(result)
+	if err != nil {
+		logf.Error(ctx, err, "idempotency: unable to marshal outcome")
+		_ = c.Client.Del(ctx, cacheKey).Err()
+		return
+	}
+	if err := c.Client.Set(ctx, cacheKey, payload, c.Window).Err(); err != nil {
+		logf.Error(ctx, err, "idempotency: unable to store outcome")
+	}
+}
+
+// idempotencyKey returns the first idempotency key in the incoming metadata
+func idempotencyKey(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, key := range md.Get(KeyHeader) {
+		if key = strings.TrimSpace(key); key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+func requestHash(msg proto.Message) (string, error) {
+	payload, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// retryable returns true for errors that did not decide the outcome of the call
+func retryable(err error) bool {
+	switch anzerrors.GetStatusCode(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,306 @@
+package idempotency
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/anzx/fabric-cards/pkg/util/testutil"
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+	"github.com/anzx/pkg/jwtauth"
+)
+
+const (
+	replaceMethod = "/fabric.service.card.v1beta1.CardAPI/Replace"
+	listMethod    = "/fabric.service.card.v1beta1.CardAPI/List"
+)
+
+func newTestClient(t *testing.T) (*Client, *miniredis.Miniredis) {
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+
+	c, err := NewClient(context.Background(), &Config{
+		Prefix:  "test:",
+		Methods: []string{"/fabric.service.card.v1beta1.cardapi/replace"},
+		Window:  time.Hour,
+	}, redis.NewClient(&redis.Options{Addr: s.Addr()}), nil)
+	require.NoError(t, err)
+	return c, s
+}
+
+func withKey(ctx context.Context, key string) context.Context {
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(KeyHeader, key))
+}
+
+func withPersona(personaID string) context.Context {
+	return jwtauth.AddClaimsToContext(context.Background(), jwtauth.NewClaims(jwtauth.BaseClaims{
+		Claims:  jwt.Claims{Subject: personaID},
+		Persona: &jwtauth.Persona{PersonaID: personaID},
+	}))
+}
+
+// countingHandler returns the outcomes in order and counts its calls
+func countingHandler(calls *int32, outcomes ...func() (interface{}, error)) grpc.UnaryHandler {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		n := atomic.AddInt32(calls, 1)
+		return outcomes[int(n-1)%len(outcomes)]()
+	}
+}
+
+func respond(value string) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		return wrapperspb.String(value), nil
+	}
+}
+
+func fail(code codes.Code) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		return nil, anzerrors.New(code, "replacement failed",
+			anzerrors.NewErrorInfo(context.Background(), anzcodes.ValidationFailure, "card ineligible"))
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name      string
+		outcomes  []func() (interface{}, error)
+		first     context.Context
+		second    context.Context
+		method    string
+		secondReq proto.Message
+		wantCalls int32
+		wantCode  codes.Code
+	}{
+		{
+			name:      "duplicate is replayed",
+			outcomes:  []func() (interface{}, error){respond("first"), respond("second")},
+			first:     withKey(testutil.GetContext(false), "key"),
+			second:    withKey(testutil.GetContext(false), "key"),
+			method:    replaceMethod,
+			wantCalls: 1,
+		},
+		{
+			name:      "error is replayed",
+			outcomes:  []func() (interface{}, error){fail(codes.InvalidArgument), respond("second")},
+			first:     withKey(testutil.GetContext(false), "key"),
+			second:    withKey(testutil.GetContext(false), "key"),
+			method:    replaceMethod,
+			wantCalls: 1,
+			wantCode:  codes.InvalidArgument,
+		},
+		{
+			name:      "error the client should retry is not replayed",
+			outcomes:  []func() (interface{}, error){fail(codes.Unavailable), respond("second")},
+			first:     withKey(testutil.GetContext(false), "key"),
+			second:    withKey(testutil.GetContext(false), "key"),
+			method:    replaceMethod,
+			wantCalls: 2,
+		},
+		{
+			name:      "different keys are separate calls",
+			outcomes:  []func() (interface{}, error){respond("first"), respond("second")},
+			first:     withKey(testutil.GetContext(false), "key"),
+			second:    withKey(testutil.GetContext(false), "other"),
+			method:    replaceMethod,
+			wantCalls: 2,
+		},
+		{
+			name:      "keys are per persona",
+			outcomes:  []func() (interface{}, error){respond("first"), respond("second")},
+			first:     withKey(withPersona("one"), "key"),
+			second:    withKey(withPersona("two"), "key"),
+			method:    replaceMethod,
+			wantCalls: 2,
+		},
+		{
+			name:      "calls without a key are not replayed",
+			outcomes:  []func() (interface{}, error){respond("first"), respond("second")},
+			first:     testutil.GetContext(false),
+			second:    testutil.GetContext(false),
+			method:    replaceMethod,
+			wantCalls: 2,
+		},
+		{
+			name:      "methods not configured are not replayed",
+			outcomes:  []func() (interface{}, error){respond("first"), respond("second")},
+			first:     withKey(testutil.GetContext(false), "key"),
+			second:    withKey(testutil.GetContext(false), "key"),
+			method:    listMethod,
+			wantCalls: 2,
+		},
+		{
+			name:      "key reused for a different request is rejected",
+			outcomes:  []func() (interface{}, error){respond("first"), respond("second")},
+			first:     withKey(testutil.GetContext(false), "key"),
+			second:    withKey(testutil.GetContext(false), "key"),
+			method:    replaceMethod,
+			secondReq: wrapperspb.String("another card"),
+			wantCalls: 1,
+			wantCode:  codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			c, _ := newTestClient(t)
+			interceptor := UnaryServerInterceptor(c)
+			info := &grpc.UnaryServerInfo{FullMethod: test.method}
+			var calls int32
+			handler := countingHandler(&calls, test.outcomes...)
+
+			req := wrapperspb.String("card")
+			first, firstErr := interceptor(test.first, req, info, handler)
+
+			secondReq := test.secondReq
+			if secondReq == nil {
+				secondReq = req
+			}
+			second, secondErr := interceptor(test.second, secondReq, info, handler)
+
+			assert.Equal(t, test.wantCalls, calls)
+			if test.wantCode != codes.OK {
+				require.Error(t, secondErr)
+				assert.Equal(t, test.wantCode, anzerrors.GetStatusCode(secondErr))
+				return
+			}
+			require.NoError(t, secondErr)
+			if test.wantCalls == 1 {
+				require.NoError(t, firstErr)
+				assert.True(t, proto.Equal(first.(proto.Message), second.(proto.Message)), "the first response is replayed")
+			} else {
+				assert.Equal(t, "second", second.(*wrapperspb.StringValue).GetValue())
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor_InProgress(t *testing.T) {
+	c, _ := newTestClient(t)
+	interceptor := UnaryServerInterceptor(c)
+	info := &grpc.UnaryServerInfo{FullMethod: replaceMethod}
+	ctx := withKey(testutil.GetContext(false), "key")
+	req := wrapperspb.String("card")
+
+	started, finish := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, err := interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			close(started)
+			<-finish
+			return wrapperspb.String("first"), nil
+		})
+		done <- err
+	}()
+	<-started
+
+	_, err := interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		t.Error("a concurrent duplicate must not be handled")
+		return nil, nil
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.Aborted, anzerrors.GetStatusCode(err))
+
+	close(finish)
+	require.NoError(t, <-done)
+}
+
+func TestUnaryServerInterceptor_Lock(t *testing.T) {
+	c, s := newTestClient(t)
+	c.Lock = 40 * time.Millisecond
+	interceptor := UnaryServerInterceptor(c)
+	info := &grpc.UnaryServerInfo{FullMethod: replaceMethod}
+	ctx := withKey(testutil.GetContext(false), "key")
+	req := wrapperspb.String("card")
+
+	_, err := interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		// the call runs for longer than the lock, which is extended meanwhile
+		for i := 0; i < 3; i++ {
+			s.FastForward(30 * time.Millisecond)
+			time.Sleep(40 * time.Millisecond)
+		}
+
+		_, err := interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			t.Error("a duplicate of a long call must not be handled")
+			return nil, nil
+		})
+		require.Error(t, err)
+		assert.Equal(t, codes.Aborted, anzerrors.GetStatusCode(err))
+		return wrapperspb.String("first"), nil
+	})
+	require.NoError(t, err)
+}
+
+func TestUnaryServerInterceptor_Window(t *testing.T) {
+	c, s := newTestClient(t)
+	interceptor := UnaryServerInterceptor(c)
+	info := &grpc.UnaryServerInfo{FullMethod: replaceMethod}
+	ctx := withKey(testutil.GetContext(false), "key")
+	var calls int32
+	handler := countingHandler(&calls, respond("first"), respond("second"))
+
+	_, err := interceptor(ctx, wrapperspb.String("card"), info, handler)
+	require.NoError(t, err)
+
+	s.FastForward(time.Hour)
+
+	resp, err := interceptor(ctx, wrapperspb.String("card"), info, handler)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), calls)
+	assert.Equal(t, "second", resp.(*wrapperspb.StringValue).GetValue())
+}
+
+func TestUnaryServerInterceptor_Redis(t *testing.T) {
+	c, s := newTestClient(t)
+	interceptor := UnaryServerInterceptor(c)
+	info := &grpc.UnaryServerInfo{FullMethod: replaceMethod}
+	s.Close()
+
+	resp, err := interceptor(withKey(testutil.GetContext(false), "key"), wrapperspb.String("card"), info,
+		countingHandler(new(int32), respond("first")))
+	require.NoError(t, err, "calls are made while redis is unavailable")
+	assert.Equal(t, "first", resp.(*wrapperspb.StringValue).GetValue())
+}
+
+func TestUnaryServerInterceptor_KeyTooLong(t *testing.T) {
+	c, _ := newTestClient(t)
+	interceptor := UnaryServerInterceptor(c)
+	info := &grpc.UnaryServerInfo{FullMethod: replaceMethod}
+	var calls int32
+
+	_, err := interceptor(withKey(testutil.GetContext(false), strings.Repeat("k", maxKeyLength+1)), wrapperspb.String("card"), info,
+		countingHandler(&calls, respond("first")))
+	assert.Equal(t, int32(0), calls)
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, anzerrors.GetStatusCode(err))
+}
+
+func TestNewClient(t *testing.T) {
+	c, err := NewClient(context.Background(), nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+
+	c, err = NewClient(context.Background(), &Config{Methods: []string{replaceMethod}}, nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, c, "idempotency keys are ignored without redis")
+
+	var calls int32
+	_, err = UnaryServerInterceptor(nil)(withKey(testutil.GetContext(false), "key"), wrapperspb.String("card"),
+		&grpc.UnaryServerInfo{FullMethod: replaceMethod}, countingHandler(&calls, respond("first")))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), calls)
+}
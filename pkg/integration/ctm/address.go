@@ -64,6 +64,22 @@ func GetAddress(ctx context.Context, in *sspb.Address) (MailingAddress, error) {
 	return out, nil
 }
 
+// GetRequestedAddress applies the GetAddress rules to an address given with a request rather than taken from the
+// customer profile, which must also be complete
+func GetRequestedAddress(ctx context.Context, in *sspb.Address) (MailingAddress, error) {
+	if in.GetLineOne() == "" || in.GetCity() == "" || in.GetPostalCode() == "" {
+		return MailingAddress{}, anzerrors.New(codes.InvalidArgument, "Invalid Address",
+			anzerrors.NewErrorInfo(ctx, anzcodes.CardInvalidAddress, "requested address is incomplete"))
+	}
+
+	if isAustralianAddress(in) {
+		return MailingAddress{}, anzerrors.New(codes.InvalidArgument, "Invalid Address",
+			anzerrors.NewErrorInfo(ctx, anzcodes.CardInvalidAddress, "requested address is international"))
+	}
+
+	return GetAddress(ctx, in)
+}
+
 func isAustralianAddress(in *sspb.Address) bool {
 	return in.GetCountry() != aus
 }
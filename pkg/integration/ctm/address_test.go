@@ -111,3 +111,48 @@ func TestGetAddress(t *testing.T) {
 		})
 	}
 }
+
+func TestGetRequestedAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    *sspb.Address
+		want    MailingAddress
+		wantErr string
+	}{
+		{
+			name: "australian address",
+			args: &sspb.Address{LineOne: "15 station street", City: "Reservoir", State: "AU-VI", PostalCode: "3011", Country: "AUS"},
+			want: MailingAddress{
+				AddressLine1: "15 station street",
+				AddressLine2: "Reservoir VIC 3011",
+				PostCode:     "3011",
+			},
+		},
+		{
+			name:    "international address",
+			args:    &sspb.Address{City: "Boston", LineOne: "213 Derrick Street", PostalCode: "02130", Country: "USA"},
+			wantErr: "status_code=InvalidArgument, error_code=20003, message=Invalid Address, reason=requested address is international",
+		},
+		{
+			name:    "missing postcode",
+			args:    &sspb.Address{LineOne: "15 station street", City: "Reservoir", State: "AU-VI", Country: "AUS"},
+			wantErr: "status_code=InvalidArgument, error_code=20003, message=Invalid Address, reason=requested address is incomplete",
+		},
+		{
+			name:    "no address",
+			wantErr: "reason=requested address is incomplete",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetRequestedAddress(context.Background(), tt.args)
+			if tt.wantErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
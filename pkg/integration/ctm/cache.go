@@ -0,0 +1,199 @@
+package ctm
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/anzx/pkg/gsm"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+
+	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
+	"github.com/anzx/fabric-cards/pkg/ratelimit"
+)
+
+const (
+	defaultCacheTTL   = 30 * time.Second
+	maxCacheTTL       = time.Minute
+	inquiryCacheGroup = "ctm:inquiry:"
+)
+
+// uncachedKey marks a context whose inquiries must be read from CTM
+type uncachedKey struct{}
+
+// Uncached returns ctx with the inquiry cache bypassed, for reads which must be current, e.g. PIN failures. The
+// inquiry read is still cached for later requests.
+func Uncached(ctx context.Context) context.Context {
+	return context.WithValue(ctx, uncachedKey{}, true)
+}
+
+func isUncached(ctx context.Context) bool {
+	uncached, _ := ctx.Value(uncachedKey{}).(bool)
+	return uncached
+}
+
+// CacheConfig of the inquiry cache. A cached inquiry includes the cardholder's name, it is kept no longer than the TTL
+// and is deleted by any change to the card.
+type CacheConfig struct {
+	// TTL of a cached inquiry, defaults to 30s and is at most 1m
+	TTL time.Duration `json:"ttl,omitempty"    yaml:"ttl,omitempty"    mapstructure:"ttl"`
+	// Prefix to be added to every cache key
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty" mapstructure:"prefix" validate:"required"`
+	// Redis the inquiries are cached in. Every service that changes cards must use the same Redis and Prefix,
+	// otherwise its changes don't invalidate the cached inquiries.
+	Redis *ratelimit.RedisConfig `json:"redis,omitempty"  yaml:"redis,omitempty"  mapstructure:"redis" validate:"required"`
+}
+
+// NewCache returns the Redis inquiries are cached in, nil if the cache is not configured. The Redis must be shared
+// by every service which changes cards so it is never defaulted to the service's own.
+func NewCache(ctx context.Context, config *CacheConfig, gsmClient *gsm.Client) (redis.Cmdable, error) {
+	if config == nil {
+		return nil, nil
+	}
+	if config.Redis == nil || config.Prefix == "" {
+		return nil, errors.New("ctm cache requires a shared redis and prefix")
+	}
+	if config.TTL > maxCacheTTL {
+		return nil, errors.Errorf("ctm cache ttl %s exceeds %s", config.TTL, maxCacheTTL)
+	}
+
+	if err := config.Redis.GetSecrets(ctx, gsmClient); err != nil {
+		logf.Error(ctx, err, "ctm cache: failed to get redis secret")
+		return nil, errors.Wrap(err, "unable to access secret")
+	}
+	client, err := ratelimit.NewRedisClient(ctx, *config.Redis)
+	if err != nil {
+		// the client reconnects, CTM is called directly until Redis is available
+		logf.Error(ctx, err, "ctm cache: redis unavailable")
+	}
+	return client, nil
+}
+
+// cachingClient caches DebitCardInquiry responses in Redis, any change to a card through the client invalidates it.
+// The cache is best effort and CTM is called directly whenever Redis is unavailable.
+type cachingClient struct {
+	Client
+	redis  redis.Cmdable
+	ttl    time.Duration
+	prefix string
+}
+
+// NewCachingClient returns next with a read-through cache for DebitCardInquiry, next is returned unchanged if
+// either the cache or its config is not provided
+func NewCachingClient(ctx context.Context, next Client, cache redis.Cmdable, config *CacheConfig) Client {
+	if next == nil || cache == nil || config == nil {
+		logf.Debug(ctx, "ctm cache not configured %v", config)
+		return next
+	}
+
+	ttl := config.TTL
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+
+	return &cachingClient{
+		Client: next,
+		redis:  cache,
+		ttl:    ttl,
+		prefix: config.Prefix,
+	}
+}
+
+func (c *cachingClient) DebitCardInquiry(ctx context.Context, tokenizedCardNumber string) (*DebitCardResponse, error) {
+	key := c.key(tokenizedCardNumber)
+
+	if !isUncached(ctx) {
+		if card, ok := c.cached(ctx, key); ok {
+			return card, nil
+		}
+	}
+
+	card, err := c.Client.DebitCardInquiry(ctx, tokenizedCardNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := json.Marshal(card)
+	if err != nil {
+		logf.Error(ctx, err, "ctm cache: unable to marshal inquiry")
+		return card, nil
+	}
+	if err := c.redis.Set(ctx, key, out, c.ttl).Err(); err != nil {
+		logf.Error(ctx, err, "ctm cache: unable to cache inquiry")
+	}
+
+	return card, nil
+}
+
+// cached returns the inquiry cached at key, if any
+func (c *cachingClient) cached(ctx context.Context, key string) (*DebitCardResponse, bool) {
+	cached, err := c.redis.Get(ctx, key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			logf.Error(ctx, err, "ctm cache: unable to read cached inquiry")
+		}
+		return nil, false
+	}
+
+	var card DebitCardResponse
+	if err := json.Unmarshal(cached, &card); err != nil {
+		logf.Error(ctx, err, "ctm cache: unable to unmarshal cached inquiry")
+		return nil, false
+	}
+	return &card, true
+}
+
+func (c *cachingClient) ReplaceCard(ctx context.Context, req *ReplaceCardRequest, tokenizedCardNumber string) (string, error) {
+	newToken, err := c.Client.ReplaceCard(ctx, req, tokenizedCardNumber)
+	c.invalidate(ctx, tokenizedCardNumber, newToken)
+	return newToken, err
+}
+
+func (c *cachingClient) UpdatePreferences(ctx context.Context, req *UpdatePreferencesRequest, tokenizedCardNumber string) (bool, error) {
+	defer c.invalidate(ctx, tokenizedCardNumber)
+	return c.Client.UpdatePreferences(ctx, req, tokenizedCardNumber)
+}
+
+func (c *cachingClient) UpdateDetails(ctx context.Context, req *UpdateDetailsRequest, tokenizedCardNumber string) (bool, error) {
+	defer c.invalidate(ctx, tokenizedCardNumber)
+	return c.Client.UpdateDetails(ctx, req, tokenizedCardNumber)
+}
+
+func (c *cachingClient) UpdateLimits(ctx context.Context, req *UpdateLimitsRequest, tokenizedCardNumber string) (bool, error) {
+	defer c.invalidate(ctx, tokenizedCardNumber)
+	return c.Client.UpdateLimits(ctx, req, tokenizedCardNumber)
+}
+
+func (c *cachingClient) Activate(ctx context.Context, tokenizedCardNumber string) (bool, error) {
+	defer c.invalidate(ctx, tokenizedCardNumber)
+	return c.Client.Activate(ctx, tokenizedCardNumber)
+}
+
+func (c *cachingClient) UpdateStatus(ctx context.Context, tokenizedCardNumber string, status Status) (bool, error) {
+	defer c.invalidate(ctx, tokenizedCardNumber)
+	return c.Client.UpdateStatus(ctx, tokenizedCardNumber, status)
+}
+
+func (c *cachingClient) UpdatePINInfo(ctx context.Context, tokenizedCardNumber string) (bool, error) {
+	defer c.invalidate(ctx, tokenizedCardNumber)
+	return c.Client.UpdatePINInfo(ctx, tokenizedCardNumber)
+}
+
+// invalidate removes the cached inquiries of every token, even if the change failed as it may have been applied
+func (c *cachingClient) invalidate(ctx context.Context, tokenizedCardNumbers ...string) {
+	keys := make([]string, 0, len(tokenizedCardNumbers))
+	for _, token := range tokenizedCardNumbers {
+		if token != "" {
+			keys = append(keys, c.key(token))
+		}
+	}
+
+	if err := c.redis.Del(ctx, keys...).Err(); err != nil {
+		logf.Error(ctx, err, "ctm cache: unable to invalidate cached inquiry")
+	}
+}
+
+func (c *cachingClient) key(tokenizedCardNumber string) string {
+	return c.prefix + inquiryCacheGroup + tokenizedCardNumber
+}
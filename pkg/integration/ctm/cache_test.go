@@ -0,0 +1,187 @@
+package ctm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anzx/fabric-cards/pkg/ratelimit"
+)
+
+type countingClient struct {
+	Client
+	inquiries int
+	status    Status
+	err       error
+}
+
+func (c *countingClient) DebitCardInquiry(_ context.Context, tokenizedCardNumber string) (*DebitCardResponse, error) {
+	c.inquiries++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &DebitCardResponse{CardNumber: Card{Token: tokenizedCardNumber}, Status: c.status}, nil
+}
+
+func (c *countingClient) UpdateStatus(_ context.Context, _ string, status Status) (bool, error) {
+	c.status = status
+	return true, nil
+}
+
+func (c *countingClient) ReplaceCard(_ context.Context, _ *ReplaceCardRequest, _ string) (string, error) {
+	return "new" + tokenizedCardNumber, nil
+}
+
+func newTestCache(t *testing.T, next Client) (Client, *miniredis.Miniredis) {
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+
+	return NewCachingClient(context.Background(), next, redis.NewClient(&redis.Options{Addr: s.Addr()}),
+		&CacheConfig{TTL: time.Minute, Prefix: "st"}), s
+}
+
+func TestCachingClient_DebitCardInquiry(t *testing.T) {
+	ctx := context.Background()
+	next := &countingClient{status: StatusIssued}
+	c, s := newTestCache(t, next)
+
+	got, err := c.DebitCardInquiry(ctx, tokenizedCardNumber)
+	require.NoError(t, err)
+	assert.Equal(t, StatusIssued, got.Status)
+
+	got, err = c.DebitCardInquiry(ctx, tokenizedCardNumber)
+	require.NoError(t, err)
+	assert.Equal(t, StatusIssued, got.Status)
+	assert.Equal(t, 1, next.inquiries)
+	assert.Equal(t, time.Minute, s.TTL("stctm:inquiry:"+tokenizedCardNumber))
+
+	// a change to the card invalidates it
+	_, err = c.UpdateStatus(ctx, tokenizedCardNumber, StatusTemporaryBlock)
+	require.NoError(t, err)
+	got, err = c.DebitCardInquiry(ctx, tokenizedCardNumber)
+	require.NoError(t, err)
+	assert.Equal(t, StatusTemporaryBlock, got.Status)
+	assert.Equal(t, 2, next.inquiries)
+
+	// once it expires CTM is called again
+	s.FastForward(time.Minute)
+	_, err = c.DebitCardInquiry(ctx, tokenizedCardNumber)
+	require.NoError(t, err)
+	assert.Equal(t, 3, next.inquiries)
+}
+
+func TestCachingClient_Uncached(t *testing.T) {
+	ctx := context.Background()
+	next := &countingClient{status: StatusIssued}
+	c, _ := newTestCache(t, next)
+
+	_, err := c.DebitCardInquiry(ctx, tokenizedCardNumber)
+	require.NoError(t, err)
+
+	// an uncached read calls CTM and refreshes the cache
+	next.status = StatusTemporaryBlock
+	got, err := c.DebitCardInquiry(Uncached(ctx), tokenizedCardNumber)
+	require.NoError(t, err)
+	assert.Equal(t, StatusTemporaryBlock, got.Status)
+	assert.Equal(t, 2, next.inquiries)
+
+	got, err = c.DebitCardInquiry(ctx, tokenizedCardNumber)
+	require.NoError(t, err)
+	assert.Equal(t, StatusTemporaryBlock, got.Status)
+	assert.Equal(t, 2, next.inquiries)
+}
+
+func TestCachingClient_ReplaceCard(t *testing.T) {
+	ctx := context.Background()
+	next := &countingClient{}
+	c, s := newTestCache(t, next)
+
+	_, err := c.DebitCardInquiry(ctx, tokenizedCardNumber)
+	require.NoError(t, err)
+	_, err = c.DebitCardInquiry(ctx, "new"+tokenizedCardNumber)
+	require.NoError(t, err)
+
+	newToken, err := c.ReplaceCard(ctx, &ReplaceCardRequest{}, tokenizedCardNumber)
+	require.NoError(t, err)
+	assert.Equal(t, "new"+tokenizedCardNumber, newToken)
+	assert.False(t, s.Exists("stctm:inquiry:"+tokenizedCardNumber))
+	assert.False(t, s.Exists("stctm:inquiry:new"+tokenizedCardNumber))
+}
+
+func TestCachingClient_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		next := &countingClient{err: errors.New("ctm unavailable")}
+		c, _ := newTestCache(t, next)
+
+		_, err := c.DebitCardInquiry(ctx, tokenizedCardNumber)
+		require.Error(t, err)
+		_, err = c.DebitCardInquiry(ctx, tokenizedCardNumber)
+		require.Error(t, err)
+		assert.Equal(t, 2, next.inquiries)
+	})
+
+	t.Run("redis unavailable calls ctm", func(t *testing.T) {
+		next := &countingClient{status: StatusIssued}
+		c, s := newTestCache(t, next)
+		s.SetError("unavailable")
+
+		got, err := c.DebitCardInquiry(ctx, tokenizedCardNumber)
+		require.NoError(t, err)
+		assert.Equal(t, StatusIssued, got.Status)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		next := &countingClient{}
+		assert.Equal(t, next, NewCachingClient(ctx, next, nil, &CacheConfig{}))
+		assert.Equal(t, next, NewCachingClient(ctx, next, redis.NewClient(&redis.Options{}), nil))
+	})
+}
+
+func TestNewCache(t *testing.T) {
+	ctx := context.Background()
+
+	cache, err := NewCache(ctx, nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, cache)
+
+	// the cache is shared by every service which changes cards so it is never defaulted
+	_, err = NewCache(ctx, &CacheConfig{Prefix: "st"}, nil)
+	assert.EqualError(t, err, "ctm cache requires a shared redis and prefix")
+	_, err = NewCache(ctx, &CacheConfig{Redis: &ratelimit.RedisConfig{}}, nil)
+	assert.EqualError(t, err, "ctm cache requires a shared redis and prefix")
+	_, err = NewCache(ctx, &CacheConfig{Prefix: "st", Redis: &ratelimit.RedisConfig{}, TTL: time.Hour}, nil)
+	assert.EqualError(t, err, "ctm cache ttl 1h0m0s exceeds 1m0s")
+}
+
+func TestCachingClient_Shared(t *testing.T) {
+	ctx := context.Background()
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+	cache := redis.NewClient(&redis.Options{Addr: s.Addr()})
+
+	// a status change in another service invalidates the inquiry cached by this one
+	next := &countingClient{status: StatusIssued}
+	config := &CacheConfig{TTL: time.Minute, Prefix: "st"}
+	cards := NewCachingClient(ctx, next, cache, config)
+	controls := NewCachingClient(ctx, next, cache, config)
+
+	_, err = cards.DebitCardInquiry(ctx, tokenizedCardNumber)
+	require.NoError(t, err)
+	_, err = controls.UpdateStatus(ctx, tokenizedCardNumber, StatusTemporaryBlock)
+	require.NoError(t, err)
+
+	got, err := cards.DebitCardInquiry(ctx, tokenizedCardNumber)
+	require.NoError(t, err)
+	assert.Equal(t, StatusTemporaryBlock, got.Status)
+	assert.Equal(t, 2, next.inquiries)
+}
@@ -39,6 +39,8 @@ const (
 	StatusBlockAtmPosCnp        Status = "Block ATM, POS & CNP"
 	StatusBlockCnp              Status = "Block CNP"
 	StatusBlockPosExcludeCnp    Status = "Block POS (exclude CNP)"
+	// StatusUnavailable is not returned by CTM, it is listed for a card that could not be inquired
+	StatusUnavailable Status = "Unavailable"
 )
 
 func (s Status) String() string {
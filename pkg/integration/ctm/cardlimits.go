@@ -0,0 +1,71 @@
+package ctm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/anzx/fabric-cards/pkg/util/apic"
+)
+
+const cardLimitsUpdate = "CardLimitsUpdate"
+
+type UpdateLimitsRequest struct {
+	Limits []LimitUpdate `json:"limits"`
+}
+
+type LimitUpdate struct {
+	Type       LimitType `json:"type"`
+	DailyLimit int64     `json:"dailyLimit"`
+}
+
+// LimitsConfig holds the daily limits a customer may choose for a card, limit types without bounds can't be changed
+type LimitsConfig struct {
+	Bounds []LimitBounds `json:"bounds,omitempty" yaml:"bounds,omitempty" mapstructure:"bounds" validate:"dive"`
+}
+
+type LimitBounds struct {
+	Type LimitType `json:"type" yaml:"type" mapstructure:"type" validate:"required,oneof=ATMEFTPOS APO"`
+	Min  int64     `json:"min"  yaml:"min"  mapstructure:"min"  validate:"gte=0"`
+	Max  int64     `json:"max"  yaml:"max"  mapstructure:"max"  validate:"gtefield=Min"`
+}
+
+// Bounds returns the bounds configured for limitType
+func (c *LimitsConfig) Bounds(limitType LimitType) (LimitBounds, bool) {
+	if c == nil {
+		return LimitBounds{}, false
+	}
+	for _, bounds := range c.Bounds {
+		if bounds.Type == limitType {
+			return bounds, true
+		}
+	}
+	return LimitBounds{}, false
+}
+
+// Allows returns true if dailyLimit is within the bounds
+func (b LimitBounds) Allows(dailyLimit int64) bool {
+	return dailyLimit >= b.Min && dailyLimit <= b.Max
+}
+
+// Limit returns the limit of limitType set on the card
+func (r DebitCardResponse) Limit(limitType LimitType) (NewLimits, bool) {
+	for _, limit := range r.Limits {
+		if limit.Type == limitType {
+			return limit, true
+		}
+	}
+	return NewLimits{}, false
+}
+
+// Updates the daily limits of a debit card in CTM, limits not included in the request are left unchanged.
+func (c client) UpdateLimits(ctx context.Context, req *UpdateLimitsRequest, tokenizedCardNumber string) (bool, error) {
+	limitsURL := fmt.Sprintf(limitsAPIUrlTemplate, c.baseURL, tokenizedCardNumber)
+
+	body, _ := json.Marshal(req)
+
+	_, err := c.apicClient.Do(ctx, apic.NewRequest(http.MethodPatch, limitsURL, body), fmt.Sprintf("ctm:%s", cardLimitsUpdate))
+
+	return err == nil, err
+}
@@ -0,0 +1,62 @@
+package ctm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anzx/fabric-cards/pkg/util/apic"
+	"github.com/anzx/fabric-cards/pkg/util/testutil"
+	"github.com/anzx/fabric-cards/test/util"
+)
+
+func TestClient_UpdateLimits(t *testing.T) {
+	req := &UpdateLimitsRequest{Limits: []LimitUpdate{{Type: LimitTypeATMEFTPOS, DailyLimit: 1500}}}
+
+	t.Run("true returned on downstream 200", func(t *testing.T) {
+		c := &client{
+			baseURL: "http://ctm",
+			apicClient: util.MockAPIcer{DoCall: func(_ context.Context, request *apic.Request, operation string) ([]byte, error) {
+				assert.Equal(t, http.MethodPatch, request.Method)
+				assert.Equal(t, "http://ctm/debit-card-maintenance/debit-cards/"+tokenizedCardNumber+"/limits", request.Destination)
+				assert.JSONEq(t, `{"limits":[{"type":"ATMEFTPOS","dailyLimit":1500}]}`, string(request.Body))
+				assert.Equal(t, "ctm:CardLimitsUpdate", operation)
+				return nil, nil
+			}},
+		}
+
+		got, err := c.UpdateLimits(testutil.GetContext(true), req, tokenizedCardNumber)
+		require.NoError(t, err)
+		assert.True(t, got)
+	})
+
+	t.Run("false returned on downstream 500", func(t *testing.T) {
+		c := &client{apicClient: util.MockAPIcer{ResponseErr: errors.New("failed request")}}
+
+		got, err := c.UpdateLimits(testutil.GetContext(true), req, tokenizedCardNumber)
+		require.Error(t, err)
+		assert.False(t, got)
+	})
+}
+
+func TestLimitsConfig_Bounds(t *testing.T) {
+	config := &LimitsConfig{Bounds: []LimitBounds{{Type: LimitTypeATMEFTPOS, Min: 200, Max: 3000}}}
+
+	bounds, ok := config.Bounds(LimitTypeATMEFTPOS)
+	require.True(t, ok)
+	assert.True(t, bounds.Allows(200))
+	assert.True(t, bounds.Allows(3000))
+	assert.False(t, bounds.Allows(199))
+	assert.False(t, bounds.Allows(3001))
+
+	_, ok = config.Bounds(LimitTypeAPO)
+	assert.False(t, ok)
+
+	var none *LimitsConfig
+	_, ok = none.Bounds(LimitTypeATMEFTPOS)
+	assert.False(t, ok)
+}
@@ -23,6 +23,7 @@ const (
 	replaceAPIUrlTemplate       = "%s/debit-card-maintenance/debit-cards/%s/replace"
 	preferenceAPIUrlTemplate    = "%s/debit-card-maintenance/debit-cards/%s/preferences"
 	updateDetailsAPIUrlTemplate = "%s/debit-card-maintenance/debit-cards/%s/details"
+	limitsAPIUrlTemplate        = "%s/debit-card-maintenance/debit-cards/%s/limits"
 	activationAPIUrlTemplate    = "%s/debit-card-status/debit-cards/%s/activate"
 	statusAPIUrlTemplate        = "%s/debit-card-status/debit-cards/%s/status"
 	pinInfoUpdateUrlTemplate    = "%s/debit-card-pin-info-update/debit-cards/%s/pin-info/update"
@@ -32,12 +33,19 @@ type Config struct {
 	BaseURL        string `json:"baseURL"             yaml:"baseURL"             mapstructure:"baseURL"         validate:"required"`
 	ClientIDEnvKey string `json:"clientIDEnvKey"      yaml:"clientIDEnvKey"      mapstructure:"clientIDEnvKey"  validate:"required"`
 	MaxRetries     int    `json:"maxRetries"          yaml:"maxRetries"          mapstructure:"maxRetries"      validate:"required"`
+	// Cache enables caching of DebitCardInquiry in the service's Redis, caching is disabled if not set
+	Cache *CacheConfig `json:"cache,omitempty"     yaml:"cache,omitempty"     mapstructure:"cache"`
+	// Resilience protects each operation with a circuit breaker and bulkhead, disabled if not set
+	Resilience *apic.ResilienceConfig `json:"resilience,omitempty" yaml:"resilience,omitempty" mapstructure:"resilience"`
+	// Timeouts bounds each operation including its retries, only the incoming deadline applies if not set
+	Timeouts *apic.TimeoutConfig `json:"timeouts,omitempty" yaml:"timeouts,omitempty" mapstructure:"timeouts"`
 }
 
 type CardMaintenanceAPI interface {
 	ReplaceCard(context.Context, *ReplaceCardRequest, string) (string, error)
 	UpdatePreferences(ctx context.Context, req *UpdatePreferencesRequest, tokenizedCardNumber string) (bool, error)
 	UpdateDetails(ctx context.Context, req *UpdateDetailsRequest, tokenizedCardNumber string) (bool, error)
+	UpdateLimits(ctx context.Context, req *UpdateLimitsRequest, tokenizedCardNumber string) (bool, error)
 }
 
 type CardInquiryAPI interface {
@@ -77,10 +85,11 @@ func ClientFromConfig(ctx context.Context, httpClient *http.Client, config *Conf
 		return nil, nil
 	}
 
-	return NewClient(ctx, config.BaseURL, config.ClientIDEnvKey, httpClient, config.MaxRetries, gsmClient)
+	return NewClient(ctx, config.BaseURL, config.ClientIDEnvKey, httpClient, config.MaxRetries, gsmClient,
+		apic.WithResilience(config.Resilience), apic.WithTimeouts(config.Timeouts))
 }
 
-func NewClient(ctx context.Context, baseURL string, clientIDEnvKey string, httpClient *http.Client, maxRetries int, gsmClient *gsm.Client) (Client, error) {
+func NewClient(ctx context.Context, baseURL string, clientIDEnvKey string, httpClient *http.Client, maxRetries int, gsmClient *gsm.Client, opts ...apic.Option) (Client, error) {
 	if httpClient == nil {
 		httpClient = rest.NewHTTPClientWithLogAndRetry(maxRetries, nil, names.CTM)
 	}
@@ -92,7 +101,7 @@ func NewClient(ctx context.Context, baseURL string, clientIDEnvKey string, httpC
 			anzerrors.NewErrorInfo(ctx, anzcodes.StartupFailure, "unable to parse configured url"))
 	}
 
-	apicClient, err := apic.NewAPICClient(ctx, clientIDEnvKey, httpClient, gsmClient)
+	apicClient, err := apic.NewAPICClient(ctx, clientIDEnvKey, httpClient, gsmClient, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -38,6 +38,7 @@ func (r DebitCardResponse) Eligibility() []epb.Eligibility {
 			epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_STOLEN,
 			epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_DAMAGED,
 			epb.Eligibility_ELIGIBILITY_UNBLOCK,
+			epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 		}
 	case StatusLost:
 		eligibilitySet = []epb.Eligibility{
@@ -59,6 +60,7 @@ func (r DebitCardResponse) Eligibility() []epb.Eligibility {
 			epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_DAMAGED,
 			epb.Eligibility_ELIGIBILITY_CARD_CONTROLS,
 			epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+			epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 		}
 
 		if r.PinChangedCount > 0 {
@@ -70,7 +72,8 @@ func (r DebitCardResponse) Eligibility() []epb.Eligibility {
 		if r.eligibleForActivation() {
 			eligibilitySet = append(eligibilitySet, epb.Eligibility_ELIGIBILITY_CARD_ACTIVATION)
 		} else {
-			eligibilitySet = append(eligibilitySet, epb.Eligibility_ELIGIBILITY_GET_DETAILS, epb.Eligibility_ELIGIBILITY_BLOCK)
+			eligibilitySet = append(eligibilitySet, epb.Eligibility_ELIGIBILITY_GET_DETAILS, epb.Eligibility_ELIGIBILITY_BLOCK,
+				epb.Eligibility_ELIGIBILITY_DAILY_LIMITS)
 		}
 
 		if !r.IssuedOrReplacedToday() {
@@ -90,6 +93,12 @@ func (r DebitCardResponse) Eligibility() []epb.Eligibility {
 	return eligibilitySet
 }
 
+// CanUpdateStatus returns true if the customer can move the card to next, only cards they can see can be changed and
+// only along the transitions allowed by ValidNextState
+func (r DebitCardResponse) CanUpdateStatus(next Status) bool {
+	return r.Visible() && r.Status.ValidNextState(next)
+}
+
 func (r DebitCardResponse) eligibleForActivation() bool {
 	return r.Visible() && (r.Status != StatusTemporaryBlock) && !r.ActivationStatus
 }
@@ -143,6 +152,8 @@ func (s Status) Reason() *StatusReason {
 		return StatusReasonWithPinOrAccountRelated.Pointer()
 	case StatusStolen:
 		return StatusReasonWithPinOrAccountRelated.Pointer()
+	case StatusClosed:
+		return StatusReasonClosed.Pointer()
 	}
 	return nil
 }
@@ -153,6 +164,10 @@ func (s Status) ValidReason(reason *StatusReason) bool {
 		if reason == nil {
 			return true
 		}
+	case StatusClosed:
+		if reason != nil && *reason == StatusReasonClosed {
+			return true
+		}
 	case StatusLost, StatusStolen:
 		if reason != nil && *reason == StatusReasonWithPinOrAccountRelated || *reason == StatusReasonWithoutPin {
 			return true
@@ -174,11 +189,13 @@ func (s Status) ValidReason(reason *StatusReason) bool {
 func (s Status) ValidNextState(next Status) bool {
 	switch s {
 	case StatusIssued:
-		if next == StatusDelinquentReturn || next == StatusLost || next == StatusStolen || next == StatusTemporaryBlock {
+		if next == StatusDelinquentReturn || next == StatusLost || next == StatusStolen || next == StatusTemporaryBlock ||
+			next == StatusClosed {
 			return true
 		}
 	case StatusTemporaryBlock:
-		if next == StatusDelinquentReturn || next == StatusLost || next == StatusStolen || next == StatusIssued {
+		if next == StatusDelinquentReturn || next == StatusLost || next == StatusStolen || next == StatusIssued ||
+			next == StatusClosed {
 			return true
 		}
 	case StatusLost, StatusStolen, StatusDelinquentRetain:
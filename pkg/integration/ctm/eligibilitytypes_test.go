@@ -33,6 +33,7 @@ func Test_Eligibility_LifeCycle(t *testing.T) {
 			epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_DAMAGED,
 			epb.Eligibility_ELIGIBILITY_CARD_CONTROLS,
 			epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+			epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 		}
 		assert.Equal(t, want, brandNewCard.Eligibility())
 	})
@@ -55,6 +56,8 @@ func Test_Eligibility_LifeCycle(t *testing.T) {
 			epb.Eligibility_ELIGIBILITY_BLOCK,
 			epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 			epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+			epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+			epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 		}
 		assert.Equal(t, want, activatedCard.Eligibility())
 	})
@@ -77,6 +80,8 @@ func Test_Eligibility_LifeCycle(t *testing.T) {
 			epb.Eligibility_ELIGIBILITY_BLOCK,
 			epb.Eligibility_ELIGIBILITY_GET_DETAILS,
 			epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+			epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+			epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 		}
 		assert.Equal(t, want, setPinCard.Eligibility())
 	})
@@ -92,6 +97,7 @@ func Test_Eligibility_LifeCycle(t *testing.T) {
 			epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_STOLEN,
 			epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_DAMAGED,
 			epb.Eligibility_ELIGIBILITY_UNBLOCK,
+			epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 		}
 		assert.Equal(t, want, lostCard.Eligibility())
 	})
@@ -175,6 +181,7 @@ func Test_Eligibility_LifeCycle(t *testing.T) {
 			epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_DAMAGED,
 			epb.Eligibility_ELIGIBILITY_CARD_CONTROLS,
 			epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+			epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 		}
 		assert.Equal(t, want, replacedCard.Eligibility())
 	})
@@ -195,6 +202,7 @@ func Test_Eligibility_LifeCycle(t *testing.T) {
 			epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_DAMAGED,
 			epb.Eligibility_ELIGIBILITY_CARD_CONTROLS,
 			epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+			epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 		}
 		assert.Equal(t, want, replacedCard.Eligibility())
 	})
@@ -226,6 +234,8 @@ func getFullEligibility(activationStatus bool, pinChangeCount int64) []epb.Eligi
 		epb.Eligibility_ELIGIBILITY_CARD_CONTROLS,
 		epb.Eligibility_ELIGIBILITY_BLOCK,
 		epb.Eligibility_ELIGIBILITY_CARD_ON_FILE,
+		epb.Eligibility_ELIGIBILITY_DAILY_LIMITS,
+		epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 	}
 	if !activationStatus {
 		eligibilities = append(eligibilities, epb.Eligibility_ELIGIBILITY_CARD_ACTIVATION)
@@ -264,6 +274,7 @@ func TestStatus_ValidReason(t *testing.T) {
 	}
 	status_StatusReason := map[Status]map[StatusReason]struct{}{
 		StatusTemporaryBlock: nil,
+		StatusClosed:         {StatusReasonClosed: {}},
 		StatusLost:           {StatusReasonWithPinOrAccountRelated: {}, StatusReasonWithoutPin: {}},
 		StatusStolen:         {StatusReasonWithPinOrAccountRelated: {}, StatusReasonWithoutPin: {}},
 		StatusDelinquentReturn: {
@@ -305,8 +316,8 @@ func TestStatus_ValidNextState(t *testing.T) {
 		StatusBlockPosExcludeCnp:    "Block POS (exclude CNP)",
 	}
 	want := map[Status]map[Status]struct{}{
-		StatusIssued:           {StatusDelinquentReturn: {}, StatusLost: {}, StatusStolen: {}, StatusTemporaryBlock: {}},
-		StatusTemporaryBlock:   {StatusDelinquentReturn: {}, StatusLost: {}, StatusStolen: {}, StatusIssued: {}},
+		StatusIssued:           {StatusDelinquentReturn: {}, StatusLost: {}, StatusStolen: {}, StatusTemporaryBlock: {}, StatusClosed: {}},
+		StatusTemporaryBlock:   {StatusDelinquentReturn: {}, StatusLost: {}, StatusStolen: {}, StatusIssued: {}, StatusClosed: {}},
 		StatusLost:             nil,
 		StatusStolen:           nil,
 		StatusDelinquentRetain: nil,
@@ -431,6 +442,7 @@ func TestDebitCardResponse_Eligibility(t *testing.T) {
 				epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_STOLEN,
 				epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_DAMAGED,
 				epb.Eligibility_ELIGIBILITY_UNBLOCK,
+				epb.Eligibility_ELIGIBILITY_UPDATE_STATUS,
 			},
 		},
 	}
@@ -615,6 +627,52 @@ func TestEligibilityAppleWallet(t *testing.T) {
 	}
 }
 
+func TestDebitCardResponse_CanUpdateStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		card DebitCardResponse
+		next Status
+		want bool
+	}{
+		{
+			name: "report an issued card lost",
+			card: DebitCardResponse{Status: StatusIssued},
+			next: StatusLost,
+			want: true,
+		},
+		{
+			name: "close a temporarily blocked card",
+			card: DebitCardResponse{Status: StatusTemporaryBlock},
+			next: StatusClosed,
+			want: true,
+		},
+		{
+			name: "card suspected of fraud",
+			card: DebitCardResponse{Status: StatusBlockAtm},
+			next: StatusStolen,
+			want: false,
+		},
+		{
+			name: "lost card cannot be closed",
+			card: DebitCardResponse{Status: StatusLost},
+			next: StatusClosed,
+			want: false,
+		},
+		{
+			name: "closed card",
+			card: DebitCardResponse{Status: StatusClosed},
+			next: StatusLost,
+			want: false,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, test.card.CanUpdateStatus(test.next))
+		})
+	}
+}
+
 func TestDebitCardResponse_Visible(t *testing.T) {
 	tests := []struct {
 		name string
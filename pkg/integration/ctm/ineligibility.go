@@ -0,0 +1,145 @@
+package ctm
+
+import (
+	"sort"
+	"strconv"
+
+	epb "github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1"
+)
+
+// IneligibleReason is a machine readable code for why a card does not have an eligibility, clients can map it to
+// guidance for the customer
+type IneligibleReason string
+
+const (
+	IneligibleCardClosed           IneligibleReason = "CARD_CLOSED"
+	IneligibleCardLost             IneligibleReason = "CARD_LOST"
+	IneligibleCardStolen           IneligibleReason = "CARD_STOLEN"
+	IneligibleCardDamaged          IneligibleReason = "CARD_DAMAGED"
+	IneligibleCardRetained         IneligibleReason = "CARD_RETAINED"
+	IneligibleCardBlocked          IneligibleReason = "CARD_BLOCKED"
+	IneligibleCardFraudBlocked     IneligibleReason = "CARD_FRAUD_BLOCKED"
+	IneligibleCardNotIssued        IneligibleReason = "CARD_NOT_ISSUED"
+	IneligibleCardStatusUnknown    IneligibleReason = "CARD_STATUS_UNKNOWN"
+	IneligibleCardNotActivated     IneligibleReason = "CARD_NOT_ACTIVATED"
+	IneligibleCardAlreadyActivated IneligibleReason = "CARD_ALREADY_ACTIVATED"
+	IneligiblePINNotSet            IneligibleReason = "PIN_NOT_SET"
+	IneligiblePINAlreadySet        IneligibleReason = "PIN_ALREADY_SET"
+	// IneligibleIssuedToday means the card was issued or replaced today and can be replaced again from tomorrow
+	IneligibleIssuedToday IneligibleReason = "CARD_ISSUED_TODAY"
+	// IneligibleNotApplicable means the eligibility does not apply to a card in its status, e.g. unblocking a card
+	// that is not blocked
+	IneligibleNotApplicable IneligibleReason = "NOT_APPLICABLE"
+)
+
+// Ineligibility explains why a card does not have an eligibility with the CTM facts the decision was based on, the
+// facts are keyed by their CTM field name
+type Ineligibility struct {
+	Eligibility epb.Eligibility
+	Reason      IneligibleReason
+	Facts       map[string]string
+}
+
+// Ineligibility returns why the card does not have eligibility, false is returned if it does
+func (r DebitCardResponse) Ineligibility(eligibility epb.Eligibility) (Ineligibility, bool) {
+	if r.HasEligibility(eligibility) {
+		return Ineligibility{}, false
+	}
+	return r.ineligibility(eligibility), true
+}
+
+// Ineligibilities explains every eligibility the card does not have, in the order of the enum
+func (r DebitCardResponse) Ineligibilities() []Ineligibility {
+	granted := map[epb.Eligibility]bool{}
+	for _, eligibility := range r.Eligibility() {
+		granted[eligibility] = true
+	}
+
+	eligibilities := make([]epb.Eligibility, 0, len(epb.Eligibility_name))
+	for value := range epb.Eligibility_name {
+		eligibility := epb.Eligibility(value)
+		if eligibility != epb.Eligibility_ELIGIBILITY_INVALID_UNSPECIFIED && !granted[eligibility] {
+			eligibilities = append(eligibilities, eligibility)
+		}
+	}
+	sort.Slice(eligibilities, func(i, j int) bool {
+		return eligibilities[i] < eligibilities[j]
+	})
+
+	out := make([]Ineligibility, 0, len(eligibilities))
+	for _, eligibility := range eligibilities {
+		out = append(out, r.ineligibility(eligibility))
+	}
+	return out
+}
+
+func (r DebitCardResponse) ineligibility(eligibility epb.Eligibility) Ineligibility {
+	out := Ineligibility{
+		Eligibility: eligibility,
+		Facts: map[string]string{
+			"status": string(r.Status),
+		},
+	}
+
+	if r.Status != StatusIssued {
+		out.Reason = r.Status.ineligibleReason()
+		if r.StatusReason != "" {
+			out.Facts["statusReason"] = string(r.StatusReason)
+		}
+		return out
+	}
+
+	switch eligibility {
+	case epb.Eligibility_ELIGIBILITY_CARD_ACTIVATION:
+		out.Reason = IneligibleCardAlreadyActivated
+		out.Facts["activationStatus"] = strconv.FormatBool(r.ActivationStatus)
+	case epb.Eligibility_ELIGIBILITY_GET_DETAILS, epb.Eligibility_ELIGIBILITY_BLOCK, epb.Eligibility_ELIGIBILITY_DAILY_LIMITS:
+		out.Reason = IneligibleCardNotActivated
+		out.Facts["activationStatus"] = strconv.FormatBool(r.ActivationStatus)
+	case epb.Eligibility_ELIGIBILITY_SET_PIN:
+		out.Reason = IneligiblePINAlreadySet
+		out.Facts["pinChangedCount"] = strconv.FormatInt(r.PinChangedCount, 10)
+	case epb.Eligibility_ELIGIBILITY_CHANGE_PIN:
+		out.Reason = IneligiblePINNotSet
+		out.Facts["pinChangedCount"] = strconv.FormatInt(r.PinChangedCount, 10)
+	case epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_LOST, epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_STOLEN:
+		out.Reason = IneligibleIssuedToday
+		out.Facts["issueDate"] = r.IssueDate
+		out.Facts["replacedDate"] = r.ReplacedDate
+	default:
+		out.Reason = IneligibleNotApplicable
+	}
+
+	return out
+}
+
+func (i Ineligibility) ToProto() *epb.Ineligibility {
+	return &epb.Ineligibility{
+		Eligibility: i.Eligibility,
+		Reason:      string(i.Reason),
+		Facts:       i.Facts,
+	}
+}
+
+func (s Status) ineligibleReason() IneligibleReason {
+	switch s {
+	case StatusClosed:
+		return IneligibleCardClosed
+	case StatusLost:
+		return IneligibleCardLost
+	case StatusStolen:
+		return IneligibleCardStolen
+	case StatusDelinquentReturn:
+		return IneligibleCardDamaged
+	case StatusDelinquentRetain:
+		return IneligibleCardRetained
+	case StatusTemporaryBlock:
+		return IneligibleCardBlocked
+	case StatusBlockAtm, StatusBlockAtmPosExcludeCnp, StatusBlockAtmPosCnpBch, StatusBlockAtmPosCnp, StatusBlockCnp, StatusBlockPosExcludeCnp:
+		return IneligibleCardFraudBlocked
+	case StatusUnissuedNdIciCards:
+		return IneligibleCardNotIssued
+	default:
+		return IneligibleCardStatusUnknown
+	}
+}
@@ -0,0 +1,122 @@
+package ctm
+
+import (
+	"testing"
+	"time"
+
+	epb "github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebitCardResponse_Ineligibility(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+	tests := []struct {
+		name        string
+		card        DebitCardResponse
+		eligibility epb.Eligibility
+		want        Ineligibility
+		eligible    bool
+	}{
+		{
+			name:        "eligible",
+			card:        DebitCardResponse{Status: StatusIssued, ActivationStatus: true, PinChangedCount: 1},
+			eligibility: epb.Eligibility_ELIGIBILITY_CHANGE_PIN,
+			eligible:    true,
+		},
+		{
+			name:        "closed card",
+			card:        DebitCardResponse{Status: StatusClosed, StatusReason: StatusReasonClosed},
+			eligibility: epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_LOST,
+			want: Ineligibility{
+				Eligibility: epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_LOST,
+				Reason:      IneligibleCardClosed,
+				Facts:       map[string]string{"status": "Closed", "statusReason": "Closed"},
+			},
+		},
+		{
+			name:        "fraud blocked card",
+			card:        DebitCardResponse{Status: StatusBlockCnp},
+			eligibility: epb.Eligibility_ELIGIBILITY_APPLE_PAY,
+			want: Ineligibility{
+				Eligibility: epb.Eligibility_ELIGIBILITY_APPLE_PAY,
+				Reason:      IneligibleCardFraudBlocked,
+				Facts:       map[string]string{"status": "Block CNP"},
+			},
+		},
+		{
+			name:        "replaced today",
+			card:        DebitCardResponse{Status: StatusIssued, ActivationStatus: true, IssueDate: "2015-08-05", ReplacedDate: today},
+			eligibility: epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_STOLEN,
+			want: Ineligibility{
+				Eligibility: epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_STOLEN,
+				Reason:      IneligibleIssuedToday,
+				Facts:       map[string]string{"status": "Issued", "issueDate": "2015-08-05", "replacedDate": today},
+			},
+		},
+		{
+			name:        "not activated",
+			card:        DebitCardResponse{Status: StatusIssued},
+			eligibility: epb.Eligibility_ELIGIBILITY_GET_DETAILS,
+			want: Ineligibility{
+				Eligibility: epb.Eligibility_ELIGIBILITY_GET_DETAILS,
+				Reason:      IneligibleCardNotActivated,
+				Facts:       map[string]string{"status": "Issued", "activationStatus": "false"},
+			},
+		},
+		{
+			name:        "already activated",
+			card:        DebitCardResponse{Status: StatusIssued, ActivationStatus: true},
+			eligibility: epb.Eligibility_ELIGIBILITY_CARD_ACTIVATION,
+			want: Ineligibility{
+				Eligibility: epb.Eligibility_ELIGIBILITY_CARD_ACTIVATION,
+				Reason:      IneligibleCardAlreadyActivated,
+				Facts:       map[string]string{"status": "Issued", "activationStatus": "true"},
+			},
+		},
+		{
+			name:        "PIN not set",
+			card:        DebitCardResponse{Status: StatusIssued, ActivationStatus: true},
+			eligibility: epb.Eligibility_ELIGIBILITY_CHANGE_PIN,
+			want: Ineligibility{
+				Eligibility: epb.Eligibility_ELIGIBILITY_CHANGE_PIN,
+				Reason:      IneligiblePINNotSet,
+				Facts:       map[string]string{"status": "Issued", "pinChangedCount": "0"},
+			},
+		},
+		{
+			name:        "not blocked",
+			card:        DebitCardResponse{Status: StatusIssued, ActivationStatus: true},
+			eligibility: epb.Eligibility_ELIGIBILITY_UNBLOCK,
+			want: Ineligibility{
+				Eligibility: epb.Eligibility_ELIGIBILITY_UNBLOCK,
+				Reason:      IneligibleNotApplicable,
+				Facts:       map[string]string{"status": "Issued"},
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := test.card.Ineligibility(test.eligibility)
+			assert.Equal(t, !test.eligible, ok)
+			if !test.eligible {
+				assert.Equal(t, test.want, got)
+			}
+		})
+	}
+}
+
+func TestDebitCardResponse_Ineligibilities(t *testing.T) {
+	card := DebitCardResponse{Status: StatusLost}
+
+	got := card.Ineligibilities()
+
+	assert.Len(t, got, len(epb.Eligibility_name)-2)
+	for i, ineligibility := range got {
+		assert.NotEqual(t, epb.Eligibility_ELIGIBILITY_CARD_REPLACEMENT_LOST, ineligibility.Eligibility)
+		assert.Equal(t, IneligibleCardLost, ineligibility.Reason)
+		if i > 0 {
+			assert.Less(t, got[i-1].Eligibility, ineligibility.Eligibility)
+		}
+	}
+}
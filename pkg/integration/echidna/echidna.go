@@ -32,6 +32,10 @@ type Config struct {
 	ClientIDEnvKey string `json:"clientIDEnvKey"      yaml:"clientIDEnvKey"      mapstructure:"clientIDEnvKey" validate:"required"`
 	ClientID       string `json:"clientID,omitempty"  yaml:"clientID,omitempty"  mapstructure:"clientID"`
 	MaxRetries     int    `json:"maxRetries"          yaml:"maxRetries"          mapstructure:"maxRetries"     validate:"required"`
+	// Resilience protects each operation with a circuit breaker and bulkhead, disabled if not set
+	Resilience *apic.ResilienceConfig `json:"resilience,omitempty" yaml:"resilience,omitempty" mapstructure:"resilience"`
+	// Timeouts bounds each operation including its retries, only the incoming deadline applies if not set
+	Timeouts *apic.TimeoutConfig `json:"timeouts,omitempty" yaml:"timeouts,omitempty" mapstructure:"timeouts"`
 }
 
 type Echidna interface {
@@ -52,10 +56,11 @@ func ClientFromConfig(ctx context.Context, httpClient *http.Client, config *Conf
 		return nil, nil
 	}
 
-	return NewClient(ctx, config.BaseURL, config.ClientIDEnvKey, httpClient, config.MaxRetries, gsmClient)
+	return NewClient(ctx, config.BaseURL, config.ClientIDEnvKey, httpClient, config.MaxRetries, gsmClient,
+		apic.WithResilience(config.Resilience), apic.WithTimeouts(config.Timeouts))
 }
 
-func NewClient(ctx context.Context, baseURL string, clientIDEnvKey string, httpClient *http.Client, maxRetries int, gsmClient *gsm.Client) (Echidna, error) {
+func NewClient(ctx context.Context, baseURL string, clientIDEnvKey string, httpClient *http.Client, maxRetries int, gsmClient *gsm.Client, opts ...apic.Option) (Echidna, error) {
 	if httpClient == nil {
 		httpClient = rest.NewHTTPClientWithLogAndRetry(maxRetries, nil, names.Echidna)
 	}
@@ -67,7 +72,7 @@ func NewClient(ctx context.Context, baseURL string, clientIDEnvKey string, httpC
 			anzerrors.NewErrorInfo(ctx, anzcodes.StartupFailure, "unable to parse configured url"))
 	}
 
-	apicClient, err := apic.NewAPICClient(ctx, clientIDEnvKey, httpClient, gsmClient)
+	apicClient, err := apic.NewAPICClient(ctx, clientIDEnvKey, httpClient, gsmClient, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -189,6 +194,15 @@ func checkCode(ctx context.Context, result Result) error {
 	return nil
 }
 
+// ResultIncorrectPIN is the result code of a PIN that does not match the card
+const ResultIncorrectPIN = 55
+
+// IsIncorrectPIN returns true if err is the result of an incorrect PIN, rather than an invalid request or a failure
+func IsIncorrectPIN(err error) bool {
+	return anzerrors.GetStatusCode(err) == GetGRPCError(ResultIncorrectPIN) &&
+		anzerrors.GetErrorInfo(err).GetReason() == GetErrorMsg(ResultIncorrectPIN)
+}
+
 func (c client) getURL(action Action) string {
 	return fmt.Sprintf(format, c.baseURL, cardPINServicesAPI, action)
 }
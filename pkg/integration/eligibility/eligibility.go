@@ -18,6 +18,8 @@ import (
 
 type Client struct {
 	epb.CardEligibilityAPIClient
+	// Evaluator checks cards that have already been inquired, eligibility is evaluated in process when nil
+	Evaluator Evaluator
 }
 
 type Config struct {
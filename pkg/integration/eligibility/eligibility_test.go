@@ -28,6 +28,10 @@ func (m *mockEligibilityClient) Can(ctx context.Context, in *epb.CanRequest, opt
 	return m.canCall(ctx, in, opts...)
 }
 
+func (m *mockEligibilityClient) BatchCan(context.Context, *epb.BatchCanRequest, ...grpc.CallOption) (*epb.BatchCanResponse, error) {
+	return &epb.BatchCanResponse{}, nil
+}
+
 func TestNewEligibilityClient(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -0,0 +1,36 @@
+package eligibility
+
+import (
+	"context"
+
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+	"google.golang.org/grpc/codes"
+
+	"github.com/anzx/fabric-cards/pkg/integration/ctm"
+
+	epb "github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1"
+)
+
+// Evaluator checks the eligibility of a card that has already been inquired from CTM
+type Evaluator interface {
+	Evaluate(ctx context.Context, eligibility epb.Eligibility, card *ctm.DebitCardResponse) error
+}
+
+// CanCard checks the eligibility of a card the caller has already inquired. Unlike Can it makes no downstream calls,
+// the caller must have checked the customer is entitled to the card.
+func (c Client) CanCard(ctx context.Context, eligibility epb.Eligibility, card *ctm.DebitCardResponse) error {
+	if c.Evaluator == nil {
+		return Evaluate(ctx, eligibility, card)
+	}
+	return c.Evaluator.Evaluate(ctx, eligibility, card)
+}
+
+// Evaluate returns the error the Can RPC returns when the card is not eligible, with the reason as an error detail
+func Evaluate(ctx context.Context, eligibility epb.Eligibility, card *ctm.DebitCardResponse) error {
+	if ineligibility, ineligible := card.Ineligibility(eligibility); ineligible {
+		return anzerrors.New(codes.InvalidArgument, "eligibility failed", anzerrors.NewErrorInfo(ctx, anzcodes.CardIneligible, "card not eligible"),
+			anzerrors.WithDetails(ineligibility.ToProto()))
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package eligibility
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/anzx/fabric-cards/pkg/integration/ctm"
+
+	epb "github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+type evaluatorFunc func(ctx context.Context, eligibility epb.Eligibility, card *ctm.DebitCardResponse) error
+
+func (f evaluatorFunc) Evaluate(ctx context.Context, eligibility epb.Eligibility, card *ctm.DebitCardResponse) error {
+	return f(ctx, eligibility, card)
+}
+
+func TestClient_CanCard(t *testing.T) {
+	active := &ctm.DebitCardResponse{Status: ctm.StatusIssued, ActivationStatus: true}
+	stolen := &ctm.DebitCardResponse{Status: ctm.StatusStolen}
+
+	t.Run("eligible card is evaluated in process", func(t *testing.T) {
+		c := Client{CardEligibilityAPIClient: &mockEligibilityClient{}}
+		assert.NoError(t, c.CanCard(context.Background(), epb.Eligibility_ELIGIBILITY_GET_DETAILS, active))
+	})
+
+	t.Run("ineligible card returns the reason as a detail", func(t *testing.T) {
+		c := Client{CardEligibilityAPIClient: &mockEligibilityClient{}}
+
+		err := c.CanCard(context.Background(), epb.Eligibility_ELIGIBILITY_GET_DETAILS, stolen)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "status_code=InvalidArgument")
+		assert.Contains(t, err.Error(), "reason=card not eligible")
+		want := &epb.Ineligibility{
+			Eligibility: epb.Eligibility_ELIGIBILITY_GET_DETAILS,
+			Reason:      string(ctm.IneligibleCardStolen),
+			Facts:       map[string]string{"status": "Stolen"},
+		}
+		details := status.Convert(err).Details()
+		require.Len(t, details, 1)
+		assert.True(t, proto.Equal(want, details[0].(*epb.Ineligibility)))
+	})
+
+	t.Run("configured evaluator is used", func(t *testing.T) {
+		wantErr := errors.New("oh no")
+		var got epb.Eligibility
+		c := Client{
+			CardEligibilityAPIClient: &mockEligibilityClient{},
+			Evaluator: evaluatorFunc(func(_ context.Context, eligibility epb.Eligibility, _ *ctm.DebitCardResponse) error {
+				got = eligibility
+				return wantErr
+			}),
+		}
+
+		err := c.CanCard(context.Background(), epb.Eligibility_ELIGIBILITY_BLOCK, active)
+
+		assert.Equal(t, wantErr, err)
+		assert.Equal(t, epb.Eligibility_ELIGIBILITY_BLOCK, got)
+	})
+}
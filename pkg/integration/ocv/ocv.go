@@ -49,6 +49,10 @@ type Config struct {
 	ClientIDEnvKey string `json:"clientIDEnvKey"      yaml:"clientIDEnvKey"      mapstructure:"clientIDEnvKey"  validate:"required"`
 	MaxRetries     int    `json:"maxRetries"          yaml:"maxRetries"          mapstructure:"maxRetries"      validate:"required"`
 	EnableLogging  bool   `json:"enableLogging"       yaml:"enableLogging"       mapstructure:"enableLogging"`
+	// Resilience protects each operation with a circuit breaker and bulkhead, disabled if not set
+	Resilience *apic.ResilienceConfig `json:"resilience,omitempty" yaml:"resilience,omitempty" mapstructure:"resilience"`
+	// Timeouts bounds each operation including its retries, only the incoming deadline applies if not set
+	Timeouts *apic.TimeoutConfig `json:"timeouts,omitempty" yaml:"timeouts,omitempty" mapstructure:"timeouts"`
 }
 
 type MaintainContractAPI interface {
@@ -75,15 +79,16 @@ func ClientFromConfig(ctx context.Context, httpClient *http.Client, config *Conf
 		return nil, nil
 	}
 
-	return NewClient(ctx, config.BaseURL, config.ClientIDEnvKey, httpClient, config.MaxRetries, gsmClient, config.EnableLogging)
+	return NewClient(ctx, config.BaseURL, config.ClientIDEnvKey, httpClient, config.MaxRetries, gsmClient, config.EnableLogging,
+		apic.WithResilience(config.Resilience), apic.WithTimeouts(config.Timeouts))
 }
 
-func NewClient(ctx context.Context, baseURL string, clientIDEnvKey string, httpClient *http.Client, maxRetries int, gsmClient *gsm.Client, requestLogging bool) (Client, error) {
+func NewClient(ctx context.Context, baseURL string, clientIDEnvKey string, httpClient *http.Client, maxRetries int, gsmClient *gsm.Client, requestLogging bool, opts ...apic.Option) (Client, error) {
 	if httpClient == nil {
 		httpClient = rest.NewHTTPClientWithLogAndRetry(maxRetries, func(_ *url.URL) bool { return requestLogging }, names.OCV)
 	}
 
-	apicClient, err := apic.NewAPICClient(ctx, clientIDEnvKey, httpClient, gsmClient)
+	apicClient, err := apic.NewAPICClient(ctx, clientIDEnvKey, httpClient, gsmClient, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -25,6 +25,10 @@ type Config struct {
 	ClientIDEnvKey string `json:"clientIDEnvKey"      yaml:"clientIDEnvKey"       mapstructure:"clientIDEnvKey"       validate:"required"`
 	ClientID       string `json:"clientID,omitempty"  yaml:"clientID,omitempty"   mapstructure:"clientID,omitempty"`
 	MaxRetries     int    `json:"maxRetries"          yaml:"maxRetries"           mapstructure:"maxRetries"           validate:"required"`
+	// Resilience protects each operation with a circuit breaker and bulkhead, disabled if not set
+	Resilience *apic.ResilienceConfig `json:"resilience,omitempty" yaml:"resilience,omitempty" mapstructure:"resilience"`
+	// Timeouts bounds each operation including its retries, only the incoming deadline applies if not set
+	Timeouts *apic.TimeoutConfig `json:"timeouts,omitempty" yaml:"timeouts,omitempty" mapstructure:"timeouts"`
 }
 
 const (
@@ -57,10 +61,11 @@ func ClientFromConfig(ctx context.Context, httpClient *http.Client, config *Conf
 		return nil, nil
 	}
 
-	return NewClient(ctx, config.BaseURL, config.ClientIDEnvKey, httpClient, config.MaxRetries, gsmClient)
+	return NewClient(ctx, config.BaseURL, config.ClientIDEnvKey, httpClient, config.MaxRetries, gsmClient,
+		apic.WithResilience(config.Resilience), apic.WithTimeouts(config.Timeouts))
 }
 
-func NewClient(ctx context.Context, baseURL string, clientIDEnvKey string, httpClient *http.Client, maxRetries int, gsmClient *gsm.Client) (CustomerRulesAPI, error) {
+func NewClient(ctx context.Context, baseURL string, clientIDEnvKey string, httpClient *http.Client, maxRetries int, gsmClient *gsm.Client, opts ...apic.Option) (CustomerRulesAPI, error) {
 	if httpClient == nil {
 		httpClient = rest.NewHTTPClientWithLogAndRetry(maxRetries, nil, names.VISA)
 	}
@@ -72,7 +77,7 @@ func NewClient(ctx context.Context, baseURL string, clientIDEnvKey string, httpC
 			anzerrors.NewErrorInfo(ctx, anzcodes.StartupFailure, "unable to parse configured url"))
 	}
 
-	apicClient, err := apic.NewAPICClient(ctx, clientIDEnvKey, httpClient, gsmClient)
+	apicClient, err := apic.NewAPICClient(ctx, clientIDEnvKey, httpClient, gsmClient, opts...)
 	if err != nil {
 		return nil, err
 	}
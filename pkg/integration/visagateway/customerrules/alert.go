@@ -0,0 +1,65 @@
+package customerrules
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/genproto/googleapis/type/money"
+	"google.golang.org/grpc/codes"
+
+	ccpb "github.com/anzx/fabricapis/pkg/fabric/service/cardcontrols/v1beta2"
+	crpb "github.com/anzx/fabricapis/pkg/gateway/visa/service/customerrules"
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+)
+
+const invalidAlert = "invalid alert"
+
+// alertThreshold validates the alert settings of a control request and returns the amount approved transactions are
+// alerted from, nil to alert on every transaction. Visa only alerts on approved transactions of controls that don't
+// decline all, so a threshold is only accepted on alert-only controls and controls with a spend limit.
+func alertThreshold(ctx context.Context, request *ccpb.ControlRequest, limit *crpb.SpendLimit) (*float64, error) {
+	if request.GetAlertOnly() {
+		if GetCategory(request.ControlType) == GLOBAL {
+			return nil, alertErr(ctx, "%s can't be alert-only", request.ControlType)
+		}
+		if limit != nil {
+			return nil, alertErr(ctx, "alert-only controls can't have a spend limit")
+		}
+	}
+
+	amount := request.GetAlertThreshold()
+	if amount == nil {
+		return nil, nil
+	}
+	if !request.GetAlertOnly() && limit == nil {
+		return nil, alertErr(ctx, "alert threshold can only be set on alert-only controls or controls with a spend limit")
+	}
+	if amount.GetCurrencyCode() != SpendLimitCurrency {
+		return nil, alertErr(ctx, "alert threshold currency must be %s", SpendLimitCurrency)
+	}
+	threshold := toFloat(amount)
+	if threshold <= 0 {
+		return nil, alertErr(ctx, "alert threshold must be greater than zero")
+	}
+	return &threshold, nil
+}
+
+// AlertOnly returns true if a control alerts on transactions without declining them. Controls created before
+// alert-only controls have ShouldDeclineAll set.
+func AlertOnly(shouldDeclineAll *bool, limit *crpb.SpendLimit) bool {
+	return shouldDeclineAll != nil && !*shouldDeclineAll && limit == nil
+}
+
+// GetAlertThreshold returns the alert threshold of a Visa control, nil if the control alerts on every transaction
+func GetAlertThreshold(threshold *float64) *money.Money {
+	if threshold == nil {
+		return nil
+	}
+	return toMoney(*threshold)
+}
+
+func alertErr(ctx context.Context, format string, args ...interface{}) error {
+	return anzerrors.New(codes.InvalidArgument, invalidAlert,
+		anzerrors.NewErrorInfo(ctx, anzcodes.ValidationFailure, fmt.Sprintf(format, args...)))
+}
@@ -0,0 +1,115 @@
+package customerrules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/type/money"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/anzx/fabric-cards/pkg/feature"
+	"github.com/anzx/fabric-cards/pkg/integration/util"
+	"github.com/anzx/fabric-cards/pkg/util/testutil"
+	ccpb "github.com/anzx/fabricapis/pkg/fabric/service/cardcontrols/v1beta2"
+	crpb "github.com/anzx/fabricapis/pkg/gateway/visa/service/customerrules"
+	anzerrors "github.com/anzx/pkg/errors"
+)
+
+func Test_alertThreshold(t *testing.T) {
+	t.Parallel()
+	limit := &ccpb.SpendLimit{Amount: aud(100, 0), Period: ccpb.SpendLimit_PERIOD_DAILY}
+	tests := []struct {
+		name    string
+		request *ccpb.ControlRequest
+		want    *float64
+		wantErr string
+	}{
+		{
+			name:    "alert-only without threshold alerts on every transaction",
+			request: &ccpb.ControlRequest{ControlType: ccpb.ControlType_MCT_GAMBLING, AlertOnly: true},
+		},
+		{
+			name:    "alert-only with threshold",
+			request: &ccpb.ControlRequest{ControlType: ccpb.ControlType_MCT_GAMBLING, AlertOnly: true, AlertThreshold: aud(50, 250000000)},
+			want:    util.ToFloat64Ptr(50.25),
+		},
+		{
+			name:    "threshold with a spend limit",
+			request: &ccpb.ControlRequest{ControlType: ccpb.ControlType_TCT_E_COMMERCE, SpendLimit: limit, AlertThreshold: aud(20, 0)},
+			want:    util.ToFloat64Ptr(20),
+		},
+		{
+			name:    "global control",
+			request: &ccpb.ControlRequest{ControlType: ccpb.ControlType_GCT_GLOBAL, AlertOnly: true},
+			wantErr: "GCT_GLOBAL can't be alert-only",
+		},
+		{
+			name:    "alert-only with a spend limit",
+			request: &ccpb.ControlRequest{ControlType: ccpb.ControlType_MCT_GAMBLING, AlertOnly: true, SpendLimit: limit},
+			wantErr: "alert-only controls can't have a spend limit",
+		},
+		{
+			name:    "threshold on a control that declines all",
+			request: &ccpb.ControlRequest{ControlType: ccpb.ControlType_MCT_GAMBLING, AlertThreshold: aud(20, 0)},
+			wantErr: "alert threshold can only be set on alert-only controls or controls with a spend limit",
+		},
+		{
+			name:    "foreign currency",
+			request: &ccpb.ControlRequest{ControlType: ccpb.ControlType_MCT_GAMBLING, AlertOnly: true, AlertThreshold: &money.Money{CurrencyCode: "NZD", Units: 20}},
+			wantErr: "alert threshold currency must be AUD",
+		},
+		{
+			name:    "zero threshold",
+			request: &ccpb.ControlRequest{ControlType: ccpb.ControlType_MCT_GAMBLING, AlertOnly: true, AlertThreshold: aud(0, 0)},
+			wantErr: "alert threshold must be greater than zero",
+		},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			ctx := testutil.GetContext(false)
+			limit, err := spendLimit(ctx, test.request)
+			require.NoError(t, err)
+
+			got, err := alertThreshold(ctx, test.request, limit)
+			if test.wantErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, codes.InvalidArgument, anzerrors.GetStatusCode(err))
+				assert.Contains(t, err.Error(), test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestWithControls_AlertOnly(t *testing.T) {
+	require.NoError(t, feature.FeatureGate.Set(map[feature.Feature]bool{feature.MCT_GAMBLING: true}))
+
+	controls, err := WithControls(testutil.GetContext(false), []*ccpb.ControlRequest{
+		{ControlType: ccpb.ControlType_MCT_GAMBLING, AlertOnly: true, AlertThreshold: aud(50, 0)},
+	}, "id")
+	require.NoError(t, err)
+
+	want := &crpb.MerchantControl{
+		ShouldDeclineAll:     util.ToBoolPtr(false),
+		ShouldAlertOnDecline: util.ToBoolPtr(true),
+		IsControlEnabled:     true,
+		ControlType:          ccpb.ControlType_MCT_GAMBLING.String(),
+		UserIdentifier:       util.ToStringPtr("id"),
+		AlertThreshold:       util.ToFloat64Ptr(50),
+	}
+	got := ControlRequest(controls...).MerchantControls
+	require.Len(t, got, 1)
+	assert.True(t, proto.Equal(want, got[0]), "want %v, got %v", want, got[0])
+}
+
+func TestAlertOnly(t *testing.T) {
+	assert.False(t, AlertOnly(nil, nil), "controls created before alert-only controls decline all")
+	assert.False(t, AlertOnly(util.ToBoolPtr(true), nil))
+	assert.True(t, AlertOnly(util.ToBoolPtr(false), nil))
+	assert.False(t, AlertOnly(util.ToBoolPtr(false), &crpb.SpendLimit{Type: "LMT_DAY", DeclineThreshold: 10}))
+}
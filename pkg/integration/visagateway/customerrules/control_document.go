@@ -40,13 +40,22 @@ func GetDeleteRequest(d *crpb.Resource, controlTypes []ccpb.ControlType) (*crpb.
 		if !ok {
 			continue
 		}
+		// a control type is set more than once when each is restricted to a different schedule, all are removed
 		switch GetCategory(controlType) {
 		case GLOBAL:
 			deleteRequest.GlobalControls = append(deleteRequest.GlobalControls, d.GlobalControls...)
 		case TRANSACTION:
-			deleteRequest.TransactionControls = append(deleteRequest.TransactionControls, d.TransactionControls[i])
+			for _, control := range d.TransactionControls[i:] {
+				if control.ControlType == controlType.String() && control.IsControlEnabled {
+					deleteRequest.TransactionControls = append(deleteRequest.TransactionControls, control)
+				}
+			}
 		case MERCHANT:
-			deleteRequest.MerchantControls = append(deleteRequest.MerchantControls, d.MerchantControls[i])
+			for _, control := range d.MerchantControls[i:] {
+				if control.ControlType == controlType.String() && control.IsControlEnabled {
+					deleteRequest.MerchantControls = append(deleteRequest.MerchantControls, control)
+				}
+			}
 		}
 	}
 
@@ -98,54 +107,110 @@ func ControlRequest(requestBuilders ...func(*crpb.ControlRequest)) *crpb.Control
 
 func WithControls(ctx context.Context, controlRequest []*ccpb.ControlRequest, id string) ([]func(*crpb.ControlRequest), error) {
 	var out []func(*crpb.ControlRequest)
+	controlTypes := make([]ccpb.ControlType, 0, len(controlRequest))
+	timeRanges := make([]*crpb.TimeRange, 0, len(controlRequest))
 
 	for _, request := range controlRequest {
-		if !feature.FeatureGate.Enabled(feature.Feature(request.ControlType.String())) {
+		if !feature.FeatureGate.EnabledFor(ctx, feature.Feature(request.ControlType.String())) {
 			return nil, anzerrors.New(codes.Unavailable, controlNotAllowed,
 				anzerrors.NewErrorInfo(ctx, anzcodes.FeatureDisabled, "control is disabled"),
 				anzerrors.WithCause(fmt.Errorf(behindFeatureToggle, request.String())))
 		}
-		out = append(out, addRequest(request, id))
+		options, err := newControlOptions(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		controlTypes = append(controlTypes, request.ControlType)
+		timeRanges = append(timeRanges, options.schedule)
+		out = append(out, addRequest(request, id, options))
+	}
+
+	if err := validateSchedules(ctx, controlTypes, timeRanges); err != nil {
+		return nil, err
 	}
 
 	return out, nil
 }
 
-func addRequest(request *ccpb.ControlRequest, id string) func(*crpb.ControlRequest) {
+// controlOptions are the optional settings of a transaction or merchant control
+type controlOptions struct {
+	limit          *crpb.SpendLimit
+	schedule       *crpb.TimeRange
+	alertOnly      bool
+	alertThreshold *float64
+}
+
+func newControlOptions(ctx context.Context, request *ccpb.ControlRequest) (controlOptions, error) {
+	limit, err := spendLimit(ctx, request)
+	if err != nil {
+		return controlOptions{}, err
+	}
+	schedule, err := timeRange(ctx, request)
+	if err != nil {
+		return controlOptions{}, err
+	}
+	threshold, err := alertThreshold(ctx, request, limit)
+	if err != nil {
+		return controlOptions{}, err
+	}
+	return controlOptions{
+		limit:          limit,
+		schedule:       schedule,
+		alertOnly:      request.GetAlertOnly(),
+		alertThreshold: threshold,
+	}, nil
+}
+
+// declineAll is false when transactions are declined over the spend limit only, or never for alert-only controls
+func (o controlOptions) declineAll() bool {
+	return o.limit == nil && !o.alertOnly
+}
+
+func addRequest(request *ccpb.ControlRequest, id string, options controlOptions) func(*crpb.ControlRequest) {
 	var out func(*crpb.ControlRequest)
 
 	switch GetCategory(request.ControlType) {
 	case GLOBAL:
 		out = globalControlRequest(request, id)
 	case MERCHANT:
-		out = merchantControlRequest(request, id)
+		out = merchantControlRequest(request, id, options)
 	case TRANSACTION:
-		out = transactionControlRequest(request, id)
+		out = transactionControlRequest(request, id, options)
 	}
 
 	return out
 }
 
-func transactionControlRequest(request *ccpb.ControlRequest, id string) func(*crpb.ControlRequest) {
+// transactionControlRequest declines every transaction of the control type, only those over the spend limit if set or none
+// if alert-only, while the schedule is active if set
+func transactionControlRequest(request *ccpb.ControlRequest, id string, options controlOptions) func(*crpb.ControlRequest) {
 	control := crpb.TransactionControl{
-		ShouldDeclineAll:     util.ToBoolPtr(true),
+		ShouldDeclineAll:     util.ToBoolPtr(options.declineAll()),
 		ShouldAlertOnDecline: util.ToBoolPtr(true),
 		IsControlEnabled:     true,
 		ControlType:          request.ControlType.String(),
 		UserIdentifier:       &id,
+		SpendLimit:           options.limit,
+		TimeRange:            options.schedule,
+		AlertThreshold:       options.alertThreshold,
 	}
 	return func(r *crpb.ControlRequest) {
 		r.TransactionControls = append(r.TransactionControls, &control)
 	}
 }
 
-func merchantControlRequest(request *ccpb.ControlRequest, id string) func(*crpb.ControlRequest) {
+// merchantControlRequest declines every transaction of the control type, only those over the spend limit if set or none
+// if alert-only, while the schedule is active if set
+func merchantControlRequest(request *ccpb.ControlRequest, id string, options controlOptions) func(*crpb.ControlRequest) {
 	control := crpb.MerchantControl{
-		ShouldDeclineAll:     util.ToBoolPtr(true),
+		ShouldDeclineAll:     util.ToBoolPtr(options.declineAll()),
 		ShouldAlertOnDecline: util.ToBoolPtr(true),
 		IsControlEnabled:     true,
 		ControlType:          request.ControlType.String(),
 		UserIdentifier:       &id,
+		SpendLimit:           options.limit,
+		TimeRange:            options.schedule,
+		AlertThreshold:       options.alertThreshold,
 	}
 	return func(r *crpb.ControlRequest) {
 		r.MerchantControls = append(r.MerchantControls, &control)
@@ -10,6 +10,7 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/anzx/fabric-cards/pkg/integration/util"
+	"github.com/anzx/fabric-cards/pkg/util/testutil"
 
 	"github.com/stretchr/testify/require"
 
@@ -406,3 +407,33 @@ func TestGetImpulseDelayPeriodProto(t *testing.T) {
 		assert.Equal(t, test.want, got)
 	}
 }
+
+func TestWithControlsRollout(t *testing.T) {
+	require.NoError(t, feature.FeatureGate.Set(map[feature.Feature]bool{
+		feature.MCT_GAMBLING: false,
+	}))
+	require.NoError(t, feature.FeatureGate.SetRollouts(map[feature.Feature]feature.Rollout{
+		feature.MCT_GAMBLING: {Personas: []string{"Persona"}},
+	}))
+	defer func() {
+		require.NoError(t, feature.FeatureGate.SetRollouts(nil))
+	}()
+
+	cr := []*ccpb.ControlRequest{
+		{
+			ControlType: ccpb.ControlType_MCT_GAMBLING,
+		},
+	}
+
+	t.Run("persona in rollout", func(t *testing.T) {
+		got, err := WithControls(testutil.GetContext(false), cr, "UID")
+		require.NoError(t, err)
+		assert.Len(t, got, 1)
+	})
+	t.Run("persona not in rollout", func(t *testing.T) {
+		got, err := WithControls(context.Background(), cr, "UID")
+		require.Error(t, err)
+		require.Nil(t, got)
+		assert.Contains(t, err.Error(), "control is disabled")
+	})
+}
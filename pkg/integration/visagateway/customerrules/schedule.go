@@ -0,0 +1,371 @@
+package customerrules
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	// the container has no zoneinfo, customer time zones are resolved from the embedded database
+	_ "time/tzdata"
+
+	"google.golang.org/genproto/googleapis/type/dayofweek"
+	"google.golang.org/genproto/googleapis/type/timeofday"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+
+	ccpb "github.com/anzx/fabricapis/pkg/fabric/service/cardcontrols/v1beta2"
+	crpb "github.com/anzx/fabricapis/pkg/gateway/visa/service/customerrules"
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+)
+
+const (
+	// DefaultTimeZone is applied to schedules set without a time zone and to GMT time ranges returned to customers
+	DefaultTimeZone = "Australia/Melbourne"
+	// visaTimeZone is the time zone of time ranges set before the customer's time zone was kept in the time range
+	visaTimeZone = "GMT"
+
+	invalidSchedule = "invalid schedule"
+	clockFormat     = "15:04"
+	minutesPerDay   = 24 * 60
+	minutesPerWeek  = 7 * minutesPerDay
+)
+
+// now is replaced in tests, the offset of a time zone on the day time ranges are compared or a GMT time range is read
+// is used for conversion
+var now = time.Now
+
+// timeRange returns the Visa time range of a control request, nil if the request has no schedule. The time range
+// keeps the customer's time zone so Visa applies its offset on the day a transaction is made, across daylight saving.
+func timeRange(ctx context.Context, request *ccpb.ControlRequest) (*crpb.TimeRange, error) {
+	schedule := request.GetSchedule()
+	if schedule == nil {
+		return nil, nil
+	}
+
+	if GetCategory(request.ControlType) == GLOBAL {
+		return nil, scheduleErr(ctx, "schedules can't be set on %s", request.ControlType)
+	}
+
+	zone := schedule.GetTimeZone()
+	if zone == "" {
+		zone = DefaultTimeZone
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, scheduleErr(ctx, "unknown time zone %s", zone)
+	}
+
+	start, err := minuteOfDay(schedule.GetStartTime())
+	if err != nil {
+		return nil, scheduleErr(ctx, "start time %v", err)
+	}
+	end, err := minuteOfDay(schedule.GetEndTime())
+	if err != nil {
+		return nil, scheduleErr(ctx, "end time %v", err)
+	}
+	if start == end {
+		return nil, scheduleErr(ctx, "start and end time must be different")
+	}
+
+	days := make([]time.Weekday, 0, len(schedule.GetDaysOfWeek()))
+	for _, day := range schedule.GetDaysOfWeek() {
+		if day == dayofweek.DayOfWeek_DAY_OF_WEEK_UNSPECIFIED || day > dayofweek.DayOfWeek_SUNDAY {
+			return nil, scheduleErr(ctx, "day of week %s is not supported", day)
+		}
+		days = append(days, toWeekday(day))
+	}
+
+	out := &crpb.TimeRange{
+		TimeZoneId: loc.String(),
+		StartTime:  clockTime(start),
+		EndTime:    clockTime(end),
+	}
+	for _, day := range days {
+		out.DaysOfWeek = append(out.DaysOfWeek, visaDay(day))
+	}
+	return out, nil
+}
+
+// GetSchedule returns the schedule of a Visa control in the time zone it was set in, nil if the control has no time
+// range. Time ranges in GMT are returned in DefaultTimeZone.
+func GetSchedule(timeRange *crpb.TimeRange) *ccpb.ControlSchedule {
+	if timeRange == nil {
+		return nil
+	}
+
+	from, err := location(timeRange)
+	if err != nil {
+		return nil
+	}
+	to := from
+	if gmt(timeRange) {
+		if to, err = time.LoadLocation(DefaultTimeZone); err != nil {
+			return nil
+		}
+	}
+
+	start, err := time.Parse(clockFormat, timeRange.GetStartTime())
+	if err != nil {
+		return nil
+	}
+	end, err := time.Parse(clockFormat, timeRange.GetEndTime())
+	if err != nil {
+		return nil
+	}
+
+	startLocal, shift := convert(start.Hour()*60+start.Minute(), from, to)
+	endLocal, _ := convert(end.Hour()*60+end.Minute(), from, to)
+
+	out := &ccpb.ControlSchedule{
+		TimeZone:  to.String(),
+		StartTime: toTimeOfDay(clockTime(startLocal)),
+		EndTime:   toTimeOfDay(clockTime(endLocal)),
+	}
+	var days []time.Weekday
+	for _, day := range timeRange.GetDaysOfWeek() {
+		if weekday, ok := parseVisaDay(day); ok {
+			days = append(days, weekday)
+		}
+	}
+	for _, day := range shiftDays(days, shift) {
+		out.DaysOfWeek = append(out.DaysOfWeek, toDayOfWeek(day))
+	}
+	return out
+}
+
+// location returns the time zone of a time range
+func location(timeRange *crpb.TimeRange) (*time.Location, error) {
+	if gmt(timeRange) {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(timeRange.GetTimeZoneId())
+}
+
+// gmt returns true if a time range was set before the customer's time zone was kept in it
+func gmt(timeRange *crpb.TimeRange) bool {
+	return timeRange.GetTimeZoneId() == "" || timeRange.GetTimeZoneId() == visaTimeZone
+}
+
+// ReplacedControls returns the enabled controls in the existing document that a request replaces, nil if there are
+// none. Setting a control type replaces all of its controls, Visa updates those with the same time range in place and
+// the others must be deleted once the request is created.
+func ReplacedControls(request *crpb.ControlRequest, existing *crpb.Resource) *crpb.ControlRequest {
+	requested := timeRangesByType(request)
+
+	out := &crpb.ControlRequest{}
+	for _, c := range existing.GetTransactionControls() {
+		if c.GetIsControlEnabled() && replaced(requested, c.GetControlType(), c.GetTimeRange()) {
+			out.TransactionControls = append(out.TransactionControls, c)
+		}
+	}
+	for _, c := range existing.GetMerchantControls() {
+		if c.GetIsControlEnabled() && replaced(requested, c.GetControlType(), c.GetTimeRange()) {
+			out.MerchantControls = append(out.MerchantControls, c)
+		}
+	}
+
+	if out.TransactionControls == nil && out.MerchantControls == nil {
+		return nil
+	}
+	return out
+}
+
+// WithoutControls returns the document with the replaced controls removed, e.g. the document created by a request
+// before the controls it replaced are deleted
+func WithoutControls(document *crpb.Resource, replaced *crpb.ControlRequest) *crpb.Resource {
+	if document == nil || replaced == nil {
+		return document
+	}
+
+	removed := timeRangesByType(replaced)
+
+	out := proto.Clone(document).(*crpb.Resource)
+	out.TransactionControls = []*crpb.TransactionControl{}
+	for _, c := range document.GetTransactionControls() {
+		if !hasTimeRange(removed[c.GetControlType()], c.GetTimeRange()) {
+			out.TransactionControls = append(out.TransactionControls, c)
+		}
+	}
+	out.MerchantControls = []*crpb.MerchantControl{}
+	for _, c := range document.GetMerchantControls() {
+		if !hasTimeRange(removed[c.GetControlType()], c.GetTimeRange()) {
+			out.MerchantControls = append(out.MerchantControls, c)
+		}
+	}
+	return out
+}
+
+// timeRangesByType returns the time ranges of the transaction and merchant controls in a request by control type
+func timeRangesByType(request *crpb.ControlRequest) map[string][]*crpb.TimeRange {
+	out := make(map[string][]*crpb.TimeRange)
+	for _, c := range request.GetTransactionControls() {
+		out[c.GetControlType()] = append(out[c.GetControlType()], c.GetTimeRange())
+	}
+	for _, c := range request.GetMerchantControls() {
+		out[c.GetControlType()] = append(out[c.GetControlType()], c.GetTimeRange())
+	}
+	return out
+}
+
+// replaced returns true if a control is of a requested type but none of the requested controls has its time range
+func replaced(requested map[string][]*crpb.TimeRange, controlType string, timeRange *crpb.TimeRange) bool {
+	timeRanges, ok := requested[controlType]
+	return ok && !hasTimeRange(timeRanges, timeRange)
+}
+
+func hasTimeRange(timeRanges []*crpb.TimeRange, timeRange *crpb.TimeRange) bool {
+	for _, r := range timeRanges {
+		if proto.Equal(r, timeRange) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSchedules rejects requests with controls of the same type that would be active at the same time. Controls
+// without a time range are always active.
+func validateSchedules(ctx context.Context, controlTypes []ccpb.ControlType, timeRanges []*crpb.TimeRange) error {
+	for i := range timeRanges {
+		for j := i + 1; j < len(timeRanges); j++ {
+			if controlTypes[i] != controlTypes[j] || (timeRanges[i] == nil && timeRanges[j] == nil) {
+				continue
+			}
+			if overlaps(timeRanges[i], timeRanges[j]) {
+				return scheduleErr(ctx, "%s schedules overlap", controlTypes[i])
+			}
+		}
+	}
+	return nil
+}
+
+// overlaps compares the minutes of the week each time range covers in GMT
+func overlaps(a, b *crpb.TimeRange) bool {
+	if a == nil || b == nil {
+		return true
+	}
+	covered := make(map[int]bool)
+	for _, window := range windows(a) {
+		for m := window[0]; m < window[1]; m++ {
+			covered[m%minutesPerWeek] = true
+		}
+	}
+	for _, window := range windows(b) {
+		for m := window[0]; m < window[1]; m++ {
+			if covered[m%minutesPerWeek] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// windows returns the [start, end) minutes of the week in GMT a time range is active from its start days, a range
+// that ends before it starts runs past midnight
+func windows(timeRange *crpb.TimeRange) [][2]int {
+	start, errStart := time.Parse(clockFormat, timeRange.GetStartTime())
+	end, errEnd := time.Parse(clockFormat, timeRange.GetEndTime())
+	loc, errLoc := location(timeRange)
+	if errStart != nil || errEnd != nil || errLoc != nil {
+		return [][2]int{{0, minutesPerWeek}}
+	}
+
+	from := start.Hour()*60 + start.Minute()
+	length := end.Hour()*60 + end.Minute() - from
+	if length <= 0 {
+		length += minutesPerDay
+	}
+	fromGMT, shift := convert(from, loc, time.UTC)
+
+	var days []time.Weekday
+	for _, day := range timeRange.GetDaysOfWeek() {
+		if weekday, ok := parseVisaDay(day); ok {
+			days = append(days, weekday)
+		}
+	}
+	if len(days) == 0 {
+		days = []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday}
+	}
+
+	out := make([][2]int, 0, len(days))
+	for _, day := range shiftDays(days, shift) {
+		s := int(day)*minutesPerDay + fromGMT
+		out = append(out, [2]int{s, s + length})
+	}
+	return out
+}
+
+// convert moves a minute of the day between time zones on the current day, returning the minute of the day and the
+// number of days it moved by
+func convert(minute int, from, to *time.Location) (int, int) {
+	today := now().In(from)
+	t := time.Date(today.Year(), today.Month(), today.Day(), minute/60, minute%60, 0, 0, from)
+	converted := t.In(to)
+
+	fromDay := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	toDay := time.Date(converted.Year(), converted.Month(), converted.Day(), 0, 0, 0, 0, time.UTC)
+	return converted.Hour()*60 + converted.Minute(), int(toDay.Sub(fromDay).Hours() / 24)
+}
+
+// clockTime formats a minute of the day as 15:04
+func clockTime(minute int) string {
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
+
+func shiftDays(days []time.Weekday, shift int) []time.Weekday {
+	out := make([]time.Weekday, 0, len(days))
+	for _, day := range days {
+		out = append(out, time.Weekday((int(day)+shift+7)%7))
+	}
+	return out
+}
+
+func minuteOfDay(t *timeofday.TimeOfDay) (int, error) {
+	if t == nil {
+		return 0, fmt.Errorf("is required")
+	}
+	if t.GetHours() < 0 || t.GetHours() > 23 || t.GetMinutes() < 0 || t.GetMinutes() > 59 {
+		return 0, fmt.Errorf("%02d:%02d is not a valid time", t.GetHours(), t.GetMinutes())
+	}
+	if t.GetSeconds() != 0 || t.GetNanos() != 0 {
+		return 0, fmt.Errorf("must be a whole minute")
+	}
+	return int(t.GetHours())*60 + int(t.GetMinutes()), nil
+}
+
+func toTimeOfDay(clock string) *timeofday.TimeOfDay {
+	t, err := time.Parse(clockFormat, clock)
+	if err != nil {
+		return nil
+	}
+	return &timeofday.TimeOfDay{Hours: int32(t.Hour()), Minutes: int32(t.Minute())}
+}
+
+// toWeekday maps MONDAY (1) to SUNDAY (7) onto time.Weekday, where Sunday is 0
+func toWeekday(day dayofweek.DayOfWeek) time.Weekday {
+	return time.Weekday(int(day) % 7)
+}
+
+func toDayOfWeek(day time.Weekday) dayofweek.DayOfWeek {
+	return dayofweek.DayOfWeek((int(day)+6)%7 + 1)
+}
+
+// visaDay formats a weekday the way Visa expects, e.g. MON
+func visaDay(day time.Weekday) string {
+	return strings.ToUpper(day.String()[:3])
+}
+
+func parseVisaDay(day string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if visaDay(d) == strings.ToUpper(day) {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+func scheduleErr(ctx context.Context, format string, args ...interface{}) error {
+	return anzerrors.New(codes.InvalidArgument, invalidSchedule,
+		anzerrors.NewErrorInfo(ctx, anzcodes.ValidationFailure, fmt.Sprintf(format, args...)))
+}
@@ -0,0 +1,335 @@
+package customerrules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/type/dayofweek"
+	"google.golang.org/genproto/googleapis/type/timeofday"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/anzx/fabric-cards/pkg/feature"
+	"github.com/anzx/fabric-cards/pkg/util/testutil"
+	ccpb "github.com/anzx/fabricapis/pkg/fabric/service/cardcontrols/v1beta2"
+	crpb "github.com/anzx/fabricapis/pkg/gateway/visa/service/customerrules"
+	anzerrors "github.com/anzx/pkg/errors"
+)
+
+var weekdays = []dayofweek.DayOfWeek{
+	dayofweek.DayOfWeek_MONDAY, dayofweek.DayOfWeek_TUESDAY, dayofweek.DayOfWeek_WEDNESDAY,
+	dayofweek.DayOfWeek_THURSDAY, dayofweek.DayOfWeek_FRIDAY,
+}
+
+// at fixes the date time zone offsets are taken from, Melbourne is GMT+11 in January and GMT+10 in July
+func at(t *testing.T, date string) {
+	d, err := time.Parse("2006-01-02", date)
+	require.NoError(t, err)
+	now = func() time.Time { return d }
+	t.Cleanup(func() { now = time.Now })
+}
+
+func clock(hours, minutes int32) *timeofday.TimeOfDay {
+	return &timeofday.TimeOfDay{Hours: hours, Minutes: minutes}
+}
+
+func Test_timeRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		date     string
+		schedule *ccpb.ControlSchedule
+		global   bool
+		want     *crpb.TimeRange
+		wantErr  string
+	}{
+		{
+			name: "no schedule",
+			date: "2022-01-10",
+		},
+		{
+			name:     "overnight every day",
+			date:     "2022-01-10",
+			schedule: &ccpb.ControlSchedule{StartTime: clock(23, 0), EndTime: clock(6, 0)},
+			want:     &crpb.TimeRange{TimeZoneId: "Australia/Melbourne", StartTime: "23:00", EndTime: "06:00"},
+		},
+		{
+			name:     "weekdays",
+			date:     "2022-01-10",
+			schedule: &ccpb.ControlSchedule{TimeZone: "Australia/Melbourne", StartTime: clock(9, 0), EndTime: clock(17, 0), DaysOfWeek: weekdays},
+			want: &crpb.TimeRange{
+				TimeZoneId: "Australia/Melbourne", StartTime: "09:00", EndTime: "17:00",
+				DaysOfWeek: []string{"MON", "TUE", "WED", "THU", "FRI"},
+			},
+		},
+		{
+			name:     "daylight saving is left to the time zone",
+			date:     "2022-07-11",
+			schedule: &ccpb.ControlSchedule{TimeZone: "Australia/Melbourne", StartTime: clock(9, 0), EndTime: clock(17, 0), DaysOfWeek: weekdays},
+			want: &crpb.TimeRange{
+				TimeZoneId: "Australia/Melbourne", StartTime: "09:00", EndTime: "17:00",
+				DaysOfWeek: []string{"MON", "TUE", "WED", "THU", "FRI"},
+			},
+		},
+		{
+			name:     "customer time zone",
+			date:     "2022-01-10",
+			schedule: &ccpb.ControlSchedule{TimeZone: "Australia/Perth", StartTime: clock(9, 30), EndTime: clock(10, 0), DaysOfWeek: []dayofweek.DayOfWeek{dayofweek.DayOfWeek_SATURDAY}},
+			want:     &crpb.TimeRange{TimeZoneId: "Australia/Perth", StartTime: "09:30", EndTime: "10:00", DaysOfWeek: []string{"SAT"}},
+		},
+		{
+			name:     "global control",
+			date:     "2022-01-10",
+			global:   true,
+			schedule: &ccpb.ControlSchedule{StartTime: clock(9, 0), EndTime: clock(17, 0)},
+			wantErr:  "schedules can't be set on GCT_GLOBAL",
+		},
+		{
+			name:     "unknown time zone",
+			date:     "2022-01-10",
+			schedule: &ccpb.ControlSchedule{TimeZone: "Australia/Atlantis", StartTime: clock(9, 0), EndTime: clock(17, 0)},
+			wantErr:  "unknown time zone Australia/Atlantis",
+		},
+		{
+			name:     "missing start time",
+			date:     "2022-01-10",
+			schedule: &ccpb.ControlSchedule{EndTime: clock(17, 0)},
+			wantErr:  "start time is required",
+		},
+		{
+			name:     "invalid end time",
+			date:     "2022-01-10",
+			schedule: &ccpb.ControlSchedule{StartTime: clock(9, 0), EndTime: clock(24, 0)},
+			wantErr:  "end time 24:00 is not a valid time",
+		},
+		{
+			name:     "empty window",
+			date:     "2022-01-10",
+			schedule: &ccpb.ControlSchedule{StartTime: clock(9, 0), EndTime: clock(9, 0)},
+			wantErr:  "start and end time must be different",
+		},
+		{
+			name:     "unspecified day",
+			date:     "2022-01-10",
+			schedule: &ccpb.ControlSchedule{StartTime: clock(9, 0), EndTime: clock(17, 0), DaysOfWeek: []dayofweek.DayOfWeek{dayofweek.DayOfWeek_DAY_OF_WEEK_UNSPECIFIED}},
+			wantErr:  "is not supported",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			at(t, test.date)
+			request := &ccpb.ControlRequest{ControlType: ccpb.ControlType_TCT_E_COMMERCE, Schedule: test.schedule}
+			if test.global {
+				request.ControlType = ccpb.ControlType_GCT_GLOBAL
+			}
+
+			got, err := timeRange(testutil.GetContext(false), request)
+			if test.wantErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, codes.InvalidArgument, anzerrors.GetStatusCode(err))
+				assert.Contains(t, err.Error(), test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, proto.Equal(test.want, got), "want %v, got %v", test.want, got)
+		})
+	}
+}
+
+func TestGetSchedule(t *testing.T) {
+	at(t, "2022-01-10")
+	assert.Nil(t, GetSchedule(nil))
+
+	got := GetSchedule(&crpb.TimeRange{
+		TimeZoneId: "GMT", StartTime: "22:00", EndTime: "06:00",
+		DaysOfWeek: []string{"SUN", "MON", "TUE", "WED", "THU"},
+	})
+	want := &ccpb.ControlSchedule{
+		TimeZone:   DefaultTimeZone,
+		StartTime:  clock(9, 0),
+		EndTime:    clock(17, 0),
+		DaysOfWeek: weekdays,
+	}
+	assert.True(t, proto.Equal(want, got), "want %v, got %v", want, got)
+
+	perth := &crpb.TimeRange{TimeZoneId: "Australia/Perth", StartTime: "09:30", EndTime: "10:00", DaysOfWeek: []string{"SAT"}}
+	want = &ccpb.ControlSchedule{
+		TimeZone:   "Australia/Perth",
+		StartTime:  clock(9, 30),
+		EndTime:    clock(10, 0),
+		DaysOfWeek: []dayofweek.DayOfWeek{dayofweek.DayOfWeek_SATURDAY},
+	}
+	got = GetSchedule(perth)
+	assert.True(t, proto.Equal(want, got), "the customer's time zone is kept, want %v, got %v", want, got)
+	at(t, "2022-07-11")
+	got = GetSchedule(perth)
+	assert.True(t, proto.Equal(want, got), "the schedule doesn't move across daylight saving, want %v, got %v", want, got)
+
+	melbourne, err := timeRange(testutil.GetContext(false), &ccpb.ControlRequest{
+		ControlType: ccpb.ControlType_TCT_E_COMMERCE,
+		Schedule:    &ccpb.ControlSchedule{StartTime: clock(9, 0), EndTime: clock(17, 0), DaysOfWeek: weekdays},
+	})
+	require.NoError(t, err)
+	at(t, "2022-01-10")
+	got = GetSchedule(melbourne)
+	assert.Equal(t, DefaultTimeZone, got.GetTimeZone())
+	assert.True(t, proto.Equal(clock(9, 0), got.GetStartTime()), "a schedule set in winter is read the same in summer")
+
+	assert.Nil(t, GetSchedule(&crpb.TimeRange{StartTime: "9am", EndTime: "17:00"}), "unreadable time ranges are not returned")
+}
+
+func Test_validateSchedules(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     *crpb.TimeRange
+		sameType bool
+		wantErr  bool
+	}{
+		{
+			name:     "same window",
+			a:        &crpb.TimeRange{StartTime: "12:00", EndTime: "19:00"},
+			b:        &crpb.TimeRange{StartTime: "12:00", EndTime: "19:00"},
+			sameType: true,
+			wantErr:  true,
+		},
+		{
+			name:     "adjacent windows",
+			a:        &crpb.TimeRange{StartTime: "09:00", EndTime: "12:00"},
+			b:        &crpb.TimeRange{StartTime: "12:00", EndTime: "13:00"},
+			sameType: true,
+		},
+		{
+			name:     "overnight window overlaps the next morning",
+			a:        &crpb.TimeRange{StartTime: "22:00", EndTime: "02:00", DaysOfWeek: []string{"FRI"}},
+			b:        &crpb.TimeRange{StartTime: "01:00", EndTime: "03:00", DaysOfWeek: []string{"SAT"}},
+			sameType: true,
+			wantErr:  true,
+		},
+		{
+			name:     "overnight window wraps the end of the week",
+			a:        &crpb.TimeRange{StartTime: "23:00", EndTime: "01:00", DaysOfWeek: []string{"SAT"}},
+			b:        &crpb.TimeRange{StartTime: "00:30", EndTime: "02:00", DaysOfWeek: []string{"SUN"}},
+			sameType: true,
+			wantErr:  true,
+		},
+		{
+			name:     "different days",
+			a:        &crpb.TimeRange{StartTime: "09:00", EndTime: "17:00", DaysOfWeek: []string{"MON", "TUE"}},
+			b:        &crpb.TimeRange{StartTime: "09:00", EndTime: "17:00", DaysOfWeek: []string{"SAT", "SUN"}},
+			sameType: true,
+		},
+		{
+			name:     "always active control overlaps every schedule",
+			b:        &crpb.TimeRange{StartTime: "09:00", EndTime: "17:00"},
+			sameType: true,
+			wantErr:  true,
+		},
+		{
+			name:     "time zones are compared in GMT",
+			a:        &crpb.TimeRange{TimeZoneId: "Australia/Melbourne", StartTime: "09:00", EndTime: "17:00"},
+			b:        &crpb.TimeRange{TimeZoneId: "Australia/Perth", StartTime: "07:00", EndTime: "08:00"},
+			sameType: true,
+			wantErr:  true,
+		},
+		{
+			name:     "same clock time in different time zones",
+			a:        &crpb.TimeRange{TimeZoneId: "Australia/Melbourne", StartTime: "09:00", EndTime: "10:00"},
+			b:        &crpb.TimeRange{TimeZoneId: "Europe/London", StartTime: "09:00", EndTime: "10:00"},
+			sameType: true,
+		},
+		{
+			name: "different control types",
+			a:    &crpb.TimeRange{StartTime: "12:00", EndTime: "19:00"},
+			b:    &crpb.TimeRange{StartTime: "12:00", EndTime: "19:00"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			types := []ccpb.ControlType{ccpb.ControlType_TCT_E_COMMERCE, ccpb.ControlType_TCT_ATM_WITHDRAW}
+			if test.sameType {
+				types[1] = ccpb.ControlType_TCT_E_COMMERCE
+			}
+			err := validateSchedules(testutil.GetContext(false), types, []*crpb.TimeRange{test.a, test.b})
+			if test.wantErr {
+				require.Error(t, err)
+				assert.Equal(t, codes.InvalidArgument, anzerrors.GetStatusCode(err))
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestWithControls_Schedules(t *testing.T) {
+	at(t, "2022-01-10")
+	require.NoError(t, feature.FeatureGate.Set(map[feature.Feature]bool{feature.TCT_E_COMMERCE: true}))
+
+	night := &ccpb.ControlSchedule{StartTime: clock(23, 0), EndTime: clock(6, 0)}
+	day := &ccpb.ControlSchedule{StartTime: clock(9, 0), EndTime: clock(17, 0), DaysOfWeek: weekdays}
+	controls, err := WithControls(testutil.GetContext(false), []*ccpb.ControlRequest{
+		{ControlType: ccpb.ControlType_TCT_E_COMMERCE, Schedule: night},
+		{ControlType: ccpb.ControlType_TCT_E_COMMERCE, Schedule: day},
+	}, "id")
+	require.NoError(t, err)
+	request := ControlRequest(controls...)
+	require.Len(t, request.TransactionControls, 2)
+	assert.Equal(t, "23:00", request.TransactionControls[0].GetTimeRange().GetStartTime())
+	assert.Equal(t, "09:00", request.TransactionControls[1].GetTimeRange().GetStartTime())
+
+	_, err = WithControls(testutil.GetContext(false), []*ccpb.ControlRequest{
+		{ControlType: ccpb.ControlType_TCT_E_COMMERCE, Schedule: night},
+		{ControlType: ccpb.ControlType_TCT_E_COMMERCE, Schedule: &ccpb.ControlSchedule{StartTime: clock(5, 0), EndTime: clock(7, 0)}},
+	}, "id")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "TCT_E_COMMERCE schedules overlap")
+
+}
+
+func TestReplacedControls(t *testing.T) {
+	night := &crpb.TimeRange{TimeZoneId: DefaultTimeZone, StartTime: "23:00", EndTime: "06:00"}
+	day := &crpb.TimeRange{TimeZoneId: DefaultTimeZone, StartTime: "09:00", EndTime: "12:00"}
+	existing := &crpb.Resource{
+		TransactionControls: []*crpb.TransactionControl{
+			{ControlType: "TCT_E_COMMERCE", IsControlEnabled: true, TimeRange: night},
+			{ControlType: "TCT_E_COMMERCE", IsControlEnabled: true, TimeRange: day},
+			{ControlType: "TCT_ATM_WITHDRAW", IsControlEnabled: true},
+		},
+		MerchantControls: []*crpb.MerchantControl{
+			{ControlType: "MCT_GAMBLING", IsControlEnabled: true},
+		},
+	}
+
+	t.Run("controls with the same time range are updated in place", func(t *testing.T) {
+		request := &crpb.ControlRequest{
+			TransactionControls: []*crpb.TransactionControl{
+				{ControlType: "TCT_E_COMMERCE", TimeRange: night},
+				{ControlType: "TCT_E_COMMERCE", TimeRange: day},
+				{ControlType: "TCT_ATM_WITHDRAW", SpendLimit: &crpb.SpendLimit{Type: "LMT_DAY", DeclineThreshold: 500}},
+			},
+		}
+		assert.Nil(t, ReplacedControls(request, existing))
+	})
+
+	t.Run("controls of a requested type with other time ranges are replaced", func(t *testing.T) {
+		request := &crpb.ControlRequest{
+			TransactionControls: []*crpb.TransactionControl{
+				{ControlType: "TCT_E_COMMERCE", TimeRange: &crpb.TimeRange{TimeZoneId: DefaultTimeZone, StartTime: "22:00", EndTime: "07:00"}},
+				{ControlType: "TCT_ATM_WITHDRAW", TimeRange: day},
+			},
+		}
+		got := ReplacedControls(request, existing)
+		require.NotNil(t, got)
+		assert.Equal(t, existing.TransactionControls, got.TransactionControls)
+		assert.Empty(t, got.MerchantControls, "controls of other types are kept")
+
+		document := &crpb.Resource{TransactionControls: append(request.TransactionControls, existing.TransactionControls...)}
+		assert.Equal(t, request.TransactionControls, WithoutControls(document, got).TransactionControls)
+	})
+
+	t.Run("disabled controls are kept", func(t *testing.T) {
+		disabled := &crpb.Resource{MerchantControls: []*crpb.MerchantControl{{ControlType: "MCT_GAMBLING"}}}
+		request := &crpb.ControlRequest{MerchantControls: []*crpb.MerchantControl{{ControlType: "MCT_GAMBLING", TimeRange: day}}}
+		assert.Nil(t, ReplacedControls(request, disabled))
+	})
+}
@@ -0,0 +1,126 @@
+package customerrules
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"google.golang.org/genproto/googleapis/type/money"
+	"google.golang.org/grpc/codes"
+
+	ccpb "github.com/anzx/fabricapis/pkg/fabric/service/cardcontrols/v1beta2"
+	crpb "github.com/anzx/fabricapis/pkg/gateway/visa/service/customerrules"
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+)
+
+// SpendLimitCurrency is the billing currency of every card, Visa compares spend limits to the cardholder bill amount
+const SpendLimitCurrency = "AUD"
+
+const (
+	invalidSpendLimit = "invalid spend limit"
+	nanosPerUnit      = 1e9
+)
+
+// spendLimitTypes maps spend limit periods to the Visa spend limit type
+var spendLimitTypes = map[ccpb.SpendLimit_Period]string{
+	ccpb.SpendLimit_PERIOD_DAILY:   "LMT_DAY",
+	ccpb.SpendLimit_PERIOD_WEEKLY:  "LMT_WEEK",
+	ccpb.SpendLimit_PERIOD_MONTHLY: "LMT_MONTH",
+}
+
+// spendLimit returns the Visa spend limit of a control request, nil if the request has no spend limit
+func spendLimit(ctx context.Context, request *ccpb.ControlRequest) (*crpb.SpendLimit, error) {
+	limit := request.GetSpendLimit()
+	if limit == nil {
+		return nil, nil
+	}
+
+	if GetCategory(request.ControlType) == GLOBAL {
+		return nil, spendLimitErr(ctx, "spend limits can't be set on %s", request.ControlType)
+	}
+
+	limitType, ok := spendLimitTypes[limit.GetPeriod()]
+	if !ok {
+		return nil, spendLimitErr(ctx, "spend limit period %s is not supported", limit.GetPeriod())
+	}
+
+	amount := limit.GetAmount()
+	if amount.GetCurrencyCode() != SpendLimitCurrency {
+		return nil, spendLimitErr(ctx, "spend limit currency must be %s", SpendLimitCurrency)
+	}
+
+	threshold := toFloat(amount)
+	if threshold <= 0 {
+		return nil, spendLimitErr(ctx, "spend limit amount must be greater than zero")
+	}
+
+	return &crpb.SpendLimit{
+		Type:             limitType,
+		DeclineThreshold: threshold,
+	}, nil
+}
+
+// GetSpendLimit returns the spend limit of a Visa control, nil if the control has no spend limit
+func GetSpendLimit(limit *crpb.SpendLimit) *ccpb.SpendLimit {
+	if limit == nil {
+		return nil
+	}
+
+	out := &ccpb.SpendLimit{
+		Amount: toMoney(limit.GetDeclineThreshold()),
+	}
+	for period, limitType := range spendLimitTypes {
+		if limitType == limit.GetType() {
+			out.Period = period
+		}
+	}
+	if limit.CurrentPeriodSpend != nil {
+		out.CurrentPeriodSpend = toMoney(limit.GetCurrentPeriodSpend())
+	}
+	return out
+}
+
+// SameSpendLimit returns true if both controls decline at the same amount over the same period
+func SameSpendLimit(a, b *crpb.SpendLimit) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.GetType() == b.GetType() && toCents(a.GetDeclineThreshold()) == toCents(b.GetDeclineThreshold())
+}
+
+// Amount returns an amount in SpendLimitCurrency rounded to cents
+func Amount(amount float64) *money.Money {
+	return toMoney(amount)
+}
+
+// SameAmount returns true if both amounts are the same to the cent
+func SameAmount(a, b *money.Money) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.GetCurrencyCode() == b.GetCurrencyCode() && toCents(toFloat(a)) == toCents(toFloat(b))
+}
+
+func spendLimitErr(ctx context.Context, format string, args ...interface{}) error {
+	return anzerrors.New(codes.InvalidArgument, invalidSpendLimit,
+		anzerrors.NewErrorInfo(ctx, anzcodes.ValidationFailure, fmt.Sprintf(format, args...)))
+}
+
+func toFloat(amount *money.Money) float64 {
+	return float64(amount.GetUnits()) + float64(amount.GetNanos())/nanosPerUnit
+}
+
+// toMoney rounds an amount to cents
+func toMoney(amount float64) *money.Money {
+	cents := toCents(amount)
+	return &money.Money{
+		CurrencyCode: SpendLimitCurrency,
+		Units:        cents / 100,
+		Nanos:        int32(cents%100) * 1e7,
+	}
+}
+
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
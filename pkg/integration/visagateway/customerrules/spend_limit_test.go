@@ -0,0 +1,133 @@
+package customerrules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/type/money"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/anzx/fabric-cards/pkg/util/testutil"
+	ccpb "github.com/anzx/fabricapis/pkg/fabric/service/cardcontrols/v1beta2"
+	crpb "github.com/anzx/fabricapis/pkg/gateway/visa/service/customerrules"
+	anzerrors "github.com/anzx/pkg/errors"
+)
+
+func aud(units int64, nanos int32) *money.Money {
+	return &money.Money{CurrencyCode: SpendLimitCurrency, Units: units, Nanos: nanos}
+}
+
+func Test_spendLimit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		request *ccpb.ControlRequest
+		want    *crpb.SpendLimit
+		wantErr string
+	}{
+		{
+			name:    "no spend limit",
+			request: &ccpb.ControlRequest{ControlType: ccpb.ControlType_MCT_GAMBLING},
+		},
+		{
+			name: "daily limit",
+			request: &ccpb.ControlRequest{
+				ControlType: ccpb.ControlType_MCT_GAMBLING,
+				SpendLimit:  &ccpb.SpendLimit{Amount: aud(100, 500000000), Period: ccpb.SpendLimit_PERIOD_DAILY},
+			},
+			want: &crpb.SpendLimit{Type: "LMT_DAY", DeclineThreshold: 100.5},
+		},
+		{
+			name: "monthly limit",
+			request: &ccpb.ControlRequest{
+				ControlType: ccpb.ControlType_TCT_E_COMMERCE,
+				SpendLimit:  &ccpb.SpendLimit{Amount: aud(2000, 0), Period: ccpb.SpendLimit_PERIOD_MONTHLY},
+			},
+			want: &crpb.SpendLimit{Type: "LMT_MONTH", DeclineThreshold: 2000},
+		},
+		{
+			name: "global control",
+			request: &ccpb.ControlRequest{
+				ControlType: ccpb.ControlType_GCT_GLOBAL,
+				SpendLimit:  &ccpb.SpendLimit{Amount: aud(100, 0), Period: ccpb.SpendLimit_PERIOD_DAILY},
+			},
+			wantErr: "spend limits can't be set on GCT_GLOBAL",
+		},
+		{
+			name: "period not set",
+			request: &ccpb.ControlRequest{
+				ControlType: ccpb.ControlType_MCT_GAMBLING,
+				SpendLimit:  &ccpb.SpendLimit{Amount: aud(100, 0)},
+			},
+			wantErr: "is not supported",
+		},
+		{
+			name: "foreign currency",
+			request: &ccpb.ControlRequest{
+				ControlType: ccpb.ControlType_MCT_GAMBLING,
+				SpendLimit: &ccpb.SpendLimit{
+					Amount: &money.Money{CurrencyCode: "USD", Units: 100},
+					Period: ccpb.SpendLimit_PERIOD_WEEKLY,
+				},
+			},
+			wantErr: "spend limit currency must be AUD",
+		},
+		{
+			name: "negative amount",
+			request: &ccpb.ControlRequest{
+				ControlType: ccpb.ControlType_MCT_GAMBLING,
+				SpendLimit:  &ccpb.SpendLimit{Amount: aud(-1, 0), Period: ccpb.SpendLimit_PERIOD_WEEKLY},
+			},
+			wantErr: "spend limit amount must be greater than zero",
+		},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			got, err := spendLimit(testutil.GetContext(false), test.request)
+			if test.wantErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, codes.InvalidArgument, anzerrors.GetStatusCode(err))
+				assert.Contains(t, err.Error(), test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, proto.Equal(test.want, got), "want %v, got %v", test.want, got)
+		})
+	}
+}
+
+func TestGetSpendLimit(t *testing.T) {
+	assert.Nil(t, GetSpendLimit(nil))
+
+	spend := 12.345
+	got := GetSpendLimit(&crpb.SpendLimit{Type: "LMT_WEEK", DeclineThreshold: 250.1, CurrentPeriodSpend: &spend})
+	want := &ccpb.SpendLimit{
+		Amount:             aud(250, 100000000),
+		Period:             ccpb.SpendLimit_PERIOD_WEEKLY,
+		CurrentPeriodSpend: aud(12, 350000000),
+	}
+	assert.True(t, proto.Equal(want, got), "want %v, got %v", want, got)
+}
+
+func TestSameSpendLimit(t *testing.T) {
+	spend := 10.0
+	limit := &crpb.SpendLimit{Type: "LMT_DAY", DeclineThreshold: 100.1}
+
+	assert.True(t, SameSpendLimit(nil, nil))
+	assert.False(t, SameSpendLimit(limit, nil))
+	assert.False(t, SameSpendLimit(nil, limit))
+	assert.True(t, SameSpendLimit(limit, &crpb.SpendLimit{Type: "LMT_DAY", DeclineThreshold: 100.1, CurrentPeriodSpend: &spend}))
+	assert.False(t, SameSpendLimit(limit, &crpb.SpendLimit{Type: "LMT_WEEK", DeclineThreshold: 100.1}))
+	assert.False(t, SameSpendLimit(limit, &crpb.SpendLimit{Type: "LMT_DAY", DeclineThreshold: 100.2}))
+}
+
+func TestSameAmount(t *testing.T) {
+	assert.True(t, SameAmount(nil, nil))
+	assert.False(t, SameAmount(aud(10, 0), nil))
+	assert.True(t, SameAmount(Amount(10.004), aud(10, 0)), "amounts are compared to the cent")
+	assert.False(t, SameAmount(aud(10, 0), aud(10, 10000000)))
+	assert.False(t, SameAmount(aud(10, 0), &money.Money{CurrencyCode: "NZD", Units: 10}))
+}
@@ -3,6 +3,7 @@ package ops
 import (
 	"fmt"
 
+	"github.com/anzx/fabric-cards/pkg/feature/admin"
 	"github.com/anzx/pkg/opentelemetry"
 
 	flag "github.com/spf13/pflag"
@@ -12,6 +13,7 @@ import (
 type Spec struct {
 	Port          int                   `json:"port"                 yaml:"port"                 mapstructure:"port" validate:"required,gt=0"`
 	OpenTelemetry *opentelemetry.Config `json:"opentelemetry,omitempty" yaml:"opentelemetry,omitempty" mapstructure:"opentelemetry"`
+	Admin         *admin.Config         `json:"admin,omitempty"         yaml:"admin,omitempty"         mapstructure:"admin"`
 }
 
 const (
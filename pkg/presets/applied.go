@@ -0,0 +1,136 @@
+package presets
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	ccpb "github.com/anzx/fabricapis/pkg/fabric/service/cardcontrols/v1beta2"
+)
+
+const (
+	appliedGroup = "presets:applied:"
+	// appliedTTL is how long the controls a preset added are remembered after it was last applied, removing the preset
+	// later removes the controls on the card as the preset sets them
+	appliedTTL = 90 * 24 * time.Hour
+)
+
+// Record remembers the controls a preset added to a card, so removing the preset leaves the controls the customer had
+// set before. Controls added by an earlier apply are kept.
+func (p *Presets) Record(ctx context.Context, tokenizedCardNumber string, preset *Preset, added []ccpb.ControlType) error {
+	if p == nil || p.client == nil {
+		return nil
+	}
+
+	recorded, _, err := p.Added(ctx, tokenizedCardNumber, preset)
+	if err != nil {
+		return err
+	}
+	controlTypes := make([]string, 0, len(recorded)+len(added))
+	for _, controlType := range append(recorded, added...) {
+		controlTypes = append(controlTypes, controlType.String())
+	}
+
+	out, err := json.Marshal(controlTypes)
+	if err != nil {
+		return err
+	}
+	return p.client.Set(ctx, p.appliedKey(tokenizedCardNumber, preset), out, appliedTTL).Err()
+}
+
+// Added returns the controls a preset added to a card, false if it was not recorded when the preset was applied
+func (p *Presets) Added(ctx context.Context, tokenizedCardNumber string, preset *Preset) ([]ccpb.ControlType, bool, error) {
+	if p == nil || p.client == nil {
+		return nil, false, nil
+	}
+
+	payload, err := p.client.Get(ctx, p.appliedKey(tokenizedCardNumber, preset)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var recorded []string
+	if err := json.Unmarshal(payload, &recorded); err != nil {
+		return nil, false, err
+	}
+
+	// only control types the preset still sets are returned, each once
+	var out []ccpb.ControlType
+	for _, controlType := range preset.ControlTypes() {
+		for _, r := range recorded {
+			if r == controlType.String() {
+				out = append(out, controlType)
+				break
+			}
+		}
+	}
+	return out, true, nil
+}
+
+// Forget drops the controls recorded for a preset once it is removed from a card
+func (p *Presets) Forget(ctx context.Context, tokenizedCardNumber string, preset *Preset) error {
+	if p == nil || p.client == nil {
+		return nil
+	}
+	return p.client.Del(ctx, p.appliedKey(tokenizedCardNumber, preset)).Err()
+}
+
+// Matching returns the control types of a preset that are on a card as the preset sets them, used when it was not
+// recorded which of them the preset added
+func (p *Preset) Matching(controls []*ccpb.CardControl) []ccpb.ControlType {
+	var out []ccpb.ControlType
+	for _, controlType := range p.ControlTypes() {
+		on := true
+		for _, request := range p.controls {
+			if request.GetControlType() == controlType && !onCard(controls, request) {
+				on = false
+				break
+			}
+		}
+		if on {
+			out = append(out, controlType)
+		}
+	}
+	return out
+}
+
+// Conflicting returns the control types of a preset a card has set differently to the preset. Applying the preset
+// would replace them and removing it could not put them back.
+func (p *Preset) Conflicting(controls []*ccpb.CardControl) []ccpb.ControlType {
+	var out []ccpb.ControlType
+	for _, control := range controls {
+		var requests []*ccpb.ControlRequest
+		for _, request := range p.controls {
+			if request.GetControlType() == control.GetControlType() {
+				requests = append(requests, request)
+			}
+		}
+		if len(requests) > 0 && !setAs(control, requests) {
+			out = append(out, control.GetControlType())
+		}
+	}
+	return out
+}
+
+// setAs returns true if a control is set exactly as the requests of its type set it, with no other schedules
+func setAs(control *ccpb.CardControl, requests []*ccpb.ControlRequest) bool {
+	schedules := 0
+	for _, request := range requests {
+		if !matches(control, request) {
+			return false
+		}
+		if request.GetSchedule() != nil {
+			schedules++
+		}
+	}
+	return len(control.GetSchedules()) == schedules
+}
+
+func (p *Presets) appliedKey(tokenizedCardNumber string, preset *Preset) string {
+	return p.prefix + appliedGroup + tokenizedCardNumber + ":" + preset.ID
+}
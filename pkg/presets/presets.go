@@ -0,0 +1,290 @@
+// Package presets expands named bundles of card controls defined in config, e.g. "travel mode", into the controls
+// they set.
+package presets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/type/dayofweek"
+	"google.golang.org/genproto/googleapis/type/timeofday"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/anzx/fabric-cards/pkg/integration/visagateway/customerrules"
+	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
+	ccpb "github.com/anzx/fabricapis/pkg/fabric/service/cardcontrols/v1beta2"
+)
+
+const clockFormat = "15:04"
+
+type Config struct {
+	// ID the preset is applied by, e.g. travel
+	ID string `json:"id" yaml:"id" mapstructure:"id" validate:"required"`
+	// DisplayName is shown to customers while the preset is on, e.g. Travel mode
+	DisplayName string          `json:"displayName" yaml:"displayName" mapstructure:"displayName" validate:"required"`
+	Controls    []ControlConfig `json:"controls" yaml:"controls" mapstructure:"controls" validate:"required,min=1"`
+}
+
+// ControlConfig is a control set by a preset, a control type can be repeated with different schedules
+type ControlConfig struct {
+	ControlType string            `json:"controlType" yaml:"controlType" mapstructure:"controlType" validate:"required"`
+	SpendLimit  *SpendLimitConfig `json:"spendLimit,omitempty" yaml:"spendLimit,omitempty" mapstructure:"spendLimit"`
+	Schedule    *ScheduleConfig   `json:"schedule,omitempty" yaml:"schedule,omitempty" mapstructure:"schedule"`
+	AlertOnly   bool              `json:"alertOnly,omitempty" yaml:"alertOnly,omitempty" mapstructure:"alertOnly"`
+	// AlertThreshold in AUD, transactions below it are not alerted on
+	AlertThreshold *float64 `json:"alertThreshold,omitempty" yaml:"alertThreshold,omitempty" mapstructure:"alertThreshold"`
+}
+
+type SpendLimitConfig struct {
+	// Amount in AUD
+	Amount float64 `json:"amount" yaml:"amount" mapstructure:"amount" validate:"gt=0"`
+	// Period is DAILY, WEEKLY or MONTHLY
+	Period string `json:"period" yaml:"period" mapstructure:"period" validate:"required"`
+}
+
+// ScheduleConfig restricts a control to a time of day in customerrules.DefaultTimeZone
+type ScheduleConfig struct {
+	// Start and End are formatted as 15:04
+	Start string `json:"start" yaml:"start" mapstructure:"start" validate:"required"`
+	End   string `json:"end" yaml:"end" mapstructure:"end" validate:"required"`
+	// Days the schedule starts on, e.g. MONDAY, every day if empty
+	Days []string `json:"days,omitempty" yaml:"days,omitempty" mapstructure:"days"`
+}
+
+// Preset is a named bundle of controls
+type Preset struct {
+	ID          string
+	DisplayName string
+	controls    []*ccpb.ControlRequest
+}
+
+// Presets are looked up by ID
+type Presets struct {
+	presets []*Preset
+	byID    map[string]*Preset
+	// client records the controls each preset added to a card, see Record
+	client redis.Cmdable
+	// prefix is added to every key client records in
+	prefix string
+}
+
+// New returns nil if no presets are configured, presets can't be applied in that case. Without client it is not
+// recorded which controls a preset added, removing a preset then removes the controls on the card as it sets them.
+// Every key recorded in client starts with prefix.
+func New(ctx context.Context, configs []Config, client redis.Cmdable, prefix string) (*Presets, error) {
+	if len(configs) == 0 {
+		logf.Debug(ctx, "presets not configured")
+		return nil, nil
+	}
+
+	p := &Presets{byID: make(map[string]*Preset, len(configs)), client: client, prefix: prefix}
+	for _, config := range configs {
+		if _, ok := p.byID[config.ID]; ok {
+			return nil, fmt.Errorf("preset %s is defined more than once", config.ID)
+		}
+		preset, err := newPreset(config)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid preset %s", config.ID)
+		}
+		p.presets = append(p.presets, preset)
+		p.byID[preset.ID] = preset
+	}
+	return p, nil
+}
+
+// Get returns the preset with an ID
+func (p *Presets) Get(id string) (*Preset, bool) {
+	if p == nil {
+		return nil, false
+	}
+	preset, ok := p.byID[id]
+	return preset, ok
+}
+
+// Active returns the presets whose controls are all on a card
+func (p *Presets) Active(controls []*ccpb.CardControl) []*ccpb.ControlPreset {
+	if p == nil {
+		return nil
+	}
+	var out []*ccpb.ControlPreset
+	for _, preset := range p.presets {
+		if preset.on(controls) {
+			out = append(out, preset.ControlPreset())
+		}
+	}
+	return out
+}
+
+// ControlRequests returns the controls a preset sets
+func (p *Preset) ControlRequests() []*ccpb.ControlRequest {
+	out := make([]*ccpb.ControlRequest, 0, len(p.controls))
+	for _, control := range p.controls {
+		out = append(out, proto.Clone(control).(*ccpb.ControlRequest))
+	}
+	return out
+}
+
+// ControlTypes returns each control type a preset sets once
+func (p *Preset) ControlTypes() []ccpb.ControlType {
+	var out []ccpb.ControlType
+	seen := make(map[ccpb.ControlType]bool, len(p.controls))
+	for _, control := range p.controls {
+		if !seen[control.GetControlType()] {
+			seen[control.GetControlType()] = true
+			out = append(out, control.GetControlType())
+		}
+	}
+	return out
+}
+
+// ControlPreset returns a preset as it is shown to customers
+func (p *Preset) ControlPreset() *ccpb.ControlPreset {
+	return &ccpb.ControlPreset{Id: p.ID, DisplayName: p.DisplayName}
+}
+
+// on returns true if every control of the preset is on the card as the preset sets it
+func (p *Preset) on(controls []*ccpb.CardControl) bool {
+	for _, request := range p.controls {
+		if !onCard(controls, request) {
+			return false
+		}
+	}
+	return true
+}
+
+// onCard returns true if a control is on the card as request sets it
+func onCard(controls []*ccpb.CardControl, request *ccpb.ControlRequest) bool {
+	for _, control := range controls {
+		if matches(control, request) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(control *ccpb.CardControl, request *ccpb.ControlRequest) bool {
+	if control.GetControlType() != request.GetControlType() ||
+		control.GetAlertOnly() != request.GetAlertOnly() ||
+		!customerrules.SameAmount(control.GetAlertThreshold(), request.GetAlertThreshold()) {
+		return false
+	}
+
+	if (control.GetSpendLimit() == nil) != (request.GetSpendLimit() == nil) {
+		return false
+	}
+	if request.GetSpendLimit() != nil && (control.GetSpendLimit().GetPeriod() != request.GetSpendLimit().GetPeriod() ||
+		!customerrules.SameAmount(control.GetSpendLimit().GetAmount(), request.GetSpendLimit().GetAmount())) {
+		return false
+	}
+
+	if request.GetSchedule() == nil {
+		return len(control.GetSchedules()) == 0
+	}
+	for _, schedule := range control.GetSchedules() {
+		if sameSchedule(schedule, request.GetSchedule()) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameSchedule compares schedules by time zone, clock time and days
+func sameSchedule(a, b *ccpb.ControlSchedule) bool {
+	if a.GetTimeZone() != b.GetTimeZone() || !proto.Equal(a.GetStartTime(), b.GetStartTime()) || !proto.Equal(a.GetEndTime(), b.GetEndTime()) ||
+		len(a.GetDaysOfWeek()) != len(b.GetDaysOfWeek()) {
+		return false
+	}
+	days := make(map[dayofweek.DayOfWeek]bool, len(a.GetDaysOfWeek()))
+	for _, day := range a.GetDaysOfWeek() {
+		days[day] = true
+	}
+	for _, day := range b.GetDaysOfWeek() {
+		if !days[day] {
+			return false
+		}
+	}
+	return true
+}
+
+func newPreset(config Config) (*Preset, error) {
+	if config.ID == "" || config.DisplayName == "" {
+		return nil, errors.New("id and display name are required")
+	}
+	if len(config.Controls) == 0 {
+		return nil, errors.New("no controls")
+	}
+
+	preset := &Preset{ID: config.ID, DisplayName: config.DisplayName}
+	for _, c := range config.Controls {
+		request, err := controlRequest(c)
+		if err != nil {
+			return nil, err
+		}
+		preset.controls = append(preset.controls, request)
+	}
+	return preset, nil
+}
+
+func controlRequest(c ControlConfig) (*ccpb.ControlRequest, error) {
+	controlType, ok := ccpb.ControlType_value[c.ControlType]
+	if !ok || controlType == int32(ccpb.ControlType_UNKNOWN_UNSPECIFIED) {
+		return nil, fmt.Errorf("unknown control type %s", c.ControlType)
+	}
+
+	request := &ccpb.ControlRequest{
+		ControlType: ccpb.ControlType(controlType),
+		AlertOnly:   c.AlertOnly,
+	}
+	if c.AlertThreshold != nil {
+		request.AlertThreshold = customerrules.Amount(*c.AlertThreshold)
+	}
+
+	if c.SpendLimit != nil {
+		period, ok := ccpb.SpendLimit_Period_value["PERIOD_"+strings.ToUpper(c.SpendLimit.Period)]
+		if !ok || period == int32(ccpb.SpendLimit_PERIOD_UNSPECIFIED) {
+			return nil, fmt.Errorf("unknown spend limit period %s", c.SpendLimit.Period)
+		}
+		request.SpendLimit = &ccpb.SpendLimit{
+			Amount: customerrules.Amount(c.SpendLimit.Amount),
+			Period: ccpb.SpendLimit_Period(period),
+		}
+	}
+
+	if c.Schedule != nil {
+		schedule, err := controlSchedule(c.Schedule)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid schedule of %s", c.ControlType)
+		}
+		request.Schedule = schedule
+	}
+	return request, nil
+}
+
+func controlSchedule(c *ScheduleConfig) (*ccpb.ControlSchedule, error) {
+	start, err := time.Parse(clockFormat, c.Start)
+	if err != nil {
+		return nil, fmt.Errorf("start %s is not formatted as %s", c.Start, clockFormat)
+	}
+	end, err := time.Parse(clockFormat, c.End)
+	if err != nil {
+		return nil, fmt.Errorf("end %s is not formatted as %s", c.End, clockFormat)
+	}
+
+	schedule := &ccpb.ControlSchedule{
+		TimeZone:  customerrules.DefaultTimeZone,
+		StartTime: &timeofday.TimeOfDay{Hours: int32(start.Hour()), Minutes: int32(start.Minute())},
+		EndTime:   &timeofday.TimeOfDay{Hours: int32(end.Hour()), Minutes: int32(end.Minute())},
+	}
+	for _, d := range c.Days {
+		day, ok := dayofweek.DayOfWeek_value[strings.ToUpper(d)]
+		if !ok || day == int32(dayofweek.DayOfWeek_DAY_OF_WEEK_UNSPECIFIED) {
+			return nil, fmt.Errorf("unknown day %s", d)
+		}
+		schedule.DaysOfWeek = append(schedule.DaysOfWeek, dayofweek.DayOfWeek(day))
+	}
+	return schedule, nil
+}
@@ -0,0 +1,215 @@
+package presets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/type/dayofweek"
+	"google.golang.org/genproto/googleapis/type/timeofday"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/anzx/fabric-cards/pkg/integration/visagateway/customerrules"
+	ccpb "github.com/anzx/fabricapis/pkg/fabric/service/cardcontrols/v1beta2"
+)
+
+func travel() Config {
+	return Config{
+		ID:          "travel",
+		DisplayName: "Travel mode",
+		Controls: []ControlConfig{
+			{ControlType: "TCT_ATM_WITHDRAW", SpendLimit: &SpendLimitConfig{Amount: 500, Period: "daily"}},
+			{ControlType: "TCT_E_COMMERCE", Schedule: &ScheduleConfig{Start: "23:00", End: "06:00", Days: []string{"SATURDAY"}}},
+		},
+	}
+}
+
+func TestNew(t *testing.T) {
+	p, err := New(context.Background(), nil, nil, "")
+	require.NoError(t, err)
+	assert.Nil(t, p)
+	_, ok := p.Get("travel")
+	assert.False(t, ok, "presets can't be applied without config")
+
+	p, err = New(context.Background(), []Config{travel()}, nil, "")
+	require.NoError(t, err)
+	preset, ok := p.Get("travel")
+	require.True(t, ok)
+
+	want := []*ccpb.ControlRequest{
+		{
+			ControlType: ccpb.ControlType_TCT_ATM_WITHDRAW,
+			SpendLimit:  &ccpb.SpendLimit{Amount: customerrules.Amount(500), Period: ccpb.SpendLimit_PERIOD_DAILY},
+		},
+		{
+			ControlType: ccpb.ControlType_TCT_E_COMMERCE,
+			Schedule: &ccpb.ControlSchedule{
+				TimeZone:   customerrules.DefaultTimeZone,
+				StartTime:  &timeofday.TimeOfDay{Hours: 23},
+				EndTime:    &timeofday.TimeOfDay{Hours: 6},
+				DaysOfWeek: []dayofweek.DayOfWeek{dayofweek.DayOfWeek_SATURDAY},
+			},
+		},
+	}
+	got := preset.ControlRequests()
+	require.Len(t, got, len(want))
+	for i := range want {
+		assert.True(t, proto.Equal(want[i], got[i]), "want %v, got %v", want[i], got[i])
+	}
+	assert.Equal(t, []ccpb.ControlType{ccpb.ControlType_TCT_ATM_WITHDRAW, ccpb.ControlType_TCT_E_COMMERCE}, preset.ControlTypes())
+}
+
+func TestNew_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  func(c *Config)
+		wantErr string
+	}{
+		{
+			name:    "unknown control type",
+			config:  func(c *Config) { c.Controls[0].ControlType = "TCT_TELEPORT" },
+			wantErr: "unknown control type TCT_TELEPORT",
+		},
+		{
+			name:    "unknown period",
+			config:  func(c *Config) { c.Controls[0].SpendLimit.Period = "fortnightly" },
+			wantErr: "unknown spend limit period fortnightly",
+		},
+		{
+			name:    "invalid start",
+			config:  func(c *Config) { c.Controls[1].Schedule.Start = "11pm" },
+			wantErr: "start 11pm is not formatted as 15:04",
+		},
+		{
+			name:    "unknown day",
+			config:  func(c *Config) { c.Controls[1].Schedule.Days = []string{"SAT"} },
+			wantErr: "unknown day SAT",
+		},
+		{
+			name:    "no controls",
+			config:  func(c *Config) { c.Controls = nil },
+			wantErr: "no controls",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := travel()
+			test.config(&config)
+			_, err := New(context.Background(), []Config{config}, nil, "")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.wantErr)
+		})
+	}
+
+	_, err := New(context.Background(), []Config{travel(), travel()}, nil, "")
+	assert.EqualError(t, err, "preset travel is defined more than once")
+}
+
+func TestPresets_Active(t *testing.T) {
+	p, err := New(context.Background(), []Config{travel(), {
+		ID:          "lock",
+		DisplayName: "Card locked",
+		Controls:    []ControlConfig{{ControlType: "GCT_GLOBAL"}},
+	}}, nil, "")
+	require.NoError(t, err)
+
+	atm := &ccpb.CardControl{
+		ControlType: ccpb.ControlType_TCT_ATM_WITHDRAW,
+		SpendLimit: &ccpb.SpendLimit{
+			Amount:             customerrules.Amount(500),
+			Period:             ccpb.SpendLimit_PERIOD_DAILY,
+			CurrentPeriodSpend: customerrules.Amount(20),
+		},
+	}
+	online := &ccpb.CardControl{
+		ControlType: ccpb.ControlType_TCT_E_COMMERCE,
+		Schedules: []*ccpb.ControlSchedule{
+			{StartTime: &timeofday.TimeOfDay{Hours: 9}, EndTime: &timeofday.TimeOfDay{Hours: 17}},
+			{
+				TimeZone:   customerrules.DefaultTimeZone,
+				StartTime:  &timeofday.TimeOfDay{Hours: 23},
+				EndTime:    &timeofday.TimeOfDay{Hours: 6},
+				DaysOfWeek: []dayofweek.DayOfWeek{dayofweek.DayOfWeek_SATURDAY},
+			},
+		},
+	}
+
+	active := p.Active([]*ccpb.CardControl{atm, online})
+	require.Len(t, active, 1)
+	assert.Equal(t, "travel", active[0].GetId())
+	assert.Equal(t, "Travel mode", active[0].GetDisplayName())
+
+	assert.Empty(t, p.Active([]*ccpb.CardControl{atm}), "every control of a preset must be on")
+
+	otherLimit := proto.Clone(atm).(*ccpb.CardControl)
+	otherLimit.SpendLimit.Amount = customerrules.Amount(100)
+	assert.Empty(t, p.Active([]*ccpb.CardControl{otherLimit, online}), "controls must be set as the preset sets them")
+
+	active = p.Active([]*ccpb.CardControl{{ControlType: ccpb.ControlType_GCT_GLOBAL}})
+	require.Len(t, active, 1)
+	assert.Equal(t, "lock", active[0].GetId())
+}
+
+func TestPresets_Record(t *testing.T) {
+	ctx := context.Background()
+	m, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(m.Close)
+
+	p, err := New(ctx, []Config{travel()}, redis.NewClient(&redis.Options{Addr: m.Addr()}), "st:")
+	require.NoError(t, err)
+	preset, _ := p.Get("travel")
+
+	_, ok, err := p.Added(ctx, "card", preset)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, p.Record(ctx, "card", preset, nil))
+	added, ok, err := p.Added(ctx, "card", preset)
+	require.NoError(t, err)
+	assert.True(t, ok, "a preset applied over the customer's controls is recorded")
+	assert.Empty(t, added)
+
+	require.NoError(t, p.Record(ctx, "card", preset, []ccpb.ControlType{ccpb.ControlType_TCT_E_COMMERCE}))
+	added, _, err = p.Added(ctx, "card", preset)
+	require.NoError(t, err)
+	assert.Equal(t, []ccpb.ControlType{ccpb.ControlType_TCT_E_COMMERCE}, added)
+	assert.Equal(t, appliedTTL, m.TTL("st:presets:applied:card:travel"), "records are prefixed and expire")
+
+	require.NoError(t, p.Forget(ctx, "card", preset))
+	_, ok, err = p.Added(ctx, "card", preset)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestPreset_Conflicting(t *testing.T) {
+	p, err := New(context.Background(), []Config{travel()}, nil, "")
+	require.NoError(t, err)
+	preset, _ := p.Get("travel")
+
+	atm := &ccpb.CardControl{
+		ControlType: ccpb.ControlType_TCT_ATM_WITHDRAW,
+		SpendLimit:  &ccpb.SpendLimit{Amount: customerrules.Amount(500), Period: ccpb.SpendLimit_PERIOD_DAILY},
+	}
+	night := &ccpb.ControlSchedule{
+		TimeZone:   customerrules.DefaultTimeZone,
+		StartTime:  &timeofday.TimeOfDay{Hours: 23},
+		EndTime:    &timeofday.TimeOfDay{Hours: 6},
+		DaysOfWeek: []dayofweek.DayOfWeek{dayofweek.DayOfWeek_SATURDAY},
+	}
+	online := &ccpb.CardControl{ControlType: ccpb.ControlType_TCT_E_COMMERCE, Schedules: []*ccpb.ControlSchedule{night}}
+	gambling := &ccpb.CardControl{ControlType: ccpb.ControlType_MCT_GAMBLING}
+
+	assert.Empty(t, preset.Conflicting([]*ccpb.CardControl{atm, online, gambling}), "controls set as the preset sets them")
+
+	unlimited := &ccpb.CardControl{ControlType: ccpb.ControlType_TCT_ATM_WITHDRAW}
+	assert.Equal(t, []ccpb.ControlType{ccpb.ControlType_TCT_ATM_WITHDRAW}, preset.Conflicting([]*ccpb.CardControl{unlimited}))
+
+	daytime := &ccpb.ControlSchedule{TimeZone: customerrules.DefaultTimeZone, StartTime: &timeofday.TimeOfDay{Hours: 9}, EndTime: &timeofday.TimeOfDay{Hours: 17}}
+	online.Schedules = append(online.Schedules, daytime)
+	assert.Equal(t, []ccpb.ControlType{ccpb.ControlType_TCT_E_COMMERCE}, preset.Conflicting([]*ccpb.CardControl{online}),
+		"a schedule the preset does not set would be replaced")
+}
@@ -0,0 +1,180 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"google.golang.org/grpc/codes"
+
+	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+)
+
+// FailurePolicy defines how a limit is enforced while Redis is unavailable
+type FailurePolicy string
+
+const (
+	// FailOpen enforces the limit with an in-process token bucket per instance, this is the default
+	FailOpen FailurePolicy = "open"
+	// FailClosed rejects every request until Redis is available again
+	FailClosed FailurePolicy = "closed"
+)
+
+const (
+	defaultProbeInterval = 5 * time.Second
+	// maxLocalBuckets bounds the memory used by the fallback, buckets are dropped once it is reached
+	maxLocalBuckets = 10000
+)
+
+var (
+	meter = metric.Must(global.Meter("github.com/anzx/fabric-cards/pkg/ratelimit"))
+
+	fallbackCounter = meter.NewInt64Counter("ratelimit.fallback.requests",
+		metric.WithDescription("Requests rate limited in process while Redis is unavailable"))
+	availabilityCounter = meter.NewInt64Counter("ratelimit.redis.availability",
+		metric.WithDescription("Changes of Redis availability seen by the rate limiter"))
+)
+
+// bucket is a token bucket refilled continuously at rate tokens per period up to burst
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// localBuckets is the in-process fallback, its zero value is ready to use
+type localBuckets struct {
+	lock    sync.Mutex
+	buckets map[string]*bucket
+}
+
+// allow takes a token for key and returns the whole tokens remaining, or how long until one is available if the
+// bucket is empty
+func (l *localBuckets) allow(key string, limitConfig LimitConfig, now time.Time) (int, time.Duration, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.buckets == nil || len(l.buckets) >= maxLocalBuckets {
+		l.buckets = map[string]*bucket{}
+	}
+
+	burst := float64(limitConfig.Rate)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	perToken := time.Duration(0)
+	if limitConfig.Rate > 0 {
+		perToken = limitConfig.Period / time.Duration(limitConfig.Rate)
+	}
+	if perToken > 0 {
+		b.tokens = math.Min(burst, b.tokens+float64(now.Sub(b.last))/float64(perToken))
+	} else {
+		b.tokens = burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return 0, time.Duration((1 - b.tokens) * float64(perToken)), false
+	}
+	b.tokens--
+
+	return int(b.tokens), 0, true
+}
+
+func (l *localBuckets) reset() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.buckets = nil
+}
+
+// available returns false once a Redis call has failed and until the next successful probe
+func (r *RedisRateLimit) available() bool {
+	return atomic.LoadInt32(&r.unavailable) == 0
+}
+
+func (r *RedisRateLimit) setAvailable(ctx context.Context, available bool) {
+	var unavailable int32
+	if !available {
+		unavailable = 1
+	}
+	if atomic.SwapInt32(&r.unavailable, unavailable) == unavailable {
+		return
+	}
+
+	if available {
+		logf.Info(ctx, "ratelimit: redis is available, in process fallback stopped")
+		// local counts are discarded so they don't linger until the next outage
+		r.local.reset()
+	} else {
+		logf.Info(ctx, "ratelimit: redis is unavailable, falling back to in process limits")
+	}
+	availabilityCounter.Add(ctx, 1, attribute.Bool("available", available))
+}
+
+// fallback enforces limitConfig in process according to its failure policy
+func (r *RedisRateLimit) fallback(ctx context.Context, name string, key string, limitConfig LimitConfig) error {
+	policy := limitConfig.OnFailure
+	if policy == "" {
+		policy = FailOpen
+	}
+	policy = FailurePolicy(strings.ToLower(string(policy)))
+
+	if policy == FailClosed {
+		fallbackCounter.Add(ctx, 1, attribute.String("name", name), attribute.String("policy", string(policy)),
+			attribute.Bool("allowed", false))
+		return anzerrors.New(codes.Unavailable, "rate limit check failed",
+			anzerrors.NewErrorInfo(ctx, anzcodes.DownstreamFailure, "rate limiter unavailable"),
+			anzerrors.WithRetryDelay(r.probeInterval()))
+	}
+
+	remaining, retryAfter, ok := r.local.allow(key, limitConfig, time.Now())
+	fallbackCounter.Add(ctx, 1, attribute.String("name", name), attribute.String("policy", string(policy)),
+		attribute.Bool("allowed", ok))
+	setQuota(ctx, limitConfig.Rate, remaining, retryAfter)
+	if !ok {
+		return exhausted(ctx, retryAfter)
+	}
+
+	return nil
+}
+
+func (r *RedisRateLimit) probeInterval() time.Duration {
+	if r.ProbeInterval > 0 {
+		return r.ProbeInterval
+	}
+	return defaultProbeInterval
+}
+
+// probe pings Redis every probe interval until ctx is done, marking it available again once a ping succeeds
+func (r *RedisRateLimit) probe(ctx context.Context, client redis.Cmdable) {
+	ticker := time.NewTicker(r.probeInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if r.available() {
+				continue
+			}
+			if err := client.Ping(ctx).Err(); err != nil {
+				logf.Debug(ctx, "ratelimit: redis probe failed: %v", err)
+				continue
+			}
+			r.setAvailable(ctx, true)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/anzx/pkg/gsm"
+	"github.com/go-redis/redis/v8"
+	"github.com/go-redis/redis_rate/v9"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	secretmanagerpb "google.golang.org/genproto/googleapis/cloud/secretmanager/v1"
+
+	"github.com/anzx/fabric-cards/pkg/util/testutil"
+)
+
+func TestLocalBuckets(t *testing.T) {
+	t.Parallel()
+
+	var l localBuckets
+	limit := LimitConfig{Rate: 2, Period: time.Minute}
+	now := time.Now()
+
+	remaining, _, ok := l.allow("key", limit, now)
+	assert.True(t, ok)
+	assert.Equal(t, 1, remaining)
+	remaining, _, ok = l.allow("key", limit, now)
+	assert.True(t, ok)
+	assert.Equal(t, 0, remaining)
+
+	_, retryAfter, ok := l.allow("key", limit, now)
+	assert.False(t, ok)
+	assert.Equal(t, 30*time.Second, retryAfter)
+
+	// other keys have their own bucket
+	_, _, ok = l.allow("other", limit, now)
+	assert.True(t, ok)
+
+	// a token is refilled every period / rate
+	_, _, ok = l.allow("key", limit, now.Add(30*time.Second))
+	assert.True(t, ok)
+	_, _, ok = l.allow("key", limit, now.Add(30*time.Second))
+	assert.False(t, ok)
+}
+
+func TestRateLimit_Fallback(t *testing.T) {
+	t.Parallel()
+
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	defer s.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: s.Addr(), MaxRetries: -1})
+	client := &RedisRateLimit{
+		Prefix: "st",
+		Limits: map[Domain]LimitConfig{
+			Activate:  {Rate: 1, Period: time.Minute},
+			VerifyPIN: {Rate: 1, Period: time.Minute, OnFailure: FailClosed},
+		},
+		ProbeInterval: 10 * time.Millisecond,
+		Limiter:       redis_rate.NewLimiter(redisClient),
+	}
+
+	ctx, cancel := context.WithCancel(testutil.GetContext(false))
+	defer cancel()
+	go client.probe(ctx, redisClient)
+
+	s.SetError("unavailable")
+
+	// fail open limits in process
+	require.NoError(t, client.Allow(ctx, Activate))
+	assert.False(t, client.available())
+	testutil.ErrorContains(t, []string{"ResourceExhausted", "over rate limit"}, client.Allow(ctx, Activate))
+
+	// fail closed rejects every request
+	testutil.ErrorContains(t, []string{"Unavailable", "rate limiter unavailable"}, client.Allow(ctx, VerifyPIN))
+
+	s.SetError("")
+	assert.Eventually(t, client.available, time.Second, 10*time.Millisecond)
+
+	// Redis counts apply again once it is available
+	require.NoError(t, client.Allow(ctx, VerifyPIN))
+	testutil.ErrorContains(t, []string{"ResourceExhausted", "over rate limit"}, client.Allow(ctx, VerifyPIN))
+}
+
+func TestNewClient_RedisUnavailable(t *testing.T) {
+	t.Parallel()
+
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	addr := s.Addr()
+	s.Close()
+
+	ctx, cancel := context.WithCancel(testutil.GetContext(false))
+	defer cancel()
+
+	r, err := NewClient(ctx, &Config{
+		Redis:  RedisConfig{Addr: addr},
+		Limits: map[Domain]LimitConfig{Activate: {Rate: 1, Period: time.Minute}},
+	}, &gsm.Client{
+		SM: mockSecretManager{
+			accessSecretVersionFunc: func(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+				return &secretmanagerpb.AccessSecretVersionResponse{Payload: &secretmanagerpb.SecretPayload{}}, nil
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, r.Allow(ctx, Activate))
+	testutil.ErrorContains(t, []string{"ResourceExhausted", "over rate limit"}, r.Allow(ctx, Activate))
+}
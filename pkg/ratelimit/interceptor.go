@@ -0,0 +1,145 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/anzx/fabric-cards/pkg/identity"
+	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// KeyType defines the dimension a method limit is counted against
+type KeyType string
+
+const (
+	// KeyPersona counts requests per persona from the JWT
+	KeyPersona KeyType = "persona"
+	// KeyCard counts requests per tokenized card number from the request
+	KeyCard KeyType = "card"
+	// KeyClientIP counts requests per client IP added to the forwarded metadata by the ingress, or the peer address
+	KeyClientIP KeyType = "clientip"
+)
+
+const (
+	forwardedForHeader    = "x-forwarded-for"
+	defaultTrustedProxies = 1
+)
+
+// MethodConfig defines the limit and key applied to a gRPC method
+type MethodConfig struct {
+	// Method is the full gRPC method name, e.g. /fabric.service.card.v1beta1.CardAPI/Activate
+	Method      string `json:"method" yaml:"method" mapstructure:"method" validate:"required"`
+	LimitConfig `json:",inline" yaml:",inline" mapstructure:",squash"`
+	// Key is one of persona, card or clientip and defaults to persona
+	Key KeyType `json:"key,omitempty" yaml:"key,omitempty" mapstructure:"key" validate:"omitempty,oneof=persona card clientip"`
+}
+
+// tokenizedCardRequest is implemented by every request carrying a tokenized card number
+type tokenizedCardRequest interface {
+	GetTokenizedCardNumber() string
+}
+
+// methodLimits indexes method limits by lower case method name so lookups are case insensitive
+func methodLimits(methods []MethodConfig) map[string]MethodConfig {
+	out := make(map[string]MethodConfig, len(methods))
+	for _, m := range methods {
+		out[strings.ToLower(m.Method)] = m
+	}
+	return out
+}
+
+// UnaryServerInterceptor rate limits every method configured in Config.Methods.
+// It must be chained after authentication when limits are keyed on persona.
+func UnaryServerInterceptor(rateLimit RateLimit) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if rateLimit != nil {
+			if err := rateLimit.AllowMethod(ctx, info.FullMethod, req); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// AllowMethod checks the limit configured for fullMethod, methods without a limit are always allowed
+func (r *RedisRateLimit) AllowMethod(ctx context.Context, fullMethod string, req interface{}) error {
+	method := strings.ToLower(fullMethod)
+
+	methodConfig, ok := r.Methods[method]
+	if !ok {
+		return nil
+	}
+
+	value, err := methodKey(ctx, methodConfig.Key, req, r.TrustedProxies)
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		logf.Debug(ctx, "rate limit key %s not found for method %s, request not limited", methodConfig.Key, method)
+		return nil
+	}
+
+	key := fmt.Sprintf("%s%s:%s:%s", r.Prefix, method, keyType(methodConfig.Key), value)
+
+	return r.allow(ctx, method, key, methodConfig.LimitConfig)
+}
+
+func keyType(key KeyType) KeyType {
+	if key == "" {
+		return KeyPersona
+	}
+	return KeyType(strings.ToLower(string(key)))
+}
+
+// methodKey returns the value of the key dimension for the request, or an empty string if it is not available
+func methodKey(ctx context.Context, key KeyType, req interface{}, trustedProxies int) (string, error) {
+	switch keyType(key) {
+	case KeyCard:
+		if r, ok := req.(tokenizedCardRequest); ok {
+			return r.GetTokenizedCardNumber(), nil
+		}
+		return "", nil
+	case KeyClientIP:
+		return clientIP(ctx, trustedProxies), nil
+	default:
+		id, err := identity.Get(ctx)
+		if err != nil {
+			return "", err
+		}
+		return id.PersonaID, nil
+	}
+}
+
+// clientIP returns the address the outermost trusted proxy added to x-forwarded-for, counting proxies from the right
+// as a client can put anything to the left of it. The peer address is used if there are no trusted proxies or fewer
+// addresses than proxies.
+func clientIP(ctx context.Context, trustedProxies int) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok && trustedProxies > 0 {
+		var forwarded []string
+		for _, header := range md.Get(forwardedForHeader) {
+			for _, ip := range strings.Split(header, ",") {
+				if ip = strings.TrimSpace(ip); ip != "" {
+					forwarded = append(forwarded, ip)
+				}
+			}
+		}
+		if len(forwarded) >= trustedProxies {
+			return forwarded[len(forwarded)-trustedProxies]
+		}
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			return p.Addr.String()
+		}
+		return host
+	}
+
+	return ""
+}
@@ -0,0 +1,211 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/go-redis/redis_rate/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	cpb "github.com/anzx/fabricapis/pkg/fabric/service/card/v1beta1"
+
+	"github.com/anzx/fabric-cards/pkg/util/testutil"
+)
+
+const (
+	activateMethod   = "/fabric.service.card.v1beta1.CardAPI/Activate"
+	getDetailsMethod = "/fabric.service.card.v1beta1.CardAPI/GetDetails"
+	listMethod       = "/fabric.service.card.v1beta1.CardAPI/List"
+)
+
+func newMethodRateLimit(t *testing.T, methods ...MethodConfig) *RedisRateLimit {
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+
+	return &RedisRateLimit{
+		Prefix:         "st",
+		Methods:        methodLimits(methods),
+		TrustedProxies: defaultTrustedProxies,
+		Limiter:        redis_rate.NewLimiter(redis.NewClient(&redis.Options{Addr: s.Addr()})),
+	}
+}
+
+func once(method string, key KeyType) MethodConfig {
+	return MethodConfig{
+		Method:      method,
+		LimitConfig: LimitConfig{Rate: 1, Period: time.Minute},
+		Key:         key,
+	}
+}
+
+func TestAllowMethod(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		description string
+		method      MethodConfig
+		fullMethod  string
+		ctx         func() context.Context
+		req         func() interface{}
+		other       func() (context.Context, interface{})
+		wantLimited bool
+	}{
+		{
+			description: "persona is limited",
+			method:      once(activateMethod, ""),
+			fullMethod:  activateMethod,
+			ctx:         func() context.Context { return testutil.GetContext(false) },
+			wantLimited: true,
+		},
+		{
+			description: "method name is case insensitive",
+			method:      once("/fabric.service.card.v1beta1.cardapi/activate", KeyPersona),
+			fullMethod:  activateMethod,
+			ctx:         func() context.Context { return testutil.GetContext(false) },
+			wantLimited: true,
+		},
+		{
+			description: "card is limited",
+			method:      once(getDetailsMethod, KeyCard),
+			fullMethod:  getDetailsMethod,
+			ctx:         func() context.Context { return testutil.GetContext(false) },
+			req:         func() interface{} { return &cpb.GetDetailsRequest{TokenizedCardNumber: "1234"} },
+			wantLimited: true,
+		},
+		{
+			description: "different cards are limited separately",
+			method:      once(getDetailsMethod, KeyCard),
+			fullMethod:  getDetailsMethod,
+			ctx:         func() context.Context { return testutil.GetContext(false) },
+			req:         func() interface{} { return &cpb.GetDetailsRequest{TokenizedCardNumber: "1234"} },
+			other: func() (context.Context, interface{}) {
+				return testutil.GetContext(false), &cpb.GetDetailsRequest{TokenizedCardNumber: "5678"}
+			},
+		},
+		{
+			description: "request without a card is not limited",
+			method:      once(listMethod, KeyCard),
+			fullMethod:  listMethod,
+			ctx:         func() context.Context { return testutil.GetContext(false) },
+			req:         func() interface{} { return &cpb.ListRequest{} },
+		},
+		{
+			description: "forwarded client ip is limited",
+			method:      once(listMethod, KeyClientIP),
+			fullMethod:  listMethod,
+			ctx: func() context.Context {
+				return metadata.NewIncomingContext(context.Background(), metadata.Pairs(forwardedForHeader, "10.0.0.9, 10.0.0.1"))
+			},
+			other: func() (context.Context, interface{}) {
+				return metadata.NewIncomingContext(context.Background(), metadata.Pairs(forwardedForHeader, "10.0.0.1")), nil
+			},
+			wantLimited: true,
+		},
+		{
+			description: "forwarded addresses set by the client are ignored",
+			method:      once(listMethod, KeyClientIP),
+			fullMethod:  listMethod,
+			ctx: func() context.Context {
+				return metadata.NewIncomingContext(context.Background(), metadata.Pairs(forwardedForHeader, "10.0.0.8, 10.0.0.1"))
+			},
+			other: func() (context.Context, interface{}) {
+				return metadata.NewIncomingContext(context.Background(), metadata.Pairs(forwardedForHeader, "10.0.0.9, 10.0.0.1")), nil
+			},
+			wantLimited: true,
+		},
+		{
+			description: "peer client ip is limited",
+			method:      once(listMethod, KeyClientIP),
+			fullMethod:  listMethod,
+			ctx: func() context.Context {
+				return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}})
+			},
+			other: func() (context.Context, interface{}) {
+				return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5678}}), nil
+			},
+			wantLimited: true,
+		},
+		{
+			description: "unconfigured method is not limited",
+			method:      once(activateMethod, KeyPersona),
+			fullMethod:  listMethod,
+			ctx:         func() context.Context { return testutil.GetContext(false) },
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.description, func(t *testing.T) {
+			t.Parallel()
+			r := newMethodRateLimit(t, test.method)
+
+			var req interface{}
+			if test.req != nil {
+				req = test.req()
+			}
+			require.NoError(t, r.AllowMethod(test.ctx(), test.fullMethod, req))
+
+			ctx, otherReq := test.ctx(), req
+			if test.other != nil {
+				ctx, otherReq = test.other()
+			}
+			err := r.AllowMethod(ctx, test.fullMethod, otherReq)
+			if test.wantLimited {
+				testutil.ErrorContains(t, []string{"ResourceExhausted", "over rate limit"}, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_clientIP(t *testing.T) {
+	forwarded := func(values ...string) context.Context {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.254"), Port: 1234}})
+		md := metadata.MD{}
+		md.Append(forwardedForHeader, values...)
+		return metadata.NewIncomingContext(ctx, md)
+	}
+
+	assert.Equal(t, "10.0.0.2", clientIP(forwarded("spoofed, 10.0.0.2"), 1))
+	assert.Equal(t, "10.0.0.254", clientIP(forwarded("spoofed, 10.0.0.2"), 0), "the peer address is used without proxies")
+	assert.Equal(t, "10.0.0.1", clientIP(forwarded("spoofed, 10.0.0.1, 10.0.0.2"), 2))
+	assert.Equal(t, "10.0.0.1", clientIP(forwarded("spoofed", "10.0.0.1, 10.0.0.2"), 2), "every header value is counted")
+	assert.Equal(t, "10.0.0.254", clientIP(forwarded("10.0.0.2"), 2), "the peer address is used without enough proxies")
+	assert.Equal(t, "10.0.0.254", clientIP(forwarded(), 1))
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: activateMethod}
+
+	t.Run("nil rate limit", func(t *testing.T) {
+		resp, err := UnaryServerInterceptor(nil)(context.Background(), nil, info, handler)
+		require.NoError(t, err)
+		assert.Equal(t, "ok", resp)
+	})
+
+	t.Run("limited", func(t *testing.T) {
+		interceptor := UnaryServerInterceptor(newMethodRateLimit(t, once(activateMethod, KeyPersona)))
+
+		_, err := interceptor(testutil.GetContext(false), nil, info, handler)
+		require.NoError(t, err)
+
+		resp, err := interceptor(testutil.GetContext(false), nil, info, handler)
+		assert.Error(t, err)
+		assert.Nil(t, resp)
+	})
+}
@@ -0,0 +1,198 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/grpc/codes"
+
+	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+)
+
+const (
+	lockoutCount = "count"
+	lockoutLast  = "last"
+	lockoutReset = "reset"
+)
+
+// LockoutConfig defines the progressive lockout of a key after consecutive failures, e.g. PIN verification of a card
+type LockoutConfig struct {
+	// Threshold is the number of consecutive failures before the first lockout
+	Threshold int64 `json:"threshold" yaml:"threshold" mapstructure:"threshold" validate:"gte=1"`
+	// BaseDelay is the duration of the first lockout, it doubles with every further failure
+	BaseDelay time.Duration `json:"baseDelay" yaml:"baseDelay" mapstructure:"baseDelay" validate:"gt=0"`
+	// MaxDelay caps the duration of a lockout
+	MaxDelay time.Duration `json:"maxDelay" yaml:"maxDelay" mapstructure:"maxDelay" validate:"gtefield=BaseDelay"`
+	// ResetAfter is how long failures are remembered after the last one
+	ResetAfter time.Duration `json:"resetAfter" yaml:"resetAfter" mapstructure:"resetAfter" validate:"gt=0"`
+}
+
+// Failures are consecutive failures of a key, either counted by the rate limiter or reported by a system of record
+type Failures struct {
+	Count int64
+	Last  time.Time
+	// Since is the earliest time the last failure may have occurred, if only known approximately, e.g. to the day.
+	// Last is used if not set.
+	Since time.Time
+}
+
+// since returns the earliest time the last failure may have occurred
+func (f Failures) since() time.Time {
+	if f.Since.IsZero() {
+		return f.Last
+	}
+	return f.Since
+}
+
+// LockoutState is the result of recording a failure
+type LockoutState struct {
+	Failures int64
+	// LockedFor is the duration of the lockout started by the failure, zero if the key is not locked
+	LockedFor time.Duration
+}
+
+// Started returns true if the failure started a lockout
+func (l LockoutState) Started() bool {
+	return l.LockedFor > 0
+}
+
+// delay returns the lockout duration after count consecutive failures
+func (c *LockoutConfig) delay(count int64) time.Duration {
+	if count < c.Threshold {
+		return 0
+	}
+
+	delay := c.BaseDelay
+	for i := c.Threshold; i < count && delay < c.MaxDelay; i++ {
+		delay *= 2
+	}
+	if c.MaxDelay > 0 && delay > c.MaxDelay {
+		delay = c.MaxDelay
+	}
+
+	return delay
+}
+
+// retryAfter returns how long the key remains locked out after failures
+func (c *LockoutConfig) retryAfter(failures Failures, now time.Time) time.Duration {
+	until := failures.Last.Add(c.delay(failures.Count))
+	if !now.Before(until) {
+		return 0
+	}
+	return until.Sub(now)
+}
+
+// CheckLockout returns a ResourceExhausted error while key is locked out. Failures known to a system of record,
+// e.g. PIN failures counted by CTM at a terminal, are reconciled with those counted here and the highest applies.
+func (r *RedisRateLimit) CheckLockout(ctx context.Context, key string, known Failures) error {
+	if r.Lockout == nil {
+		return nil
+	}
+
+	now := time.Now()
+	failures, reset, err := r.failures(ctx, key)
+	if err != nil {
+		logf.Error(ctx, err, "ratelimit: unable to read lockout for key: %v", key)
+	}
+
+	// failures which may be from before the last reset, or which have been forgotten, don't count
+	if known.Count > failures.Count && known.since().After(reset) && now.Sub(known.Last) < r.Lockout.ResetAfter {
+		failures = known
+		if err == nil {
+			r.storeFailures(ctx, key, failures)
+		}
+	}
+
+	if retryAfter := r.Lockout.retryAfter(failures, now); retryAfter > 0 {
+		return anzerrors.New(codes.ResourceExhausted, "lockout check failed",
+			anzerrors.NewErrorInfo(ctx, anzcodes.RateLimitExhausted, "locked out after repeated failures"),
+			anzerrors.WithRetryDelay(retryAfter))
+	}
+
+	return nil
+}
+
+// RecordFailure counts a consecutive failure for key and returns the lockout it started, if any
+func (r *RedisRateLimit) RecordFailure(ctx context.Context, key string) (LockoutState, error) {
+	if r.Lockout == nil {
+		return LockoutState{}, nil
+	}
+
+	redisKey := r.lockoutKey(key)
+	var count *redis.IntCmd
+	_, err := r.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		count = pipe.HIncrBy(ctx, redisKey, lockoutCount, 1)
+		pipe.HSet(ctx, redisKey, lockoutLast, time.Now().UnixNano())
+		pipe.PExpire(ctx, redisKey, r.Lockout.ResetAfter)
+		return nil
+	})
+	if err != nil {
+		logf.Error(ctx, err, "ratelimit: unable to record failure for key: %v", key)
+		return LockoutState{}, anzerrors.Wrap(err, codes.Internal, "lockout failed",
+			anzerrors.NewErrorInfo(ctx, anzcodes.DownstreamFailure, "unable to record failure"))
+	}
+
+	return LockoutState{
+		Failures:  count.Val(),
+		LockedFor: r.Lockout.delay(count.Val()),
+	}, nil
+}
+
+// ResetLockout forgets the failures of key, including those known to a system of record until it records a new one
+func (r *RedisRateLimit) ResetLockout(ctx context.Context, key string) error {
+	if r.Lockout == nil {
+		return nil
+	}
+
+	redisKey := r.lockoutKey(key)
+	_, err := r.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, redisKey, lockoutCount, 0, lockoutLast, 0, lockoutReset, time.Now().UnixNano())
+		pipe.PExpire(ctx, redisKey, r.Lockout.ResetAfter)
+		return nil
+	})
+	if err != nil {
+		logf.Error(ctx, err, "ratelimit: unable to reset lockout for key: %v", key)
+		return anzerrors.Wrap(err, codes.Internal, "lockout failed",
+			anzerrors.NewErrorInfo(ctx, anzcodes.DownstreamFailure, "unable to reset failures"))
+	}
+
+	return nil
+}
+
+func (r *RedisRateLimit) failures(ctx context.Context, key string) (Failures, time.Time, error) {
+	values, err := r.Client.HGetAll(ctx, r.lockoutKey(key)).Result()
+	if err != nil {
+		return Failures{}, time.Time{}, err
+	}
+
+	count, _ := strconv.ParseInt(values[lockoutCount], 10, 64)
+	return Failures{Count: count, Last: unixNano(values[lockoutLast])}, unixNano(values[lockoutReset]), nil
+}
+
+func (r *RedisRateLimit) storeFailures(ctx context.Context, key string, failures Failures) {
+	redisKey := r.lockoutKey(key)
+	_, err := r.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, redisKey, lockoutCount, failures.Count, lockoutLast, failures.Last.UnixNano())
+		pipe.PExpire(ctx, redisKey, r.Lockout.ResetAfter)
+		return nil
+	})
+	if err != nil {
+		logf.Error(ctx, err, "ratelimit: unable to reconcile lockout for key: %v", key)
+	}
+}
+
+func (r *RedisRateLimit) lockoutKey(key string) string {
+	return r.Prefix + "lockout:" + key
+}
+
+func unixNano(value string) time.Time {
+	nanos, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
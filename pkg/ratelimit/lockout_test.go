@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anzx/fabric-cards/pkg/util/testutil"
+)
+
+var testLockout = &LockoutConfig{
+	Threshold:  3,
+	BaseDelay:  time.Minute,
+	MaxDelay:   10 * time.Minute,
+	ResetAfter: 24 * time.Hour,
+}
+
+func TestLockoutConfig_Delay(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		count int64
+		want  time.Duration
+	}{
+		{count: 0, want: 0},
+		{count: 2, want: 0},
+		{count: 3, want: time.Minute},
+		{count: 4, want: 2 * time.Minute},
+		{count: 6, want: 8 * time.Minute},
+		{count: 7, want: 10 * time.Minute},
+		{count: 100, want: 10 * time.Minute},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, testLockout.delay(test.count), "count %d", test.count)
+	}
+}
+
+func newLockout(t *testing.T) (*RedisRateLimit, *miniredis.Miniredis) {
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+
+	return &RedisRateLimit{
+		Prefix:  "st",
+		Lockout: testLockout,
+		Client:  redis.NewClient(&redis.Options{Addr: s.Addr()}),
+	}, s
+}
+
+func TestLockout(t *testing.T) {
+	t.Parallel()
+	ctx := testutil.GetContext(false)
+	r, s := newLockout(t)
+
+	for i := int64(1); i < testLockout.Threshold; i++ {
+		state, err := r.RecordFailure(ctx, "card")
+		require.NoError(t, err)
+		assert.Equal(t, i, state.Failures)
+		assert.False(t, state.Started())
+		require.NoError(t, r.CheckLockout(ctx, "card", Failures{}))
+	}
+
+	state, err := r.RecordFailure(ctx, "card")
+	require.NoError(t, err)
+	assert.True(t, state.Started())
+	assert.Equal(t, time.Minute, state.LockedFor)
+
+	err = r.CheckLockout(ctx, "card", Failures{})
+	testutil.ErrorContains(t, []string{"ResourceExhausted", "locked out after repeated failures"}, err)
+
+	// other cards are not locked out
+	require.NoError(t, r.CheckLockout(ctx, "other", Failures{}))
+
+	// failures are forgotten after ResetAfter
+	assert.Equal(t, testLockout.ResetAfter, s.TTL("stlockout:card"))
+
+	require.NoError(t, r.ResetLockout(ctx, "card"))
+	require.NoError(t, r.CheckLockout(ctx, "card", Failures{}))
+}
+
+func TestLockout_Reconcile(t *testing.T) {
+	t.Parallel()
+	ctx := testutil.GetContext(false)
+	r, _ := newLockout(t)
+
+	// failures counted by a system of record lock out the key
+	known := Failures{Count: 3, Last: time.Now().Add(-30 * time.Second)}
+	err := r.CheckLockout(ctx, "card", known)
+	testutil.ErrorContains(t, []string{"locked out after repeated failures"}, err)
+
+	// and are kept once reconciled
+	err = r.CheckLockout(ctx, "card", Failures{})
+	testutil.ErrorContains(t, []string{"locked out after repeated failures"}, err)
+
+	// failures from before a reset are ignored
+	require.NoError(t, r.ResetLockout(ctx, "card"))
+	require.NoError(t, r.CheckLockout(ctx, "card", known))
+
+	// as are failures older than ResetAfter
+	require.NoError(t, r.CheckLockout(ctx, "old", Failures{Count: 10, Last: time.Now().Add(-48 * time.Hour)}))
+}
+
+func TestLockout_ReconcileDay(t *testing.T) {
+	t.Parallel()
+	ctx := testutil.GetContext(false)
+	r, _ := newLockout(t)
+
+	// failures only known to the day are taken as the end of that day
+	day := time.Now().Truncate(24 * time.Hour)
+	known := Failures{Count: 3, Last: day.Add(24 * time.Hour), Since: day}
+	err := r.CheckLockout(ctx, "card", known)
+	testutil.ErrorContains(t, []string{"locked out after repeated failures"}, err)
+
+	// a reset on the same day ignores them
+	require.NoError(t, r.ResetLockout(ctx, "card"))
+	require.NoError(t, r.CheckLockout(ctx, "card", known))
+
+	// and further failures start a new count
+	_, err = r.RecordFailure(ctx, "card")
+	require.NoError(t, err)
+	require.NoError(t, r.CheckLockout(ctx, "card", known))
+}
+
+func TestLockout_Disabled(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	r := &RedisRateLimit{}
+
+	state, err := r.RecordFailure(ctx, "card")
+	require.NoError(t, err)
+	assert.False(t, state.Started())
+	require.NoError(t, r.CheckLockout(ctx, "card", Failures{Count: 100, Last: time.Now()}))
+	require.NoError(t, r.ResetLockout(ctx, "card"))
+}
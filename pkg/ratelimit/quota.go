@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Quota trailers are set on every rate limited response, they are turned into headers by the REST gateway
+const (
+	TrailerLimit      = "ratelimit-limit"
+	TrailerRemaining  = "ratelimit-remaining"
+	TrailerRetryAfter = "ratelimit-retry-after"
+)
+
+// Quota headers returned to REST clients, see https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/
+const (
+	HeaderLimit      = "RateLimit-Limit"
+	HeaderRemaining  = "RateLimit-Remaining"
+	HeaderRetryAfter = "Retry-After"
+)
+
+// setQuota adds the quota of a limit to the response trailers, retryAfter is only set once the limit is used up.
+// It is a no-op outside of a gRPC server call.
+func setQuota(ctx context.Context, limit int, remaining int, retryAfter time.Duration) {
+	md := metadata.Pairs(TrailerLimit, strconv.Itoa(limit), TrailerRemaining, strconv.Itoa(remaining))
+	if retryAfter > 0 {
+		md.Append(TrailerRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+	_ = grpc.SetTrailer(ctx, md)
+}
+
+// QuotaHeaders sets the quota headers from the trailers of a rate limited response. When a request is counted
+// against several limits the one with the least remaining requests is returned.
+func QuotaHeaders(header http.Header, trailer metadata.MD) {
+	limits, remaining := trailer.Get(TrailerLimit), trailer.Get(TrailerRemaining)
+	if len(limits) == 0 || len(limits) != len(remaining) {
+		return
+	}
+
+	least := 0
+	for i := range remaining {
+		if atoi(remaining[i]) < atoi(remaining[least]) {
+			least = i
+		}
+	}
+	header.Set(HeaderLimit, limits[least])
+	header.Set(HeaderRemaining, remaining[least])
+
+	retryAfter := 0
+	for _, v := range trailer.Get(TrailerRetryAfter) {
+		if seconds := atoi(v); seconds > retryAfter {
+			retryAfter = seconds
+		}
+	}
+	if retryAfter > 0 {
+		header.Set(HeaderRetryAfter, strconv.Itoa(retryAfter))
+	}
+}
+
+func atoi(v string) int {
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return math.MaxInt32
+	}
+	return i
+}
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/anzx/fabric-cards/pkg/util/testutil"
+)
+
+type trailerStream struct {
+	grpc.ServerTransportStream
+	trailer metadata.MD
+}
+
+func (s *trailerStream) SetTrailer(md metadata.MD) error {
+	s.trailer = metadata.Join(s.trailer, md)
+	return nil
+}
+
+func TestAllow_Quota(t *testing.T) {
+	t.Parallel()
+
+	r := newMethodRateLimit(t)
+	r.Limits = map[Domain]LimitConfig{Activate: {Rate: 2, Period: time.Minute}}
+
+	stream := &trailerStream{}
+	ctx := grpc.NewContextWithServerTransportStream(testutil.GetContext(false), stream)
+
+	require.NoError(t, r.Allow(ctx, Activate))
+	assert.Equal(t, []string{"2"}, stream.trailer.Get(TrailerLimit))
+	assert.Equal(t, []string{"1"}, stream.trailer.Get(TrailerRemaining))
+	assert.Empty(t, stream.trailer.Get(TrailerRetryAfter))
+
+	require.NoError(t, r.Allow(ctx, Activate))
+	stream.trailer = nil
+	require.Error(t, r.Allow(ctx, Activate))
+	assert.Equal(t, []string{"0"}, stream.trailer.Get(TrailerRemaining))
+	assert.Equal(t, []string{"30"}, stream.trailer.Get(TrailerRetryAfter))
+}
+
+func TestQuotaHeaders(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		description string
+		trailer     metadata.MD
+		want        http.Header
+	}{
+		{
+			description: "no quota",
+			trailer:     metadata.Pairs("other", "value"),
+			want:        http.Header{},
+		},
+		{
+			description: "single limit",
+			trailer:     metadata.Pairs(TrailerLimit, "5", TrailerRemaining, "4"),
+			want:        http.Header{HeaderLimit: {"5"}, HeaderRemaining: {"4"}},
+		},
+		{
+			description: "least remaining of several limits",
+			trailer: metadata.Pairs(TrailerLimit, "100", TrailerRemaining, "50",
+				TrailerLimit, "5", TrailerRemaining, "0", TrailerRetryAfter, "12"),
+			want: http.Header{HeaderLimit: {"5"}, HeaderRemaining: {"0"}, HeaderRetryAfter: {"12"}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.description, func(t *testing.T) {
+			header := http.Header{}
+			QuotaHeaders(header, test.trailer)
+			assert.Equal(t, test.want, header)
+		})
+	}
+}
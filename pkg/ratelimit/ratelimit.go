@@ -23,8 +23,9 @@ import (
 type Domain string
 
 const (
-	Activate  Domain = "activate"
-	VerifyPIN Domain = "verifypin"
+	Activate    Domain = "activate"
+	VerifyPIN   Domain = "verifypin"
+	DailyLimits Domain = "dailylimits"
 )
 
 type Config struct {
@@ -32,6 +33,16 @@ type Config struct {
 	Limits map[Domain]LimitConfig `json:"limits" yaml:"limits" mapstructure:"limits" validate:"required"`
 	// Prefix to be added to every cache key, can be empty
 	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty" mapstructure:"prefix"`
+	// Methods are the limits applied by UnaryServerInterceptor, a list is used as viper splits map keys on dots
+	Methods []MethodConfig `json:"methods,omitempty" yaml:"methods,omitempty" mapstructure:"methods"`
+	// TrustedProxies is how many proxies in front of the service append to x-forwarded-for, the client IP of methods
+	// keyed on clientip is the address the outermost of them added. Defaults to 1, 0 uses the peer address of a service
+	// with no proxy in front of it
+	TrustedProxies *int `json:"trustedProxies,omitempty" yaml:"trustedProxies,omitempty" mapstructure:"trustedProxies" validate:"omitempty,gte=0"`
+	// ProbeInterval is how often Redis is pinged while it is unavailable, defaults to 5s
+	ProbeInterval time.Duration `json:"probeInterval,omitempty" yaml:"probeInterval,omitempty" mapstructure:"probeInterval"`
+	// Lockout enables progressive lockout after consecutive failures, lockout is disabled if not set
+	Lockout *LockoutConfig `json:"lockout,omitempty" yaml:"lockout,omitempty" mapstructure:"lockout"`
 }
 
 type LimitConfig struct {
@@ -39,10 +50,16 @@ type LimitConfig struct {
 	Rate int `json:"rate" yaml:"rate" mapstructure:"rate" validate:"gte=1"`
 	// Period in time.duration
 	Period time.Duration `json:"period" yaml:"period" mapstructure:"period" validate:"gte=0"`
+	// OnFailure is open to limit in process or closed to reject requests while Redis is unavailable, defaults to open
+	OnFailure FailurePolicy `json:"onFailure,omitempty" yaml:"onFailure,omitempty" mapstructure:"onFailure" validate:"omitempty,oneof=open closed"`
 }
 
 type RateLimit interface {
 	Allow(ctx context.Context, domain Domain) error
+	AllowMethod(ctx context.Context, fullMethod string, req interface{}) error
+	CheckLockout(ctx context.Context, key string, known Failures) error
+	RecordFailure(ctx context.Context, key string) (LockoutState, error)
+	ResetLockout(ctx context.Context, key string) error
 }
 
 func NewClient(ctx context.Context, config *Config, gsmClient *gsm.Client) (RateLimit, error) {
@@ -56,16 +73,32 @@ func NewClient(ctx context.Context, config *Config, gsmClient *gsm.Client) (Rate
 		return nil, errors.Wrap(err, "unable to access secret")
 	}
 
-	redisClient, err := newRedisClient(ctx, config.Redis)
+	trustedProxies := defaultTrustedProxies
+	if config.TrustedProxies != nil {
+		trustedProxies = *config.TrustedProxies
+	}
+
+	redisClient, err := NewRedisClient(ctx, config.Redis)
+
+	r := &RedisRateLimit{
+		Prefix:         config.Prefix,
+		Limits:         config.Limits,
+		Methods:        methodLimits(config.Methods),
+		TrustedProxies: trustedProxies,
+		ProbeInterval:  config.ProbeInterval,
+		Lockout:        config.Lockout,
+		Client:         redisClient,
+		Limiter:        redis_rate.NewLimiter(redisClient),
+	}
+
+	// an unavailable Redis doesn't block startup, limits fall back in process until a probe succeeds
 	if err != nil {
-		return nil, err
+		logf.Error(ctx, err, "ratelimit: redis unavailable at startup")
+		r.setAvailable(ctx, false)
 	}
+	go r.probe(ctx, redisClient)
 
-	return &RedisRateLimit{
-		Prefix:  config.Prefix,
-		Limits:  config.Limits,
-		Limiter: redis_rate.NewLimiter(redisClient),
-	}, nil
+	return r, nil
 }
 
 // Check return error if not pass the check, otherwise return nil
@@ -77,12 +110,6 @@ func (r *RedisRateLimit) Allow(ctx context.Context, domain Domain) error {
 			anzerrors.NewErrorInfo(ctx, anzcodes.RateLimitExhausted, "service unavailable"))
 	}
 
-	limit := redis_rate.Limit{
-		Rate:   limitConfig.Rate,
-		Burst:  limitConfig.Rate,
-		Period: limitConfig.Period,
-	}
-
 	id, err := identity.Get(ctx)
 	if err != nil {
 		return err
@@ -90,22 +117,44 @@ func (r *RedisRateLimit) Allow(ctx context.Context, domain Domain) error {
 
 	key := fmt.Sprintf("%s%s:%s", r.Prefix, domain, id.PersonaID)
 
+	return r.allow(ctx, string(domain), key, limitConfig)
+}
+
+// allow checks the limit for key and returns a ResourceExhausted error once it is used up.
+// The limit is enforced by fallback while Redis is unavailable.
+func (r *RedisRateLimit) allow(ctx context.Context, name string, key string, limitConfig LimitConfig) error {
+	if !r.available() {
+		return r.fallback(ctx, name, key, limitConfig)
+	}
+
+	limit := redis_rate.Limit{
+		Rate:   limitConfig.Rate,
+		Burst:  limitConfig.Rate,
+		Period: limitConfig.Period,
+	}
+
 	result, err := r.Limiter.Allow(ctx, key, limit)
 	if err != nil {
 		logf.Error(ctx, err, "rate limiter failed with key: %v", key)
-		return anzerrors.Wrap(err, codes.Internal, "rate limit check failed",
-			anzerrors.NewErrorInfo(ctx, anzcodes.DownstreamFailure, "rate limit check failed"))
+		r.setAvailable(ctx, false)
+		return r.fallback(ctx, name, key, limitConfig)
 	}
 
 	if result.Allowed == 0 {
-		return anzerrors.New(codes.ResourceExhausted, "rate limit check failed",
-			anzerrors.NewErrorInfo(ctx, anzcodes.RateLimitExhausted, "over rate limit"),
-			anzerrors.WithRetryDelay(result.RetryAfter))
+		setQuota(ctx, limitConfig.Rate, result.Remaining, result.RetryAfter)
+		return exhausted(ctx, result.RetryAfter)
 	}
+	setQuota(ctx, limitConfig.Rate, result.Remaining, 0)
 
 	return nil
 }
 
+func exhausted(ctx context.Context, retryAfter time.Duration) error {
+	return anzerrors.New(codes.ResourceExhausted, "rate limit check failed",
+		anzerrors.NewErrorInfo(ctx, anzcodes.RateLimitExhausted, "over rate limit"),
+		anzerrors.WithRetryDelay(retryAfter))
+}
+
 func (c *Config) Byte() []byte {
 	out, _ := json.Marshal(c)
 	return out
@@ -103,3 +103,39 @@ func TestRateLimit_RedisAvailable(t *testing.T) {
 	err = client.Allow(testutil.GetContext(false), "activate")
 	assert.Error(t, err)
 }
+
+func TestNewClient_TrustedProxies(t *testing.T) {
+	t.Parallel()
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	gsmClient := &gsm.Client{
+		SM: mockSecretManager{
+			accessSecretVersionFunc: func(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+				return &secretmanagerpb.AccessSecretVersionResponse{Payload: &secretmanagerpb.SecretPayload{}}, nil
+			},
+		},
+	}
+	noProxies := 0
+
+	tests := []struct {
+		name           string
+		trustedProxies *int
+		want           int
+	}{
+		{name: "defaults to 1", want: 1},
+		{name: "explicit 0 uses the peer address", trustedProxies: &noProxies, want: 0},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			client, err := NewClient(ctx, &Config{Redis: RedisConfig{Addr: s.Addr()}, TrustedProxies: test.trustedProxies}, gsmClient)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, client.(*RedisRateLimit).TrustedProxies)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/go-redis/redis_rate/v9"
@@ -52,10 +53,20 @@ func (c *RedisConfig) GetSecrets(ctx context.Context, secrets *gsm.Client) error
 type RedisRateLimit struct {
 	Prefix  string
 	Limits  map[Domain]LimitConfig
-	Limiter *redis_rate.Limiter
+	Methods map[string]MethodConfig
+	// TrustedProxies is how many proxies append to x-forwarded-for, the peer address is used if 0. See Config
+	TrustedProxies int
+	ProbeInterval  time.Duration
+	Lockout        *LockoutConfig
+	Client         redis.Cmdable
+	Limiter        *redis_rate.Limiter
+
+	unavailable int32
+	local       localBuckets
 }
 
-func newRedisClient(ctx context.Context, config RedisConfig) (*redis.Client, error) {
+// NewRedisClient returns the client along with any error pinging it, the client is usable once Redis is available
+func NewRedisClient(ctx context.Context, config RedisConfig) (*redis.Client, error) {
 	opts := &redis.Options{
 		Addr:      config.Addr,
 		Password:  config.Password,
@@ -67,7 +78,7 @@ func newRedisClient(ctx context.Context, config RedisConfig) (*redis.Client, err
 
 	ping, err := client.Ping(ctx).Result()
 	if err != nil {
-		return nil, errors.Wrap(err, fmt.Sprintf("failed to ping redis: %s", ping))
+		return client, errors.Wrap(err, fmt.Sprintf("failed to ping redis: %s", ping))
 	}
 
 	return client, nil
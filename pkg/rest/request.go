@@ -33,11 +33,27 @@ func NewHTTPClientWithLogAndRetry(maxRetries int, payloadLoggingDecider httplogg
 	client := NewHTTPClientWithLog(http.DefaultTransport, payloadLoggingDecider, service)
 	retryClient := retryablehttp.NewClient()
 	retryClient.RetryMax = maxRetries
+	retryClient.CheckRetry = deadlineRetryPolicy(retryClient.RetryWaitMin)
 
 	retryClient.HTTPClient = client
 	return retryClient.StandardClient()
 }
 
+// deadlineRetryPolicy retries as retryablehttp.DefaultRetryPolicy does, unless the request deadline leaves less than
+// minWait for another attempt. The last response is then returned as is rather than waiting for the deadline to pass.
+func deadlineRetryPolicy(minWait time.Duration) retryablehttp.CheckRetry {
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		retry, checkErr := retryablehttp.DefaultRetryPolicy(ctx, resp, err)
+		if !retry || checkErr != nil {
+			return retry, checkErr
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < minWait {
+			return false, nil
+		}
+		return true, nil
+	}
+}
+
 type ClientTagExtKey struct{}
 
 type ClientTagExt struct {
@@ -10,6 +10,7 @@ import (
 
 	"github.com/anzx/pkg/monitoring/names"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewDefaultHTTPClient_TimeoutValues(t *testing.T) {
@@ -63,3 +64,27 @@ func TestNewHTTPClientWithLogAndRetry(t *testing.T) {
 		assert.Nil(t, err)
 	})
 }
+
+func TestNewHTTPClientWithLogAndRetry_Deadline(t *testing.T) {
+	attempts := 0
+	var handler http.HandlerFunc = func(rw http.ResponseWriter, req *http.Request) {
+		attempts++
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	client := NewHTTPClientWithLogAndRetry(3, nil, names.Unknown)
+	request, _ := http.NewRequestWithContext(ctx, "POST", server.URL, bytes.NewBuffer([]byte("haha")))
+	resp, err := client.Do(request)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// no time is left to wait for a retry so the failed response is returned straight away
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, 1, attempts)
+	assert.NoError(t, ctx.Err())
+}
@@ -0,0 +1,19 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// WriteJSON writes body as a JSON response with status
+func WriteJSON(w http.ResponseWriter, status int, body interface{}) {
+	payload, _ := json.Marshal(body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(payload)
+}
+
+// WriteError writes a JSON response with status and an error message
+func WriteError(w http.ResponseWriter, status int, message string) {
+	WriteJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,36 @@
+package rules
+
+import (
+	"github.com/anzx/fabric-cards/pkg/integration/ctm"
+)
+
+// fields are the attributes of a CTM card rules can refer to, derived attributes such as visible use the same logic
+// as the ctm package so a rule cannot drift from how the rest of the service reads a card
+var (
+	stringFields = map[string]func(ctm.DebitCardResponse) string{
+		"status":           func(r ctm.DebitCardResponse) string { return string(r.Status) },
+		"statusCode":       func(r ctm.DebitCardResponse) string { return string(r.StatusCode) },
+		"statusReason":     func(r ctm.DebitCardResponse) string { return string(r.StatusReason) },
+		"productCode":      func(r ctm.DebitCardResponse) string { return r.ProductCode },
+		"subProductCode":   func(r ctm.DebitCardResponse) string { return r.SubProductCode },
+		"designCode":       func(r ctm.DebitCardResponse) string { return r.DesignCode },
+		"dispatchedMethod": func(r ctm.DebitCardResponse) string { return string(r.DispatchedMethod) },
+		"collectionStatus": func(r ctm.DebitCardResponse) string { return string(r.CollectionStatus) },
+	}
+
+	boolFields = map[string]func(ctm.DebitCardResponse) bool{
+		"activationStatus":         func(r ctm.DebitCardResponse) bool { return r.ActivationStatus },
+		"cardControlPreference":    func(r ctm.DebitCardResponse) bool { return r.CardControlPreference },
+		"merchantUpdatePreference": func(r ctm.DebitCardResponse) bool { return r.MerchantUpdatePreference },
+		"visible":                  func(r ctm.DebitCardResponse) bool { return r.Visible() },
+		"issuedOrReplacedToday":    func(r ctm.DebitCardResponse) bool { return r.IssuedOrReplacedToday() },
+	}
+
+	numberFields = map[string]func(ctm.DebitCardResponse) int64{
+		"pinChangedCount":     func(r ctm.DebitCardResponse) int64 { return r.PinChangedCount },
+		"pinFailedCount":      func(r ctm.DebitCardResponse) int64 { return r.PinFailedCount },
+		"replacementCount":    func(r ctm.DebitCardResponse) int64 { return r.ReplacementCount },
+		"accountsLinkedCount": func(r ctm.DebitCardResponse) int64 { return r.AccountsLinkedCount },
+		"totalCards":          func(r ctm.DebitCardResponse) int64 { return r.TotalCards },
+	}
+)
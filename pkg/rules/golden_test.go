@@ -0,0 +1,175 @@
+package rules
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/anzx/fabric-cards/pkg/integration/ctm"
+
+	epb "github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1"
+)
+
+const goldenFile = "testdata/golden.yaml"
+
+var update = flag.Bool("update", false, "regenerate "+goldenFile+" from ctm.DebitCardResponse.Eligibility")
+
+type golden struct {
+	Cases []goldenCase `yaml:"cases"`
+}
+
+// goldenCase is a card in one combination of the fields the rules depend on
+type goldenCase struct {
+	Status           ctm.Status `yaml:"status"`
+	ActivationStatus bool       `yaml:"activationStatus"`
+	PinChangedCount  int64      `yaml:"pinChangedCount"`
+	IssuedToday      bool       `yaml:"issuedToday"`
+	ReplacedToday    bool       `yaml:"replacedToday"`
+	Eligibilities    []string   `yaml:"eligibilities,flow"`
+}
+
+func (c goldenCase) String() string {
+	return fmt.Sprintf("%s activated=%t pins=%d issuedToday=%t replacedToday=%t",
+		c.Status, c.ActivationStatus, c.PinChangedCount, c.IssuedToday, c.ReplacedToday)
+}
+
+func (c goldenCase) card() ctm.DebitCardResponse {
+	today := time.Now().Format("2006-01-02")
+	card := ctm.DebitCardResponse{
+		Status:           c.Status,
+		ActivationStatus: c.ActivationStatus,
+		PinChangedCount:  c.PinChangedCount,
+		IssueDate:        "2015-08-05",
+	}
+	if c.IssuedToday {
+		card.IssueDate = today
+	}
+	if c.ReplacedToday {
+		card.ReplacedDate = today
+	}
+	return card
+}
+
+// combinations returns every combination of the card fields the eligibilities depend on, an unknown status included
+func combinations() []goldenCase {
+	statuses := []ctm.Status{
+		ctm.StatusIssued,
+		ctm.StatusTemporaryBlock,
+		ctm.StatusLost,
+		ctm.StatusStolen,
+		ctm.StatusDelinquentReturn,
+		ctm.StatusDelinquentRetain,
+		ctm.StatusClosed,
+		ctm.StatusUnissuedNdIciCards,
+		ctm.StatusBlockAtm,
+		ctm.StatusBlockAtmPosExcludeCnp,
+		ctm.StatusBlockAtmPosCnpBch,
+		ctm.StatusBlockAtmPosCnp,
+		ctm.StatusBlockCnp,
+		ctm.StatusBlockPosExcludeCnp,
+		"Unknown",
+	}
+
+	var out []goldenCase
+	for _, status := range statuses {
+		for _, activated := range []bool{false, true} {
+			for _, pins := range []int64{0, 1} {
+				for _, today := range []struct{ issued, replaced bool }{{false, false}, {true, false}, {false, true}} {
+					out = append(out, goldenCase{
+						Status:           status,
+						ActivationStatus: activated,
+						PinChangedCount:  pins,
+						IssuedToday:      today.issued,
+						ReplacedToday:    today.replaced,
+					})
+				}
+			}
+		}
+	}
+	return out
+}
+
+func names(eligibilities []epb.Eligibility) []string {
+	out := make([]string, 0, len(eligibilities))
+	for _, e := range eligibilities {
+		out = append(out, e.String())
+	}
+	return out
+}
+
+func sorted(t *testing.T, names []string) []epb.Eligibility {
+	out := make([]epb.Eligibility, 0, len(names))
+	for _, name := range names {
+		e, ok := epb.Eligibility_value[name]
+		require.True(t, ok, "unknown eligibility %s", name)
+		out = append(out, epb.Eligibility(e))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i] < out[j]
+	})
+	return out
+}
+
+func writeGolden(t *testing.T) {
+	var g golden
+	for _, c := range combinations() {
+		c.Eligibilities = names(c.card().Eligibility())
+		g.Cases = append(g.Cases, c)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# Generated from ctm.DebitCardResponse.Eligibility by go test ./pkg/rules -run TestGolden -update, it records the\n")
+	buf.WriteString("# eligibilities of every combination of the card fields the rules depend on. Do not edit by hand.\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	require.NoError(t, enc.Encode(g))
+	require.NoError(t, enc.Close())
+	require.NoError(t, os.WriteFile(goldenFile, buf.Bytes(), 0o600))
+}
+
+func readGolden(t *testing.T) golden {
+	b, err := os.ReadFile(goldenFile)
+	require.NoError(t, err)
+
+	var g golden
+	require.NoError(t, yaml.Unmarshal(b, &g))
+	return g
+}
+
+// TestGolden proves the embedded rules grant exactly the eligibilities of the hard-coded switch in the ctm package for
+// every combination of card fields it depends on
+func TestGolden(t *testing.T) {
+	if *update {
+		writeGolden(t)
+	}
+
+	rules, err := Default()
+	require.NoError(t, err)
+
+	g := readGolden(t)
+
+	recorded := map[string]bool{}
+	for _, c := range g.Cases {
+		recorded[c.String()] = true
+	}
+	for _, c := range combinations() {
+		assert.True(t, recorded[c.String()], "%s missing from %s, run with -update", c, goldenFile)
+	}
+
+	for _, c := range g.Cases {
+		c := c
+		t.Run(c.String(), func(t *testing.T) {
+			want := sorted(t, c.Eligibilities)
+			assert.Equal(t, want, c.card().Eligibility(), "ctm no longer matches %s, run with -update if intended", goldenFile)
+			assert.Equal(t, want, rules.Eligibility(c.card()))
+		})
+	}
+}
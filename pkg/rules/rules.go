@@ -0,0 +1,195 @@
+// Package rules derives the eligibilities of a card from declarative rules instead of code. The embedded rules are
+// proven against ctm.DebitCardResponse.Eligibility by the golden test, which remains the source of eligibility until
+// services switch over to the rules.
+package rules
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/anzx/fabric-cards/pkg/integration/ctm"
+
+	epb "github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1"
+)
+
+// Version is the only version of the rules format currently understood
+const Version = 1
+
+//go:embed eligibility.yaml
+var defaultRules []byte
+
+// Rules grants eligibilities to a card. A card gets the grants of every rule whose conditions all hold, a card no rule
+// matches has no eligibilities.
+type Rules struct {
+	Version int    `yaml:"version"`
+	Rules   []Rule `yaml:"rules"`
+}
+
+type Rule struct {
+	// Name describes the rule in errors and logs
+	Name  string      `yaml:"name"`
+	When  []Condition `yaml:"when"`
+	Grant []string    `yaml:"grant"`
+
+	grants []epb.Eligibility
+}
+
+// Condition is a predicate over a single field of the CTM card, exactly one operator must be set and it must suit the
+// type of the field: in and notIn for text, is for flags, above and below for counts
+type Condition struct {
+	Field string   `yaml:"field"`
+	In    []string `yaml:"in,omitempty"`
+	NotIn []string `yaml:"notIn,omitempty"`
+	Is    *bool    `yaml:"is,omitempty"`
+	Above *int64   `yaml:"above,omitempty"`
+	Below *int64   `yaml:"below,omitempty"`
+}
+
+// Default returns the rules embedded in the binary
+func Default() (*Rules, error) {
+	return Load(defaultRules)
+}
+
+// Load parses and validates YAML rules, unknown keys, fields and eligibilities are rejected so a typo cannot silently
+// take an eligibility away
+func Load(b []byte) (*Rules, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+
+	var rules Rules
+	if err := dec.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("unable to parse eligibility rules: %w", err)
+	}
+
+	if err := rules.validate(); err != nil {
+		return nil, err
+	}
+
+	return &rules, nil
+}
+
+// Eligibility returns the eligibilities of the card sorted in the order of the enum
+func (r *Rules) Eligibility(card ctm.DebitCardResponse) []epb.Eligibility {
+	granted := map[epb.Eligibility]bool{}
+	for _, rule := range r.Rules {
+		if !rule.matches(card) {
+			continue
+		}
+		for _, e := range rule.grants {
+			granted[e] = true
+		}
+	}
+
+	eligibilities := make([]epb.Eligibility, 0, len(granted))
+	for e := range granted {
+		eligibilities = append(eligibilities, e)
+	}
+
+	sort.Slice(eligibilities, func(i, j int) bool {
+		return eligibilities[i] < eligibilities[j]
+	})
+	return eligibilities
+}
+
+func (r *Rules) validate() error {
+	if r.Version != Version {
+		return fmt.Errorf("eligibility rules version %d not supported", r.Version)
+	}
+
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if len(rule.Grant) == 0 {
+			return fmt.Errorf("eligibility rule %q grants nothing", rule.Name)
+		}
+		for _, condition := range rule.When {
+			if err := condition.validate(); err != nil {
+				return fmt.Errorf("eligibility rule %q: %w", rule.Name, err)
+			}
+		}
+		rule.grants = make([]epb.Eligibility, 0, len(rule.Grant))
+		for _, name := range rule.Grant {
+			e, ok := epb.Eligibility_value[name]
+			if !ok || epb.Eligibility(e) == epb.Eligibility_ELIGIBILITY_INVALID_UNSPECIFIED {
+				return fmt.Errorf("eligibility rule %q grants unknown eligibility %s", rule.Name, name)
+			}
+			rule.grants = append(rule.grants, epb.Eligibility(e))
+		}
+	}
+
+	return nil
+}
+
+func (r Rule) matches(card ctm.DebitCardResponse) bool {
+	for _, condition := range r.When {
+		if !condition.holds(card) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Condition) validate() error {
+	operators := 0
+	for _, set := range []bool{c.In != nil, c.NotIn != nil, c.Is != nil, c.Above != nil, c.Below != nil} {
+		if set {
+			operators++
+		}
+	}
+	if operators != 1 {
+		return fmt.Errorf("condition on %s must have exactly one operator", c.Field)
+	}
+
+	_, isString := stringFields[c.Field]
+	_, isBool := boolFields[c.Field]
+	_, isNumber := numberFields[c.Field]
+	switch {
+	case isString:
+		if c.In == nil && c.NotIn == nil {
+			return fmt.Errorf("condition on %s must use in or notIn", c.Field)
+		}
+	case isBool:
+		if c.Is == nil {
+			return fmt.Errorf("condition on %s must use is", c.Field)
+		}
+	case isNumber:
+		if c.Above == nil && c.Below == nil {
+			return fmt.Errorf("condition on %s must use above or below", c.Field)
+		}
+	default:
+		return fmt.Errorf("unknown field %s", c.Field)
+	}
+
+	return nil
+}
+
+func (c Condition) holds(card ctm.DebitCardResponse) bool {
+	if value, ok := stringFields[c.Field]; ok {
+		if c.In != nil {
+			return contains(c.In, value(card))
+		}
+		return !contains(c.NotIn, value(card))
+	}
+
+	if value, ok := boolFields[c.Field]; ok {
+		return value(card) == *c.Is
+	}
+
+	value := numberFields[c.Field](card)
+	if c.Above != nil {
+		return value > *c.Above
+	}
+	return value < *c.Below
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
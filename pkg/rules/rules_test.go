@@ -0,0 +1,201 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anzx/fabric-cards/pkg/integration/ctm"
+
+	epb "github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   string
+		wantErr string
+	}{
+		{
+			name: "valid rules",
+			rules: `
+version: 1
+rules:
+  - name: issued
+    when:
+      - field: status
+        in: [Issued]
+      - field: pinChangedCount
+        above: 0
+      - field: visible
+        is: true
+    grant: [ELIGIBILITY_CHANGE_PIN]
+`,
+		},
+		{
+			name:    "unsupported version",
+			rules:   "version: 2\n",
+			wantErr: "eligibility rules version 2 not supported",
+		},
+		{
+			name: "unknown key",
+			rules: `
+version: 1
+rules:
+  - name: issued
+    grants: [ELIGIBILITY_CHANGE_PIN]
+`,
+			wantErr: "unable to parse eligibility rules",
+		},
+		{
+			name: "unknown eligibility",
+			rules: `
+version: 1
+rules:
+  - name: issued
+    grant: [ELIGIBILITY_TELEPORT]
+`,
+			wantErr: `eligibility rule "issued" grants unknown eligibility ELIGIBILITY_TELEPORT`,
+		},
+		{
+			name: "unspecified eligibility",
+			rules: `
+version: 1
+rules:
+  - name: issued
+    grant: [ELIGIBILITY_INVALID_UNSPECIFIED]
+`,
+			wantErr: "grants unknown eligibility ELIGIBILITY_INVALID_UNSPECIFIED",
+		},
+		{
+			name: "grants nothing",
+			rules: `
+version: 1
+rules:
+  - name: issued
+    when:
+      - field: status
+        in: [Issued]
+`,
+			wantErr: `eligibility rule "issued" grants nothing`,
+		},
+		{
+			name: "unknown field",
+			rules: `
+version: 1
+rules:
+  - name: issued
+    when:
+      - field: colour
+        in: [blue]
+    grant: [ELIGIBILITY_CHANGE_PIN]
+`,
+			wantErr: `eligibility rule "issued": unknown field colour`,
+		},
+		{
+			name: "no operator",
+			rules: `
+version: 1
+rules:
+  - name: issued
+    when:
+      - field: status
+    grant: [ELIGIBILITY_CHANGE_PIN]
+`,
+			wantErr: "condition on status must have exactly one operator",
+		},
+		{
+			name: "two operators",
+			rules: `
+version: 1
+rules:
+  - name: issued
+    when:
+      - field: pinChangedCount
+        above: 0
+        below: 3
+    grant: [ELIGIBILITY_CHANGE_PIN]
+`,
+			wantErr: "condition on pinChangedCount must have exactly one operator",
+		},
+		{
+			name: "operator does not suit field",
+			rules: `
+version: 1
+rules:
+  - name: issued
+    when:
+      - field: activationStatus
+        in: ["true"]
+    grant: [ELIGIBILITY_CHANGE_PIN]
+`,
+			wantErr: "condition on activationStatus must use is",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Load([]byte(test.rules))
+			if test.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRules_Eligibility(t *testing.T) {
+	rules, err := Load([]byte(`
+version: 1
+rules:
+  - name: premium
+    when:
+      - field: productCode
+        in: [PDV]
+      - field: subProductCode
+        notIn: ["031"]
+    grant: [ELIGIBILITY_CARD_CONTROLS, ELIGIBILITY_APPLE_PAY]
+  - name: locked out
+    when:
+      - field: pinFailedCount
+        below: 3
+    grant: [ELIGIBILITY_CHANGE_PIN, ELIGIBILITY_CARD_CONTROLS]
+`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		card ctm.DebitCardResponse
+		want []epb.Eligibility
+	}{
+		{
+			name: "grants of every matching rule sorted without duplicates",
+			card: ctm.DebitCardResponse{ProductCode: "PDV", SubProductCode: "001"},
+			want: []epb.Eligibility{
+				epb.Eligibility_ELIGIBILITY_APPLE_PAY,
+				epb.Eligibility_ELIGIBILITY_CHANGE_PIN,
+				epb.Eligibility_ELIGIBILITY_CARD_CONTROLS,
+			},
+		},
+		{
+			name: "excluded value",
+			card: ctm.DebitCardResponse{ProductCode: "PDV", SubProductCode: "031", PinFailedCount: 3},
+			want: []epb.Eligibility{},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, rules.Eligibility(test.card))
+		})
+	}
+}
+
+func TestDefault(t *testing.T) {
+	rules, err := Default()
+	require.NoError(t, err)
+	assert.Equal(t, Version, rules.Version)
+}
@@ -0,0 +1,66 @@
+package saga
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/anzx/fabric-cards/pkg/rest"
+)
+
+// AdminPath serves the sagas on the ops port, GET lists the sagas that have not finished or returns the saga of the
+// id query parameter, POST re-drives the saga of the id query parameter
+const AdminPath = "/admin/sagas"
+
+// Admin returns the handler of AdminPath, it does not authenticate requests
+func Admin(r *Runner) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id := req.URL.Query().Get("id")
+
+		switch req.Method {
+		case http.MethodGet:
+			if id == "" {
+				sagas, err := r.Active(req.Context())
+				if err != nil {
+					rest.WriteError(w, http.StatusInternalServerError, err.Error())
+					return
+				}
+				sort.Slice(sagas, func(i, j int) bool {
+					return sagas[i].CreatedAt.Before(sagas[j].CreatedAt)
+				})
+				rest.WriteJSON(w, http.StatusOK, map[string][]*Saga{"sagas": sagas})
+				return
+			}
+			saga, err := r.Get(req.Context(), id)
+			if err != nil {
+				rest.WriteError(w, statusOf(err), err.Error())
+				return
+			}
+			rest.WriteJSON(w, http.StatusOK, saga)
+		case http.MethodPost:
+			if id == "" {
+				rest.WriteError(w, http.StatusBadRequest, "id is required")
+				return
+			}
+			saga, err := r.Redrive(req.Context(), id)
+			if saga == nil {
+				rest.WriteError(w, statusOf(err), err.Error())
+				return
+			}
+			// the saga is returned even when a step failed again so the operator can see where it is stuck
+			rest.WriteJSON(w, http.StatusOK, saga)
+		default:
+			rest.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	})
+}
+
+func statusOf(err error) int {
+	switch err {
+	case ErrNotFound:
+		return http.StatusNotFound
+	case ErrLeased:
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
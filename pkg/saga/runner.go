@@ -0,0 +1,425 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+
+	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
+	anzerrors "github.com/anzx/pkg/errors"
+)
+
+// ErrLeased is returned when another instance is running the saga
+var ErrLeased = errors.New("saga is running on another instance")
+
+// Runner starts, resumes and re-drives sagas. A nil Runner runs every step once without persisting the saga, so
+// callers behave the same whether or not sagas are configured.
+type Runner struct {
+	config Config
+	store  *store
+	// owner identifies the instance in the leases it holds
+	owner string
+	now   func() time.Time
+
+	lock        sync.RWMutex
+	definitions map[string]Definition
+}
+
+// NewRunner returns a Runner for the config, or nil if no config is provided. Sagas are retried but not persisted or
+// resumed if client is nil.
+func NewRunner(ctx context.Context, config *Config, client redis.Cmdable) *Runner {
+	if config == nil {
+		logf.Debug(ctx, "saga config not provided %v", config)
+		return nil
+	}
+
+	r := &Runner{
+		config:      config.withDefaults(),
+		owner:       uuid.New().String(),
+		now:         time.Now,
+		definitions: map[string]Definition{},
+	}
+
+	if client == nil {
+		logf.Info(ctx, "saga: redis not configured, sagas will not be persisted or resumed")
+		return r
+	}
+
+	r.store = &store{
+		client:    client,
+		prefix:    r.config.Prefix,
+		retention: r.config.Retention,
+	}
+	return r
+}
+
+// Register makes the sagas of a definition resumable
+func (r *Runner) Register(def Definition) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.definitions[def.Kind] = def
+}
+
+func (r *Runner) definition(kind string) (Definition, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	def, ok := r.definitions[kind]
+	return def, ok
+}
+
+// Start runs a new saga of def with data, a pointer to the data the steps share. The saga is returned with the error
+// of the step that failed, if the failure was after the pivot the saga is parked and will be resumed.
+func (r *Runner) Start(ctx context.Context, def Definition, data interface{}) (*Saga, error) {
+	now := time.Now()
+	saga := &Saga{
+		ID:        uuid.New().String(),
+		Kind:      def.Kind,
+		Status:    StatusRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if r == nil {
+		unpersisted := &Runner{config: Config{Attempts: 1}, now: time.Now}
+		return saga, unpersisted.execute(ctx, def, saga, data)
+	}
+
+	if r.store != nil {
+		if _, err := r.store.lease(ctx, saga.ID, r.owner, r.config.Lease); err != nil {
+			logf.Error(ctx, err, "saga: unable to lease %s %s", saga.Kind, saga.ID)
+		}
+		defer r.release(ctx, saga)
+	}
+	r.save(ctx, def, saga, data)
+
+	return saga, r.execute(ctx, def, saga, data)
+}
+
+// Get returns a saga
+func (r *Runner) Get(ctx context.Context, id string) (*Saga, error) {
+	if r == nil || r.store == nil {
+		return nil, ErrNotFound
+	}
+	return r.store.get(ctx, id)
+}
+
+// Active returns every saga that has not finished
+func (r *Runner) Active(ctx context.Context) ([]*Saga, error) {
+	if r == nil || r.store == nil {
+		return nil, nil
+	}
+	return r.store.active(ctx)
+}
+
+// Claim reserves key for the saga until it is unclaimed or the saga expires, false is returned if another saga holds
+// the claim
+func (r *Runner) Claim(ctx context.Context, key string, saga *Saga) (bool, error) {
+	if r == nil || r.store == nil {
+		return true, nil
+	}
+	return r.store.claim(ctx, key, saga.ID)
+}
+
+// Unclaim releases a claim held by the saga
+func (r *Runner) Unclaim(ctx context.Context, key string, saga *Saga) error {
+	if r == nil || r.store == nil {
+		return nil
+	}
+	return r.store.unclaim(ctx, key, saga.ID)
+}
+
+// Redrive resumes a saga immediately whether or not it is due, its resumes are reset
+func (r *Runner) Redrive(ctx context.Context, id string) (*Saga, error) {
+	return r.resume(ctx, id, true)
+}
+
+// Scopes returns the scopes configured for the system JWT sagas are resumed with
+func (r *Runner) Scopes() []string {
+	if r == nil {
+		return nil
+	}
+	return r.config.Scopes
+}
+
+// Resume returns a function suitable for an errgroup which resumes due sagas until ctx is done.
+// It is a no-op if sagas are not persisted.
+func (r *Runner) Resume(ctx context.Context) func() error {
+	return func() error {
+		if r == nil || r.store == nil {
+			return nil
+		}
+
+		ticker := time.NewTicker(r.config.ResumeInterval)
+		defer ticker.Stop()
+
+		logf.Info(ctx, "saga: resuming parked sagas every %v", r.config.ResumeInterval)
+
+		for {
+			r.ResumeDue(ctx)
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// ResumeDue resumes every saga that is due, sagas leased by another instance are skipped
+func (r *Runner) ResumeDue(ctx context.Context) {
+	ids, err := r.store.due(ctx, r.now())
+	if err != nil {
+		logf.Error(ctx, err, "saga: unable to list due sagas")
+		return
+	}
+
+	for _, id := range ids {
+		if _, err := r.resume(ctx, id, false); err != nil && err != ErrLeased {
+			logf.Error(ctx, err, "saga: resume of %s failed", id)
+		}
+	}
+}
+
+func (r *Runner) resume(ctx context.Context, id string, redrive bool) (*Saga, error) {
+	if r == nil || r.store == nil {
+		return nil, ErrNotFound
+	}
+
+	leased, err := r.store.lease(ctx, id, r.owner, r.config.Lease)
+	if err != nil {
+		return nil, err
+	}
+	if !leased {
+		return nil, ErrLeased
+	}
+
+	saga, err := r.store.get(ctx, id)
+	if err != nil {
+		r.releaseLease(ctx, id)
+		if err == ErrNotFound {
+			_ = r.store.unschedule(ctx, id)
+		}
+		return nil, err
+	}
+	defer r.release(ctx, saga)
+
+	if saga.Status.Terminal() {
+		return saga, r.store.unschedule(ctx, id)
+	}
+
+	def, ok := r.definition(saga.Kind)
+	if !ok {
+		return saga, fmt.Errorf("saga kind %q is not registered", saga.Kind)
+	}
+
+	data := def.NewData()
+	if err := json.Unmarshal(saga.Data, data); err != nil {
+		return saga, errors.Wrap(err, "unable to unmarshal saga data")
+	}
+
+	if redrive {
+		saga.Resumes = 0
+	} else {
+		saga.Resumes++
+	}
+	saga.Stuck = false
+
+	runCtx := ctx
+	if def.Context != nil {
+		if runCtx, err = def.Context(ctx, data); err != nil {
+			r.park(ctx, def, saga, data, err)
+			return saga, err
+		}
+	}
+
+	logf.Info(ctx, "saga: resuming %s %s at step %d", saga.Kind, saga.ID, saga.Step)
+	return saga, r.execute(runCtx, def, saga, data)
+}
+
+// execute runs the saga forward and compensates it if it fails before the pivot, the error of the failed step is
+// returned even once compensated
+func (r *Runner) execute(ctx context.Context, def Definition, saga *Saga, data interface{}) error {
+	if saga.Status == StatusCompensating {
+		return r.compensate(ctx, def, saga, data)
+	}
+
+	err := r.forward(ctx, def, saga, data)
+	if err == nil || saga.Status != StatusCompensating {
+		return err
+	}
+
+	if cerr := r.compensate(ctx, def, saga, data); cerr != nil {
+		logf.Error(ctx, cerr, "saga: compensation of %s %s failed", saga.Kind, saga.ID)
+	}
+	return err
+}
+
+func (r *Runner) forward(ctx context.Context, def Definition, saga *Saga, data interface{}) error {
+	for saga.Step < len(def.Steps) {
+		step := def.Steps[saga.Step]
+		saga.StepName = step.Name
+
+		if err := r.attempt(ctx, saga, step.Do, data); err != nil {
+			saga.Error = err.Error()
+			if !pivoted(def, saga.Step) {
+				logf.Error(ctx, err, "saga: %s %s failed at step %s, compensating", saga.Kind, saga.ID, step.Name)
+				saga.Status = StatusCompensating
+				saga.Step--
+				r.save(ctx, def, saga, data)
+				return err
+			}
+			r.park(ctx, def, saga, data, err)
+			return err
+		}
+
+		saga.Step++
+		saga.Error = ""
+		r.save(ctx, def, saga, data)
+	}
+
+	saga.Status = StatusCompleted
+	saga.StepName = ""
+	r.save(ctx, def, saga, data)
+	return nil
+}
+
+func (r *Runner) compensate(ctx context.Context, def Definition, saga *Saga, data interface{}) error {
+	for saga.Step >= 0 {
+		step := def.Steps[saga.Step]
+		saga.StepName = step.Name
+
+		if step.Compensate != nil {
+			if err := r.attempt(ctx, saga, step.Compensate, data); err != nil {
+				r.park(ctx, def, saga, data, err)
+				return err
+			}
+		}
+		saga.Step--
+		r.save(ctx, def, saga, data)
+	}
+
+	saga.Status = StatusCompensated
+	saga.Step = 0
+	saga.StepName = ""
+	r.save(ctx, def, saga, data)
+	return nil
+}
+
+// attempt calls f until it succeeds, fails with an error that can't be retried or runs out of attempts
+func (r *Runner) attempt(ctx context.Context, saga *Saga, f StepFunc, data interface{}) error {
+	var err error
+	for attempt := 1; attempt <= r.config.Attempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(backoff(r.config.Backoff, attempt-1)):
+			}
+		}
+
+		if err = f(ctx, saga, data); err == nil || !retryable(err) {
+			return err
+		}
+		logf.Debug(ctx, "saga: %s %s step %s attempt %d failed: %v", saga.Kind, saga.ID, saga.StepName, attempt, err)
+	}
+	return err
+}
+
+// park leaves a saga that can't make progress for the resumer, it is left for an operator once it was resumed
+// MaxResumes times
+func (r *Runner) park(ctx context.Context, def Definition, saga *Saga, data interface{}, err error) {
+	saga.Stuck = true
+	saga.Error = err.Error()
+	logf.Error(ctx, err, "saga: %s %s parked at step %s after %d resumes", saga.Kind, saga.ID, saga.StepName, saga.Resumes)
+	r.save(ctx, def, saga, data)
+
+	if r.store == nil {
+		return
+	}
+	if saga.Resumes >= r.config.MaxResumes {
+		logf.Error(ctx, err, "saga: %s %s needs an operator to re-drive it", saga.Kind, saga.ID)
+		if err := r.store.unschedule(ctx, saga.ID); err != nil {
+			logf.Error(ctx, err, "saga: unable to unschedule %s", saga.ID)
+		}
+		return
+	}
+	if err := r.store.schedule(ctx, saga.ID, r.now().Add(backoff(r.config.ResumeBackoff, saga.Resumes+1))); err != nil {
+		logf.Error(ctx, err, "saga: unable to schedule %s", saga.ID)
+	}
+}
+
+// save persists the saga and its data. While a saga is running it is scheduled for when its lease expires so it is
+// resumed if the instance stops. A saga that can't be saved keeps running, it just can't be resumed. Once past its pivot
+// the data is redacted before it is persisted, the steps keep the data they were given.
+func (r *Runner) save(ctx context.Context, def Definition, saga *Saga, data interface{}) {
+	persisted := data
+	if def.Redact != nil && pivoted(def, saga.Step) {
+		persisted = def.Redact(data)
+	}
+
+	payload, err := json.Marshal(persisted)
+	if err != nil {
+		logf.Error(ctx, err, "saga: unable to marshal data of %s", saga.ID)
+		return
+	}
+	saga.Data = payload
+	saga.UpdatedAt = r.now()
+
+	if r.store == nil {
+		return
+	}
+	if err := r.store.save(ctx, saga); err != nil {
+		logf.Error(ctx, err, "saga: unable to save %s", saga.ID)
+		return
+	}
+	if !saga.Status.Terminal() && !saga.Stuck {
+		if _, err := r.store.lease(ctx, saga.ID, r.owner, r.config.Lease); err != nil {
+			logf.Error(ctx, err, "saga: unable to renew lease of %s", saga.ID)
+		}
+		if err := r.store.schedule(ctx, saga.ID, r.now().Add(r.config.Lease)); err != nil {
+			logf.Error(ctx, err, "saga: unable to schedule %s", saga.ID)
+		}
+	}
+}
+
+func (r *Runner) release(ctx context.Context, saga *Saga) {
+	r.releaseLease(ctx, saga.ID)
+}
+
+func (r *Runner) releaseLease(ctx context.Context, id string) {
+	if err := r.store.release(ctx, id, r.owner); err != nil {
+		logf.Error(ctx, err, "saga: unable to release lease of %s", id)
+	}
+}
+
+// pivoted returns true if a pivot step before step has completed
+func pivoted(def Definition, step int) bool {
+	for i := 0; i < step; i++ {
+		if def.Steps[i].Pivot {
+			return true
+		}
+	}
+	return false
+}
+
+// retryable returns true for errors a downstream may recover from
+func retryable(err error) bool {
+	switch anzerrors.GetStatusCode(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Internal, codes.Unknown:
+		return true
+	default:
+		return false
+	}
+}
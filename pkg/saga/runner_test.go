@@ -0,0 +1,318 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+)
+
+type testData struct {
+	Done []string `json:"done"`
+}
+
+var (
+	errUnavailable = anzerrors.New(codes.Unavailable, "failed request",
+		anzerrors.NewErrorInfo(context.Background(), anzcodes.DownstreamFailure, "service unavailable"))
+	errInvalid = anzerrors.New(codes.InvalidArgument, "failed request",
+		anzerrors.NewErrorInfo(context.Background(), anzcodes.ValidationFailure, "invalid"))
+)
+
+// testSaga has steps a, b (the pivot) and c, failures maps a step to the errors it returns on successive calls
+func testSaga(failures map[string][]error) Definition {
+	step := func(name string) StepFunc {
+		return func(_ context.Context, _ *Saga, data interface{}) error {
+			if errs := failures[name]; len(errs) > 0 {
+				failures[name] = errs[1:]
+				if errs[0] != nil {
+					return errs[0]
+				}
+			}
+			d := data.(*testData)
+			d.Done = append(d.Done, name)
+			return nil
+		}
+	}
+	undo := func(name string) StepFunc {
+		return func(_ context.Context, _ *Saga, data interface{}) error {
+			d := data.(*testData)
+			d.Done = append(d.Done, "undo "+name)
+			return nil
+		}
+	}
+
+	return Definition{
+		Kind: "test",
+		Steps: []Step{
+			{Name: "a", Do: step("a"), Compensate: undo("a")},
+			{Name: "b", Do: step("b"), Compensate: undo("b"), Pivot: true},
+			{Name: "c", Do: step("c")},
+		},
+		NewData: func() interface{} {
+			return &testData{}
+		},
+	}
+}
+
+func newTestRunner(t *testing.T) (*Runner, *miniredis.Miniredis) {
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+
+	r := NewRunner(context.Background(), &Config{
+		Prefix:        "test:",
+		Attempts:      2,
+		Backoff:       time.Millisecond,
+		ResumeBackoff: time.Minute,
+		MaxResumes:    2,
+	}, redis.NewClient(&redis.Options{Addr: s.Addr()}))
+	return r, s
+}
+
+func TestRunner_Start(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   map[string][]error
+		wantDone   []string
+		wantStatus Status
+		wantStuck  bool
+		wantErr    error
+	}{
+		{
+			name:       "completes",
+			wantDone:   []string{"a", "b", "c"},
+			wantStatus: StatusCompleted,
+		},
+		{
+			name:       "retries a step",
+			failures:   map[string][]error{"c": {errUnavailable}},
+			wantDone:   []string{"a", "b", "c"},
+			wantStatus: StatusCompleted,
+		},
+		{
+			name:       "compensates a failure before the pivot",
+			failures:   map[string][]error{"b": {errInvalid}},
+			wantDone:   []string{"a", "undo a"},
+			wantStatus: StatusCompensated,
+			wantErr:    errInvalid,
+		},
+		{
+			name:       "compensates when attempts are exhausted before the pivot",
+			failures:   map[string][]error{"b": {errUnavailable, errUnavailable}},
+			wantDone:   []string{"a", "undo a"},
+			wantStatus: StatusCompensated,
+			wantErr:    errUnavailable,
+		},
+		{
+			name:       "parks a failure after the pivot",
+			failures:   map[string][]error{"c": {errUnavailable, errUnavailable}},
+			wantDone:   []string{"a", "b"},
+			wantStatus: StatusRunning,
+			wantStuck:  true,
+			wantErr:    errUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			r, _ := newTestRunner(t)
+			def := testSaga(test.failures)
+			r.Register(def)
+			data := &testData{}
+
+			saga, err := r.Start(context.Background(), def, data)
+
+			assert.Equal(t, test.wantErr, err)
+			assert.Equal(t, test.wantDone, data.Done)
+			assert.Equal(t, test.wantStatus, saga.Status)
+			assert.Equal(t, test.wantStuck, saga.Stuck)
+
+			stored, err := r.Get(context.Background(), saga.ID)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantStatus, stored.Status)
+			assert.JSONEq(t, string(saga.Data), string(stored.Data))
+
+			active, err := r.Active(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, !test.wantStatus.Terminal(), len(active) == 1)
+		})
+	}
+}
+
+func TestRunner_ResumeDue(t *testing.T) {
+	r, s := newTestRunner(t)
+	failures := map[string][]error{"c": {errUnavailable, errUnavailable}}
+	def := testSaga(failures)
+	r.Register(def)
+
+	saga, err := r.Start(context.Background(), def, &testData{})
+	require.Error(t, err)
+	require.True(t, saga.Stuck)
+
+	// not due until the resume backoff has passed
+	r.ResumeDue(context.Background())
+	stored, err := r.Get(context.Background(), saga.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusRunning, stored.Status)
+
+	s.FastForward(time.Minute)
+	r.now = func() time.Time { return time.Now().Add(time.Minute) }
+	r.ResumeDue(context.Background())
+
+	stored, err = r.Get(context.Background(), saga.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, stored.Status)
+	assert.False(t, stored.Stuck)
+	assert.Equal(t, 1, stored.Resumes)
+	assert.JSONEq(t, `{"done":["a","b","c"]}`, string(stored.Data))
+}
+
+func TestRunner_ResumeAfterRestart(t *testing.T) {
+	r, s := newTestRunner(t)
+	def := testSaga(nil)
+	r.Register(def)
+
+	// a saga left running by an instance that stopped after step a
+	stopped := &Saga{ID: "stopped", Kind: def.Kind, Status: StatusRunning, Step: 1, Data: []byte(`{"done":["a"]}`)}
+	require.NoError(t, r.store.save(context.Background(), stopped))
+	require.NoError(t, r.store.schedule(context.Background(), stopped.ID, time.Now()))
+	leased, err := r.store.lease(context.Background(), stopped.ID, "stopped instance", time.Minute)
+	require.NoError(t, err)
+	require.True(t, leased)
+
+	// another instance can't resume it while it is leased
+	r.ResumeDue(context.Background())
+	stored, err := r.Get(context.Background(), stopped.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusRunning, stored.Status)
+
+	s.FastForward(time.Minute)
+	r.ResumeDue(context.Background())
+
+	stored, err = r.Get(context.Background(), stopped.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, stored.Status)
+	assert.JSONEq(t, `{"done":["a","b","c"]}`, string(stored.Data))
+}
+
+func TestRunner_MaxResumes(t *testing.T) {
+	r, s := newTestRunner(t)
+	failures := map[string][]error{"c": {errUnavailable, errUnavailable, errUnavailable, errUnavailable, errUnavailable, errUnavailable}}
+	def := testSaga(failures)
+	r.Register(def)
+
+	saga, err := r.Start(context.Background(), def, &testData{})
+	require.Error(t, err)
+
+	for i := 1; i <= 2; i++ {
+		_, err = r.resume(context.Background(), saga.ID, false)
+		require.Error(t, err)
+	}
+
+	due, err := r.store.due(context.Background(), time.Now().Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, due, "a saga resumed MaxResumes times is left for an operator")
+	assert.True(t, s.Exists("test:saga:"+saga.ID))
+
+	redriven, err := r.Redrive(context.Background(), saga.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, redriven.Status)
+	assert.Equal(t, 0, redriven.Resumes)
+}
+
+func TestRunner_Redact(t *testing.T) {
+	r, _ := newTestRunner(t)
+	failures := map[string][]error{"c": {errUnavailable, errUnavailable}}
+	def := testSaga(failures)
+	// a is only needed until the pivot
+	def.Redact = func(data interface{}) interface{} {
+		d := *data.(*testData)
+		d.Done = d.Done[1:]
+		return &d
+	}
+	r.Register(def)
+	data := &testData{}
+
+	saga, err := r.Start(context.Background(), def, data)
+	require.Error(t, err)
+	assert.Equal(t, []string{"a", "b"}, data.Done, "the steps keep the data they were given")
+
+	stored, err := r.Get(context.Background(), saga.ID)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"done":["b"]}`, string(stored.Data))
+
+	_, err = r.Redrive(context.Background(), saga.ID)
+	require.NoError(t, err)
+	stored, err = r.Get(context.Background(), saga.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, stored.Status)
+	assert.JSONEq(t, `{"done":["c"]}`, string(stored.Data))
+}
+
+func TestRunner_Claim(t *testing.T) {
+	r, _ := newTestRunner(t)
+	first, second := &Saga{ID: "first"}, &Saga{ID: "second"}
+
+	ok, err := r.Claim(context.Background(), "card", first)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = r.Claim(context.Background(), "card", first)
+	require.NoError(t, err)
+	assert.True(t, ok, "a claim can be made again by its holder")
+
+	ok, err = r.Claim(context.Background(), "card", second)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, r.Unclaim(context.Background(), "card", second))
+	ok, err = r.Claim(context.Background(), "card", second)
+	require.NoError(t, err)
+	assert.False(t, ok, "only the holder can release a claim")
+
+	require.NoError(t, r.Unclaim(context.Background(), "card", first))
+	ok, err = r.Claim(context.Background(), "card", second)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestRunner_Nil(t *testing.T) {
+	var r *Runner
+	failures := map[string][]error{"c": {errUnavailable}}
+	def := testSaga(failures)
+	data := &testData{}
+
+	r.Register(def)
+	saga, err := r.Start(context.Background(), def, data)
+
+	assert.Equal(t, errUnavailable, err, "steps are tried once")
+	assert.True(t, saga.Stuck)
+	assert.Equal(t, []string{"a", "b"}, data.Done)
+
+	ok, err := r.Claim(context.Background(), "card", saga)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = r.Get(context.Background(), saga.ID)
+	assert.Equal(t, ErrNotFound, err)
+	assert.NoError(t, r.Resume(context.Background())())
+}
+
+func TestNewRunner_NilConfig(t *testing.T) {
+	assert.Nil(t, NewRunner(context.Background(), nil, nil))
+}
+
+func TestRetryable(t *testing.T) {
+	assert.True(t, retryable(errUnavailable))
+	assert.False(t, retryable(errInvalid))
+	assert.True(t, retryable(errors.New("plain errors are unknown")))
+}
@@ -0,0 +1,139 @@
+// Package saga runs operations that span several systems as sagas persisted in Redis. Every step is retried, a saga
+// that fails before its pivot step is compensated, and one that fails after it is parked and resumed in the background
+// until it completes.
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Status of a saga
+type Status string
+
+const (
+	StatusRunning      Status = "RUNNING"
+	StatusCompensating Status = "COMPENSATING"
+	StatusCompleted    Status = "COMPLETED"
+	StatusCompensated  Status = "COMPENSATED"
+)
+
+// Terminal returns true once a saga will make no further progress
+func (s Status) Terminal() bool {
+	return s == StatusCompleted || s == StatusCompensated
+}
+
+// Saga is the persisted state of a run of a Definition
+type Saga struct {
+	ID     string `json:"id"`
+	Kind   string `json:"kind"`
+	Status Status `json:"status"`
+	// Step is the index of the next step to run, or while compensating of the next step to compensate
+	Step int `json:"step"`
+	// StepName names Step for operators
+	StepName string `json:"stepName,omitempty"`
+	// Resumes counts the times the saga was resumed in the background since it was started or last re-driven
+	Resumes int `json:"resumes"`
+	// Stuck is set once a step exhausted its attempts, the saga waits for the resumer or an operator
+	Stuck bool `json:"stuck"`
+	// Error is the last error of the current step
+	Error string `json:"error,omitempty"`
+	// Data is shared by the steps, it is persisted after every step
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"createdAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+// StepFunc runs or compensates a step with the data of the saga, it must be safe to call again after a failure or a
+// restart as a step is retried until it succeeds
+type StepFunc func(ctx context.Context, saga *Saga, data interface{}) error
+
+// Step of a saga
+type Step struct {
+	Name string
+	Do   StepFunc
+	// Compensate undoes Do when a later step fails before the pivot, nil if there is nothing to undo
+	Compensate StepFunc
+	// Pivot marks the step after which a saga can no longer be compensated, later steps are retried until they succeed
+	Pivot bool
+}
+
+// Definition describes the steps of a kind of saga
+type Definition struct {
+	Kind  string
+	Steps []Step
+	// NewData returns a pointer the persisted data of a saga is unmarshalled into when it is resumed
+	NewData func() interface{}
+	// Context returns the context a resumed saga runs its steps with, e.g. the identity of the customer it runs for
+	Context func(ctx context.Context, data interface{}) (context.Context, error)
+	// Redact returns a copy of the data to persist once the pivot step has succeeded, without the personal details only
+	// the steps up to the pivot need. The data is persisted as is if nil.
+	Redact func(data interface{}) interface{}
+}
+
+// Config enables persisted sagas
+type Config struct {
+	// Prefix to be added to every key, can be empty
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty" mapstructure:"prefix"`
+	// Attempts is how many times a step is tried before the saga is parked, defaults to 3
+	Attempts int `json:"attempts,omitempty" yaml:"attempts,omitempty" mapstructure:"attempts" validate:"gte=0"`
+	// Backoff is the delay before the second attempt of a step, it doubles with every further attempt. Defaults to 200ms
+	Backoff time.Duration `json:"backoff,omitempty" yaml:"backoff,omitempty" mapstructure:"backoff"`
+	// Lease is how long a running saga is owned by an instance before another may resume it, defaults to 2m
+	Lease time.Duration `json:"lease,omitempty" yaml:"lease,omitempty" mapstructure:"lease"`
+	// ResumeInterval is how often parked sagas are looked for, defaults to 30s
+	ResumeInterval time.Duration `json:"resumeInterval,omitempty" yaml:"resumeInterval,omitempty" mapstructure:"resumeInterval"`
+	// ResumeBackoff is the delay before a parked saga is first resumed, it doubles with every resume. Defaults to 1m
+	ResumeBackoff time.Duration `json:"resumeBackoff,omitempty" yaml:"resumeBackoff,omitempty" mapstructure:"resumeBackoff"`
+	// MaxResumes is how many times a parked saga is resumed before it is left for an operator, defaults to 10
+	MaxResumes int `json:"maxResumes,omitempty" yaml:"maxResumes,omitempty" mapstructure:"maxResumes" validate:"gte=0"`
+	// Retention is how long a saga is kept after it was last updated, defaults to 7 days
+	Retention time.Duration `json:"retention,omitempty" yaml:"retention,omitempty" mapstructure:"retention"`
+	// Scopes are requested for the system JWT a saga is resumed with
+	Scopes []string `json:"scopes,omitempty" yaml:"scopes,omitempty" mapstructure:"scopes"`
+}
+
+const (
+	defaultAttempts       = 3
+	defaultBackoff        = 200 * time.Millisecond
+	defaultLease          = 2 * time.Minute
+	defaultResumeInterval = 30 * time.Second
+	defaultResumeBackoff  = time.Minute
+	defaultMaxResumes     = 10
+	defaultRetention      = 7 * 24 * time.Hour
+)
+
+func (c Config) withDefaults() Config {
+	if c.Attempts <= 0 {
+		c.Attempts = defaultAttempts
+	}
+	if c.Backoff <= 0 {
+		c.Backoff = defaultBackoff
+	}
+	if c.Lease <= 0 {
+		c.Lease = defaultLease
+	}
+	if c.ResumeInterval <= 0 {
+		c.ResumeInterval = defaultResumeInterval
+	}
+	if c.ResumeBackoff <= 0 {
+		c.ResumeBackoff = defaultResumeBackoff
+	}
+	if c.MaxResumes <= 0 {
+		c.MaxResumes = defaultMaxResumes
+	}
+	if c.Retention <= 0 {
+		c.Retention = defaultRetention
+	}
+	return c
+}
+
+// backoff doubles base for every previous try
+func backoff(base time.Duration, tries int) time.Duration {
+	delay := base
+	for i := 1; i < tries && delay < time.Hour; i++ {
+		delay *= 2
+	}
+	return delay
+}
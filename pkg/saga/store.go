@@ -0,0 +1,160 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+const (
+	sagaGroup   = "saga:"
+	activeKey   = "saga:active"
+	dueKey      = "saga:due"
+	leaseGroup  = "saga:lease:"
+	claimsGroup = "saga:claim:"
+)
+
+// ErrNotFound is returned for a saga that does not exist or has expired
+var ErrNotFound = errors.New("saga not found")
+
+// unlock deletes a key only while it holds the expected value
+var unlock = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`)
+
+// store persists sagas in Redis. Sagas that are not finished are kept in a set so operators can list them, and those
+// waiting to be resumed in a sorted set scored by when they are due.
+type store struct {
+	client    redis.Cmdable
+	prefix    string
+	retention time.Duration
+}
+
+func (s *store) save(ctx context.Context, saga *Saga) error {
+	payload, err := json.Marshal(saga)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal saga")
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.prefix+sagaGroup+saga.ID, payload, s.retention)
+		if saga.Status.Terminal() {
+			pipe.SRem(ctx, s.prefix+activeKey, saga.ID)
+			pipe.ZRem(ctx, s.prefix+dueKey, saga.ID)
+		} else {
+			pipe.SAdd(ctx, s.prefix+activeKey, saga.ID)
+		}
+		return nil
+	})
+	return errors.Wrap(err, "unable to save saga")
+}
+
+func (s *store) get(ctx context.Context, id string) (*Saga, error) {
+	payload, err := s.client.Get(ctx, s.prefix+sagaGroup+id).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read saga")
+	}
+
+	var saga Saga
+	if err := json.Unmarshal(payload, &saga); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal saga")
+	}
+	return &saga, nil
+}
+
+// active returns every saga that is not finished, sagas that expired are forgotten
+func (s *store) active(ctx context.Context) ([]*Saga, error) {
+	ids, err := s.client.SMembers(ctx, s.prefix+activeKey).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list sagas")
+	}
+
+	sagas := make([]*Saga, 0, len(ids))
+	for _, id := range ids {
+		saga, err := s.get(ctx, id)
+		if err == ErrNotFound {
+			s.client.SRem(ctx, s.prefix+activeKey, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sagas = append(sagas, saga)
+	}
+	return sagas, nil
+}
+
+func (s *store) schedule(ctx context.Context, id string, at time.Time) error {
+	err := s.client.ZAdd(ctx, s.prefix+dueKey, &redis.Z{Score: float64(at.Unix()), Member: id}).Err()
+	return errors.Wrap(err, "unable to schedule saga")
+}
+
+func (s *store) unschedule(ctx context.Context, id string) error {
+	return errors.Wrap(s.client.ZRem(ctx, s.prefix+dueKey, id).Err(), "unable to unschedule saga")
+}
+
+// due returns the sagas scheduled at or before now
+func (s *store) due(ctx context.Context, now time.Time) ([]string, error) {
+	ids, err := s.client.ZRangeByScore(ctx, s.prefix+dueKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.Unix(), 10),
+	}).Result()
+	return ids, errors.Wrap(err, "unable to list due sagas")
+}
+
+// lease makes owner the only runner of a saga until ttl has passed, it is renewed if owner already holds it
+func (s *store) lease(ctx context.Context, id, owner string, ttl time.Duration) (bool, error) {
+	key := s.prefix + leaseGroup + id
+	ok, err := s.client.SetNX(ctx, key, owner, ttl).Result()
+	if err != nil {
+		return false, errors.Wrap(err, "unable to lease saga")
+	}
+	if ok {
+		return true, nil
+	}
+
+	holder, err := s.client.Get(ctx, key).Result()
+	if err != nil && err != redis.Nil {
+		return false, errors.Wrap(err, "unable to lease saga")
+	}
+	if holder != owner {
+		return false, nil
+	}
+	return true, errors.Wrap(s.client.Expire(ctx, key, ttl).Err(), "unable to renew saga lease")
+}
+
+func (s *store) release(ctx context.Context, id, owner string) error {
+	err := unlock.Run(ctx, s.client, []string{s.prefix + leaseGroup + id}, owner).Err()
+	return errors.Wrap(err, "unable to release saga lease")
+}
+
+// claim reserves key for a saga, it succeeds if the saga already holds the claim
+func (s *store) claim(ctx context.Context, key, id string) (bool, error) {
+	ok, err := s.client.SetNX(ctx, s.prefix+claimsGroup+key, id, s.retention).Result()
+	if err != nil {
+		return false, errors.Wrap(err, "unable to claim key")
+	}
+	if ok {
+		return true, nil
+	}
+
+	holder, err := s.client.Get(ctx, s.prefix+claimsGroup+key).Result()
+	if err != nil && err != redis.Nil {
+		return false, errors.Wrap(err, "unable to claim key")
+	}
+	return holder == id, nil
+}
+
+func (s *store) unclaim(ctx context.Context, key, id string) error {
+	err := unlock.Run(ctx, s.client, []string{s.prefix + claimsGroup + key}, id).Err()
+	return errors.Wrap(err, "unable to release claim")
+}
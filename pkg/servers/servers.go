@@ -13,6 +13,9 @@ import (
 	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
 
 	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/anzx/fabric-cards/pkg/ratelimit"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 
@@ -56,10 +59,16 @@ func GRPCServer(registrations []GRPCRegistration, interceptors []grpc.UnaryServe
 	return server
 }
 
-func RunOperationsServer(ctx context.Context, appName string, port int) func() error {
+// HTTPRegistration is the signature for registering additional handlers on the operations server.
+type HTTPRegistration func(mux *http.ServeMux)
+
+func RunOperationsServer(ctx context.Context, appName string, port int, registrations ...HTTPRegistration) func() error {
 	return func() error {
 		mux := http.NewServeMux()
 		opentelemetry.Serve(mux)
+		for _, register := range registrations {
+			register(mux)
+		}
 		return httpServer(ctx, mux, appName, port)
 	}
 }
@@ -111,6 +120,22 @@ func httpServer(ctx context.Context, mux *http.ServeMux, appName string, port in
 	return <-serverErr
 }
 
+// RateLimitForwardResponse sets the rate limit quota headers of a successful response from its gRPC trailers
+func RateLimitForwardResponse(ctx context.Context, w http.ResponseWriter, _ proto.Message) error {
+	if md, ok := runtime.ServerMetadataFromContext(ctx); ok {
+		ratelimit.QuotaHeaders(w.Header(), md.TrailerMD)
+	}
+	return nil
+}
+
+// RateLimitErrorHandler sets the rate limit quota headers of an error response, e.g. a 429, from its gRPC trailers
+func RateLimitErrorHandler(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	if md, ok := runtime.ServerMetadataFromContext(ctx); ok {
+		ratelimit.QuotaHeaders(w.Header(), md.TrailerMD)
+	}
+	runtime.DefaultHTTPErrorHandler(ctx, mux, marshaler, w, r, err)
+}
+
 type RestRegistration func(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) (err error)
 
 // CreateRestServer stands up a server to handle rest requests for the API
@@ -118,6 +143,8 @@ func CreateRestServer(ctx context.Context, port int, serveMux *runtime.ServeMux,
 	if serveMux == nil {
 		serveMux = runtime.NewServeMux(
 			runtime.WithIncomingHeaderMatcher(otelHTTP.TraceIncomingHeaderMatcher),
+			runtime.WithForwardResponseOption(RateLimitForwardResponse),
+			runtime.WithErrorHandler(RateLimitErrorHandler),
 			runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.HTTPBodyMarshaler{
 				Marshaler: &runtime.JSONPb{
 					MarshalOptions: protojson.MarshalOptions{
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -16,6 +17,11 @@ import (
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/anzx/fabric-cards/pkg/ratelimit"
 
 	"github.com/anzx/pkg/monitoring/names"
 )
@@ -98,6 +104,28 @@ func TestCreateRestServer(t *testing.T) {
 	})
 }
 
+func TestRateLimitHeaders(t *testing.T) {
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+		TrailerMD: metadata.Pairs(ratelimit.TrailerLimit, "5", ratelimit.TrailerRemaining, "0", ratelimit.TrailerRetryAfter, "60"),
+	})
+
+	t.Run("forward response", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		require.NoError(t, RateLimitForwardResponse(ctx, w, nil))
+		assert.Equal(t, "5", w.Header().Get(ratelimit.HeaderLimit))
+		assert.Equal(t, "0", w.Header().Get(ratelimit.HeaderRemaining))
+	})
+
+	t.Run("error response", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/v1/cards/activate", nil)
+		RateLimitErrorHandler(ctx, runtime.NewServeMux(), &runtime.JSONPb{}, w, r, status.Error(codes.ResourceExhausted, "rate limit check failed"))
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "60", w.Header().Get(ratelimit.HeaderRetryAfter))
+		assert.Equal(t, "0", w.Header().Get(ratelimit.HeaderRemaining))
+	})
+}
+
 func TestSignalListener(t *testing.T) {
 	t.Run("", func(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
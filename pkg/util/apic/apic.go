@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
+	"time"
 
 	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
 
@@ -32,6 +34,15 @@ const (
 type Client struct {
 	clientID   string
 	httpClient *http.Client
+	resilience *ResilienceConfig
+
+	defaultTimeout time.Duration
+	deadlineMargin time.Duration
+	timeouts       map[string]time.Duration
+
+	lock      sync.Mutex
+	breakers  map[string]*breaker
+	bulkheads map[string]chan struct{}
 }
 
 type Request struct {
@@ -58,7 +69,7 @@ type Clienter interface {
 	Do(context.Context, *Request, string) ([]byte, error)
 }
 
-func NewAPICClient(ctx context.Context, clientIDEnvKey string, httpClient *http.Client, gsmClient *gsm.Client) (*Client, error) {
+func NewAPICClient(ctx context.Context, clientIDEnvKey string, httpClient *http.Client, gsmClient *gsm.Client, opts ...Option) (*Client, error) {
 	if clientIDEnvKey == "" {
 		err := anzerrors.New(codes.Internal, "failed to create APIc adapter",
 			anzerrors.NewErrorInfo(ctx, anzcodes.StartupFailure, "APIc secret key was empty"))
@@ -73,14 +84,26 @@ func NewAPICClient(ctx context.Context, clientIDEnvKey string, httpClient *http.
 			anzerrors.NewErrorInfo(ctx, anzcodes.StartupFailure, "unable to find clientID"))
 	}
 
-	return &Client{
+	c := &Client{
 		clientID:   clientID,
 		httpClient: httpClient,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
 }
 
 func (c *Client) Do(ctx context.Context, r *Request, operation string) ([]byte, error) {
-	request, err := http.NewRequestWithContext(ctx, r.Method, r.Destination, bytes.NewBuffer(r.Body))
+	requestCtx, cancel, err := c.budget(ctx, operation)
+	if err != nil {
+		logf.Error(ctx, err, "APIc client has no time left for %v", operation)
+		return nil, err
+	}
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(requestCtx, r.Method, r.Destination, bytes.NewBuffer(r.Body))
 	if err != nil {
 		logf.Error(ctx, err, "APIc client failed to create request")
 		// TODO: (ElliotMJackson) evaluate appropriate err code
@@ -97,7 +120,19 @@ func (c *Client) Do(ctx context.Context, r *Request, operation string) ([]byte,
 		return nil, err
 	}
 
+	done, err := c.guard(ctx, operation)
+	if err != nil {
+		logf.Error(ctx, err, "APIc client rejected request to %v", operation)
+		return nil, err
+	}
+
 	resp, err := c.httpClient.Do(request)
+	done(failed(ctx, resp, err))
+	if err != nil && requestCtx.Err() == context.DeadlineExceeded {
+		logf.Error(ctx, err, "APIc client request to %v timed out", operation)
+		return nil, anzerrors.Wrap(err, codes.DeadlineExceeded, "failed request",
+			anzerrors.NewErrorInfo(ctx, anzcodes.DownstreamFailure, "request timed out"))
+	}
 	if err != nil {
 		logf.Error(ctx, err, "APIc client failed service unavailable")
 		return nil, anzerrors.Wrap(err, codes.Unavailable, "failed request",
@@ -0,0 +1,292 @@
+package apic
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"google.golang.org/grpc/codes"
+
+	logf "github.com/anzx/fabric-cards/pkg/middleware/log"
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+)
+
+// Error codes returned without calling the downstream, so clients can tell them apart from a downstream failure
+const (
+	CircuitOpen  anzcodes.Code = 30001
+	BulkheadFull anzcodes.Code = 30002
+)
+
+// State of a circuit breaker
+type State int
+
+const (
+	// StateClosed lets every request through
+	StateClosed State = iota
+	// StateHalfOpen lets a limited number of trial requests through after the open timeout
+	StateHalfOpen
+	// StateOpen rejects every request until the open timeout passes
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 30 * time.Second
+	defaultHalfOpenRequests = 1
+)
+
+// ResilienceConfig protects a downstream with a circuit breaker and bulkhead per operation
+type ResilienceConfig struct {
+	// Breaker is disabled if not set
+	Breaker *BreakerConfig `json:"breaker,omitempty"       yaml:"breaker,omitempty"       mapstructure:"breaker"`
+	// MaxConcurrent is the number of requests in flight per operation, unlimited if zero
+	MaxConcurrent int `json:"maxConcurrent,omitempty" yaml:"maxConcurrent,omitempty" mapstructure:"maxConcurrent" validate:"gte=0"`
+}
+
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures which open the breaker, defaults to 5
+	FailureThreshold int `json:"failureThreshold,omitempty" yaml:"failureThreshold,omitempty" mapstructure:"failureThreshold" validate:"gte=0"`
+	// OpenTimeout is how long the breaker stays open before it lets trial requests through, defaults to 30s
+	OpenTimeout time.Duration `json:"openTimeout,omitempty"      yaml:"openTimeout,omitempty"      mapstructure:"openTimeout"      validate:"gte=0"`
+	// HalfOpenRequests is the number of successful trial requests which close the breaker, defaults to 1
+	HalfOpenRequests int `json:"halfOpenRequests,omitempty" yaml:"halfOpenRequests,omitempty" mapstructure:"halfOpenRequests" validate:"gte=0"`
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithResilience protects every operation of the client with a circuit breaker and bulkhead
+func WithResilience(config *ResilienceConfig) Option {
+	return func(c *Client) {
+		c.resilience = config
+	}
+}
+
+var (
+	meter = metric.Must(global.Meter("github.com/anzx/fabric-cards/pkg/util/apic"))
+
+	transitionCounter = meter.NewInt64Counter("apic.breaker.transitions",
+		metric.WithDescription("Circuit breaker state changes per operation"))
+	rejectedCounter = meter.NewInt64Counter("apic.breaker.rejected",
+		metric.WithDescription("Requests rejected by a circuit breaker or bulkhead without calling the downstream"))
+
+	// breakers of every client, observed for the breaker state metric
+	breakers sync.Map
+	_        = meter.NewInt64GaugeObserver("apic.breaker.state",
+		func(ctx context.Context, result metric.Int64ObserverResult) {
+			breakers.Range(func(_, b interface{}) bool {
+				result.Observe(int64(b.(*breaker).current()), attribute.String("operation", b.(*breaker).operation))
+				return true
+			})
+		},
+		metric.WithDescription("Circuit breaker state per operation, 0 closed, 1 half-open and 2 open"))
+)
+
+type breaker struct {
+	operation        string
+	failureThreshold int
+	openTimeout      time.Duration
+	halfOpenRequests int
+	now              func() time.Time
+
+	lock      sync.Mutex
+	state     State
+	failures  int
+	successes int
+	trials    int
+	openedAt  time.Time
+}
+
+func newBreaker(operation string, config BreakerConfig) *breaker {
+	b := &breaker{
+		operation:        operation,
+		failureThreshold: config.FailureThreshold,
+		openTimeout:      config.OpenTimeout,
+		halfOpenRequests: config.HalfOpenRequests,
+		now:              time.Now,
+	}
+	if b.failureThreshold <= 0 {
+		b.failureThreshold = defaultFailureThreshold
+	}
+	if b.openTimeout <= 0 {
+		b.openTimeout = defaultOpenTimeout
+	}
+	if b.halfOpenRequests <= 0 {
+		b.halfOpenRequests = defaultHalfOpenRequests
+	}
+	return b
+}
+
+func (b *breaker) current() State {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.state
+}
+
+// allow returns false if the request must be rejected, an allowed request must be followed by done
+func (b *breaker) allow(ctx context.Context) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state == StateOpen {
+		if b.now().Before(b.openedAt.Add(b.openTimeout)) {
+			return false
+		}
+		b.transition(ctx, StateHalfOpen)
+	}
+
+	if b.state == StateHalfOpen {
+		if b.trials >= b.halfOpenRequests {
+			return false
+		}
+		b.trials++
+	}
+
+	return true
+}
+
+// done records the outcome of an allowed request
+func (b *breaker) done(ctx context.Context, failed bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case StateClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.failureThreshold {
+			b.transition(ctx, StateOpen)
+		}
+	case StateHalfOpen:
+		b.trials--
+		if failed {
+			b.transition(ctx, StateOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= b.halfOpenRequests {
+			b.transition(ctx, StateClosed)
+		}
+	}
+}
+
+func (b *breaker) transition(ctx context.Context, state State) {
+	logf.Info(ctx, "APIc circuit breaker for %s changed from %s to %s", b.operation, b.state, state)
+	transitionCounter.Add(ctx, 1, attribute.String("operation", b.operation), attribute.String("state", state.String()))
+
+	b.state = state
+	b.failures = 0
+	b.successes = 0
+	b.trials = 0
+	if state == StateOpen {
+		b.openedAt = b.now()
+	}
+}
+
+// guard applies the breaker and bulkhead of operation, the returned func must be called with the outcome of the
+// request if it is allowed
+func (c *Client) guard(ctx context.Context, operation string) (func(failed bool), error) {
+	if c.resilience == nil {
+		return func(bool) {}, nil
+	}
+
+	b, bulkhead := c.protection(operation)
+
+	if b != nil && !b.allow(ctx) {
+		rejectedCounter.Add(ctx, 1, attribute.String("operation", operation), attribute.String("reason", "breaker"))
+		return nil, anzerrors.New(codes.Unavailable, "failed request",
+			anzerrors.NewErrorInfo(ctx, CircuitOpen, "circuit breaker open"),
+			anzerrors.WithRetryDelay(b.openTimeout))
+	}
+
+	if bulkhead != nil {
+		select {
+		case bulkhead <- struct{}{}:
+		default:
+			if b != nil {
+				// the request never reached the downstream so it doesn't count towards the breaker
+				b.release()
+			}
+			rejectedCounter.Add(ctx, 1, attribute.String("operation", operation), attribute.String("reason", "bulkhead"))
+			return nil, anzerrors.New(codes.Unavailable, "failed request",
+				anzerrors.NewErrorInfo(ctx, BulkheadFull, "too many concurrent requests"))
+		}
+	}
+
+	return func(failed bool) {
+		if bulkhead != nil {
+			<-bulkhead
+		}
+		if b != nil {
+			b.done(ctx, failed)
+		}
+	}, nil
+}
+
+// release gives back a half-open trial which was not used
+func (b *breaker) release() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state == StateHalfOpen && b.trials > 0 {
+		b.trials--
+	}
+}
+
+// protection returns the breaker and bulkhead of operation, creating them on first use
+func (c *Client) protection(operation string) (*breaker, chan struct{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.breakers == nil {
+		c.breakers = map[string]*breaker{}
+		c.bulkheads = map[string]chan struct{}{}
+	}
+
+	b, ok := c.breakers[operation]
+	if !ok && c.resilience.Breaker != nil {
+		b = newBreaker(operation, *c.resilience.Breaker)
+		c.breakers[operation] = b
+		breakers.Store(b, b)
+	}
+
+	bulkhead, ok := c.bulkheads[operation]
+	if !ok && c.resilience.MaxConcurrent > 0 {
+		bulkhead = make(chan struct{}, c.resilience.MaxConcurrent)
+		c.bulkheads[operation] = bulkhead
+	}
+
+	return b, bulkhead
+}
+
+// failed returns true if the outcome of a request indicates the downstream is unhealthy.
+// Client errors and requests cancelled by the caller are not failures.
+func failed(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+}
@@ -0,0 +1,166 @@
+package apic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+
+	"github.com/anzx/fabric-cards/pkg/util/testutil"
+	anzerrors "github.com/anzx/pkg/errors"
+)
+
+// newResilientClient returns a client for a server using handler, along with its URL and a count of its requests
+func newResilientClient(t *testing.T, handler http.HandlerFunc, config *ResilienceConfig) (*Client, string, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		handler(rw, req)
+	}))
+	t.Cleanup(server.Close)
+
+	return &Client{httpClient: server.Client(), resilience: config}, server.URL, &calls
+}
+
+func doRequest(c *Client, ctx context.Context, url string, operation string) error {
+	_, err := c.Do(ctx, NewRequest(http.MethodGet, url, nil), operation)
+	return err
+}
+
+func TestBreaker(t *testing.T) {
+	ctx := testutil.GetContext(true)
+
+	status := int32(http.StatusInternalServerError)
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(int(atomic.LoadInt32(&status)))
+	}
+	c, url, calls := newResilientClient(t, handler, &ResilienceConfig{
+		Breaker: &BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute, HalfOpenRequests: 1},
+	})
+
+	now := time.Now()
+	b, _ := c.protection("inquiry")
+	b.now = func() time.Time { return now }
+
+	// consecutive failures open the breaker
+	for i := 0; i < 2; i++ {
+		err := doRequest(c, ctx, url, "inquiry")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unexpected response from downstream")
+	}
+	assert.Equal(t, StateOpen, b.current())
+
+	// an open breaker fails fast without calling the downstream
+	err := doRequest(c, ctx, url, "inquiry")
+	require.Error(t, err)
+	assert.Equal(t, codes.Unavailable, anzerrors.GetStatusCode(err))
+	assert.Contains(t, err.Error(), "circuit breaker open")
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+
+	// other operations are not affected
+	atomic.StoreInt32(&status, http.StatusOK)
+	require.NoError(t, doRequest(c, ctx, url, "update"))
+
+	// after the open timeout a failed trial opens it again
+	atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+	now = now.Add(time.Minute)
+	require.Error(t, doRequest(c, ctx, url, "inquiry"))
+	assert.Equal(t, StateOpen, b.current())
+
+	// and a successful trial closes it
+	atomic.StoreInt32(&status, http.StatusOK)
+	now = now.Add(time.Minute)
+	require.NoError(t, doRequest(c, ctx, url, "inquiry"))
+	assert.Equal(t, StateClosed, b.current())
+}
+
+func TestBreaker_ClientErrors(t *testing.T) {
+	ctx := testutil.GetContext(true)
+
+	c, url, _ := newResilientClient(t, func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNotFound)
+	}, &ResilienceConfig{Breaker: &BreakerConfig{FailureThreshold: 1}})
+
+	// client errors don't open the breaker
+	for i := 0; i < 3; i++ {
+		err := doRequest(c, ctx, url, "inquiry")
+		require.Error(t, err)
+		assert.Equal(t, codes.NotFound, anzerrors.GetStatusCode(err))
+	}
+	b, _ := c.protection("inquiry")
+	assert.Equal(t, StateClosed, b.current())
+}
+
+func TestBreaker_HalfOpenRequests(t *testing.T) {
+	ctx := context.Background()
+	b := newBreaker("inquiry", BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Second, HalfOpenRequests: 2})
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	require.True(t, b.allow(ctx))
+	b.done(ctx, true)
+	assert.False(t, b.allow(ctx))
+
+	now = now.Add(time.Second)
+	require.True(t, b.allow(ctx))
+	require.True(t, b.allow(ctx))
+	// only the configured number of trials are let through at once
+	assert.False(t, b.allow(ctx))
+	assert.Equal(t, StateHalfOpen, b.current())
+
+	b.done(ctx, false)
+	assert.Equal(t, StateHalfOpen, b.current())
+	b.done(ctx, false)
+	assert.Equal(t, StateClosed, b.current())
+}
+
+func TestBulkhead(t *testing.T) {
+	ctx := testutil.GetContext(true)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	defer server.Close()
+
+	c := &Client{httpClient: server.Client(), resilience: &ResilienceConfig{MaxConcurrent: 1}}
+
+	errs := make(chan error)
+	go func() { errs <- doRequest(c, ctx, server.URL, "inquiry") }()
+	<-started
+
+	err := doRequest(c, ctx, server.URL, "inquiry")
+	require.Error(t, err)
+	assert.Equal(t, codes.Unavailable, anzerrors.GetStatusCode(err))
+	assert.Contains(t, err.Error(), "too many concurrent requests")
+
+	close(release)
+	require.NoError(t, <-errs)
+
+	// the slot is released once the request completes
+	go func() { <-started }()
+	require.NoError(t, doRequest(c, ctx, server.URL, "inquiry"))
+}
+
+func TestResilience_NotConfigured(t *testing.T) {
+	ctx := testutil.GetContext(true)
+
+	c, url, calls := newResilientClient(t, func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}, nil)
+
+	for i := 0; i < 10; i++ {
+		err := doRequest(c, ctx, url, "inquiry")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unexpected response from downstream")
+	}
+	assert.Equal(t, int32(10), atomic.LoadInt32(calls))
+}
@@ -0,0 +1,89 @@
+package apic
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	anzerrors "github.com/anzx/pkg/errors"
+	anzcodes "github.com/anzx/pkg/errors/errcodes"
+)
+
+// defaultDeadlineMargin is left of the incoming deadline so the caller still has time to handle the outcome
+const defaultDeadlineMargin = 500 * time.Millisecond
+
+// TimeoutConfig bounds the time spent on an operation, including any retries
+type TimeoutConfig struct {
+	// Default applies to every operation without its own timeout, only the incoming deadline applies if zero
+	Default time.Duration `json:"default,omitempty"        yaml:"default,omitempty"        mapstructure:"default"        validate:"gte=0"`
+	// Operations overrides Default per operation
+	Operations []OperationTimeout `json:"operations,omitempty"     yaml:"operations,omitempty"     mapstructure:"operations"     validate:"dive"`
+	// DeadlineMargin is taken off the incoming deadline, defaults to 500ms
+	DeadlineMargin time.Duration `json:"deadlineMargin,omitempty" yaml:"deadlineMargin,omitempty" mapstructure:"deadlineMargin" validate:"gte=0"`
+}
+
+type OperationTimeout struct {
+	// Operation is matched case insensitively with or without its downstream prefix, e.g. CardActivate or
+	// ctm:CardActivate
+	Operation string        `json:"operation" yaml:"operation" mapstructure:"operation" validate:"required"`
+	Timeout   time.Duration `json:"timeout"   yaml:"timeout"   mapstructure:"timeout"   validate:"gt=0"`
+}
+
+// WithTimeouts bounds each operation of the client by its configured timeout
+func WithTimeouts(config *TimeoutConfig) Option {
+	return func(c *Client) {
+		if config == nil {
+			return
+		}
+		c.defaultTimeout = config.Default
+		c.deadlineMargin = config.DeadlineMargin
+		c.timeouts = make(map[string]time.Duration, len(config.Operations))
+		for _, operation := range config.Operations {
+			c.timeouts[strings.ToLower(operation.Operation)] = operation.Timeout
+		}
+	}
+}
+
+// timeout returns the configured timeout of operation, zero if there is none
+func (c *Client) timeout(operation string) time.Duration {
+	operation = strings.ToLower(operation)
+	if timeout, ok := c.timeouts[operation]; ok {
+		return timeout
+	}
+	if i := strings.Index(operation, ":"); i >= 0 {
+		if timeout, ok := c.timeouts[operation[i+1:]]; ok {
+			return timeout
+		}
+	}
+	return c.defaultTimeout
+}
+
+// budget returns ctx bounded by the timeout of operation and the incoming deadline less the margin, or an error if
+// the deadline leaves no time for the request
+func (c *Client) budget(ctx context.Context, operation string) (context.Context, context.CancelFunc, error) {
+	timeout := c.timeout(operation)
+
+	if deadline, ok := ctx.Deadline(); ok {
+		margin := c.deadlineMargin
+		if margin <= 0 {
+			margin = defaultDeadlineMargin
+		}
+		remaining := time.Until(deadline) - margin
+		if remaining <= 0 {
+			return nil, nil, anzerrors.New(codes.DeadlineExceeded, "failed request",
+				anzerrors.NewErrorInfo(ctx, anzcodes.DownstreamFailure, "deadline exceeded before request"))
+		}
+		if timeout <= 0 || remaining < timeout {
+			timeout = remaining
+		}
+	}
+
+	if timeout <= 0 {
+		return ctx, func() {}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, nil
+}
@@ -0,0 +1,83 @@
+package apic
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+
+	"github.com/anzx/fabric-cards/pkg/util/testutil"
+	anzerrors "github.com/anzx/pkg/errors"
+)
+
+func TestClient_timeout(t *testing.T) {
+	c := &Client{}
+	WithTimeouts(&TimeoutConfig{
+		Default: time.Second,
+		Operations: []OperationTimeout{
+			{Operation: "CardActivate", Timeout: 2 * time.Second},
+			{Operation: "echidna:getWrappingKey", Timeout: 3 * time.Second},
+		},
+	})(c)
+
+	assert.Equal(t, 2*time.Second, c.timeout("ctm:CardActivate"))
+	assert.Equal(t, 2*time.Second, c.timeout("cardactivate"))
+	assert.Equal(t, 3*time.Second, c.timeout("echidna:getWrappingKey"))
+	assert.Equal(t, time.Second, c.timeout("ctm:DebitCardInquiry"))
+
+	WithTimeouts(nil)(c)
+	assert.Equal(t, time.Second, c.timeout("ctm:DebitCardInquiry"))
+}
+
+func TestClient_Do_Timeouts(t *testing.T) {
+	slow := func(rw http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}
+
+	t.Run("operation timeout", func(t *testing.T) {
+		c, url, _ := newResilientClient(t, slow, nil)
+		WithTimeouts(&TimeoutConfig{Operations: []OperationTimeout{{Operation: "inquiry", Timeout: 50 * time.Millisecond}}})(c)
+
+		start := time.Now()
+		err := doRequest(c, testutil.GetContext(true), url, "ctm:inquiry")
+		require.Error(t, err)
+		assert.Equal(t, codes.DeadlineExceeded, anzerrors.GetStatusCode(err))
+		assert.Contains(t, err.Error(), "request timed out")
+		assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	})
+
+	t.Run("capped at the incoming deadline less the margin", func(t *testing.T) {
+		c, url, _ := newResilientClient(t, slow, nil)
+		WithTimeouts(&TimeoutConfig{Default: time.Minute, DeadlineMargin: 200 * time.Millisecond})(c)
+
+		ctx, cancel := context.WithTimeout(testutil.GetContext(true), 300*time.Millisecond)
+		defer cancel()
+
+		err := doRequest(c, ctx, url, "ctm:inquiry")
+		require.Error(t, err)
+		assert.Equal(t, codes.DeadlineExceeded, anzerrors.GetStatusCode(err))
+		// the caller is left with its margin
+		assert.NoError(t, ctx.Err())
+	})
+
+	t.Run("no time left", func(t *testing.T) {
+		c, url, calls := newResilientClient(t, slow, nil)
+
+		ctx, cancel := context.WithTimeout(testutil.GetContext(true), 100*time.Millisecond)
+		defer cancel()
+
+		err := doRequest(c, ctx, url, "ctm:inquiry")
+		require.Error(t, err)
+		assert.Equal(t, codes.DeadlineExceeded, anzerrors.GetStatusCode(err))
+		assert.Contains(t, err.Error(), "deadline exceeded before request")
+		assert.Zero(t, atomic.LoadInt32(calls))
+	})
+}
@@ -17,8 +17,15 @@ type Card struct {
 	Reason           *ctm.StatusReason
 	NewCardNumber    *string
 	NewToken         *string
+	OldToken         *string
 	PinChangedCount  int64
+	PinFailedCount   int64
+	LastPinFailed    string
 	AccountNumbers   []string
+	// Limits replace the default limits of the card if set
+	Limits []ctm.NewLimits
+	// Replacement is the CTM request the card was issued with when it replaced another card
+	Replacement *ctm.ReplaceCardRequest
 }
 
 type CardControlsPresetType string
@@ -93,18 +100,37 @@ func WithNewCard(cardNumber, tokenizedCardNumber string) func(u *Card) {
 	}
 }
 
+func WithOldCard(tokenizedCardNumber string) func(u *Card) {
+	return func(u *Card) {
+		u.OldToken = util.ToStringPtr(tokenizedCardNumber)
+	}
+}
+
 func WithPINChangeCount(count int64) func(u *Card) {
 	return func(u *Card) {
 		u.PinChangedCount = count
 	}
 }
 
+func WithPINFailures(count int64, lastFailed string) func(u *Card) {
+	return func(u *Card) {
+		u.PinFailedCount = count
+		u.LastPinFailed = lastFailed
+	}
+}
+
 func WithAccountNumbers(accountNumbers ...string) func(u *Card) {
 	return func(u *Card) {
 		u.AccountNumbers = accountNumbers
 	}
 }
 
+func WithLimits(limits ...ctm.NewLimits) func(u *Card) {
+	return func(u *Card) {
+		u.Limits = limits
+	}
+}
+
 func (c Card) AddAccountNumbers(accountNumbers ...string) *Card {
 	c.AccountNumbers = append(c.AccountNumbers, accountNumbers...)
 	return &c
@@ -12,6 +12,7 @@ import (
 
 	crpb "github.com/anzx/fabricapis/pkg/gateway/visa/service/customerrules"
 
+	"github.com/anzx/fabric-cards/pkg/integration/ctm"
 	"github.com/anzx/fabric-cards/pkg/integration/ocv"
 
 	"github.com/anzx/fabric-cards/test/stubs/grpc/cardcontrols"
@@ -31,6 +32,8 @@ import (
 	"github.com/anzx/fabric-cards/test/util"
 	"github.com/anzx/pkg/auditlog/auditlogtest"
 
+	"github.com/anzx/fabric-cards/pkg/ratelimit"
+	"github.com/anzx/fabric-cards/pkg/saga"
 	"github.com/anzx/fabric-cards/test/data"
 	"github.com/anzx/fabric-cards/test/stubs/grpc/accounts"
 	auditLogStub "github.com/anzx/fabric-cards/test/stubs/grpc/auditlog"
@@ -78,6 +81,8 @@ type ServerBuilder struct {
 	CardControlsClient           cardcontrols.StubClient
 	APCAMClient                  apcam.StubClient
 	GPayClient                   gpay.StubClient
+	DailyLimits                  *ctm.LimitsConfig
+	Sagas                        *saga.Runner
 }
 
 func AServer() *ServerBuilder {
@@ -104,6 +109,12 @@ func (c *ServerBuilder) WithData(users ...*data.User) *ServerBuilder {
 		CardControlsClient:           cardcontrols.NewStubClient(),
 		APCAMClient:                  apcam.NewStubClient(),
 		GPayClient:                   gpay.NewStubClient(),
+		DailyLimits: &ctm.LimitsConfig{
+			Bounds: []ctm.LimitBounds{
+				{Type: ctm.LimitTypeATMEFTPOS, Min: 100, Max: 5000},
+				{Type: ctm.LimitTypeAPO, Min: 0, Max: 5000},
+			},
+		},
 	}
 }
 
@@ -137,6 +148,14 @@ func (c *ServerBuilder) WithCtmInquiryErrorFunc(f func() error) *ServerBuilder {
 	return c
 }
 
+func (c *ServerBuilder) WithCtmInquiryErrorFor(tokenizedCardNumber string, err error) *ServerBuilder {
+	if c.CTMClient.InquiryErrors == nil {
+		c.CTMClient.InquiryErrors = map[string]error{}
+	}
+	c.CTMClient.InquiryErrors[tokenizedCardNumber] = err
+	return c
+}
+
 func (c *ServerBuilder) WithCtmReplaceError(err error) *ServerBuilder {
 	c.CTMClient.ReplaceError = err
 	return c
@@ -147,6 +166,21 @@ func (c *ServerBuilder) WithCtmUpdateError(err error) *ServerBuilder {
 	return c
 }
 
+func (c *ServerBuilder) WithCtmUpdateLimitsError(err error) *ServerBuilder {
+	c.CTMClient.UpdateLimitsError = err
+	return c
+}
+
+func (c *ServerBuilder) WithSagas(runner *saga.Runner) *ServerBuilder {
+	c.Sagas = runner
+	return c
+}
+
+func (c *ServerBuilder) WithDailyLimits(config *ctm.LimitsConfig) *ServerBuilder {
+	c.DailyLimits = config
+	return c
+}
+
 func (c *ServerBuilder) WithCtmSetPreferenceError(err error) *ServerBuilder {
 	c.CTMClient.SetPreferenceError = err
 	return c
@@ -204,6 +238,18 @@ func (c *ServerBuilder) WithRateLimitError(err error) *ServerBuilder {
 	return c
 }
 
+func (c *ServerBuilder) WithLockoutError(retryAfter time.Duration) *ServerBuilder {
+	c.RateLimit.LockoutErr = anzerrors.New(codes.ResourceExhausted, "lockout check failed",
+		anzerrors.NewErrorInfo(context.Background(), anzcodes.RateLimitExhausted, "locked out after repeated failures"),
+		anzerrors.WithRetryDelay(retryAfter))
+	return c
+}
+
+func (c *ServerBuilder) WithLockoutState(state ratelimit.LockoutState) *ServerBuilder {
+	c.RateLimit.LockoutState = state
+	return c
+}
+
 func (c *ServerBuilder) WithSelfServiceError(err error) *ServerBuilder {
 	c.SelfServiceClient.GetPartyError = err
 	return c
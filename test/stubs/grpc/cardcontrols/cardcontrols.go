@@ -39,3 +39,7 @@ func (s StubClient) TransferControls(_ context.Context, _ *v1beta2pb.TransferCon
 func (s StubClient) BlockCard(_ context.Context, _ *v1beta2pb.BlockCardRequest, _ ...grpc.CallOption) (*v1beta2pb.BlockCardResponse, error) {
 	return nil, nil
 }
+
+func (s StubClient) ApplyPreset(_ context.Context, _ *v1beta2pb.ApplyPresetRequest, _ ...grpc.CallOption) (*v1beta2pb.CardControlResponse, error) {
+	return nil, nil
+}
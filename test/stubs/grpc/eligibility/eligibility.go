@@ -14,6 +14,8 @@ import (
 
 	"github.com/anzx/fabric-cards/test/stubs/http/ctm"
 
+	ctmapi "github.com/anzx/fabric-cards/pkg/integration/ctm"
+
 	epb "github.com/anzx/fabricapis/pkg/fabric/service/eligibility/v1beta1"
 	"github.com/stretchr/testify/mock"
 	"google.golang.org/grpc"
@@ -46,8 +48,46 @@ func (m StubClient) Can(ctx context.Context, in *epb.CanRequest, _ ...grpc.CallO
 		return nil, err
 	}
 
-	if eligible := cardDetails.HasEligibility(in.Eligibility); !eligible {
-		return nil, anzerrors.New(codes.PermissionDenied, "eligibility failed", anzerrors.NewErrorInfo(context.Background(), anzcodes.CardIneligible, "card not eligible"))
+	if err := m.Evaluate(ctx, in.Eligibility, cardDetails); err != nil {
+		return nil, err
 	}
 	return &epb.CanResponse{}, nil
 }
+
+func (m StubClient) BatchCan(ctx context.Context, in *epb.BatchCanRequest, _ ...grpc.CallOption) (*epb.BatchCanResponse, error) {
+	user, err := identity.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &epb.BatchCanResponse{}
+	for _, tokenizedCardNumber := range in.TokenizedCardNumbers {
+		cardDetails, err := ctm.GetCardDetails(m.testingData, tokenizedCardNumber, user.PersonaID)
+		if err != nil {
+			return nil, err
+		}
+
+		card := &epb.CardEligibilities{TokenizedCardNumber: tokenizedCardNumber}
+		for _, eligibility := range in.Eligibilities {
+			if ineligibility, ineligible := cardDetails.Ineligibility(eligibility); ineligible {
+				card.Ineligibilities = append(card.Ineligibilities, ineligibility.ToProto())
+				continue
+			}
+			card.Eligibilities = append(card.Eligibilities, eligibility)
+		}
+		response.Cards = append(response.Cards, card)
+	}
+	return response, nil
+}
+
+func (m StubClient) Evaluate(_ context.Context, eligibility epb.Eligibility, card *ctmapi.DebitCardResponse) error {
+	if m.CanErr != nil {
+		return m.CanErr
+	}
+
+	if ineligibility, ineligible := card.Ineligibility(eligibility); ineligible {
+		return anzerrors.New(codes.PermissionDenied, "eligibility failed", anzerrors.NewErrorInfo(context.Background(), anzcodes.CardIneligible, "card not eligible"),
+			anzerrors.WithDetails(ineligibility.ToProto()))
+	}
+	return nil
+}
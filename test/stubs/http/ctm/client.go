@@ -14,15 +14,18 @@ import (
 )
 
 type StubClient struct {
-	testingData        *data.Data
-	ActivateError      error
-	InquiryError       error
-	InquiryErrorFunc   func() error
+	testingData      *data.Data
+	ActivateError    error
+	InquiryError     error
+	InquiryErrorFunc func() error
+	// InquiryErrors fails the inquiry of the tokenized card numbers it contains
+	InquiryErrors      map[string]error
 	ReplaceError       error
 	UpdateError        error
 	SetPreferenceError error
 	updateDetailsError error
 	PINInfoUpdateError error
+	UpdateLimitsError  error
 }
 
 // NewStubClient creates a ctm client stubs
@@ -39,6 +42,7 @@ func (m StubClient) ReplaceCard(ctx context.Context, req *ctm.ReplaceCardRequest
 
 	user := m.testingData.GetUserByPersonaID(GetPersonaID(ctx))
 	newCard := user.ReplaceCard(tokenizedCardNumber, req.PlasticType)
+	newCard.Replacement = req
 
 	return newCard.Token, nil
 }
@@ -64,6 +68,60 @@ func (m StubClient) UpdateDetails(ctx context.Context, req *ctm.UpdateDetailsReq
 	return true, nil
 }
 
+func (m StubClient) UpdateLimits(_ context.Context, req *ctm.UpdateLimitsRequest, tokenizedCardNumber string) (bool, error) {
+	if m.UpdateLimitsError != nil {
+		return false, m.UpdateLimitsError
+	}
+
+	card := m.testingData.GetCardByTokenizedCardNumber(tokenizedCardNumber)
+	card.Limits = UpdateLimits(cardLimits(*card), req)
+	return true, nil
+}
+
+// UpdateLimits returns limits with the daily limits of req applied, the amount available is adjusted by the change
+func UpdateLimits(limits []ctm.NewLimits, req *ctm.UpdateLimitsRequest) []ctm.NewLimits {
+	out := make([]ctm.NewLimits, len(limits))
+	copy(out, limits)
+
+	for _, update := range req.Limits {
+		for i := range out {
+			if out[i].Type != update.Type {
+				continue
+			}
+			out[i].DailyLimitAvailable += update.DailyLimit - out[i].DailyLimit
+			if out[i].DailyLimitAvailable < 0 {
+				out[i].DailyLimitAvailable = 0
+			}
+			out[i].DailyLimit = update.DailyLimit
+		}
+	}
+
+	return out
+}
+
+func cardLimits(item data.Card) []ctm.NewLimits {
+	if item.Limits != nil {
+		return item.Limits
+	}
+	return defaultLimits()
+}
+
+func defaultLimits() []ctm.NewLimits {
+	return []ctm.NewLimits{
+		{
+			DailyLimit:          1000,
+			DailyLimitAvailable: 1000,
+			Type:                ctm.LimitTypeAPO,
+		},
+		{
+			DailyLimit:          2500,
+			DailyLimitAvailable: 2347,
+			LastTransaction:     "2015-08-05",
+			Type:                ctm.LimitTypeATMEFTPOS,
+		},
+	}
+}
+
 func cardControlsPresent(item data.Card) bool {
 	return item.CardControls == data.CardControlsPresetAllControls ||
 		item.CardControls == data.CardControlsPresetGlobalControls ||
@@ -79,6 +137,9 @@ func (m StubClient) DebitCardInquiry(ctx context.Context, req string) (*ctm.Debi
 			return nil, err
 		}
 	}
+	if err, ok := m.InquiryErrors[req]; ok {
+		return nil, err
+	}
 	return GetCardDetails(m.testingData, req, GetPersonaID(ctx))
 }
 
@@ -135,32 +196,19 @@ func GetCardDetails(testingData *data.Data, tokenizedCardNumber string, userID s
 	dataItem := *item
 
 	response := &ctm.DebitCardResponse{
-		AccountsLinkedCount: 2,
-		CollectionBranch:    4672,
-		CollectionStatus:    "Card NOT Collected",
-		DetailsChangedDate:  "2015-08-05",
-		DispatchedMethod:    "Sent to Branch",
-		EmbossingLine1:      "MR NATHAN FUKUSHIMA",
-		EmbossingLine2:      "MR NATHAN FUKUSHIMA",
-		FirstName:           "NATHAN",
-		LastName:            "FUKUSHIMA",
-		ExpiryDate:          "1705",
-		IssueBranch:         4672,
-		IssueReason:         "New",
-		IssueDate:           "2015-08-05",
-		Limits: []ctm.NewLimits{
-			{
-				DailyLimit:          1000,
-				DailyLimitAvailable: 1000,
-				Type:                ctm.LimitTypeAPO,
-			},
-			{
-				DailyLimit:          2500,
-				DailyLimitAvailable: 2347,
-				LastTransaction:     "2015-08-05",
-				Type:                ctm.LimitTypeATMEFTPOS,
-			},
-		},
+		AccountsLinkedCount:      2,
+		CollectionBranch:         4672,
+		CollectionStatus:         "Card NOT Collected",
+		DetailsChangedDate:       "2015-08-05",
+		DispatchedMethod:         "Sent to Branch",
+		EmbossingLine1:           "MR NATHAN FUKUSHIMA",
+		EmbossingLine2:           "MR NATHAN FUKUSHIMA",
+		FirstName:                "NATHAN",
+		LastName:                 "FUKUSHIMA",
+		ExpiryDate:               "1705",
+		IssueBranch:              4672,
+		IssueReason:              "New",
+		IssueDate:                "2015-08-05",
 		MerchantUpdatePreference: true,
 		PinChangeDate:            "2015-08-05",
 		PinFailedCount:           0,
@@ -203,9 +251,16 @@ func GetCardDetails(testingData *data.Data, tokenizedCardNumber string, userID s
 		}
 	}
 
+	if dataItem.OldToken != nil {
+		response.OldCardNumber = &ctm.Card{Token: *dataItem.OldToken}
+	}
+
 	response.ActivationStatus = dataItem.ActivationStatus
+	response.Limits = cardLimits(dataItem)
 	response.CardControlPreference = cardControlsPresent(dataItem)
 	response.PinChangedCount = dataItem.PinChangedCount
+	response.PinFailedCount = dataItem.PinFailedCount
+	response.LastPinFailed = dataItem.LastPinFailed
 	response.Status = dataItem.Status
 	if dataItem.Reason != nil {
 		response.StatusReason = *dataItem.Reason
@@ -19,6 +19,7 @@ import (
 const (
 	preferences      = "preferences"
 	replace          = "replace"
+	limits           = "limits"
 	activation       = "activate"
 	status           = "status"
 	pinInfoUpdate    = "pin-info/update"
@@ -58,6 +59,7 @@ func (d *StubServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		statusURL           = regexp.MustCompile(fmt.Sprintf("/%s/%s/\\d{16}/%s", statusAPI, debitCards, status))
 		replaceCardURL      = regexp.MustCompile(fmt.Sprintf("/%s/%s/\\d{16}/%s", maintenanceAPI, debitCards, replace))
 		preferencesCardURL  = regexp.MustCompile(fmt.Sprintf("/%s/%s/\\d{16}/%s", maintenanceAPI, debitCards, preferences))
+		limitsURL           = regexp.MustCompile(fmt.Sprintf("/%s/%s/\\d{16}/%s", maintenanceAPI, debitCards, limits))
 		pinInfoUpdateURL    = regexp.MustCompile(fmt.Sprintf("/%s/%s/\\d{16}/%s", pinInfoUpdateAPI, debitCards, pinInfoUpdate))
 	)
 
@@ -74,6 +76,8 @@ func (d *StubServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		d.preferencesHandler(w, r)
 	case pinInfoUpdateURL.MatchString(r.URL.Path):
 		d.pinInfoUpdateHandler(w, r)
+	case limitsURL.MatchString(r.URL.Path):
+		d.limitsHandler(w, r)
 	default:
 		w.WriteHeader(http.StatusNotFound)
 	}
@@ -321,6 +325,43 @@ func (d *StubServer) pinInfoUpdateHandler(w http.ResponseWriter, r *http.Request
 	w.WriteHeader(http.StatusOK)
 }
 
+func (d *StubServer) limitsHandler(w http.ResponseWriter, r *http.Request) {
+	body, ok := utils.GetRequestBody(w, r, http.MethodPatch)
+	if !ok {
+		return
+	}
+	defer r.Body.Close()
+
+	tokenizedCardNumber := extractTokenizedCardNumber(r.URL.Path)
+
+	var request ctm.UpdateLimitsRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		logf.Error(d.ctx, err, "unexpected request body")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+
+	logf.Debug(d.ctx, "limits request: %+v\n", request)
+
+	card := d.store.GetCard(tokenizedCardNumber)
+	if card == nil {
+		logf.Debug(d.ctx, "requested card: %s not found", tokenizedCardNumber)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if card.Limits == nil {
+		card.Limits = defaultLimits()
+	}
+	card.Limits = UpdateLimits(card.Limits, &request)
+
+	d.store.SaveCard(tokenizedCardNumber, card)
+
+	logf.Debug(d.ctx, "card new limits: %+v\n", card.Limits)
+
+	w.WriteHeader(http.StatusOK)
+}
+
 func extractTokenizedCardNumber(path string) string {
 	const expr = "[0-9]{16}"
 	re := regexp.MustCompile(expr)
@@ -7,13 +7,36 @@ import (
 )
 
 type StubClient struct {
-	Err error
+	Err          error
+	LockoutErr   error
+	LockoutState ratelimit.LockoutState
+	// Failures records the failures known to a system of record passed to CheckLockout
+	Failures *ratelimit.Failures
 }
 
 func (r StubClient) Allow(_ context.Context, _ ratelimit.Domain) error {
 	return r.Err
 }
 
+func (r StubClient) AllowMethod(_ context.Context, _ string, _ interface{}) error {
+	return r.Err
+}
+
+func (r StubClient) CheckLockout(_ context.Context, _ string, known ratelimit.Failures) error {
+	if r.Failures != nil {
+		*r.Failures = known
+	}
+	return r.LockoutErr
+}
+
+func (r StubClient) RecordFailure(_ context.Context, _ string) (ratelimit.LockoutState, error) {
+	return r.LockoutState, nil
+}
+
+func (r StubClient) ResetLockout(_ context.Context, _ string) error {
+	return nil
+}
+
 func NewStubClient() StubClient {
 	return StubClient{}
 }